@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 
@@ -10,6 +11,11 @@ import (
 
 func main() {
 	flag.Parse()
+
+	if flag.Arg(0) == "migrate" {
+		os.Exit(migrate(flag.Args()[1:]))
+	}
+
 	a := architect.New()
 
 	go a.Start()
@@ -21,3 +27,20 @@ func main() {
 	<-quit
 	a.Stop()
 }
+
+// migrate copies an existing storm database into a new SQLite database, after which the
+// architect can be started with DB_DRIVER=sqlite.
+func migrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String("from", architect.DefaultStormPath, "Storm database to migrate from")
+	to := flags.String("to", architect.DefaultSQLitePath, "SQLite database to create")
+	logs := flags.String("logs", architect.DefaultLogsPath, "Directory to move build logs into")
+	flags.Parse(args)
+
+	if err := architect.MigrateStormToSQLite(*from, *to, *logs, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Migrated %s to %s, start the architect with DB_DRIVER=%s to use it\n", *from, *to, architect.DBDriverSQLite)
+	return 0
+}
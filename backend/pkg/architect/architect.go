@@ -2,8 +2,10 @@ package architect
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,11 +18,13 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/domain/builder"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/pipeline"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
 	v_sync "github.com/velocity-ci/velocity/backend/pkg/domain/sync"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/user"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
 )
 
 type Architect struct {
@@ -28,6 +32,10 @@ type Architect struct {
 	workerWg sync.WaitGroup
 	Workers  []domain.Worker
 	DB       *storm.DB
+	// SQLDB is used instead of DB when set
+	SQLDB *sql.DB
+	// LogsPath is where build logs are kept, LOGS_PATH is used when it isn't set
+	LogsPath string
 }
 
 func (a *Architect) Start() {
@@ -67,22 +75,31 @@ func New() *Architect {
 }
 
 func (a *Architect) Init() {
-	if a.DB == nil {
-		a.DB = domain.NewStormDB("/opt/velocityci/architect.db")
+	if err := domain.CheckEncryptionKey(); err != nil {
+		velocity.GetLogger().Fatal("cannot store secrets", zap.Error(err))
 	}
+	repos := a.openRepositories()
+	logs := a.openLogStore()
 	validator, trans := domain.NewValidator()
-	userManager := user.NewManager(a.DB, validator, trans)
+	userManager := user.NewManager(repos.users, validator, trans)
 	userManager.EnsureAdminUser()
-	knownHostManager := knownhost.NewManager(a.DB, validator, trans, "")
-	projectManager := project.NewManager(a.DB, validator, trans, velocity.Validate)
-	commitManager := githistory.NewCommitManager(a.DB)
-	branchManager := githistory.NewBranchManager(a.DB)
-	taskManager := task.NewManager(a.DB, projectManager, branchManager, commitManager)
-	buildStepManager := build.NewStepManager(a.DB)
-	buildStreamManager := build.NewStreamManager(a.DB)
-	buildManager := build.NewBuildManager(a.DB, buildStepManager, buildStreamManager)
-	builderManager := builder.NewManager(buildManager, knownHostManager, buildStepManager, buildStreamManager)
-	syncManager := v_sync.NewManager(projectManager, taskManager, branchManager, commitManager)
+	knownHostManager := knownhost.NewManager(repos.knownHosts, validator, trans, "")
+	projectManager := project.NewManager(repos.projects, validator, trans, velocity.Validate)
+	commitManager := githistory.NewCommitManager(repos.commits)
+	branchManager := githistory.NewBranchManager(repos.branches)
+	tagManager := githistory.NewTagManager(repos.tags)
+	taskManager := task.NewManager(repos.tasks, projectManager, branchManager, commitManager)
+	buildStepManager := build.NewStepManager(repos.steps)
+	buildStreamManager := build.NewStreamManager(repos.streams, logs)
+	buildManager := build.NewBuildManager(repos.builds, buildStepManager, buildStreamManager)
+	pipelineManager := pipeline.NewManager(repos.pipelines, taskManager, buildManager)
+	buildManager.AddBroker(pipelineManager)
+	buildManager.AddDependent(pipelineManager)
+	builderManager := builder.NewManager(buildManager, knownHostManager, buildStepManager, buildStreamManager, reconnectGracePeriod())
+	syncManager := v_sync.NewManager(projectManager, taskManager, branchManager, commitManager, tagManager)
+	scheduler := builder.NewScheduler(builderManager, buildManager, &a.workerWg)
+	buildManager.AddBroker(scheduler)
+	builderManager.AddBroker(scheduler)
 
 	a.Server.Use(middleware.CORS())
 	rest.AddRoutes(
@@ -92,15 +109,59 @@ func (a *Architect) Init() {
 		projectManager,
 		commitManager,
 		branchManager,
+		tagManager,
 		taskManager,
 		buildStepManager,
 		buildStreamManager,
 		buildManager,
+		pipelineManager,
 		builderManager,
 		syncManager,
 	)
 
 	a.Workers = []domain.Worker{
-		builder.NewScheduler(builderManager, buildManager, &a.workerWg),
+		scheduler,
+		build.NewPruner(buildManager, projectManager, branchManager, retentionPolicy(), &a.workerWg),
 	}
 }
+
+// retentionPolicy returns the retention policy for projects that don't have their own, from
+// RETENTION_KEEP_BUILDS and RETENTION_KEEP_DAYS. Builds are kept forever when neither is set.
+func retentionPolicy() project.RetentionPolicy {
+	policy := project.RetentionPolicy{}
+	for env, rule := range map[string]*int{
+		"RETENTION_KEEP_BUILDS": &policy.KeepBuilds,
+		"RETENTION_KEEP_DAYS":   &policy.KeepDays,
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			velocity.GetLogger().Fatal("invalid retention rule, expected a positive number", zap.String(env, v))
+		}
+		*rule = n
+	}
+
+	return policy
+}
+
+// defaultReconnectGracePeriod is how long builds are kept running on a builder that has
+// disconnected before they are failed.
+const defaultReconnectGracePeriod = 5 * time.Minute
+
+// reconnectGracePeriod returns the grace period for builders to reconnect in from
+// BUILDER_RECONNECT_GRACE, e.g. "90s". 0 fails builds as soon as their builder disconnects.
+func reconnectGracePeriod() time.Duration {
+	v := os.Getenv("BUILDER_RECONNECT_GRACE")
+	if v == "" {
+		return defaultReconnectGracePeriod
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		velocity.GetLogger().Fatal("invalid reconnect grace period, expected a duration", zap.String("BUILDER_RECONNECT_GRACE", v))
+	}
+
+	return d
+}
@@ -0,0 +1,35 @@
+package architect
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+)
+
+func TestRoutes(t *testing.T) {
+	os.Setenv("ENCRYPTION_KEY", "test")
+	dir, err := ioutil.TempDir("", "velocity-architect")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	db, err := domain.OpenSQLiteDB(dir + "/architect.sqlite")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	a := New()
+	a.SQLDB = db
+	a.LogsPath = dir + "/logs"
+	a.Init()
+
+	routes := map[string]bool{}
+	for _, r := range a.Server.Routes() {
+		routes[r.Method+" "+r.Path] = true
+	}
+	assert.True(t, routes["GET /v1/builds/:id"])
+	assert.True(t, routes["GET /v1/builds/:id/steps"])
+	assert.True(t, routes["DELETE /v1/builds/:id"])
+	assert.True(t, routes["GET /v1/queue"])
+	assert.False(t, routes["GET /v1/queue/:id/steps"])
+}
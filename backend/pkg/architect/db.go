@@ -0,0 +1,137 @@
+package architect
+
+import (
+	"database/sql"
+	"os"
+
+	"github.com/asdine/storm"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/pipeline"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/user"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+// Storage backends for the architect, selected with the DB_DRIVER environment variable.
+// DB_PATH overrides where the database is kept and LOGS_PATH where build logs are kept.
+const (
+	DBDriverStorm  = "storm"
+	DBDriverSQLite = "sqlite"
+
+	DefaultStormPath  = "/opt/velocityci/architect.db"
+	DefaultSQLitePath = "/opt/velocityci/architect.sqlite"
+	DefaultLogsPath   = "/opt/velocityci/logs"
+)
+
+// repositories are what the domain managers store their aggregates in.
+type repositories struct {
+	users      user.Repository
+	knownHosts knownhost.Repository
+	projects   project.Repository
+	branches   githistory.BranchRepository
+	commits    githistory.CommitRepository
+	tags       githistory.TagRepository
+	tasks      task.Repository
+	builds     build.BuildRepository
+	steps      build.StepRepository
+	streams    build.StreamRepository
+	pipelines  pipeline.Repository
+}
+
+func newStormRepositories(db *storm.DB) *repositories {
+	return &repositories{
+		users:      user.NewStormRepository(db),
+		knownHosts: knownhost.NewStormRepository(db),
+		projects:   project.NewStormRepository(db),
+		branches:   githistory.NewBranchStormRepository(db),
+		commits:    githistory.NewCommitStormRepository(db),
+		tags:       githistory.NewTagStormRepository(db),
+		tasks:      task.NewStormRepository(db),
+		builds:     build.NewBuildStormRepository(db),
+		steps:      build.NewStepStormRepository(db),
+		streams:    build.NewStreamStormRepository(db),
+		pipelines:  pipeline.NewStormRepository(db),
+	}
+}
+
+func newSQLiteRepositories(db *sql.DB) *repositories {
+	return &repositories{
+		users:      user.NewSQLiteRepository(db),
+		knownHosts: knownhost.NewSQLiteRepository(db),
+		projects:   project.NewSQLiteRepository(db),
+		branches:   githistory.NewBranchSQLiteRepository(db),
+		commits:    githistory.NewCommitSQLiteRepository(db),
+		tags:       githistory.NewTagSQLiteRepository(db),
+		tasks:      task.NewSQLiteRepository(db),
+		builds:     build.NewBuildSQLiteRepository(db),
+		steps:      build.NewStepSQLiteRepository(db),
+		streams:    build.NewStreamSQLiteRepository(db),
+		pipelines:  pipeline.NewSQLiteRepository(db),
+	}
+}
+
+// openRepositories uses the database the architect was given, or otherwise opens the one
+// configured in the environment.
+func (a *Architect) openRepositories() *repositories {
+	if a.SQLDB != nil {
+		return newSQLiteRepositories(a.SQLDB)
+	}
+	if a.DB != nil {
+		return newStormRepositories(a.DB)
+	}
+
+	driver := os.Getenv("DB_DRIVER")
+	path := os.Getenv("DB_PATH")
+	switch driver {
+	case "", DBDriverStorm:
+		if path == "" {
+			path = DefaultStormPath
+		}
+		a.DB = domain.NewStormDB(path)
+		return newStormRepositories(a.DB)
+	case DBDriverSQLite:
+		if path == "" {
+			path = DefaultSQLitePath
+		}
+		a.SQLDB = domain.NewSQLiteDB(path)
+		return newSQLiteRepositories(a.SQLDB)
+	}
+
+	velocity.GetLogger().Fatal("unknown DB_DRIVER, expected storm or sqlite", zap.String("driver", driver))
+	return nil
+}
+
+// openLogStore opens the log store that build logs are kept in, and moves any stream lines
+// that are still in the database into it.
+func (a *Architect) openLogStore() build.LogStore {
+	path := a.LogsPath
+	if path == "" {
+		path = os.Getenv("LOGS_PATH")
+	}
+	if path == "" {
+		path = DefaultLogsPath
+	}
+	logs := build.NewFileLogStore(path)
+
+	var n int
+	var err error
+	if a.SQLDB != nil {
+		n, err = build.MigrateStreamLinesSQLite(a.SQLDB, logs)
+	} else {
+		n, err = build.MigrateStreamLinesStorm(a.DB, logs)
+	}
+	if err != nil {
+		// the lines are only removed from the database once they have all been moved, so
+		// this is tried again on the next start
+		velocity.GetLogger().Error("could not move stream lines into the log store", zap.Error(err))
+	} else if n > 0 {
+		velocity.GetLogger().Info("moved stream lines into the log store", zap.Int("lines", n), zap.String("path", path))
+	}
+
+	return logs
+}
@@ -0,0 +1,81 @@
+package architect
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/asdine/storm"
+	bolt "github.com/coreos/bbolt"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/pipeline"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/user"
+)
+
+// MigrateStormToSQLite copies everything in a storm database into a new SQLite database,
+// and moves any stream lines still in the storm database into the log store at logsPath.
+// The architect must be stopped first. If anything fails the SQLite database is removed
+// so that the migration can be run again.
+func MigrateStormToSQLite(stormPath, sqlitePath, logsPath string, out io.Writer) (err error) {
+	if _, err := os.Stat(sqlitePath); err == nil {
+		return fmt.Errorf("%s already exists", sqlitePath)
+	}
+	if _, err := os.Stat(stormPath); err != nil {
+		return err
+	}
+
+	from, err := storm.Open(stormPath, storm.BoltOptions(0600, &bolt.Options{Timeout: time.Second}))
+	if err != nil {
+		return fmt.Errorf("could not open %s, is the architect still running? %s", stormPath, err)
+	}
+	defer from.Close()
+
+	sqlDB, err := domain.OpenSQLiteDB(sqlitePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		sqlDB.Close()
+		if err != nil {
+			os.Remove(sqlitePath)
+			os.Remove(sqlitePath + "-wal")
+			os.Remove(sqlitePath + "-shm")
+		}
+	}()
+	to := newSQLiteRepositories(sqlDB)
+	logs := build.NewFileLogStore(logsPath)
+
+	// parents are copied before their children so that the children can be resolved
+	steps := []struct {
+		name    string
+		migrate func() (int, error)
+	}{
+		{"users", func() (int, error) { return user.MigrateStorm(from, to.users) }},
+		{"known hosts", func() (int, error) { return knownhost.MigrateStorm(from, to.knownHosts) }},
+		{"projects", func() (int, error) { return project.MigrateStorm(from, to.projects) }},
+		{"branches", func() (int, error) { return githistory.MigrateBranchesStorm(from, to.branches) }},
+		{"commits", func() (int, error) { return githistory.MigrateCommitsStorm(from, to.commits) }},
+		{"tags", func() (int, error) { return githistory.MigrateTagsStorm(from, to.tags) }},
+		{"tasks", func() (int, error) { return task.MigrateStorm(from, to.tasks) }},
+		{"builds", func() (int, error) { return build.MigrateBuildsStorm(from, to.builds) }},
+		{"build steps", func() (int, error) { return build.MigrateStepsStorm(from, to.steps) }},
+		{"build streams", func() (int, error) { return build.MigrateStreamsStorm(from, to.streams) }},
+		{"build log lines", func() (int, error) { return build.MigrateStreamLinesStorm(from, logs) }},
+		{"pipelines", func() (int, error) { return pipeline.MigrateStorm(from, to.pipelines) }},
+	}
+	for _, s := range steps {
+		n, err := s.migrate()
+		if err != nil {
+			return fmt.Errorf("could not migrate %s: %s", s.name, err)
+		}
+		fmt.Fprintf(out, "Migrated %d %s\n", n, s.name)
+	}
+
+	return nil
+}
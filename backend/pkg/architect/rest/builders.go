@@ -3,6 +3,8 @@ package rest
 import (
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -14,10 +16,14 @@ import (
 )
 
 type builderResponse struct {
-	ID        string    `json:"id"`
-	State     string    `json:"state"`
-	UpdatedAt time.Time `json:"updatedAt"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID           string    `json:"id"`
+	State        string    `json:"state"`
+	Capabilities []string  `json:"capabilities"`
+	Labels       []string  `json:"labels"`
+	Slots        int       `json:"slots"`
+	Builds       []string  `json:"builds"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	CreatedAt    time.Time `json:"createdAt"`
 }
 
 type builderList struct {
@@ -26,11 +32,19 @@ type builderList struct {
 }
 
 func newBuilderResponse(b *builder.Builder) *builderResponse {
+	builds := []string{}
+	for _, bu := range b.Builds() {
+		builds = append(builds, bu.ID)
+	}
 	return &builderResponse{
-		ID:        b.ID,
-		State:     b.State,
-		CreatedAt: b.CreatedAt,
-		UpdatedAt: b.UpdatedAt,
+		ID:           b.ID,
+		State:        b.State,
+		Capabilities: b.Capabilities,
+		Labels:       b.Labels,
+		Slots:        b.Slots,
+		Builds:       builds,
+		CreatedAt:    b.CreatedAt,
+		UpdatedAt:    b.UpdatedAt,
 	}
 }
 
@@ -103,6 +117,12 @@ func (h *builderHandler) connect(c echo.Context) error {
 		return nil
 	}
 
+	// builders that don't advertise their slots run one build at a time
+	slots, err := strconv.Atoi(c.Request().Header.Get(builder.SlotsHeader))
+	if err != nil {
+		slots = 1
+	}
+
 	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		velocity.GetLogger().Error("could not upgrade builder http connection", zap.Error(err))
@@ -110,6 +130,22 @@ func (h *builderHandler) connect(c echo.Context) error {
 		return nil
 	}
 
-	h.builderManager.CreateBuilder(ws)
+	h.builderManager.CreateBuilder(ws,
+		c.Request().Header.Get(builder.IDHeader),
+		splitHeader(c.Request().Header.Get(builder.CapabilitiesHeader)),
+		splitHeader(c.Request().Header.Get(builder.LabelsHeader)),
+		slots,
+	)
 	return nil
 }
+
+// splitHeader returns the non-empty values of a comma separated header.
+func splitHeader(h string) []string {
+	r := []string{}
+	for _, v := range strings.Split(h, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
@@ -8,12 +8,14 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
 
 	"github.com/labstack/echo"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
 )
 
 type buildRequest struct {
 	Parameters []requestParameter `json:"params"`
+	Priority   int                `json:"priority"`
 }
 
 type requestParameter struct {
@@ -27,7 +29,9 @@ type buildResponse struct {
 
 	Steps []*stepResponse `json:"buildSteps"`
 
+	Priority    int       `json:"priority"`
 	Status      string    `json:"status"`
+	Reason      string    `json:"reason"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 	StartedAt   time.Time `json:"startedAt"`
@@ -39,7 +43,9 @@ func newBuildResponse(b *build.Build, steps []*stepResponse, branchManager *gith
 		ID:          b.ID,
 		Task:        newTaskResponse(b.Task, branchManager),
 		Steps:       steps,
+		Priority:    b.Priority,
 		Status:      b.Status,
+		Reason:      b.Reason,
 		CreatedAt:   b.CreatedAt,
 		UpdatedAt:   b.UpdatedAt,
 		StartedAt:   b.StartedAt,
@@ -68,6 +74,7 @@ type buildHandler struct {
 	projectManager *project.Manager
 	commitManager  *githistory.CommitManager
 	branchManager  *githistory.BranchManager
+	tagManager     *githistory.TagManager
 	taskManager    *task.Manager
 }
 
@@ -78,6 +85,7 @@ func newBuildHandler(
 	projectManager *project.Manager,
 	commitManager *githistory.CommitManager,
 	branchManager *githistory.BranchManager,
+	tagManager *githistory.TagManager,
 	taskManager *task.Manager,
 ) *buildHandler {
 	return &buildHandler{
@@ -87,6 +95,7 @@ func newBuildHandler(
 		projectManager: projectManager,
 		commitManager:  commitManager,
 		branchManager:  branchManager,
+		tagManager:     tagManager,
 		taskManager:    taskManager,
 	}
 }
@@ -108,7 +117,9 @@ func (h *buildHandler) create(c echo.Context) error {
 		params[p.Name] = p.Value
 	}
 
-	b, err := h.buildManager.Create(t, params)
+	setDefaultGitParams(params, t.Commit, h.branchManager, h.tagManager)
+
+	b, err := h.buildManager.CreateWithPriority(t, params, rB.Priority)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err.ErrorMap)
 		return nil
@@ -119,6 +130,25 @@ func (h *buildHandler) create(c echo.Context) error {
 	return nil
 }
 
+// setDefaultGitParams defaults GIT_BRANCH and GIT_TAG to where the commit was found.
+func setDefaultGitParams(
+	params map[string]string,
+	commit *githistory.Commit,
+	branchManager *githistory.BranchManager,
+	tagManager *githistory.TagManager,
+) {
+	if _, ok := params["GIT_BRANCH"]; !ok {
+		if bs, _ := branchManager.GetAllForCommit(commit, &domain.PagingQuery{Limit: 1, Page: 1}); len(bs) > 0 {
+			params["GIT_BRANCH"] = bs[0].Name
+		}
+	}
+	if _, ok := params["GIT_TAG"]; !ok {
+		if ts := tagManager.GetAllForCommit(commit); len(ts) > 0 {
+			params["GIT_TAG"] = ts[0].Name
+		}
+	}
+}
+
 func (h *buildHandler) getAllForProject(c echo.Context) error {
 
 	p := getProjectBySlug(c, h.projectManager)
@@ -198,6 +228,63 @@ func (h *buildHandler) getByID(c echo.Context) error {
 	return nil
 }
 
+type queuedBuildResponse struct {
+	Position int            `json:"position"`
+	Build    *buildResponse `json:"build"`
+}
+
+type queueList struct {
+	Total int                    `json:"total"`
+	Data  []*queuedBuildResponse `json:"data"`
+}
+
+func (h *buildHandler) getQueue(c echo.Context) error {
+	pQ := getPagingQueryParams(c)
+	if pQ == nil {
+		return nil
+	}
+
+	queue := h.buildManager.GetQueue()
+	r := []*queuedBuildResponse{}
+	for i, b := range queue {
+		if i < (pQ.Page-1)*pQ.Limit {
+			continue
+		}
+		if len(r) >= pQ.Limit {
+			break
+		}
+		steps := h.stepManager.GetStepsForBuild(b)
+		r = append(r, &queuedBuildResponse{
+			Position: i + 1,
+			Build:    newBuildResponse(b, stepsToStepResponse(steps, h.streamManager), h.branchManager),
+		})
+	}
+
+	c.JSON(http.StatusOK, queueList{
+		Total: len(queue),
+		Data:  r,
+	})
+	return nil
+}
+
+func (h *buildHandler) delete(c echo.Context) error {
+	b := getBuildByID(c, h.buildManager)
+	if b == nil {
+		return nil
+	}
+	if err := h.buildManager.Delete(b); err != nil {
+		if err == build.ErrBuildRunning || err == build.ErrBuildInUse {
+			c.JSON(http.StatusConflict, err.Error())
+			return nil
+		}
+		c.JSON(http.StatusInternalServerError, "could not delete build")
+		return nil
+	}
+
+	c.JSON(http.StatusOK, nil)
+	return nil
+}
+
 func getBuildByID(c echo.Context, buildManager *build.BuildManager) *build.Build {
 	id := c.Param("id")
 	b, err := buildManager.GetBuildByID(id)
@@ -9,6 +9,7 @@ import (
 	"github.com/labstack/echo"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 )
 
 type commitResponse struct {
@@ -19,6 +20,30 @@ type commitResponse struct {
 	Message   string    `json:"message"`
 	Signed    string    `json:"signed"`
 	Branches  []string  `json:"branches"`
+
+	Signature *signatureResponse `json:"signature"`
+}
+
+type signatureResponse struct {
+	Status      string `json:"status"`
+	Fingerprint string `json:"fingerprint"`
+	Trusted     bool   `json:"trusted"`
+}
+
+func newSignatureResponse(c *githistory.Commit) *signatureResponse {
+	status := c.Signature.Status
+	trusted := false
+	if c.Project != nil {
+		trusted = c.Project.Signatures.IsTrusted(&c.Signature)
+		if status == velocity.SignatureVerified && !trusted {
+			status = velocity.SignatureUntrusted
+		}
+	}
+	return &signatureResponse{
+		Status:      status,
+		Fingerprint: c.Signature.Fingerprint,
+		Trusted:     trusted,
+	}
 }
 
 type commitList struct {
@@ -39,6 +64,7 @@ func newCommitResponse(c *githistory.Commit, bs []*githistory.Branch) *commitRes
 		Message:   c.Message,
 		Signed:    c.Signed,
 		Branches:  branches,
+		Signature: newSignatureResponse(c),
 	}
 }
 
@@ -0,0 +1,198 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/pipeline"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+)
+
+type pipelineRequest struct {
+	Parameters []requestParameter `json:"params"`
+}
+
+type stageResponse struct {
+	Name   string           `json:"name"`
+	Tasks  []string         `json:"tasks"`
+	Status string           `json:"status"`
+	Error  string           `json:"error"`
+	Builds []*buildResponse `json:"builds"`
+}
+
+type pipelineResponse struct {
+	ID     string           `json:"id"`
+	Commit string           `json:"commit"`
+	Stages []*stageResponse `json:"stages"`
+
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+type pipelineList struct {
+	Total int                 `json:"total"`
+	Data  []*pipelineResponse `json:"data"`
+}
+
+func newPipelineResponse(
+	p *pipeline.Pipeline,
+	stepManager *build.StepManager,
+	streamManager *build.StreamManager,
+	branchManager *githistory.BranchManager,
+) *pipelineResponse {
+	commit := ""
+	if p.Commit != nil {
+		commit = p.Commit.Hash
+	}
+	stages := []*stageResponse{}
+	for _, s := range p.Stages {
+		builds := buildsToBuildResponse(s.Builds, stepManager, streamManager, branchManager)
+		if builds == nil {
+			builds = []*buildResponse{}
+		}
+		stages = append(stages, &stageResponse{
+			Name:   s.Name,
+			Tasks:  s.Tasks,
+			Status: s.Status,
+			Error:  s.Error,
+			Builds: builds,
+		})
+	}
+	return &pipelineResponse{
+		ID:          p.ID,
+		Commit:      commit,
+		Stages:      stages,
+		Status:      p.Status,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		StartedAt:   p.StartedAt,
+		CompletedAt: p.CompletedAt,
+	}
+}
+
+type pipelineHandler struct {
+	pipelineManager *pipeline.Manager
+	stepManager     *build.StepManager
+	streamManager   *build.StreamManager
+	projectManager  *project.Manager
+	commitManager   *githistory.CommitManager
+	branchManager   *githistory.BranchManager
+	tagManager      *githistory.TagManager
+}
+
+func newPipelineHandler(
+	pipelineManager *pipeline.Manager,
+	stepManager *build.StepManager,
+	streamManager *build.StreamManager,
+	projectManager *project.Manager,
+	commitManager *githistory.CommitManager,
+	branchManager *githistory.BranchManager,
+	tagManager *githistory.TagManager,
+) *pipelineHandler {
+	return &pipelineHandler{
+		pipelineManager: pipelineManager,
+		stepManager:     stepManager,
+		streamManager:   streamManager,
+		projectManager:  projectManager,
+		commitManager:   commitManager,
+		branchManager:   branchManager,
+		tagManager:      tagManager,
+	}
+}
+
+func (h *pipelineHandler) pipelinesToPipelineResponse(ps []*pipeline.Pipeline) []*pipelineResponse {
+	r := []*pipelineResponse{}
+	for _, p := range ps {
+		r = append(r, newPipelineResponse(p, h.stepManager, h.streamManager, h.branchManager))
+	}
+	return r
+}
+
+func (h *pipelineHandler) create(c echo.Context) error {
+	rP := new(pipelineRequest)
+	if err := c.Bind(rP); err != nil {
+		c.JSON(http.StatusBadRequest, "invalid payload")
+		return nil
+	}
+
+	commit := getCommitByProjectAndHash(c, h.projectManager, h.commitManager)
+	if commit == nil {
+		return nil
+	}
+
+	params := map[string]string{}
+	for _, p := range rP.Parameters {
+		params[p.Name] = p.Value
+	}
+	setDefaultGitParams(params, commit, h.branchManager, h.tagManager)
+
+	p, err := h.pipelineManager.Create(commit, params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.ErrorMap)
+		return nil
+	}
+
+	c.JSON(http.StatusCreated, newPipelineResponse(p, h.stepManager, h.streamManager, h.branchManager))
+	return nil
+}
+
+func (h *pipelineHandler) getAllForProject(c echo.Context) error {
+
+	p := getProjectBySlug(c, h.projectManager)
+	if p == nil {
+		return nil
+	}
+
+	pQ := getPagingQueryParams(c)
+	if pQ == nil {
+		return nil
+	}
+
+	ps, total := h.pipelineManager.GetAllForProject(p, pQ)
+
+	c.JSON(http.StatusOK, pipelineList{
+		Total: total,
+		Data:  h.pipelinesToPipelineResponse(ps),
+	})
+
+	return nil
+}
+
+func (h *pipelineHandler) getAllForCommit(c echo.Context) error {
+
+	commit := getCommitByProjectAndHash(c, h.projectManager, h.commitManager)
+	if commit == nil {
+		return nil
+	}
+
+	pQ := getPagingQueryParams(c)
+	if pQ == nil {
+		return nil
+	}
+
+	ps, total := h.pipelineManager.GetAllForCommit(commit, pQ)
+
+	c.JSON(http.StatusOK, pipelineList{
+		Total: total,
+		Data:  h.pipelinesToPipelineResponse(ps),
+	})
+
+	return nil
+}
+
+func (h *pipelineHandler) getByID(c echo.Context) error {
+	p, err := h.pipelineManager.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, "not found")
+		return nil
+	}
+
+	c.JSON(http.StatusOK, newPipelineResponse(p, h.stepManager, h.streamManager, h.branchManager))
+	return nil
+}
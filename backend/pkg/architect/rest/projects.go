@@ -15,6 +15,25 @@ type projectRequest struct {
 	Name       string `json:"name"`
 	Address    string `json:"address"`
 	PrivateKey string `json:"key"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+type signaturePolicyRequest struct {
+	Mode        string   `json:"mode"`
+	TrustedKeys []string `json:"trustedKeys"`
+}
+
+// retentionPolicyRequest resets the project to the architect's retention policy when it is null.
+type retentionPolicyRequest struct {
+	Retention *struct {
+		KeepBuilds int `json:"keepBuilds"`
+		KeepDays   int `json:"keepDays"`
+	} `json:"retention"`
+}
+
+type concurrencyRequest struct {
+	MaxConcurrentBuilds int `json:"maxConcurrentBuilds"`
 }
 
 type projectResponse struct {
@@ -34,6 +53,22 @@ type projectResponse struct {
 	Parameters []parameterResp `json:"parameters"`
 	Plugins    []pluginResp    `json:"plugins"`
 	Stages     []stageResp     `json:"stages"`
+
+	Signatures signaturePolicyResp  `json:"signatures"`
+	Retention  *retentionPolicyResp `json:"retention"`
+
+	MaxConcurrentBuilds int `json:"maxConcurrentBuilds"`
+}
+
+type signaturePolicyResp struct {
+	Mode         string   `json:"mode"`
+	Fingerprints []string `json:"fingerprints"`
+}
+
+// retentionPolicyResp is null when the project uses the architect's retention policy.
+type retentionPolicyResp struct {
+	KeepBuilds int `json:"keepBuilds"`
+	KeepDays   int `json:"keepDays"`
 }
 
 type parameterResp struct {
@@ -78,6 +113,15 @@ func newProjectResponse(p *project.Project) *projectResponse {
 			Tasks: s.Tasks,
 		})
 	}
+	var retention *retentionPolicyResp
+	if p.Retention != nil {
+		retention = &retentionPolicyResp{
+			KeepBuilds: p.Retention.KeepBuilds,
+			KeepDays:   p.Retention.KeepDays,
+		}
+	}
+
+	fingerprints, _ := velocity.TrustedKeyFingerprints(p.Signatures.TrustedKeys)
 	return &projectResponse{
 		ID:            p.ID,
 		Slug:          p.Slug,
@@ -92,6 +136,12 @@ func newProjectResponse(p *project.Project) *projectResponse {
 		Parameters:    params,
 		Plugins:       plugins,
 		Stages:        stages,
+		Signatures: signaturePolicyResp{
+			Mode:         p.Signatures.Mode,
+			Fingerprints: fingerprints,
+		},
+		Retention:           retention,
+		MaxConcurrentBuilds: p.MaxConcurrentBuilds,
 	}
 }
 
@@ -116,6 +166,8 @@ func (h *projectHandler) create(c echo.Context) error {
 	p, err := h.projectManager.Create(rP.Name, velocity.GitRepository{
 		Address:    strings.TrimSpace(rP.Address),
 		PrivateKey: strings.TrimSpace(rP.PrivateKey),
+		Username:   strings.TrimSpace(rP.Username),
+		Password:   strings.TrimSpace(rP.Password),
 	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err.ErrorMap)
@@ -166,6 +218,83 @@ func (h *projectHandler) sync(c echo.Context) error {
 	return nil
 }
 
+func (h *projectHandler) updateSignatures(c echo.Context) error {
+	p := getProjectBySlug(c, h.projectManager)
+	if p == nil {
+		return nil
+	}
+
+	rS := new(signaturePolicyRequest)
+	if err := c.Bind(rS); err != nil {
+		c.JSON(http.StatusBadRequest, "invalid payload")
+		return nil
+	}
+	trustedKeys := []string{}
+	for _, k := range rS.TrustedKeys {
+		trustedKeys = append(trustedKeys, strings.TrimSpace(k))
+	}
+
+	if err := h.projectManager.UpdateSignaturePolicy(p, velocity.SignaturePolicy{
+		Mode:        rS.Mode,
+		TrustedKeys: trustedKeys,
+	}); err != nil {
+		c.JSON(http.StatusBadRequest, err.ErrorMap)
+		return nil
+	}
+
+	c.JSON(http.StatusOK, newProjectResponse(p))
+	return nil
+}
+
+func (h *projectHandler) updateRetention(c echo.Context) error {
+	p := getProjectBySlug(c, h.projectManager)
+	if p == nil {
+		return nil
+	}
+
+	rR := new(retentionPolicyRequest)
+	if err := c.Bind(rR); err != nil {
+		c.JSON(http.StatusBadRequest, "invalid payload")
+		return nil
+	}
+	var policy *project.RetentionPolicy
+	if rR.Retention != nil {
+		policy = &project.RetentionPolicy{
+			KeepBuilds: rR.Retention.KeepBuilds,
+			KeepDays:   rR.Retention.KeepDays,
+		}
+	}
+
+	if err := h.projectManager.UpdateRetentionPolicy(p, policy); err != nil {
+		c.JSON(http.StatusBadRequest, err.ErrorMap)
+		return nil
+	}
+
+	c.JSON(http.StatusOK, newProjectResponse(p))
+	return nil
+}
+
+func (h *projectHandler) updateConcurrency(c echo.Context) error {
+	p := getProjectBySlug(c, h.projectManager)
+	if p == nil {
+		return nil
+	}
+
+	rC := new(concurrencyRequest)
+	if err := c.Bind(rC); err != nil {
+		c.JSON(http.StatusBadRequest, "invalid payload")
+		return nil
+	}
+
+	if err := h.projectManager.UpdateMaxConcurrentBuilds(p, rC.MaxConcurrentBuilds); err != nil {
+		c.JSON(http.StatusBadRequest, err.ErrorMap)
+		return nil
+	}
+
+	c.JSON(http.StatusOK, newProjectResponse(p))
+	return nil
+}
+
 func getProjectBySlug(c echo.Context, pM *project.Manager) *project.Project {
 	slug := c.Param("slug")
 
@@ -7,6 +7,7 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/domain/builder"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/pipeline"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/sync"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
@@ -23,10 +24,12 @@ func AddRoutes(
 	projectManager *project.Manager,
 	commitManager *githistory.CommitManager,
 	branchManager *githistory.BranchManager,
+	tagManager *githistory.TagManager,
 	taskManager *task.Manager,
 	buildStepManager *build.StepManager,
 	buildStreamManager *build.StreamManager,
 	buildManager *build.BuildManager,
+	pipelineManager *pipeline.Manager,
 	builderManager *builder.Manager,
 	syncManager *sync.Manager,
 ) {
@@ -40,8 +43,10 @@ func AddRoutes(
 	projectHandler := newProjectHandler(projectManager, syncManager)
 	commitHandler := newCommitHandler(projectManager, commitManager, branchManager)
 	branchHandler := newBranchHandler(projectManager, branchManager, commitManager)
-	taskHandler := newTaskHandler(projectManager, commitManager, branchManager, taskManager)
-	buildHandler := newBuildHandler(buildManager, buildStepManager, buildStreamManager, projectManager, commitManager, branchManager, taskManager)
+	tagHandler := newTagHandler(projectManager, tagManager)
+	taskHandler := newTaskHandler(projectManager, commitManager, branchManager, tagManager, taskManager)
+	buildHandler := newBuildHandler(buildManager, buildStepManager, buildStreamManager, projectManager, commitManager, branchManager, tagManager, taskManager)
+	pipelineHandler := newPipelineHandler(pipelineManager, buildStepManager, buildStreamManager, projectManager, commitManager, branchManager, tagManager)
 	buildStepHandler := newBuildStepHandler(buildManager, buildStepManager, buildStreamManager)
 	buildStreamHandler := newBuildStreamHandler(buildStepManager, buildStreamManager)
 
@@ -54,10 +59,12 @@ func AddRoutes(
 	projectManager.AddBroker(wsBroker)
 	commitManager.AddBroker(wsBroker)
 	branchManager.AddBroker(wsBroker)
+	tagManager.AddBroker(wsBroker)
 	taskManager.AddBroker(wsBroker)
 	buildStepManager.AddBroker(wsBroker)
 	buildStepManager.AddBroker(wsBroker)
 	buildManager.AddBroker(wsBroker)
+	pipelineManager.AddBroker(wsBroker)
 	buildStreamManager.AddBroker(wsBroker)
 
 	// Used by Builders
@@ -87,25 +94,44 @@ func AddRoutes(
 	r.GET("", projectHandler.getAll)
 	r.GET("/:slug", projectHandler.get)
 	r.POST("/:slug/sync", projectHandler.sync)
+	r.PUT("/:slug/signatures", projectHandler.updateSignatures)
+	r.PUT("/:slug/retention", projectHandler.updateRetention)
+	r.PUT("/:slug/concurrency", projectHandler.updateConcurrency)
 
 	r.GET("/:slug/branches", branchHandler.getAllForProject)
 	r.GET("/:slug/branches/:name", branchHandler.getByProjectAndName)
 	r.GET("/:slug/branches/:name/commits", branchHandler.getCommitsForBranch)
+	r.GET("/:slug/tags", tagHandler.getAllForProject)
+	r.GET("/:slug/tags/:name", tagHandler.getByProjectAndName)
 	r.GET("/:slug/commits", commitHandler.getAllForProject)
 	r.GET("/:slug/commits/:hash", commitHandler.getByProjectAndHash)
 	r.GET("/:slug/commits/:hash/tasks", taskHandler.getAllForCommit)
 	r.GET("/:slug/commits/:hash/tasks/:taskSlug", taskHandler.getByProjectCommitAndSlug)
+	r.GET("/:slug/commits/:hash/tasks/:taskSlug/plan", taskHandler.plan)
 
 	r.POST("/:slug/commits/:hash/tasks/:taskSlug/builds", buildHandler.create)
 	r.GET("/:slug/commits/:hash/tasks/:taskSlug/builds", buildHandler.getAllForTask)
 	r.GET("/:slug/commits/:hash/builds", buildHandler.getAllForCommit)
 	r.GET("/:slug/builds", buildHandler.getAllForProject)
 
+	r.POST("/:slug/commits/:hash/pipelines", pipelineHandler.create)
+	r.GET("/:slug/commits/:hash/pipelines", pipelineHandler.getAllForCommit)
+	r.GET("/:slug/pipelines", pipelineHandler.getAllForProject)
+
 	r = e.Group("/v1/builds")
 	r.Use(middleware.JWTWithConfig(jwtConfig))
 	r.GET("/:id", buildHandler.getByID)
+	r.DELETE("/:id", buildHandler.delete)
 	r.GET("/:id/steps", buildStepHandler.getStepsForBuildID)
 
+	r = e.Group("/v1/queue")
+	r.Use(middleware.JWTWithConfig(jwtConfig))
+	r.GET("", buildHandler.getQueue)
+
+	r = e.Group("/v1/pipelines")
+	r.Use(middleware.JWTWithConfig(jwtConfig))
+	r.GET("/:id", pipelineHandler.getByID)
+
 	r = e.Group("/v1/steps")
 	r.Use(middleware.JWTWithConfig(jwtConfig))
 	r.GET("/:id", buildStepHandler.getByID)
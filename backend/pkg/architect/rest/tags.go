@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+)
+
+type tagResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Commit      string    `json:"commit"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+type tagList struct {
+	Total int            `json:"total"`
+	Data  []*tagResponse `json:"data"`
+}
+
+func newTagResponse(t *githistory.Tag) *tagResponse {
+	commit := ""
+	if t.Commit != nil {
+		commit = t.Commit.Hash
+	}
+	return &tagResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		Commit:      commit,
+		LastUpdated: t.LastUpdated,
+	}
+}
+
+type tagHandler struct {
+	projectManager *project.Manager
+	tagManager     *githistory.TagManager
+}
+
+func newTagHandler(
+	projectManager *project.Manager,
+	tagManager *githistory.TagManager,
+) *tagHandler {
+	return &tagHandler{
+		projectManager: projectManager,
+		tagManager:     tagManager,
+	}
+}
+
+func (h *tagHandler) getAllForProject(c echo.Context) error {
+
+	p := getProjectBySlug(c, h.projectManager)
+	if p == nil {
+		return nil
+	}
+
+	pQ := getPagingQueryParams(c)
+	if pQ == nil {
+		return nil
+	}
+
+	ts, total := h.tagManager.GetAllForProject(p, pQ)
+	rTags := []*tagResponse{}
+	for _, t := range ts {
+		rTags = append(rTags, newTagResponse(t))
+	}
+
+	c.JSON(http.StatusOK, tagList{
+		Total: total,
+		Data:  rTags,
+	})
+
+	return nil
+}
+
+func (h *tagHandler) getByProjectAndName(c echo.Context) error {
+
+	p := getProjectBySlug(c, h.projectManager)
+	if p == nil {
+		return nil
+	}
+
+	t, err := h.tagManager.GetByProjectAndName(p, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, "not found")
+		return nil
+	}
+
+	c.JSON(http.StatusOK, newTagResponse(t))
+	return nil
+}
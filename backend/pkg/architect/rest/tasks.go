@@ -1,7 +1,9 @@
 package rest
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
@@ -39,6 +41,7 @@ type taskHandler struct {
 	projectManager *project.Manager
 	commitManager  *githistory.CommitManager
 	branchManager  *githistory.BranchManager
+	tagManager     *githistory.TagManager
 	taskManager    *task.Manager
 }
 
@@ -46,12 +49,14 @@ func newTaskHandler(
 	projectManager *project.Manager,
 	commitManager *githistory.CommitManager,
 	branchManager *githistory.BranchManager,
+	tagManager *githistory.TagManager,
 	taskManager *task.Manager,
 ) *taskHandler {
 	return &taskHandler{
 		projectManager: projectManager,
 		commitManager:  commitManager,
 		branchManager:  branchManager,
+		tagManager:     tagManager,
 		taskManager:    taskManager,
 	}
 }
@@ -91,6 +96,81 @@ func (h *taskHandler) getByProjectCommitAndSlug(c echo.Context) error {
 	return nil
 }
 
+// plan shows what a build of the task would do with the parameters given as ?param=NAME=value,
+// as JSON or as text with ?format=text. Project parameters come from the .velocity.yml of the
+// last synchronisation, and derived parameters are only known to builders so they aren't
+// resolved.
+func (h *taskHandler) plan(c echo.Context) error {
+	t := getTaskByCommitAndSlug(c, h.projectManager, h.commitManager, h.taskManager)
+	if t == nil {
+		return nil
+	}
+
+	params := map[string]string{}
+	for _, p := range c.QueryParams()["param"] {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || len(parts[0]) < 1 {
+			c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid parameter %s, expected NAME=value", p))
+			return nil
+		}
+		params[parts[0]] = parts[1]
+	}
+	setDefaultGitParams(params, t.Commit, h.branchManager, h.tagManager)
+
+	p, err := newTaskPlan(t, params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	if c.QueryParam("format") == "text" {
+		var b strings.Builder
+		p.WriteText(&b)
+		c.String(http.StatusOK, b.String())
+		return nil
+	}
+	c.JSON(http.StatusOK, p)
+	return nil
+}
+
+// newTaskPlan plans a build of the task with the given parameters, along with those of the
+// project.
+func newTaskPlan(t *task.Task, params map[string]string) (*velocity.Plan, error) {
+	return velocity.NewPlan(
+		t.VTask,
+		getCommitGitParams(t.Commit, params),
+		t.Commit.Project.RepositoryConfig.Parameters,
+		planParameterResolver(params),
+	)
+}
+
+// getCommitGitParams returns the git parameters that a builder would set for the commit,
+// apart from GIT_DESCRIBE which needs the repository.
+func getCommitGitParams(commit *githistory.Commit, params map[string]string) map[string]velocity.Parameter {
+	shortHash := commit.Hash
+	if len(shortHash) > 7 {
+		shortHash = shortHash[:7]
+	}
+	return map[string]velocity.Parameter{
+		"GIT_COMMIT_LONG_SHA":  {Value: commit.Hash},
+		"GIT_COMMIT_SHORT_SHA": {Value: shortHash},
+		"GIT_BRANCH":           {Value: params["GIT_BRANCH"]},
+		"GIT_TAG":              {Value: params["GIT_TAG"]},
+		"GIT_COMMIT_AUTHOR":    {Value: commit.Author},
+		"GIT_COMMIT_MESSAGE":   {Value: commit.Message},
+		"GIT_COMMIT_TIMESTAMP": {Value: commit.CreatedAt.String()},
+	}
+}
+
+type planParameterResolver map[string]string
+
+func (r planParameterResolver) Resolve(paramName string) (string, error) {
+	if v, ok := r[paramName]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("parameter %s not given, use ?param=%s=<value>", paramName, paramName)
+}
+
 func getTaskByCommitAndSlug(
 	c echo.Context,
 	pM *project.Manager,
@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+func TestNewTaskPlanResolvesProjectParameters(t *testing.T) {
+	vTask, err := velocity.ParseTask([]byte(`
+name: deploy
+parameters:
+- name: VERSION
+steps:
+- type: run
+  description: Deploy
+  image: example/deploy:${VERSION}
+  command: deploy --region ${REGION}
+`), "")
+	assert.Nil(t, err)
+
+	p := &project.Project{Slug: "test-project"}
+	p.RepositoryConfig.Parameters = velocity.ParameterConfigs{
+		velocity.BasicParameter{Name: "REGION", Value: "eu-west-1"},
+	}
+	tsk := &task.Task{
+		Commit: &githistory.Commit{Project: p, Hash: "abcdef0123456789", CreatedAt: time.Now().UTC()},
+		VTask:  vTask,
+	}
+
+	plan, err := newTaskPlan(tsk, map[string]string{"VERSION": "1.2.0"})
+	assert.Nil(t, err)
+	assert.Contains(t, plan.Parameters, &velocity.PlanParameter{Name: "REGION", Value: "eu-west-1", Source: "project"})
+	assert.Equal(t, "example/deploy:1.2.0", plan.Steps[0].Image)
+	assert.Equal(t, []string{"deploy", "--region", "eu-west-1"}, plan.Steps[0].Command)
+}
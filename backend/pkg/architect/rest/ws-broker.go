@@ -10,6 +10,7 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/pipeline"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/user"
 
@@ -111,6 +112,10 @@ func (m *broker) handleEmit(em *domain.Emit) *PhoenixMessage {
 		topic = fmt.Sprintf("project:%s", v.Project.Slug)
 		payload = newBranchResponse(v)
 		break
+	case *githistory.Tag:
+		topic = fmt.Sprintf("project:%s", v.Project.Slug)
+		payload = newTagResponse(v)
+		break
 	case *githistory.Commit:
 		topic = fmt.Sprintf("project:%s", v.Project.Slug)
 		bs, _ := m.branchManager.GetAllForCommit(v, domain.NewPagingQuery())
@@ -129,6 +134,10 @@ func (m *broker) handleEmit(em *domain.Emit) *PhoenixMessage {
 		topic = fmt.Sprintf("project:%s", v.Build.Task.Commit.Project.Slug)
 		steps := m.stepManager.GetStepsForBuild(v.Build)
 		payload = newBuildResponse(v.Build, stepsToStepResponse(steps, m.streamManager), m.branchManager)
+	case *pipeline.Pipeline:
+		topic = fmt.Sprintf("project:%s", v.Commit.Project.Slug)
+		payload = newPipelineResponse(v, m.stepManager, m.streamManager, m.branchManager)
+		break
 	case *build.StreamLine:
 		topic = fmt.Sprintf("stream:%s", v.StreamID)
 		payload = newStreamLineResponse(v)
@@ -163,14 +172,22 @@ var wsEventMapping = map[string]string{
 	githistory.EventBranchCreate: "branch:new",
 	githistory.EventBranchUpdate: "branch:update",
 
+	githistory.EventTagCreate: "tag:new",
+	githistory.EventTagUpdate: "tag:update",
+	githistory.EventTagDelete: "tag:delete",
+
 	knownhost.EventCreate: "knownhost:new",
 	knownhost.EventDelete: "knownhost:delete",
 
 	build.EventBuildCreate:      "build:new",
 	build.EventBuildUpdate:      "build:update",
+	build.EventBuildDelete:      "build:delete",
 	build.EventStepUpdate:       "build:update",
 	build.EventStreamLineCreate: "streamLine:new",
 
+	pipeline.EventCreate: "pipeline:new",
+	pipeline.EventUpdate: "pipeline:update",
+
 	// "": "builder:new",
 	// "": "builder:update",
 	// "": "builder:delete",
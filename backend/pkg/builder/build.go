@@ -1,22 +1,46 @@
 package builder
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
-	"github.com/gorilla/websocket"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/builder"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 	"go.uber.org/zap"
 )
 
-func runBuild(build *builder.BuildCtrl, ws *websocket.Conn) {
-	emitter := NewEmitter(ws, build.Build)
+// runBuild runs a build in its own workspace, so that it can run alongside the other builds
+// in this builder's slots.
+func (b *Builder) runBuild(build *builder.BuildCtrl) {
+	emitter := NewEmitter(b.outbox, build.Build)
 
 	backupResolver := NewParameterResolver(build.Build.Parameters)
 
 	vT := build.Build.Task.VTask
+	vT.BuildID = build.Build.ID
+	vT.Project = build.Build.Task.Commit.Project.Slug
+	vT.Branch = build.Build.Parameters["GIT_BRANCH"]
+	vT.Tag = build.Build.Parameters["GIT_TAG"]
+	// Docker resources are named after the run, which has to be unique between slots
+	vT.RunID = fmt.Sprintf("vci-%s", build.Build.ID)
 
+	if vT.HasStepType("shell") && !b.allowShell {
+		emitter.SetStepAndStreams(build.Steps[0], build.Streams)
+		writer := emitter.GetStreamWriter(vT.Steps[0].GetOutputStreams()[0])
+		writer.SetStatus(velocity.StateFailed)
+		writer.Write([]byte("shell steps are not allowed on this builder (set BUILDER_ALLOW_SHELL=true to enable)"))
+		velocity.GetLogger().Error("refused build with shell steps", zap.String("buildID", build.Build.ID))
+		return
+	}
+
+	b.setActiveBuild(build.Build.ID, true)
+	defer b.setActiveBuild(build.Build.ID, false)
+
+	events := velocity.NewEventDispatcher(build.Build.Task.Commit.Project.Plugins, vT, emitter)
+	events.Start()
+
+	var err error
 	for i, step := range vT.Steps {
 		bStep := build.Steps[i]
 		emitter.SetStepAndStreams(bStep, build.Streams)
@@ -27,18 +51,22 @@ func runBuild(build *builder.BuildCtrl, ws *websocket.Conn) {
 				&backupResolver,
 				&build.Build.Task.Commit.Project.Config,
 				build.Build.Task.Commit.Hash,
+				build.Build.Task.Commit.Project.Git,
+				build.Build.Task.Commit.Project.Signatures,
 			)
 		}
 
-		err := step.Execute(emitter, vT)
+		events.StepStart(i, step)
+		err = step.Execute(emitter, vT)
+		events.StepComplete(err)
 		if err != nil {
 			break
 		}
 	}
-	wd, _ := os.Getwd()
-	if strings.Contains(wd, velocity.WorkspaceDir) {
-		os.RemoveAll(wd)
+	events.Complete(err)
+
+	if strings.HasPrefix(vT.Workspace, velocity.WorkspaceDir) {
+		os.RemoveAll(vT.Workspace)
 	}
 	velocity.GetLogger().Info("completed build", zap.String("buildID", build.Build.ID))
-	os.Chdir("/opt/velocityci")
 }
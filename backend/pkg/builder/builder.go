@@ -3,12 +3,17 @@ package builder
 import (
 	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	uuid "github.com/satori/go.uuid"
 	"go.uber.org/zap"
 
 	"github.com/velocity-ci/velocity/backend/pkg/architect"
@@ -16,8 +21,22 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 )
 
+// defaultStateDir is where builders keep their identity and the messages the architect
+// hasn't received yet.
+const defaultStateDir = "/opt/velocityci/builder"
+
 type Builder struct {
-	run bool
+	id         string
+	run        bool
+	allowShell bool
+	labels     []string
+	slots      int
+
+	janitor *velocity.Janitor
+	outbox  *outbox
+	// activeBuilds are the IDs of the builds running in a slot
+	activeBuilds map[string]bool
+	lock         sync.RWMutex
 }
 
 func (b *Builder) Start() {
@@ -27,16 +46,27 @@ func (b *Builder) Start() {
 		Timeout: time.Second * 10,
 	}
 
+	go b.runJanitor()
+
 	for b.run {
 		if !waitForService(client, address) {
+			// running builds carry on while the architect is unreachable
+			if len(b.activeBuildIDs()) > 0 {
+				continue
+			}
 			velocity.GetLogger().Fatal("could not connect to architect", zap.String("address", address))
 		}
 
-		ws := connectToArchitect(address, secret)
+		ws, err := connectToArchitect(address, secret, b.id, b.capabilities(), b.labels, b.slots)
+		if err != nil {
+			velocity.GetLogger().Error("could not connect to architect", zap.String("address", address), zap.Error(err))
+			time.Sleep(5 * time.Second)
+			continue
+		}
 
-		velocity.GetLogger().Info("connected to architect", zap.String("address", address))
+		velocity.GetLogger().Info("connected to architect", zap.String("address", address), zap.String("builderID", b.id), zap.Int("slots", b.slots))
 
-		monitorCommands(ws)
+		b.monitorCommands(ws)
 	}
 }
 
@@ -46,7 +76,57 @@ func (b *Builder) Stop() error {
 }
 
 func New() architect.App {
-	return &Builder{run: true}
+	stateDir := getStateDir()
+	o, err := newOutbox(stateDir)
+	if err != nil {
+		velocity.GetLogger().Fatal("could not open outbox", zap.String("path", stateDir), zap.Error(err))
+	}
+	b := &Builder{
+		id:         getBuilderID(stateDir),
+		outbox:     o,
+		run:        true,
+		allowShell: getAllowShell(),
+		labels:     getLabels(),
+		slots:      getSlots(),
+
+		activeBuilds: map[string]bool{},
+	}
+	b.janitor = newJanitor(b)
+	return b
+}
+
+func (b *Builder) setActiveBuild(id string, active bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if active {
+		b.activeBuilds[id] = true
+	} else {
+		delete(b.activeBuilds, id)
+	}
+}
+
+func (b *Builder) isActiveBuild(id string) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return id != "" && b.activeBuilds[id]
+}
+
+func (b *Builder) activeBuildIDs() []string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	ids := []string{}
+	for id := range b.activeBuilds {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (b *Builder) capabilities() []string {
+	c := []string{}
+	if b.allowShell {
+		c = append(c, builder.CapabilityShell)
+	}
+	return c
 }
 
 func getArchitectAddress() string {
@@ -72,6 +152,71 @@ func getBuilderSecret() string {
 	return secret
 }
 
+// getAllowShell returns whether this builder has opted in to running shell steps on the host.
+func getAllowShell() bool {
+	allowShell, _ := strconv.ParseBool(os.Getenv("BUILDER_ALLOW_SHELL"))
+	if allowShell {
+		velocity.GetLogger().Warn("shell steps are allowed to run on this host")
+	}
+
+	return allowShell
+}
+
+// getLabels returns the labels this builder advertises to the architect, so that tasks can
+// require them.
+func getLabels() []string {
+	labels := []string{}
+	for _, l := range strings.Split(os.Getenv("BUILDER_LABELS"), ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+
+	return labels
+}
+
+// getStateDir returns the directory this builder keeps its state in across restarts.
+func getStateDir() string {
+	if dir := os.Getenv("BUILDER_STATE_DIR"); dir != "" {
+		return dir
+	}
+
+	return defaultStateDir
+}
+
+// getBuilderID returns the identity this builder reconnects to the architect with, which is
+// generated on its first start unless BUILDER_ID is set.
+func getBuilderID(stateDir string) string {
+	if id := os.Getenv("BUILDER_ID"); id != "" {
+		return id
+	}
+
+	path := filepath.Join(stateDir, "id")
+	if b, err := ioutil.ReadFile(path); err == nil && strings.TrimSpace(string(b)) != "" {
+		return strings.TrimSpace(string(b))
+	}
+	id := uuid.NewV4().String()
+	if err := ioutil.WriteFile(path, []byte(id), 0600); err != nil {
+		velocity.GetLogger().Fatal("could not save builder id", zap.String("path", path), zap.Error(err))
+	}
+
+	return id
+}
+
+// getSlots returns how many builds this builder runs at the same time.
+func getSlots() int {
+	v := os.Getenv("BUILDER_SLOTS")
+	if v == "" {
+		return 1
+	}
+	slots, err := strconv.Atoi(v)
+	if err != nil || slots < 1 {
+		velocity.GetLogger().Fatal("invalid number of slots in environment variable", zap.String("environment variable", "BUILDER_SLOTS"), zap.String("value", v))
+	}
+
+	return slots
+}
+
 func waitForService(client *http.Client, address string) bool {
 
 	for i := 0; i < 6; i++ {
@@ -89,38 +234,57 @@ func waitForService(client *http.Client, address string) bool {
 	return false
 }
 
-func connectToArchitect(address string, secret string) *websocket.Conn {
+func connectToArchitect(address string, secret string, id string, capabilities []string, labels []string, slots int) (*websocket.Conn, error) {
 	wsAddress := strings.Replace(address, "http", "ws", 1)
 	headers := http.Header{}
 	headers.Set("Authorization", secret)
+	headers.Set(builder.IDHeader, id)
+	headers.Set(builder.CapabilitiesHeader, strings.Join(capabilities, ","))
+	headers.Set(builder.LabelsHeader, strings.Join(labels, ","))
+	headers.Set(builder.SlotsHeader, strconv.Itoa(slots))
 	var dialer *websocket.Dialer
-	conn, _, err := dialer.Dial(
+	conn, resp, err := dialer.Dial(
 		fmt.Sprintf("%s/builder/ws", wsAddress),
 		headers,
 	)
 
-	if err != nil {
+	if err != nil && resp != nil && resp.StatusCode == http.StatusUnauthorized {
 		h := sha256.New()
 		h.Write([]byte(secret))
 		velocity.GetLogger().Fatal("could not connect to architect", zap.String("address", address), zap.String("secretSHA256", string(h.Sum(nil))))
 	}
 
-	return conn
+	return conn, err
 }
 
-func monitorCommands(ws *websocket.Conn) {
+func (b *Builder) monitorCommands(ws *websocket.Conn) {
+	// builds write their log lines to the connection at the same time
+	sws := &safeWebsocket{ws: ws}
 	for {
 		command := &builder.BuilderCtrlMessage{}
 		err := ws.ReadJSON(command)
 		if err != nil {
 			velocity.GetLogger().Error("could not read websocket message", zap.Error(err))
+			b.outbox.Disconnect()
 			ws.Close()
 			return
 		}
 
-		if command.Command == builder.CommandBuild {
+		if command.Command == builder.CommandResume {
+			sequence := command.Payload.(*builder.SequenceCtrl).Sequence
+			if err := b.outbox.Resume(sws, sequence, b.activeBuildIDs()); err != nil {
+				velocity.GetLogger().Error("could not resume", zap.Int("sequence", sequence), zap.Error(err))
+				b.outbox.Disconnect()
+				ws.Close()
+				return
+			}
+		} else if command.Command == builder.CommandAck {
+			if err := b.outbox.Ack(command.Payload.(*builder.SequenceCtrl).Sequence); err != nil {
+				velocity.GetLogger().Error("could not forget acknowledged messages", zap.Error(err))
+			}
+		} else if command.Command == builder.CommandBuild {
 			velocity.GetLogger().Info("got build", zap.Any("payload", command.Payload))
-			runBuild(command.Payload.(*builder.BuildCtrl), ws)
+			go b.runBuild(command.Payload.(*builder.BuildCtrl))
 		} else if command.Command == builder.CommandKnownHosts {
 			velocity.GetLogger().Info("got known hosts", zap.Any("payload", command.Payload))
 			updateKnownHosts(command.Payload.(*builder.KnownHostCtrl))
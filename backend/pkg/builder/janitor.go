@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"os"
+	"time"
+
+	units "github.com/docker/go-units"
+	"go.uber.org/zap"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+const (
+	defaultGCInterval = 10 * time.Minute
+	defaultGCMaxAge   = 6 * time.Hour
+)
+
+func newJanitor(b *Builder) *velocity.Janitor {
+	j := velocity.NewJanitor(
+		getDurationEnv("BUILDER_GC_MAX_AGE", defaultGCMaxAge),
+		getImageThreshold(),
+	)
+	j.IsActive = b.isActiveBuild
+	return j
+}
+
+func (b *Builder) runJanitor() {
+	interval := getDurationEnv("BUILDER_GC_INTERVAL", defaultGCInterval)
+	for b.run {
+		r, err := b.janitor.Run()
+		if err != nil {
+			velocity.GetLogger().Error("could not collect garbage", zap.Error(err))
+		} else if len(r.Containers)+len(r.Networks)+len(r.Images) > 0 {
+			velocity.GetLogger().Info("collected garbage",
+				zap.Strings("containers", r.Containers),
+				zap.Strings("networks", r.Networks),
+				zap.Strings("images", r.Images),
+				zap.String("reclaimed", units.HumanSize(float64(r.ReclaimedBytes))),
+			)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func getDurationEnv(name string, defaultDuration time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return defaultDuration
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		velocity.GetLogger().Fatal("invalid duration in environment variable", zap.String("environment variable", name), zap.Error(err))
+	}
+
+	return d
+}
+
+// getImageThreshold returns the Docker disk usage above which velocity images are pruned e.g. "20GB".
+func getImageThreshold() int64 {
+	v := os.Getenv("BUILDER_GC_IMAGE_THRESHOLD")
+	if v == "" {
+		return 0
+	}
+	size, err := units.FromHumanSize(v)
+	if err != nil {
+		velocity.GetLogger().Fatal("invalid size in environment variable", zap.String("environment variable", "BUILDER_GC_IMAGE_THRESHOLD"), zap.Error(err))
+	}
+
+	return size
+}
@@ -0,0 +1,194 @@
+package builder
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain/builder"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+const outboxFile = "outbox.jsonl"
+
+// outbox delivers the messages of the builds to the architect. Messages are numbered and
+// kept on disk until the architect acknowledges them, so that the ones lost while the
+// connection was down are replayed once the builder reconnects.
+type outbox struct {
+	path string
+
+	lock     sync.Mutex
+	sequence int
+	// acked is the sequence number of the last message the architect has handled
+	acked int
+	// ws is nil while the builder isn't connected
+	ws *safeWebsocket
+}
+
+func newOutbox(dir string) (*outbox, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	o := &outbox{path: filepath.Join(dir, outboxFile)}
+	messages, err := o.read()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range messages {
+		if m.Sequence > o.sequence {
+			o.sequence = m.Sequence
+		}
+	}
+
+	return o, nil
+}
+
+// Send numbers and keeps a message, and sends it if the builder is connected.
+func (o *outbox) Send(m *builder.BuilderRespMessage) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.sequence++
+	m.Sequence = o.sequence
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if o.ws != nil {
+		if err := o.ws.WriteJSON(m); err != nil {
+			velocity.GetLogger().Warn("could not send message, keeping it until reconnected", zap.Int("sequence", m.Sequence), zap.Error(err))
+			o.ws = nil
+		}
+	}
+
+	return nil
+}
+
+// Resume replays the messages after the last one the architect has handled on a new
+// connection, followed by the builds that are still running, and sends new messages on it.
+func (o *outbox) Resume(ws *safeWebsocket, sequence int, buildIDs []string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	// the architect has seen messages from before the builder was restarted
+	if sequence > o.sequence {
+		o.sequence = sequence
+	}
+	if err := o.trim(sequence); err != nil {
+		return err
+	}
+	messages, err := o.read()
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if m.Sequence <= sequence {
+			continue
+		}
+		if err := ws.WriteJSON(m); err != nil {
+			return err
+		}
+	}
+	if len(messages) > 0 {
+		velocity.GetLogger().Info("replayed messages", zap.Int("amount", len(messages)), zap.Int("from", sequence))
+	}
+
+	if err := ws.WriteJSON(&builder.BuilderRespMessage{
+		Type: builder.MessageBuilds,
+		Data: &builder.BuilderBuildsMessage{BuildIDs: buildIDs},
+	}); err != nil {
+		return err
+	}
+
+	o.ws = ws
+	return nil
+}
+
+// Ack forgets the messages up to the given sequence number, which the architect has handled.
+func (o *outbox) Ack(sequence int) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.trim(sequence)
+}
+
+// Disconnect keeps new messages until the builder has reconnected.
+func (o *outbox) Disconnect() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.ws = nil
+}
+
+func (o *outbox) trim(sequence int) error {
+	if sequence <= o.acked {
+		return nil
+	}
+	messages, err := o.read()
+	if err != nil {
+		return err
+	}
+	o.acked = sequence
+
+	tmp, err := ioutil.TempFile(filepath.Dir(o.path), outboxFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	encoder := json.NewEncoder(tmp)
+	for _, m := range messages {
+		if m.Sequence <= sequence {
+			continue
+		}
+		if err := encoder.Encode(m); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), o.path)
+}
+
+func (o *outbox) read() ([]*builder.BuilderRespMessage, error) {
+	f, err := os.Open(o.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	messages := []*builder.BuilderRespMessage{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		m := &builder.BuilderRespMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), m); err != nil {
+			// the last message can be cut short if the builder stopped while writing it
+			continue
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, scanner.Err()
+}
@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain/builder"
+)
+
+// connectOutbox returns a connection to a fake architect, which passes on the messages it
+// receives.
+func connectOutbox(t *testing.T) (*safeWebsocket, <-chan *builder.BuilderRespMessage, func()) {
+	received := make(chan *builder.BuilderRespMessage, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			m := &builder.BuilderRespMessage{}
+			if err := ws.ReadJSON(m); err != nil {
+				return
+			}
+			received <- m
+		}
+	}))
+	ws, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL, "http", "ws", 1), nil)
+	assert.Nil(t, err)
+
+	return &safeWebsocket{ws: ws}, received, func() {
+		ws.Close()
+		server.Close()
+	}
+}
+
+func logMessage(output string) *builder.BuilderRespMessage {
+	return &builder.BuilderRespMessage{
+		Type: builder.MessageLog,
+		Data: &builder.BuilderStreamLineMessage{BuildID: "build-1", Output: output},
+	}
+}
+
+func TestOutboxReplaysUnacknowledgedMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "velocity-builder")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	o, err := newOutbox(dir)
+	assert.Nil(t, err)
+	// messages are kept while disconnected
+	for _, output := range []string{"first", "second", "third"} {
+		assert.Nil(t, o.Send(logMessage(output)))
+	}
+
+	ws, received, closeWs := connectOutbox(t)
+	defer closeWs()
+	// the architect has handled the first message before the connection dropped
+	assert.Nil(t, o.Resume(ws, 1, []string{"build-1"}))
+	assert.Nil(t, o.Send(logMessage("fourth")))
+
+	outputs := []string{}
+	sequences := []int{}
+	for i := 0; i < 4; i++ {
+		m := <-received
+		sequences = append(sequences, m.Sequence)
+		if m.Type == builder.MessageBuilds {
+			assert.Equal(t, []string{"build-1"}, m.Data.(*builder.BuilderBuildsMessage).BuildIDs)
+			continue
+		}
+		outputs = append(outputs, m.Data.(*builder.BuilderStreamLineMessage).Output)
+	}
+	assert.Equal(t, []string{"second", "third", "fourth"}, outputs)
+	assert.Equal(t, []int{2, 3, 0, 4}, sequences)
+
+	// acknowledged messages are forgotten, and numbering carries on after a restart
+	assert.Nil(t, o.Ack(3))
+	o, err = newOutbox(dir)
+	assert.Nil(t, err)
+	messages, err := o.read()
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, 4, o.sequence)
+}
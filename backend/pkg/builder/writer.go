@@ -25,7 +25,7 @@ func (sws *safeWebsocket) WriteJSON(m interface{}) error {
 }
 
 type StreamWriter struct {
-	ws         *safeWebsocket
+	outbox     *outbox
 	StepNumber int
 
 	BuildID  string
@@ -37,15 +37,21 @@ type StreamWriter struct {
 }
 
 type Emitter struct {
-	ws      *safeWebsocket
+	outbox  *outbox
 	BuildID string
 	StepID  string
 	Streams []*build.Stream
 
 	StepNumber int
+	// writers are shared by everything writing to a stream of the step, e.g. the step and
+	// plugin warnings, so that their lines are numbered one after the other
+	writers map[string]*StreamWriter
 }
 
 func (e *Emitter) GetStreamWriter(streamName string) velocity.StreamWriter {
+	if w, ok := e.writers[streamName]; ok {
+		return w
+	}
 	streamID := ""
 	for _, s := range e.Streams {
 		if s.Name == streamName {
@@ -56,18 +62,22 @@ func (e *Emitter) GetStreamWriter(streamName string) velocity.StreamWriter {
 	if streamID == "" {
 		velocity.GetLogger().Error("could not find streamID", zap.String("stream name", streamName))
 	}
-	return &StreamWriter{
-		ws:         e.ws,
+	w := &StreamWriter{
+		outbox:     e.outbox,
 		BuildID:    e.BuildID,
 		StepID:     e.StepID,
 		StreamID:   streamID,
 		StepNumber: e.StepNumber,
 		LineNumber: 0,
+		status:     velocity.StateRunning,
 	}
+	e.writers[streamName] = w
+	return w
 }
 
 func (e *Emitter) SetStepAndStreams(step *build.Step, streams []*build.Stream) {
 	e.StepID = step.ID
+	e.writers = map[string]*StreamWriter{}
 	e.Streams = []*build.Stream{}
 	for _, s := range streams {
 		if s.Step.ID == step.ID {
@@ -80,16 +90,20 @@ func (e *Emitter) SetStepNumber(n int) {
 	e.StepNumber = n
 }
 
-func NewEmitter(ws *websocket.Conn, b *build.Build) *Emitter {
+func NewEmitter(o *outbox, b *build.Build) *Emitter {
 	return &Emitter{
-		ws:      &safeWebsocket{ws: ws},
+		outbox:  o,
 		BuildID: b.ID,
+		writers: map[string]*StreamWriter{},
 	}
 }
 
 func (w *StreamWriter) Write(p []byte) (n int, err error) {
 	o := strings.TrimSpace(string(p))
-	if !strings.HasSuffix(string(p), "\r") {
+	if strings.HasSuffix(string(p), "\r") {
+		// progress lines keep their carriage return so that the architect only keeps the latest
+		o += "\r"
+	} else {
 		w.LineNumber++
 		o += "\n"
 	}
@@ -102,11 +116,13 @@ func (w *StreamWriter) Write(p []byte) (n int, err error) {
 		Status:     w.status,
 		Output:     o,
 	}
-	m := builder.BuilderRespMessage{
-		Type: "log",
+	m := &builder.BuilderRespMessage{
+		Type: builder.MessageLog,
 		Data: lM,
 	}
-	err = w.ws.WriteJSON(m)
+	// lines are kept until they have reached the architect, so this only fails if they
+	// couldn't be kept
+	err = w.outbox.Send(m)
 
 	if err != nil {
 		return 0, err
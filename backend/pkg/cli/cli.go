@@ -3,17 +3,31 @@ package cli
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
-	yaml "gopkg.in/yaml.v2"
 )
 
+const usage = `Usage: vcli <command> [flags] [arguments]
+
+Commands:
+  run <task>       Run a task
+  watch <task>     Run a task again whenever project files change
+  list             List tasks
+  validate         Check that tasks can be parsed
+  describe <task>  Show the parameters and steps of a task
+  plan <task>      Show what running a task would do, with its parameters resolved
+  version          Show version
+  gc               Remove resources left behind by previous runs
+  login            Log in to an architect
+  remote           Run and follow builds on an architect
+
+Run 'vcli <command> -h' for the flags of a command.
+`
+
 type CLI struct {
 	wg     sync.WaitGroup
 	runner *runner
@@ -28,7 +42,11 @@ func New() *CLI {
 }
 
 func (c *CLI) Start(quit chan os.Signal) {
-	c.routeFlags()
+	code := c.route(os.Args[1:])
+	c.Stop()
+	if code != 0 {
+		os.Exit(code)
+	}
 
 	quit <- os.Interrupt
 }
@@ -39,64 +57,288 @@ func (c *CLI) Stop() error {
 	return nil
 }
 
-func (c *CLI) routeFlags() {
-	version := flag.Bool("v", false, "Show version")
-	list := flag.Bool("l", false, "List tasks")
-
-	flag.Parse()
+// route runs the subcommand in args, returning the exit code. A task name on its own,
+// -v and -l are still accepted for compatibility with earlier versions.
+func (c *CLI) route(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
 
-	if *version {
+	switch args[0] {
+	case "run":
+		return c.run(args[1:])
+	case "watch":
+		return c.watch(args[1:])
+	case "list", "-l":
+		return list(args[1:])
+	case "validate":
+		return validate(args[1:])
+	case "describe":
+		return describe(args[1:])
+	case "plan":
+		return plan(args[1:])
+	case "version", "-v":
 		fmt.Printf("Version: %s\n", "alpha")
-		os.Exit(0)
+		return 0
+	case "gc":
+		gc(args[1:])
+		return 0
+	case "login":
+		return login(args[1:])
+	case "remote":
+		return remote(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
 	}
 
-	if *list {
-		tasks := getTasksFromDirectory("./tasks/")
-		// iterate through tasks in memory and list them.
-		for _, task := range tasks {
-			fmt.Printf("%s: %s (", task.Name, task.Description)
-			for _, paramName := range task.Parameters {
-				fmt.Printf(" %s ", paramName)
-			}
-			fmt.Println(")")
-			for _, step := range task.Steps {
-				fmt.Printf("\t%s| %s: %s\n", step.GetType(), step.GetDescription(), step.GetDetails())
-			}
-			fmt.Println()
+	if strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "unknown flag: %s\n\n%s", args[0], usage)
+		return 2
+	}
+
+	return c.run(args)
+}
+
+func (c *CLI) run(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	runFlags := addRunFlags(flags)
+	junit := flags.String("junit", "", "Write a JUnit report of the steps to this file")
+	fromStep := flags.Int("from-step", 0, "Run from this step, where 1 is the first step of the task")
+	onlySteps := stringsFlag{}
+	skipSteps := stringsFlag{}
+	flags.Var(&onlySteps, "only-step", "Only run this step (repeatable)")
+	flags.Var(&skipSteps, "skip-step", "Don't run this step (repeatable)")
+	resume := flags.Bool("resume", false, "Run from the first failed step of the last run")
+	commit := flags.String("commit", "", "Run in a clean checkout of this commit, branch or tag instead of the current directory")
+	keepWorkspace := flags.Bool("keep-workspace", false, "Keep the checkout made by --commit e.g. for debugging")
+	flags.Usage = commandUsage(flags, "run [flags] <task>")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 || (flags.NArg() < 1 && !*resume) {
+		flags.Usage()
+		return 2
+	}
+	taskName := flags.Arg(0)
+
+	if err := runFlags.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *keepWorkspace && *commit == "" {
+		fmt.Fprintln(os.Stderr, "--keep-workspace can only be used with --commit")
+		return 2
+	}
+
+	selector := newStepSelector()
+	selector.From = *fromStep
+	if err := addSteps(selector.Only, onlySteps); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := addSteps(selector.Skip, skipSteps); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *resume {
+		if *fromStep != 0 || len(onlySteps) > 0 {
+			fmt.Fprintln(os.Stderr, "--resume can't be used with --from-step or --only-step")
+			return 2
+		}
+		last, err := getLastRunRecord(wd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not resume: %s\n", err)
+			return 1
+		}
+		if taskName != "" && taskName != last.Task {
+			fmt.Fprintf(os.Stderr, "could not resume: the last run was of %s\n", last.Task)
+			return 1
 		}
-		os.Exit(0)
+		failed, ok := last.firstFailedStep()
+		if !ok {
+			fmt.Fprintf(os.Stderr, "could not resume: the last run of %s didn't fail\n", last.Task)
+			return 1
+		}
+		taskName = last.Task
+		selector.From = failed
 	}
 
-	switch os.Args[1] {
-	case "run":
-		c.wg.Add(1)
-		c.runner.Run(os.Args[2])
-		break
-	default:
+	resolver, err := runFlags.parameterResolver()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ws, err := currentWorkspace()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *commit != "" {
+		// the worktree is removed before vcli exits, even when interrupted
 		c.wg.Add(1)
-		c.runner.Run(os.Args[1])
-		break
+		defer c.wg.Done()
+		ws, err = newCommitWorkspace(*commit, &lineWriter{out: os.Stderr})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer func() {
+			if *keepWorkspace {
+				fmt.Fprintf(os.Stderr, "Kept workspace: %s\n", ws.ProjectRoot)
+				return
+			}
+			if err := ws.Remove(); err != nil {
+				fmt.Fprintf(os.Stderr, "could not remove workspace %s: %s\n", ws.ProjectRoot, err)
+			}
+		}()
 	}
 
-	c.Stop()
+	emitter := NewEmitter(*runFlags.output, os.Stdout)
+	startedAt := time.Now().UTC()
+	c.wg.Add(1)
+	err = c.runner.Run(taskName, ws, runFlags.tasksDirs, resolver, emitter, selector)
+	if len(emitter.Results) > 0 {
+		if err := saveRunRecord(wd, newRunRecord(taskName, startedAt, emitter.Results, err)); err != nil {
+			fmt.Fprintf(os.Stderr, "could not save run record: %s\n", err)
+		}
+		if *junit != "" {
+			if err := writeJUnitReport(*junit, taskName, emitter.Results); err != nil {
+				fmt.Fprintf(os.Stderr, "could not write JUnit report: %s\n", err)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	return 0
 }
 
-func getTasksFromDirectory(dir string) []velocity.Task {
-	tasks := []velocity.Task{}
+// parameterFlags are the flags that give the values of task parameters.
+type parameterFlags struct {
+	params     stringsFlag
+	paramFiles stringsFlag
+	secrets    stringsFlag
+}
 
-	filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".yml") || strings.HasSuffix(f.Name(), ".yaml") {
-			taskYml, _ := ioutil.ReadFile(fmt.Sprintf("%s%s", dir, f.Name()))
-			var t velocity.Task
-			err := yaml.Unmarshal(taskYml, &t)
-			if err != nil {
-				log.Println(err)
-			} else {
-				tasks = append(tasks, t)
-			}
+func addParameterFlags(flags *flag.FlagSet) *parameterFlags {
+	f := &parameterFlags{}
+	flags.Var(&f.params, "param", "Set a parameter as NAME=value (repeatable)")
+	flags.Var(&f.paramFiles, "param-file", "Read parameters from a .env file (repeatable)")
+	flags.Var(&f.secrets, "secret-from-env", "Read a parameter from the environment as NAME or NAME=ENV_VAR (repeatable)")
+	return f
+}
+
+func (f *parameterFlags) parameterResolver() (*ParameterResolver, error) {
+	resolver := NewParameterResolver()
+	for _, p := range f.params {
+		if err := resolver.AddParam(p); err != nil {
+			return nil, err
 		}
+	}
+	for _, path := range f.paramFiles {
+		if err := resolver.AddParamFile(path); err != nil {
+			return nil, fmt.Errorf("could not read parameter file: %s", err)
+		}
+	}
+	for _, s := range f.secrets {
+		if err := resolver.AddSecretFromEnv(s); err != nil {
+			return nil, err
+		}
+	}
+	return resolver, nil
+}
+
+// runFlags are the flags shared by the commands that run tasks.
+type runFlags struct {
+	*parameterFlags
+	tasksDirs stringsFlag
+	output    *string
+}
+
+func addRunFlags(flags *flag.FlagSet) *runFlags {
+	f := &runFlags{parameterFlags: addParameterFlags(flags)}
+	flags.Var(&f.tasksDirs, "tasks-dir", tasksDirUsage)
+	f.output = flags.String("output", OutputPretty, "Output format: pretty, plain or jsonl")
+	return f
+}
+
+func (f *runFlags) validate() error {
+	switch *f.output {
+	case OutputPretty, OutputPlain, OutputJSONL:
 		return nil
-	})
+	}
+	return fmt.Errorf("invalid output %s, expected pretty, plain or jsonl", *f.output)
+}
+
+// stringsFlag is a flag that can be given more than once.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
 
-	return tasks
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+const tasksDirUsage = "Find tasks in this directory instead of the tasksPath in .velocity.yml (repeatable)"
+
+func addTasksDirFlag(flags *flag.FlagSet) *stringsFlag {
+	tasksDirs := &stringsFlag{}
+	flags.Var(tasksDirs, "tasks-dir", tasksDirUsage)
+	return tasksDirs
+}
+
+func commandUsage(flags *flag.FlagSet, use string) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: vcli %s\n", use)
+		flags.PrintDefaults()
+	}
+}
+
+// getTasks discovers the tasks of the project in the working directory, from the given
+// tasks paths if there are any.
+func getTasks(tasksPaths []string) ([]*velocity.Task, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	return getTasksIn(wd, tasksPaths)
+}
+
+func getTasksIn(projectRoot string, tasksPaths []string) ([]*velocity.Task, error) {
+	if len(tasksPaths) > 0 {
+		return velocity.DiscoverTasksIn(projectRoot, tasksPaths)
+	}
+	return velocity.DiscoverTasks(projectRoot)
+}
+
+// findTask returns the named task in the project, listing the tasks that were found if it
+// isn't one of them.
+func findTask(projectRoot string, taskName string, tasksPaths []string) (*velocity.Task, error) {
+	tasks, err := getTasksIn(projectRoot, tasksPaths)
+	if err != nil {
+		return nil, fmt.Errorf("could not find tasks: %s", err)
+	}
+
+	names := []string{}
+	for _, t := range tasks {
+		if t.Name == taskName {
+			return t, nil
+		}
+		names = append(names, t.Name)
+	}
+	return nil, fmt.Errorf("task %s not found in: %v", taskName, names)
 }
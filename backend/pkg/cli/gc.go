@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	units "github.com/docker/go-units"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// gc removes containers, networks and images left behind by previous runs.
+func gc(args []string) {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	maxAge := flags.Duration("max-age", time.Hour, "Remove resources older than this")
+	imageThreshold := flags.String("image-threshold", "", "Prune velocity images when Docker disk usage is above this size e.g. 20GB")
+	flags.Parse(args)
+
+	threshold := int64(0)
+	if *imageThreshold != "" {
+		size, err := units.FromHumanSize(*imageThreshold)
+		if err != nil {
+			fmt.Printf("invalid image threshold: %s\n", err)
+			os.Exit(1)
+		}
+		threshold = size
+	}
+
+	r, err := velocity.NewJanitor(*maxAge, threshold).Run()
+	if err != nil {
+		fmt.Printf("could not collect garbage: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, c := range r.Containers {
+		fmt.Printf("Removed container: %s\n", c)
+	}
+	for _, n := range r.Networks {
+		fmt.Printf("Removed network: %s\n", n)
+	}
+	for _, i := range r.Images {
+		fmt.Printf("Removed image: %s\n", i)
+	}
+	fmt.Printf("Reclaimed: %s\n", units.HumanSize(float64(r.ReclaimedBytes)))
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	Cases     []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Output  string `xml:",chardata"`
+}
+
+func junitDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// newJUnitReport returns a test suite for the task with each step as a test case.
+func newJUnitReport(taskName string, results []*stepResult) *junitTestSuites {
+	suite := &junitTestSuite{
+		Name:  taskName,
+		Cases: []*junitTestCase{},
+	}
+	var total time.Duration
+	for _, r := range results {
+		name := fmt.Sprintf("%02d %s", r.Number, r.Type)
+		if r.Description != "" {
+			name = fmt.Sprintf("%s: %s", name, r.Description)
+		}
+		c := &junitTestCase{
+			ClassName: taskName,
+			Name:      name,
+			Time:      junitDuration(r.Duration()),
+		}
+		switch r.Status {
+		case velocity.StateFailed:
+			c.Failure = &junitFailure{
+				Message: r.Error,
+				Output:  r.output.String(),
+			}
+			suite.Failures++
+		case velocity.StateSuccess:
+		default:
+			c.Time = junitDuration(0)
+			c.Skipped = &struct{}{}
+			suite.Skipped++
+		}
+		if suite.Timestamp == "" && !r.StartedAt.IsZero() {
+			suite.Timestamp = r.StartedAt.Format("2006-01-02T15:04:05")
+		}
+		total += r.Duration()
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = junitDuration(total)
+
+	return &junitTestSuites{Suites: []*junitTestSuite{suite}}
+}
+
+func writeJUnitReport(path string, taskName string, results []*stepResult) error {
+	b, err := xml.MarshalIndent(newJUnitReport(taskName, results), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append([]byte(xml.Header), b...), 0644)
+}
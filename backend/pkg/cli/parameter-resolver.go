@@ -3,31 +3,142 @@ package cli
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
 )
 
+// ParameterResolver resolves the parameters a task asks for from, in order, --param flags,
+// --param-file files, --secret-from-env environment variables and environment variables of
+// the same name. Anything left is prompted for, unless stdin isn't a terminal in which case
+// it is an error so that vcli never blocks waiting for input.
 type ParameterResolver struct {
+	Params         map[string]string
+	SecretsEnv     map[string]string
+	NonInteractive bool
 }
 
-func (pR ParameterResolver) Resolve(paramName string) (string, error) {
+func NewParameterResolver() *ParameterResolver {
+	return &ParameterResolver{
+		Params:         map[string]string{},
+		SecretsEnv:     map[string]string{},
+		NonInteractive: !terminal.IsTerminal(int(os.Stdin.Fd())),
+	}
+}
 
-	var text string
-	reader := bufio.NewReader(os.Stdin)
+func (pR *ParameterResolver) Resolve(paramName string) (string, error) {
+	if v, ok := pR.Params[paramName]; ok {
+		return v, nil
+	}
+
+	if envName, ok := pR.SecretsEnv[paramName]; ok {
+		v, ok := os.LookupEnv(envName)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s for %s is not set", envName, paramName)
+		}
+		return v, nil
+	}
 
 	fromEnv := os.Getenv(paramName)
 
+	if pR.NonInteractive {
+		if len(fromEnv) > 0 {
+			return fromEnv, nil
+		}
+		return "", fmt.Errorf("no value for %s, use --param %s=<value> when not running in a terminal", paramName, paramName)
+	}
+
+	var text string
+	reader := bufio.NewReader(os.Stdin)
+
 	for text == "" {
 		if len(fromEnv) > 0 {
 			fmt.Printf("\nEnter value for %s (%s): ", paramName, fromEnv)
 		} else {
 			fmt.Printf("\nEnter value for %s: ", paramName)
 		}
-		text, _ = reader.ReadString('\n')
+		var err error
+		text, err = reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("could not read value for %s: %s", paramName, err)
+		}
 		if text == "\n" {
 			text = fromEnv
 		}
 	}
 
-	return strings.TrimSpace(text), nil
+	// remember the value so that reruns e.g. vcli watch don't prompt again
+	v := strings.TrimSpace(text)
+	pR.Params[paramName] = v
+	return v, nil
+}
+
+// AddParam adds a NAME=value parameter.
+func (pR *ParameterResolver) AddParam(param string) error {
+	parts := strings.SplitN(param, "=", 2)
+	if len(parts) != 2 || len(parts[0]) < 1 {
+		return fmt.Errorf("invalid parameter %s, expected NAME=value", param)
+	}
+	pR.Params[parts[0]] = parts[1]
+	return nil
+}
+
+// AddSecretFromEnv resolves the NAME parameter from the environment variable of the same
+// name, or from ENV_VAR when given as NAME=ENV_VAR.
+func (pR *ParameterResolver) AddSecretFromEnv(secret string) error {
+	parts := strings.SplitN(secret, "=", 2)
+	if len(parts[0]) < 1 {
+		return fmt.Errorf("invalid secret %s, expected NAME or NAME=ENV_VAR", secret)
+	}
+	envName := parts[0]
+	if len(parts) == 2 && len(parts[1]) > 0 {
+		envName = parts[1]
+	}
+	pR.SecretsEnv[parts[0]] = envName
+	return nil
+}
+
+// AddParamFile adds the parameters in a .env file. Parameters that are already set
+// e.g. from --param, are not overridden.
+func (pR *ParameterResolver) AddParamFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	params, err := parseEnvFile(string(b))
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	for k, v := range params {
+		if _, ok := pR.Params[k]; !ok {
+			pR.Params[k] = v
+		}
+	}
+	return nil
+}
+
+// parseEnvFile parses NAME=value lines, ignoring blank lines, comments and export prefixes
+// and unquoting quoted values.
+func parseEnvFile(contents string) (map[string]string, error) {
+	params := map[string]string{}
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("line %d: expected NAME=value", i+1)
+		}
+		value := strings.TrimSpace(parts[1])
+		if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		params[name] = value
+	}
+	return params, nil
 }
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	params, err := parseEnvFile(`
+# registry settings
+REGISTRY=registry.example.com
+export VERSION = 1.0.0
+MESSAGE="hello world"
+EMPTY=
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"REGISTRY": "registry.example.com",
+		"VERSION":  "1.0.0",
+		"MESSAGE":  "hello world",
+		"EMPTY":    "",
+	}, params)
+
+	_, err = parseEnvFile("REGISTRY\n")
+	assert.NotNil(t, err)
+}
+
+func TestParameterResolverNonInteractive(t *testing.T) {
+	f, err := ioutil.TempFile("", "velocity-params")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString("REGISTRY=file.example.com\nVERSION=1.0.0\n")
+	f.Close()
+
+	os.Setenv("VELOCITY_TEST_TOKEN", "s3cret")
+	defer os.Unsetenv("VELOCITY_TEST_TOKEN")
+
+	r := NewParameterResolver()
+	r.NonInteractive = true
+	assert.Nil(t, r.AddParam("REGISTRY=flag.example.com"))
+	assert.Nil(t, r.AddParamFile(f.Name()))
+	assert.Nil(t, r.AddSecretFromEnv("TOKEN=VELOCITY_TEST_TOKEN"))
+	assert.NotNil(t, r.AddParam("REGISTRY"))
+
+	v, err := r.Resolve("REGISTRY")
+	assert.Nil(t, err)
+	assert.Equal(t, "flag.example.com", v)
+
+	v, err = r.Resolve("VERSION")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", v)
+
+	v, err = r.Resolve("TOKEN")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", v)
+
+	_, err = r.Resolve("MISSING")
+	assert.NotNil(t, err)
+}
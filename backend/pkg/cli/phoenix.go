@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Phoenix Framework (http://phoenixframework.org/) websocket protocol events, as served by
+// the architect on /v1/ws.
+const (
+	phxJoinEvent      = "phx_join"
+	phxReplyEvent     = "phx_reply"
+	phxHeartbeatEvent = "heartbeat"
+	phxSystemTopic    = "phoenix"
+
+	phxHeartbeatInterval = 30 * time.Second
+)
+
+type phoenixMessage struct {
+	Event   string          `json:"event"`
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+	Ref     uint64          `json:"ref"`
+}
+
+type phoenixReplyPayload struct {
+	Status   string            `json:"status"`
+	Response map[string]string `json:"response"`
+}
+
+// phoenixSocket is a client for the architect's Phoenix websocket.
+type phoenixSocket struct {
+	ws    *websocket.Conn
+	lock  sync.Mutex
+	ref   uint64
+	token string
+	stop  chan struct{}
+}
+
+func dialPhoenix(address string, token string) (*phoenixSocket, error) {
+	wsAddress := strings.Replace(strings.TrimSuffix(address, "/"), "http", "ws", 1)
+	var dialer *websocket.Dialer
+	conn, _, err := dialer.Dial(fmt.Sprintf("%s/v1/ws", wsAddress), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %s", address, err)
+	}
+
+	s := &phoenixSocket{
+		ws:    conn,
+		token: token,
+		stop:  make(chan struct{}),
+	}
+	go s.heartbeat()
+	return s, nil
+}
+
+func (s *phoenixSocket) send(topic string, event string, payload interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ref++
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.ws.WriteJSON(&phoenixMessage{
+		Event:   event,
+		Topic:   topic,
+		Payload: b,
+		Ref:     s.ref,
+	})
+}
+
+// join subscribes to a topic. Replies are read with the topic's messages, see read.
+func (s *phoenixSocket) join(topic string) error {
+	return s.send(topic, phxJoinEvent, map[string]string{"token": s.token})
+}
+
+func (s *phoenixSocket) heartbeat() {
+	ticker := time.NewTicker(phxHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.send(phxSystemTopic, phxHeartbeatEvent, map[string]string{})
+		}
+	}
+}
+
+// read returns the next message from a joined topic, returning an error if joining a topic
+// was refused.
+func (s *phoenixSocket) read() (*phoenixMessage, error) {
+	for {
+		m := &phoenixMessage{}
+		if err := s.ws.ReadJSON(m); err != nil {
+			return nil, err
+		}
+		if m.Event != phxReplyEvent {
+			return m, nil
+		}
+		reply := phoenixReplyPayload{}
+		json.Unmarshal(m.Payload, &reply)
+		if reply.Status == "error" {
+			return nil, fmt.Errorf("could not join %s: %s", m.Topic, reply.Response["message"])
+		}
+	}
+}
+
+func (s *phoenixSocket) Close() error {
+	close(s.stop)
+	return s.ws.Close()
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// Output formats for vcli plan
+const (
+	planOutputText = "text"
+	planOutputJSON = "json"
+)
+
+// plan prints what running a task would do with its parameters resolved, without running
+// anything in Docker.
+func plan(args []string) int {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	paramFlags := addParameterFlags(flags)
+	tasksDirs := addTasksDirFlag(flags)
+	output := flags.String("output", planOutputText, "Output format: text or json")
+	flags.Usage = commandUsage(flags, "plan [flags] <task>")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *output != planOutputText && *output != planOutputJSON {
+		fmt.Fprintf(os.Stderr, "invalid output %s, expected text or json\n", *output)
+		return 2
+	}
+	resolver, err := paramFlags.parameterResolver()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	t, err := findTask(wd, flags.Arg(0), *tasksDirs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	t.Workspace = wd
+	t.Project = filepath.Base(wd)
+
+	p, err := velocity.NewWorkspacePlan(t, resolver)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not plan %s: %s\n", t.Name, err)
+		return 1
+	}
+
+	if *output == planOutputJSON {
+		b, _ := json.MarshalIndent(p, "", "  ")
+		fmt.Printf("%s\n", b)
+		return 0
+	}
+	p.WriteText(os.Stdout)
+	return 0
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// credentials are saved by vcli login for the remote commands. VELOCITY_ADDRESS and
+// VELOCITY_TOKEN override them e.g. in CI.
+type credentials struct {
+	Address  string    `json:"address"`
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	Expires  time.Time `json:"expires"`
+}
+
+func getCredentialsPath() (string, error) {
+	home := os.Getenv("HOME")
+	if home == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		home = u.HomeDir
+	}
+	return filepath.Join(home, ".velocityci", "credentials.json"), nil
+}
+
+func loadCredentials() (*credentials, error) {
+	c := &credentials{}
+	path, err := getCredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	if address := os.Getenv("VELOCITY_ADDRESS"); address != "" {
+		c.Address = address
+	}
+	if token := os.Getenv("VELOCITY_TOKEN"); token != "" {
+		c.Token = token
+		c.Expires = time.Time{}
+	}
+
+	if c.Address == "" || c.Token == "" {
+		return nil, fmt.Errorf("not logged in, run vcli login")
+	}
+	if !c.Expires.IsZero() && c.Expires.Before(time.Now()) {
+		return nil, fmt.Errorf("session for %s expired, run vcli login", c.Address)
+	}
+	return c, nil
+}
+
+func saveCredentials(c *credentials) (string, error) {
+	path, err := getCredentialsPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, ioutil.WriteFile(path, b, 0600)
+}
+
+// remoteClient talks to an architect's REST API.
+type remoteClient struct {
+	address string
+	token   string
+	http    *http.Client
+}
+
+func newRemoteClient(address string, token string) *remoteClient {
+	return &remoteClient{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *remoteClient) do(method string, path string, body interface{}, v interface{}) error {
+	var reqBody *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader([]byte{})
+	}
+
+	req, err := http.NewRequest(method, c.address+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("unauthorized, run vcli login")
+	case res.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("not found: %s", path)
+	case res.StatusCode >= 300:
+		return fmt.Errorf("%s %s: %s %s", method, path, res.Status, strings.TrimSpace(string(resBody)))
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(resBody, v)
+}
+
+type remoteAuth struct {
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	Expires  time.Time `json:"expires"`
+}
+
+type remoteCommit struct {
+	Hash string `json:"hash"`
+}
+
+type remoteStream struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type remoteStep struct {
+	ID      string          `json:"id"`
+	Number  int             `json:"number"`
+	Status  string          `json:"status"`
+	Streams []*remoteStream `json:"streams"`
+}
+
+type remoteTask struct {
+	Slug   string        `json:"slug"`
+	Name   string        `json:"name"`
+	Commit *remoteCommit `json:"commit"`
+}
+
+type remoteBuild struct {
+	ID     string        `json:"id"`
+	Task   *remoteTask   `json:"task"`
+	Steps  []*remoteStep `json:"buildSteps"`
+	Status string        `json:"status"`
+}
+
+type remoteStreamLine struct {
+	LineNumber int       `json:"lineNumber"`
+	Timestamp  time.Time `json:"timestamp"`
+	Output     string    `json:"output"`
+}
+
+type remoteParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (c *remoteClient) auth(username string, password string) (*remoteAuth, error) {
+	a := &remoteAuth{}
+	err := c.do(http.MethodPost, "/v1/auth", map[string]string{
+		"username": username,
+		"password": password,
+	}, a)
+	if err != nil && strings.HasPrefix(err.Error(), "unauthorized") {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+	return a, err
+}
+
+// getBranchHead returns the most recent commit on a project's branch.
+func (c *remoteClient) getBranchHead(projectSlug string, branch string) (string, error) {
+	commits := struct {
+		Data []*remoteCommit `json:"data"`
+	}{}
+	path := fmt.Sprintf("/v1/projects/%s/commits?amount=1&branch=%s", url.PathEscape(projectSlug), url.QueryEscape(branch))
+	if err := c.do(http.MethodGet, path, nil, &commits); err != nil {
+		return "", err
+	}
+	if len(commits.Data) < 1 {
+		return "", fmt.Errorf("no commits found on branch %s", branch)
+	}
+	return commits.Data[0].Hash, nil
+}
+
+func (c *remoteClient) createBuild(projectSlug string, commitHash string, taskSlug string, params map[string]string) (*remoteBuild, error) {
+	reqParams := []remoteParameter{}
+	for k, v := range params {
+		reqParams = append(reqParams, remoteParameter{Name: k, Value: v})
+	}
+	b := &remoteBuild{}
+	path := fmt.Sprintf("/v1/projects/%s/commits/%s/tasks/%s/builds",
+		url.PathEscape(projectSlug),
+		url.PathEscape(commitHash),
+		url.PathEscape(taskSlug),
+	)
+	return b, c.do(http.MethodPost, path, map[string]interface{}{"params": reqParams}, b)
+}
+
+func (c *remoteClient) getBuild(id string) (*remoteBuild, error) {
+	b := &remoteBuild{}
+	return b, c.do(http.MethodGet, fmt.Sprintf("/v1/builds/%s", url.PathEscape(id)), nil, b)
+}
+
+// getStreamLines returns a page of a stream's lines and the total number of lines.
+func (c *remoteClient) getStreamLines(id string, page int, amount int) ([]*remoteStreamLine, int, error) {
+	lines := struct {
+		Total int                 `json:"total"`
+		Data  []*remoteStreamLine `json:"data"`
+	}{}
+	path := fmt.Sprintf("/v1/streams/%s/log?page=%d&amount=%d", url.PathEscape(id), page, amount)
+	err := c.do(http.MethodGet, path, nil, &lines)
+	return lines.Data, lines.Total, err
+}
@@ -0,0 +1,355 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+const remoteUsage = `Usage: vcli remote <command> [flags] [arguments]
+
+Commands:
+  run <project> <task>  Build a task of a pushed commit and follow its logs
+  logs <buildID>        Show the logs of a build
+
+Run 'vcli login' first to authenticate with an architect.
+`
+
+// buildPollInterval is how often a followed build is checked for completion.
+var buildPollInterval = 2 * time.Second
+
+// login authenticates with an architect and saves the token for the remote commands.
+func login(args []string) int {
+	flags := flag.NewFlagSet("login", flag.ExitOnError)
+	address := flags.String("address", os.Getenv("VELOCITY_ADDRESS"), "Address of the architect e.g. https://velocity.example.com")
+	username := flags.String("username", "", "Username to log in as")
+	flags.Usage = commandUsage(flags, "login [flags]")
+	flags.Parse(args)
+
+	if *address == "" {
+		if c, err := loadCredentials(); err == nil {
+			*address = c.Address
+		}
+	}
+	if *address == "" {
+		fmt.Fprintln(os.Stderr, "missing --address")
+		return 2
+	}
+
+	interactive := terminal.IsTerminal(int(os.Stdin.Fd()))
+	reader := bufio.NewReader(os.Stdin)
+	if *username == "" {
+		if !interactive {
+			fmt.Fprintln(os.Stderr, "missing --username")
+			return 2
+		}
+		fmt.Print("Username: ")
+		u, _ := reader.ReadString('\n')
+		*username = strings.TrimSpace(u)
+	}
+
+	var password string
+	if interactive {
+		fmt.Print("Password: ")
+		b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read password: %s\n", err)
+			return 1
+		}
+		password = string(b)
+	} else {
+		// e.g. echo $VELOCITY_PASSWORD | vcli login --username ci
+		p, _ := reader.ReadString('\n')
+		password = strings.TrimSpace(p)
+	}
+
+	auth, err := newRemoteClient(*address, "").auth(*username, password)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not log in to %s: %s\n", *address, err)
+		return 1
+	}
+
+	path, err := saveCredentials(&credentials{
+		Address:  *address,
+		Username: auth.Username,
+		Token:    auth.Token,
+		Expires:  auth.Expires,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not save credentials: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("Logged in to %s as %s (saved to %s)\n", *address, auth.Username, path)
+	return 0
+}
+
+func remote(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, remoteUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "run":
+		return remoteRun(args[1:])
+	case "logs":
+		return remoteLogs(args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown remote command: %s\n\n%s", args[0], remoteUsage)
+	return 2
+}
+
+// remoteRun creates a build of a task on an architect and follows it, exiting with the
+// build's status.
+func remoteRun(args []string) int {
+	flags := flag.NewFlagSet("remote run", flag.ExitOnError)
+	commit := flags.String("commit", "", "Hash of the commit to build")
+	branch := flags.String("branch", "", "Build the latest commit of this branch")
+	detach := flags.Bool("detach", false, "Print the build ID without following the build")
+	params := stringsFlag{}
+	flags.Var(&params, "param", "Set a parameter as NAME=value (repeatable)")
+	flags.Usage = commandUsage(flags, "remote run [flags] <project> <task>")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 || (*commit == "") == (*branch == "") {
+		fmt.Fprintln(os.Stderr, "expected a project, a task and one of --commit or --branch")
+		flags.Usage()
+		return 2
+	}
+	projectSlug, taskName := flags.Arg(0), flags.Arg(1)
+
+	reqParams := map[string]string{}
+	for _, p := range params {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			fmt.Fprintf(os.Stderr, "invalid parameter %s, expected NAME=value\n", p)
+			return 2
+		}
+		reqParams[parts[0]] = parts[1]
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	client := newRemoteClient(creds.Address, creds.Token)
+
+	hash := *commit
+	if *branch != "" {
+		hash, err = client.getBranchHead(projectSlug, *branch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not find branch %s: %s\n", *branch, err)
+			return 1
+		}
+	}
+
+	b, err := client.createBuild(projectSlug, hash, slug.Make(taskName), reqParams)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not create build: %s\n", err)
+		return 1
+	}
+
+	if *detach {
+		fmt.Println(b.ID)
+		return 0
+	}
+	fmt.Printf("Running task: %s (build %s of %s)\n", taskName, b.ID, hash)
+
+	return followBuild(creds, client, b.ID, true)
+}
+
+// remoteLogs prints the logs of a build, following them until the build completes with -f.
+func remoteLogs(args []string) int {
+	flags := flag.NewFlagSet("remote logs", flag.ExitOnError)
+	follow := flags.Bool("f", false, "Follow the logs until the build completes")
+	flags.Usage = commandUsage(flags, "remote logs [flags] <buildID>")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return followBuild(creds, newRemoteClient(creds.Address, creds.Token), flags.Arg(0), *follow)
+}
+
+func isBuildComplete(status string) bool {
+	return status == velocity.StateSuccess || status == velocity.StateFailed
+}
+
+// buildExitCode returns 1 for failed builds so that vcli can be used to gate on remote builds.
+func buildExitCode(b *remoteBuild) int {
+	if b.Status == velocity.StateFailed {
+		return 1
+	}
+	return 0
+}
+
+type remoteLine struct {
+	streamID string
+	line     *remoteStreamLine
+}
+
+// buildLogs prints a build's stream lines once each, from both the stream log API and the
+// stream:<id> websocket topics.
+type buildLogs struct {
+	client  *remoteClient
+	writers map[string]velocity.StreamWriter
+	last    map[string]int
+	count   map[string]int
+}
+
+func newBuildLogs(client *remoteClient, b *remoteBuild) *buildLogs {
+	l := &buildLogs{
+		client:  client,
+		writers: map[string]velocity.StreamWriter{},
+		last:    map[string]int{},
+		count:   map[string]int{},
+	}
+	emitter := NewEmitter(OutputPretty, os.Stdout)
+	for _, step := range b.Steps {
+		emitter.SetStepNumber(uint64(step.Number))
+		for _, s := range step.Streams {
+			l.writers[s.ID] = emitter.GetStreamWriter(s.Name)
+		}
+	}
+	return l
+}
+
+func (l *buildLogs) print(streamID string, line *remoteStreamLine) {
+	w, ok := l.writers[streamID]
+	if !ok {
+		return
+	}
+	if last, ok := l.last[streamID]; ok && line.LineNumber <= last {
+		return
+	}
+	l.last[streamID] = line.LineNumber
+	l.count[streamID]++
+	w.Write([]byte(line.Output))
+}
+
+// printHistory prints the lines of each stream that haven't been printed yet, in step order.
+func (l *buildLogs) printHistory(b *remoteBuild) error {
+	amount := 100
+	for _, step := range b.Steps {
+		for _, s := range step.Streams {
+			for page := l.count[s.ID]/amount + 1; ; page++ {
+				lines, _, err := l.client.getStreamLines(s.ID, page, amount)
+				if err != nil {
+					return err
+				}
+				for _, line := range lines {
+					l.print(s.ID, line)
+				}
+				if len(lines) < amount {
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func followBuild(creds *credentials, client *remoteClient, buildID string, follow bool) int {
+	b, err := client.getBuild(buildID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not get build %s: %s\n", buildID, err)
+		return 1
+	}
+	logs := newBuildLogs(client, b)
+
+	follow = follow && !isBuildComplete(b.Status)
+	lines := make(chan *remoteLine, 1000)
+	errs := make(chan error, 1)
+	if follow {
+		// join the stream topics before reading the history so that no lines are missed
+		socket, err := dialPhoenix(creds.Address, creds.Token)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer socket.Close()
+		for streamID := range logs.writers {
+			if err := socket.join(fmt.Sprintf("stream:%s", streamID)); err != nil {
+				fmt.Fprintf(os.Stderr, "could not follow build: %s\n", err)
+				return 1
+			}
+		}
+		go readStreamLines(socket, lines, errs)
+	}
+
+	if err := logs.printHistory(b); err != nil {
+		fmt.Fprintf(os.Stderr, "could not get build logs: %s\n", err)
+		return 1
+	}
+
+	ticker := time.NewTicker(buildPollInterval)
+	defer ticker.Stop()
+	for follow {
+		select {
+		case l := <-lines:
+			logs.print(l.streamID, l.line)
+		case err := <-errs:
+			fmt.Fprintf(os.Stderr, "lost connection to %s: %s\n", creds.Address, err)
+			return 1
+		case <-ticker.C:
+			b, err = client.getBuild(buildID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not get build %s: %s\n", buildID, err)
+				return 1
+			}
+			if isBuildComplete(b.Status) {
+				// catch up on any lines still in flight
+				if err := logs.printHistory(b); err != nil {
+					fmt.Fprintf(os.Stderr, "could not get build logs: %s\n", err)
+					return 1
+				}
+				follow = false
+			}
+		}
+	}
+
+	fmt.Printf("Build %s: %s\n", b.ID, b.Status)
+	return buildExitCode(b)
+}
+
+func readStreamLines(socket *phoenixSocket, lines chan<- *remoteLine, errs chan<- error) {
+	for {
+		m, err := socket.read()
+		if err != nil {
+			errs <- err
+			return
+		}
+		if m.Event != "streamLine:new" || !strings.HasPrefix(m.Topic, "stream:") {
+			continue
+		}
+		line := &remoteStreamLine{}
+		if err := json.Unmarshal(m.Payload, line); err != nil {
+			continue
+		}
+		lines <- &remoteLine{
+			streamID: strings.TrimPrefix(m.Topic, "stream:"),
+			line:     line,
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// fakeArchitect serves a build with a single stream. One line is in the stream log and a
+// second is sent on the stream topic once it has been joined, after which the build has status.
+type fakeArchitect struct {
+	status    string
+	lock      sync.Mutex
+	completed bool
+	joined    []string
+}
+
+func (a *fakeArchitect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/ws":
+		a.serveWebsocket(w, r)
+	case r.URL.Path == "/v1/builds/build-1":
+		a.lock.Lock()
+		status := velocity.StateRunning
+		if a.completed {
+			status = a.status
+		}
+		a.lock.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":     "build-1",
+			"status": status,
+			"buildSteps": []map[string]interface{}{
+				{"id": "step-1", "number": 0, "streams": []map[string]string{{"id": "stream-1", "name": "test"}}},
+			},
+		})
+	case r.URL.Path == "/v1/streams/stream-1/log":
+		lines := []map[string]interface{}{{"lineNumber": 1, "output": "first"}}
+		a.lock.Lock()
+		if a.completed {
+			lines = append(lines, map[string]interface{}{"lineNumber": 2, "output": "second"})
+		}
+		a.lock.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"total": len(lines), "data": lines})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (a *fakeArchitect) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	for {
+		m := &phoenixMessage{}
+		if err := ws.ReadJSON(m); err != nil {
+			return
+		}
+		if m.Event != phxJoinEvent {
+			continue
+		}
+		a.lock.Lock()
+		a.joined = append(a.joined, m.Topic)
+		a.lock.Unlock()
+		ws.WriteJSON(map[string]interface{}{
+			"event":   phxReplyEvent,
+			"topic":   m.Topic,
+			"ref":     m.Ref,
+			"payload": map[string]interface{}{"status": "ok", "response": map[string]string{}},
+		})
+		ws.WriteJSON(map[string]interface{}{
+			"event":   "streamLine:new",
+			"topic":   m.Topic,
+			"payload": map[string]interface{}{"lineNumber": 2, "output": "second"},
+		})
+		a.lock.Lock()
+		a.completed = true
+		a.lock.Unlock()
+	}
+}
+
+func TestFollowBuildExitsWithBuildStatus(t *testing.T) {
+	defer func(i time.Duration) { buildPollInterval = i }(buildPollInterval)
+	buildPollInterval = 10 * time.Millisecond
+
+	for status, code := range map[string]int{
+		velocity.StateSuccess: 0,
+		velocity.StateFailed:  1,
+	} {
+		a := &fakeArchitect{status: status}
+		server := httptest.NewServer(a)
+
+		creds := &credentials{Address: server.URL, Token: "token"}
+		assert.Equal(t, code, followBuild(creds, newRemoteClient(server.URL, creds.Token), "build-1", true), status)
+		assert.Equal(t, []string{"stream:stream-1"}, a.joined)
+
+		server.Close()
+	}
+}
+
+func TestBuildLogsPrintsLinesOnce(t *testing.T) {
+	server := httptest.NewServer(&fakeArchitect{completed: true, status: velocity.StateSuccess})
+	defer server.Close()
+
+	client := newRemoteClient(server.URL, "token")
+	b, err := client.getBuild("build-1")
+	assert.Nil(t, err)
+
+	logs := newBuildLogs(client, b)
+	logs.print("stream-1", &remoteStreamLine{LineNumber: 1, Output: "first"})
+	assert.Nil(t, logs.printHistory(b))
+	assert.Nil(t, logs.printHistory(b))
+	assert.Equal(t, 2, logs.count["stream-1"])
+	assert.Equal(t, 2, logs.last["stream-1"])
+}
+
+func TestRemoteClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := newRemoteClient(server.URL, "").auth("admin", "wrong")
+	assert.Equal(t, fmt.Errorf("invalid username or password"), err)
+
+	_, err = newRemoteClient(server.URL, "expired").getBuild("build-1")
+	assert.True(t, strings.Contains(err.Error(), "vcli login"))
+}
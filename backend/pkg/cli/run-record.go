@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// runsDir is where run records are kept, relative to the project root.
+var runsDir = filepath.Join(".velocityci", "runs")
+
+// stepSelector selects the task steps to run by number, where 1 is the first step of the
+// task. Setup (0) always runs so that parameters are resolved.
+type stepSelector struct {
+	From int
+	Only map[int]bool
+	Skip map[int]bool
+}
+
+func newStepSelector() *stepSelector {
+	return &stepSelector{
+		Only: map[int]bool{},
+		Skip: map[int]bool{},
+	}
+}
+
+func (s *stepSelector) selects(n int) bool {
+	if n == 0 {
+		return true
+	}
+	if n < s.From {
+		return false
+	}
+	if len(s.Only) > 0 && !s.Only[n] {
+		return false
+	}
+	return !s.Skip[n]
+}
+
+// validate checks that the selected steps exist in a task with the given number of steps.
+func (s *stepSelector) validate(steps int) error {
+	check := func(flag string, n int) error {
+		if n < 1 || n > steps {
+			return fmt.Errorf("%s %d is not a step, the task has steps 1 to %d", flag, n, steps)
+		}
+		return nil
+	}
+	if s.From != 0 {
+		if err := check("--from-step", s.From); err != nil {
+			return err
+		}
+	}
+	for n := range s.Only {
+		if err := check("--only-step", n); err != nil {
+			return err
+		}
+	}
+	for n := range s.Skip {
+		if err := check("--skip-step", n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSteps adds step numbers from repeatable or comma separated flag values e.g. 2,3.
+func addSteps(steps map[int]bool, values []string) error {
+	for _, v := range values {
+		for _, n := range strings.Split(v, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(n))
+			if err != nil {
+				return fmt.Errorf("invalid step number %s", n)
+			}
+			steps[i] = true
+		}
+	}
+	return nil
+}
+
+type runStepRecord struct {
+	Number      uint64    `json:"number"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// runRecord is kept for each local run so that a failed run can be resumed.
+type runRecord struct {
+	ID          string           `json:"id"`
+	Task        string           `json:"task"`
+	Status      string           `json:"status"`
+	StartedAt   time.Time        `json:"startedAt"`
+	CompletedAt time.Time        `json:"completedAt"`
+	Steps       []*runStepRecord `json:"steps"`
+}
+
+func newRunRecord(taskName string, startedAt time.Time, results []*stepResult, err error) *runRecord {
+	r := &runRecord{
+		ID:          startedAt.Format("20060102T150405.000000000Z"),
+		Task:        taskName,
+		Status:      velocity.StateSuccess,
+		StartedAt:   startedAt,
+		CompletedAt: time.Now().UTC(),
+		Steps:       []*runStepRecord{},
+	}
+	if err != nil {
+		r.Status = velocity.StateFailed
+	}
+	for _, s := range results {
+		r.Steps = append(r.Steps, &runStepRecord{
+			Number:      s.Number,
+			Type:        s.Type,
+			Description: s.Description,
+			Status:      s.Status,
+			Error:       s.Error,
+			StartedAt:   s.StartedAt,
+			CompletedAt: s.CompletedAt,
+		})
+	}
+	return r
+}
+
+// firstFailedStep returns the number of the first failed step, which is 0 if setup failed.
+func (r *runRecord) firstFailedStep() (int, bool) {
+	for _, s := range r.Steps {
+		if s.Status == velocity.StateFailed {
+			return int(s.Number), true
+		}
+	}
+	return 0, false
+}
+
+func saveRunRecord(projectRoot string, r *runRecord) error {
+	dir := filepath.Join(projectRoot, runsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%s.json", r.ID)), b, 0644)
+}
+
+// getLastRunRecord returns the most recent run record in the project.
+func getLastRunRecord(projectRoot string) (*runRecord, error) {
+	dir := filepath.Join(projectRoot, runsDir)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no previous runs in %s", runsDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	if len(names) < 1 {
+		return nil, fmt.Errorf("no previous runs in %s", runsDir)
+	}
+	sort.Strings(names)
+
+	path := filepath.Join(dir, names[len(names)-1])
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &runRecord{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// fakeStep records that it ran, failing if fail is set.
+type fakeStep struct {
+	velocity.DockerRun
+	ran  *[]string
+	fail bool
+}
+
+func newFakeStep(description string, ran *[]string, fail bool) *fakeStep {
+	s := &fakeStep{ran: ran, fail: fail}
+	s.Type = "run"
+	s.Description = description
+	return s
+}
+
+func (s *fakeStep) Execute(emitter velocity.Emitter, t *velocity.Task) error {
+	*s.ran = append(*s.ran, s.Description)
+	if s.fail {
+		return fmt.Errorf("%s failed", s.Description)
+	}
+	return nil
+}
+
+func runFakeSteps(selector *stepSelector, failing string) ([]string, *Emitter, error) {
+	ran := []string{}
+	t := &velocity.Task{Name: "test", Steps: []velocity.Step{}}
+	for _, d := range []string{"setup", "pull", "build", "test", "push"} {
+		t.Steps = append(t.Steps, newFakeStep(d, &ran, d == failing))
+	}
+	r := newRunner(&sync.WaitGroup{})
+	r.run = true
+	e := NewEmitter(OutputPlain, &bytes.Buffer{})
+	err := r.runSteps(t, e, selector)
+	return ran, e, err
+}
+
+func TestStepSelectors(t *testing.T) {
+	ran, _, err := runFakeSteps(&stepSelector{From: 3}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"setup", "test", "push"}, ran)
+
+	ran, _, err = runFakeSteps(&stepSelector{Only: map[int]bool{2: true}}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"setup", "build"}, ran)
+
+	ran, e, err := runFakeSteps(&stepSelector{Skip: map[int]bool{1: true, 4: true}}, "test")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"setup", "build", "test"}, ran)
+	statuses := []string{}
+	for _, r := range e.Results {
+		statuses = append(statuses, r.Status)
+	}
+	assert.Equal(t, []string{
+		velocity.StateSuccess,
+		velocity.StateWaiting,
+		velocity.StateSuccess,
+		velocity.StateFailed,
+		velocity.StateWaiting,
+	}, statuses)
+
+	assert.NotNil(t, (&stepSelector{From: 5}).validate(4))
+	assert.NotNil(t, (&stepSelector{Skip: map[int]bool{0: true}}).validate(4))
+	assert.Nil(t, (&stepSelector{From: 2, Only: map[int]bool{3: true}}).validate(4))
+
+	steps := map[int]bool{}
+	assert.Nil(t, addSteps(steps, []string{"1,3", "5"}))
+	assert.Equal(t, map[int]bool{1: true, 3: true, 5: true}, steps)
+	assert.NotNil(t, addSteps(steps, []string{"two"}))
+}
+
+func TestResumeFromLastRunRecord(t *testing.T) {
+	root, err := ioutil.TempDir("", "velocity-runs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	_, err = getLastRunRecord(root)
+	assert.NotNil(t, err)
+
+	startedAt := time.Now().UTC()
+	_, e, _ := runFakeSteps(newStepSelector(), "")
+	assert.Nil(t, saveRunRecord(root, newRunRecord("test", startedAt, e.Results, nil)))
+
+	_, e, err = runFakeSteps(newStepSelector(), "test")
+	assert.Nil(t, saveRunRecord(root, newRunRecord("test", startedAt.Add(time.Second), e.Results, err)))
+
+	last, err := getLastRunRecord(root)
+	assert.Nil(t, err)
+	assert.Equal(t, velocity.StateFailed, last.Status)
+	failed, ok := last.firstFailedStep()
+	assert.True(t, ok)
+	assert.Equal(t, 3, failed)
+
+	ran, _, err := runFakeSteps(&stepSelector{From: failed}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"setup", "test", "push"}, ran)
+}
@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"sync"
 
+	"go.uber.org/zap"
+
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 )
 
 type runner struct {
 	run bool
 	wg  *sync.WaitGroup
+
+	lock  sync.Mutex
+	runID string
 }
 
 func newRunner(wg *sync.WaitGroup) *runner {
@@ -19,46 +24,79 @@ func newRunner(wg *sync.WaitGroup) *runner {
 	}
 }
 
-func (r *runner) Run(taskName string) {
+// Run runs the named task in the workspace, returning an error if it couldn't be found or failed.
+func (r *runner) Run(taskName string, ws *workspace, tasksPaths []string, resolver *ParameterResolver, emitter *Emitter, selector *stepSelector) error {
 	r.run = true
 	defer r.wg.Done()
 	defer func() { r.run = false }()
-	tasks := getTasksFromDirectory("./tasks/")
-
-	var t *velocity.Task
-	// find Task requested
-	for _, tsk := range tasks {
-		if tsk.Name == taskName {
-			t = &tsk
-			break
-		}
+	t, err := findTask(ws.ProjectRoot, taskName, tasksPaths)
+	if err != nil {
+		return err
 	}
-
-	if t == nil {
-		fmt.Printf("Task %s not found in:\n%v\n", taskName, tasks)
-		return
+	if err := selector.validate(len(t.Steps)); err != nil {
+		return err
 	}
-	fmt.Printf("Running task: %s\n", t.Name)
+	emitter.TaskStart(t)
+
+	t.Workspace = ws.ProjectRoot
+	t.Project = ws.Project
+	t.Branch = ws.Branch
+	t.Tag = ws.Tag
 
-	emitter := NewEmitter()
+	setup := velocity.NewSetup()
+	setup.Init(resolver, nil, ws.Commit, velocity.GitConfig{}, velocity.SignaturePolicy{})
+	t.Steps = append([]velocity.Step{setup}, t.Steps...)
+	t.RunID = velocity.NewRunID()
+	r.lock.Lock()
+	r.runID = t.RunID
+	r.lock.Unlock()
 
-	t.Steps = append([]velocity.Step{velocity.NewSetup()}, t.Steps...)
+	err = r.runSteps(t, emitter, selector)
+	emitter.TaskEnd(err)
+	return err
+}
 
-	// Run each step unless they fail (optional)
+// runSteps runs each selected step until one fails, recording the rest as skipped.
+func (r *runner) runSteps(t *velocity.Task, emitter *Emitter, selector *stepSelector) error {
 	for i, step := range t.Steps {
-		if !r.run {
-			return
+		if !selector.selects(i) {
+			emitter.StepSkipped(uint64(i), step)
+			continue
 		}
-		if step.GetType() == "setup" {
-			step.(*velocity.Setup).Init(&ParameterResolver{}, nil, "")
+		if !r.run {
+			skipSteps(t.Steps[i:], i, emitter)
+			return fmt.Errorf("interrupted")
 		}
-		emitter.SetStepNumber(uint64(i))
+		emitter.StepStart(uint64(i), step)
 		err := step.Execute(emitter, t)
+		emitter.StepEnd(err)
 		if err != nil {
-			fmt.Printf("encountered error: %s", err)
-			return
+			skipSteps(t.Steps[i+1:], i+1, emitter)
+			return fmt.Errorf("encountered error: %s", err)
 		}
 	}
+
+	return nil
+}
+
+func skipSteps(steps []velocity.Step, from int, emitter *Emitter) {
+	for i, step := range steps {
+		emitter.StepSkipped(uint64(from+i), step)
+	}
+}
+
+// Cancel stops the current run without waiting for the running step to finish.
+func (r *runner) Cancel() {
+	r.run = false
+	r.lock.Lock()
+	runID := r.runID
+	r.lock.Unlock()
+	if runID == "" {
+		return
+	}
+	if err := velocity.StopRun(runID); err != nil {
+		velocity.GetLogger().Error("could not stop run", zap.String("runID", runID), zap.Error(err))
+	}
 }
 
 func (r *runner) Stop() {
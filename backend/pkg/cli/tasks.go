@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// list prints the name and description of each task.
+func list(args []string) int {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	tasksDirs := addTasksDirFlag(flags)
+	flags.Usage = commandUsage(flags, "list [flags]")
+	flags.Parse(args)
+
+	tasks, err := getTasks(*tasksDirs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not find tasks: %s\n", err)
+		return 1
+	}
+	for _, task := range tasks {
+		fmt.Printf("%s: %s\n", task.Name, task.Description)
+	}
+	return 0
+}
+
+// describe prints the parameters and steps of a task.
+func describe(args []string) int {
+	flags := flag.NewFlagSet("describe", flag.ExitOnError)
+	tasksDirs := addTasksDirFlag(flags)
+	flags.Usage = commandUsage(flags, "describe [flags] <task>")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	tasks, err := getTasks(*tasksDirs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not find tasks: %s\n", err)
+		return 1
+	}
+	for _, task := range tasks {
+		if task.Name != flags.Arg(0) {
+			continue
+		}
+		fmt.Printf("%s: %s\n", task.Name, task.Description)
+		if len(task.Parameters) > 0 {
+			fmt.Println("\nParameters:")
+			for _, p := range task.Parameters {
+				fmt.Printf("\t%s\n", p.GetInfo())
+			}
+		}
+		fmt.Println("\nSteps:")
+		for _, step := range task.Steps {
+			fmt.Printf("\t%s| %s: %s\n", step.GetType(), step.GetDescription(), step.GetDetails())
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "task %s not found\n", flags.Arg(0))
+	return 1
+}
+
+// validate parses the repository configuration and every task file, reporting all of the
+// problems found rather than skipping them as discovery does.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	tasksDirs := addTasksDirFlag(flags)
+	flags.Usage = commandUsage(flags, "validate [flags]")
+	flags.Parse(args)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	config, err := velocity.GetRepositoryConfig(wd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid repository configuration: %s\n", err)
+		return 1
+	}
+	tasksPaths := []string(*tasksDirs)
+	if len(tasksPaths) < 1 {
+		tasksPaths = config.Project.GetTasksPaths()
+	}
+
+	files, err := velocity.FindTaskFiles(wd, tasksPaths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not find tasks: %s\n", err)
+		return 1
+	}
+
+	failed := 0
+	taskFiles := map[string]string{}
+	for _, f := range files {
+		rel, _ := filepath.Rel(wd, f)
+		taskYml, err := ioutil.ReadFile(f)
+		if err == nil {
+			var t *velocity.Task
+			t, err = velocity.ParseTask(taskYml, wd)
+			if err == nil {
+				if other, ok := taskFiles[t.Name]; ok {
+					err = fmt.Errorf("duplicate task name %s, also in %s", t.Name, other)
+				} else {
+					taskFiles[t.Name] = rel
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", rel, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d task files are invalid\n", failed, len(files))
+		return 1
+	}
+	fmt.Printf("%d task files are valid\n", len(files))
+	return 0
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// watchShowChanges is how many changed files are shown before they are summarised.
+const watchShowChanges = 5
+
+// watch runs a task and reruns it whenever files in the project change, stopping the
+// run in progress first.
+func (c *CLI) watch(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	runFlags := addRunFlags(flags)
+	debounce := flags.Duration("debounce", 500*time.Millisecond, "Wait for changes to settle for this long before rerunning")
+	flags.Usage = commandUsage(flags, "watch [flags] <task>")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	taskName := flags.Arg(0)
+
+	if err := runFlags.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	resolver, err := runFlags.parameterResolver()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ws, err := currentWorkspace()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	config, err := velocity.GetRepositoryConfig(ws.ProjectRoot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read .velocity.yml: %s\n", err)
+		return 1
+	}
+	watcher, err := velocity.NewWatcher(ws.ProjectRoot, config.Watch.Ignore, *debounce)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not watch %s: %s\n", ws.ProjectRoot, err)
+		return 1
+	}
+	defer watcher.Close()
+
+	done := make(chan error)
+	start := func() {
+		c.wg.Add(1)
+		go func() {
+			done <- c.runner.Run(taskName, ws, runFlags.tasksDirs, resolver, NewEmitter(*runFlags.output, os.Stdout), newStepSelector())
+		}()
+	}
+
+	start()
+	running := true
+	for {
+		select {
+		case err := <-done:
+			running = false
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+			fmt.Printf("\nWatching for changes...\n")
+		case changes := <-watcher.Changes:
+			fmt.Printf("\nChanged: %s\n", summariseChanges(changes))
+			if running {
+				fmt.Printf("Stopping run\n")
+				c.runner.Cancel()
+				<-done
+			}
+			fmt.Println()
+			start()
+			running = true
+		}
+	}
+}
+
+func summariseChanges(changes []string) string {
+	if len(changes) <= watchShowChanges {
+		return strings.Join(changes, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(changes[:watchShowChanges], ", "), len(changes)-watchShowChanges)
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// workspace is where a task is run from: the current directory, or with --commit a clean
+// worktree of the local repository at that commit as a builder would clone it.
+type workspace struct {
+	ProjectRoot string
+	Project     string
+	// Commit, Branch and Tag are set for a worktree, as the architect sets them for a build.
+	Commit string
+	Branch string
+	Tag    string
+
+	repo     *velocity.RawRepository
+	worktree string
+}
+
+func currentWorkspace() (*workspace, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return &workspace{
+		ProjectRoot: wd,
+		Project:     filepath.Base(wd),
+	}, nil
+}
+
+// newCommitWorkspace checks out a commit, branch or tag of the repository that the current
+// directory is in, with submodules, into a temporary worktree. The project root is the same
+// directory within the worktree as the current directory is within the repository.
+func newCommitWorkspace(rev string, writer io.Writer) (*workspace, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	repo := &velocity.RawRepository{Directory: wd}
+	topLevel, err := repo.GetTopLevel()
+	if err != nil {
+		return nil, err
+	}
+	repo.Directory = topLevel
+	commit, err := repo.ResolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	realWd, err := filepath.EvalSymlinks(wd)
+	if err != nil {
+		return nil, err
+	}
+	realTopLevel, err := filepath.EvalSymlinks(topLevel)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(realTopLevel, realWd)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "vcli-worktree-")
+	if err != nil {
+		return nil, err
+	}
+	// git creates the worktree directory itself
+	os.Remove(dir)
+	if _, err := repo.AddWorktree(dir, commit, true, writer); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("could not check out %s: %s", rev, err)
+	}
+
+	w := &workspace{
+		ProjectRoot: filepath.Join(dir, rel),
+		Project:     filepath.Base(wd),
+		Commit:      commit,
+		repo:        repo,
+		worktree:    dir,
+	}
+	if repo.HasRef(fmt.Sprintf("refs/heads/%s", rev)) {
+		w.Branch = rev
+	} else if repo.HasRef(fmt.Sprintf("refs/tags/%s", rev)) {
+		w.Tag = rev
+	}
+	return w, nil
+}
+
+// Remove removes the worktree, if there is one.
+func (w *workspace) Remove() error {
+	if w.worktree == "" {
+		return nil
+	}
+	return w.repo.RemoveWorktree(w.worktree)
+}
+
+// lineWriter writes each write on its own line e.g. the output of git commands.
+type lineWriter struct {
+	out io.Writer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	fmt.Fprintf(w.out, "%s\n", p)
+	return len(p), nil
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func git(t *testing.T, dir string, args ...string) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	assert.Nil(t, err, string(out))
+}
+
+func TestCommitWorkspaceIsACleanCheckout(t *testing.T) {
+	root, err := ioutil.TempDir("", "velocity-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "app"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "app", "version"), []byte("1.0.0\n"), 0644))
+	git(t, root, "init")
+	git(t, root, "config", "user.email", "velocity@example.com")
+	git(t, root, "config", "user.name", "Velocity")
+	git(t, root, "checkout", "-b", "release")
+	git(t, root, "add", ".")
+	git(t, root, "commit", "-m", "release 1.0.0")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "app", "version"), []byte("dirty\n"), 0644))
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	assert.Nil(t, os.Chdir(filepath.Join(root, "app")))
+
+	ws, err := newCommitWorkspace("release", &bytes.Buffer{})
+	assert.Nil(t, err)
+	assert.Equal(t, "release", ws.Branch)
+	assert.Equal(t, "", ws.Tag)
+	assert.Equal(t, "app", ws.Project)
+	assert.Len(t, ws.Commit, 40)
+	b, err := ioutil.ReadFile(filepath.Join(ws.ProjectRoot, "version"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0\n", string(b))
+
+	assert.Nil(t, ws.Remove())
+	_, err = os.Stat(ws.ProjectRoot)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = newCommitWorkspace("does-not-exist", &bytes.Buffer{})
+	assert.NotNil(t, err)
+}
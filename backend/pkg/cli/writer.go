@@ -1,25 +1,94 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 )
 
+// Output modes for vcli run
+const (
+	OutputPretty = "pretty"
+	OutputPlain  = "plain"
+	OutputJSONL  = "jsonl"
+)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+func stripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// stepResult is the outcome of a step, kept for reports e.g. JUnit.
+type stepResult struct {
+	Number      uint64
+	Type        string
+	Description string
+	Status      string
+	Error       string
+	StartedAt   time.Time
+	CompletedAt time.Time
+	output      strings.Builder
+}
+
+func (r *stepResult) Duration() time.Duration {
+	return r.CompletedAt.Sub(r.StartedAt)
+}
+
+type outputStepEvent struct {
+	Event       string    `json:"event"`
+	Timestamp   time.Time `json:"timestamp"`
+	Task        string    `json:"task,omitempty"`
+	Step        uint64    `json:"step"`
+	Type        string    `json:"type,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Duration    float64   `json:"duration,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type outputLineEvent struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Step      uint64    `json:"step"`
+	Stream    string    `json:"stream"`
+	Status    string    `json:"status"`
+	Output    string    `json:"output"`
+}
+
 type StreamWriter struct {
 	StepNumber uint64
 	StreamName string
 	status     string
 	ansiColour string
+	emitter    *Emitter
 }
 
+// Emitter writes the output of a task's steps as pretty (coloured) text, plain text or JSON
+// lines and records the result of each step.
 type Emitter struct {
 	StepNumber uint64
+	Results    []*stepResult
+
+	output string
+	out    io.Writer
+	lock   sync.Mutex
+	task   string
+	step   *stepResult
 }
 
-func NewEmitter() *Emitter {
-	return &Emitter{}
+func NewEmitter(output string, out io.Writer) *Emitter {
+	return &Emitter{
+		Results: []*stepResult{},
+		output:  output,
+		out:     out,
+	}
 }
 
 func (e *Emitter) SetStepNumber(n uint64) {
@@ -30,14 +99,154 @@ func (e *Emitter) GetStreamWriter(streamName string) velocity.StreamWriter {
 	return &StreamWriter{
 		StreamName: streamName,
 		StepNumber: e.StepNumber,
+		emitter:    e,
 	}
 }
 
-func (w *StreamWriter) Write(p []byte) (n int, err error) {
-	fmt.Printf("%s:    %s", w.StreamName, string(p))
-	if !strings.HasSuffix(string(p), "\r") {
-		fmt.Println()
+func (e *Emitter) writeJSON(v interface{}) {
+	b, _ := json.Marshal(v)
+	fmt.Fprintf(e.out, "%s\n", b)
+}
+
+// TaskStart writes the start of a task.
+func (e *Emitter) TaskStart(t *velocity.Task) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.task = t.Name
+	if e.output == OutputJSONL {
+		e.writeJSON(&outputStepEvent{Event: "task:start", Timestamp: time.Now().UTC(), Task: t.Name})
+		return
+	}
+	fmt.Fprintf(e.out, "Running task: %s\n", t.Name)
+}
+
+// TaskEnd writes the outcome of a task.
+func (e *Emitter) TaskEnd(err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.output != OutputJSONL {
+		return
+	}
+	ev := &outputStepEvent{Event: "task:end", Timestamp: time.Now().UTC(), Task: e.task, Status: velocity.StateSuccess}
+	if err != nil {
+		ev.Status = velocity.StateFailed
+		ev.Error = err.Error()
+	}
+	e.writeJSON(ev)
+}
+
+// StepStart sets the step that following output belongs to.
+func (e *Emitter) StepStart(n uint64, s velocity.Step) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.StepNumber = n
+	e.step = &stepResult{
+		Number:      n,
+		Type:        s.GetType(),
+		Description: s.GetDescription(),
+		Status:      velocity.StateRunning,
+		StartedAt:   time.Now().UTC(),
+	}
+	e.Results = append(e.Results, e.step)
+	if e.output == OutputJSONL {
+		e.writeJSON(&outputStepEvent{
+			Event:       "step:start",
+			Timestamp:   e.step.StartedAt,
+			Step:        n,
+			Type:        e.step.Type,
+			Description: e.step.Description,
+			Status:      e.step.Status,
+		})
+	}
+}
+
+// StepEnd records the outcome of the current step.
+func (e *Emitter) StepEnd(err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.step == nil {
+		return
+	}
+	e.step.CompletedAt = time.Now().UTC()
+	e.step.Status = velocity.StateSuccess
+	if err != nil {
+		e.step.Status = velocity.StateFailed
+		e.step.Error = err.Error()
+	}
+	if e.output == OutputJSONL {
+		e.writeJSON(&outputStepEvent{
+			Event:       "step:end",
+			Timestamp:   e.step.CompletedAt,
+			Step:        e.step.Number,
+			Type:        e.step.Type,
+			Description: e.step.Description,
+			Status:      e.step.Status,
+			Duration:    e.step.Duration().Seconds(),
+			Error:       e.step.Error,
+		})
+	}
+	e.step = nil
+}
+
+// StepSkipped records a step that wasn't run, because it wasn't selected or an earlier step
+// failed.
+func (e *Emitter) StepSkipped(n uint64, s velocity.Step) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	r := &stepResult{
+		Number:      n,
+		Type:        s.GetType(),
+		Description: s.GetDescription(),
+		Status:      velocity.StateWaiting,
 	}
+	e.Results = append(e.Results, r)
+	if e.output == OutputJSONL {
+		e.writeJSON(&outputStepEvent{
+			Event:       "step:skip",
+			Timestamp:   time.Now().UTC(),
+			Step:        n,
+			Type:        r.Type,
+			Description: r.Description,
+			Status:      r.Status,
+		})
+	}
+}
+
+func (e *Emitter) write(w *StreamWriter, p []byte) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	o := string(p)
+	if e.output != OutputPretty {
+		o = strings.TrimRight(stripANSI(o), "\r\n")
+	}
+	if e.step != nil {
+		e.step.output.WriteString(strings.TrimRight(stripANSI(string(p)), "\r\n"))
+		e.step.output.WriteString("\n")
+	}
+
+	switch e.output {
+	case OutputJSONL:
+		e.writeJSON(&outputLineEvent{
+			Event:     "line",
+			Timestamp: time.Now().UTC(),
+			Step:      w.StepNumber,
+			Stream:    w.StreamName,
+			Status:    w.status,
+			Output:    o,
+		})
+	case OutputPlain:
+		fmt.Fprintf(e.out, "%s:    %s\n", w.StreamName, o)
+	default:
+		fmt.Fprintf(e.out, "%s:    %s", w.StreamName, o)
+		if !strings.HasSuffix(o, "\r") {
+			fmt.Fprintln(e.out)
+		}
+	}
+}
+
+func (w *StreamWriter) Write(p []byte) (n int, err error) {
+	w.emitter.write(w, p)
 	return len(p), nil
 }
 
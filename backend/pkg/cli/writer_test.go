@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+func runTestSteps(e *Emitter) {
+	e.TaskStart(&velocity.Task{Name: "test"})
+
+	e.StepStart(0, velocity.NewSetup())
+	w := e.GetStreamWriter("setup")
+	w.SetStatus(velocity.StateRunning)
+	w.Write([]byte("\x1b[1m\x1b[49m\x1b[34m## setting up\x1b[0m"))
+	e.StepEnd(nil)
+
+	e.StepStart(1, &velocity.DockerRun{BaseStep: velocity.BaseStep{Type: "run", Description: "unit tests"}})
+	e.GetStreamWriter("run").Write([]byte("FAIL: TestSomething\n"))
+	err := fmt.Errorf("exited: 1")
+	e.StepEnd(err)
+	e.StepSkipped(2, &velocity.DockerRun{BaseStep: velocity.BaseStep{Type: "run", Description: "deploy"}})
+	e.TaskEnd(err)
+}
+
+func TestEmitterPlainOutput(t *testing.T) {
+	out := &bytes.Buffer{}
+	runTestSteps(NewEmitter(OutputPlain, out))
+
+	assert.Equal(t, "Running task: test\nsetup:    ## setting up\nrun:    FAIL: TestSomething\n", out.String())
+}
+
+func TestEmitterJSONLOutput(t *testing.T) {
+	out := &bytes.Buffer{}
+	runTestSteps(NewEmitter(OutputJSONL, out))
+
+	events := []map[string]interface{}{}
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		ev := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(l), &ev), l)
+		assert.NotEmpty(t, ev["timestamp"])
+		events = append(events, ev)
+	}
+
+	names := []string{}
+	for _, ev := range events {
+		names = append(names, ev["event"].(string))
+	}
+	assert.Equal(t, []string{"task:start", "step:start", "line", "step:end", "step:start", "line", "step:end", "step:skip", "task:end"}, names)
+
+	assert.Equal(t, "## setting up", events[2]["output"])
+	assert.Equal(t, velocity.StateRunning, events[2]["status"])
+	assert.Equal(t, "setup", events[2]["stream"])
+	assert.Equal(t, velocity.StateFailed, events[6]["status"])
+	assert.Equal(t, "exited: 1", events[6]["error"])
+	assert.Equal(t, float64(2), events[7]["step"])
+	assert.Equal(t, velocity.StateFailed, events[8]["status"])
+}
+
+func TestJUnitReport(t *testing.T) {
+	e := NewEmitter(OutputPlain, &bytes.Buffer{})
+	runTestSteps(e)
+
+	b, err := xml.Marshal(newJUnitReport("test", e.Results))
+	assert.Nil(t, err)
+
+	report := &junitTestSuites{}
+	assert.Nil(t, xml.Unmarshal(b, report))
+	suite := report.Suites[0]
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 1, suite.Skipped)
+
+	assert.Equal(t, "00 setup", suite.Cases[0].Name)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Equal(t, "01 run: unit tests", suite.Cases[1].Name)
+	assert.Equal(t, "exited: 1", suite.Cases[1].Failure.Message)
+	assert.Equal(t, "FAIL: TestSomething\n", suite.Cases[1].Failure.Output)
+	assert.NotNil(t, suite.Cases[2].Skipped)
+}
@@ -1,15 +1,18 @@
 package build
 
 import (
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
-	"github.com/asdine/storm"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
 )
 
 // Event constants
@@ -19,20 +22,48 @@ const (
 	EventBuildDelete = "build:delete"
 )
 
+// ReasonNoEligibleBuilder is why a build waits when none of the connected builders can run it.
+const ReasonNoEligibleBuilder = "no eligible builder"
+
+// ErrBuildRunning is returned when deleting a build that is still running.
+var ErrBuildRunning = errors.New("build is running")
+
+// ErrBuildInUse is returned when deleting a build that a dependent still relies on.
+var ErrBuildInUse = errors.New("build is used by a running pipeline")
+
+// Dependent is something that relies on builds, e.g. a running pipeline, which keeps them
+// from being deleted.
+type Dependent interface {
+	DependsOn(b *Build) bool
+}
+
+// BuildRepository stores builds.
+type BuildRepository interface {
+	Save(b *Build) error
+	Delete(b *Build) error
+	GetByID(id string) (*Build, error)
+	GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Build, int)
+	GetAllForCommit(c *githistory.Commit, q *domain.PagingQuery) ([]*Build, int)
+	GetAllForTask(t *task.Task, q *domain.PagingQuery) ([]*Build, int)
+	GetRunningBuilds() ([]*Build, int)
+	GetWaitingBuilds() ([]*Build, int)
+}
+
 type BuildManager struct {
-	db            *buildStormDB
+	db            BuildRepository
 	stepManager   *StepManager
 	streamManager *StreamManager
 	brokers       []domain.Broker
+	dependents    []Dependent
 }
 
 func NewBuildManager(
-	db *storm.DB,
+	db BuildRepository,
 	stepManager *StepManager,
 	streamManager *StreamManager,
 ) *BuildManager {
 	m := &BuildManager{
-		db:            newBuildStormDB(db),
+		db:            db,
 		stepManager:   stepManager,
 		streamManager: streamManager,
 		brokers:       []domain.Broker{},
@@ -44,21 +75,49 @@ func (m *BuildManager) AddBroker(b domain.Broker) {
 	m.brokers = append(m.brokers, b)
 }
 
+// AddDependent keeps the builds the given dependent relies on from being deleted.
+func (m *BuildManager) AddDependent(d Dependent) {
+	m.dependents = append(m.dependents, d)
+}
+
+func (m *BuildManager) inUse(b *Build) bool {
+	for _, d := range m.dependents {
+		if d.DependsOn(b) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *BuildManager) Create(
 	t *task.Task,
 	params map[string]string,
+) (*Build, *domain.ValidationErrors) {
+	return m.CreateWithPriority(t, params, 0)
+}
+
+// CreateWithPriority queues a build ahead of the waiting builds with a lower priority.
+func (m *BuildManager) CreateWithPriority(
+	t *task.Task,
+	params map[string]string,
+	priority int,
 ) (*Build, *domain.ValidationErrors) {
 	// TODO: implement validation
+	if err := checkSignaturePolicy(t); err != nil {
+		return nil, err
+	}
+
 	timestamp := time.Now().UTC()
 	b := &Build{
 		ID:         uuid.NewV3(uuid.NewV1(), t.ID).String(),
 		Task:       t,
 		Parameters: params,
+		Priority:   priority,
 		CreatedAt:  timestamp,
 		UpdatedAt:  timestamp,
 		Status:     velocity.StateWaiting,
 	}
-	m.db.save(b)
+	m.db.Save(b)
 
 	// steps := []*Step{}
 	for i, tS := range t.VTask.Steps {
@@ -82,8 +141,43 @@ func (m *BuildManager) Create(
 	return b, nil
 }
 
+// checkSignaturePolicy refuses builds of commits that don't satisfy the project's signature policy.
+func checkSignaturePolicy(t *task.Task) *domain.ValidationErrors {
+	if t.Commit == nil || t.Commit.Project == nil {
+		return nil
+	}
+	policy := t.Commit.Project.Signatures
+	if policy.Mode == velocity.SignaturePolicyOff {
+		return nil
+	}
+
+	v := t.Commit.Signature
+	if v.Status == velocity.SignatureVerified && !policy.IsTrusted(&v) {
+		// the key has been removed since the commit was verified
+		v.Status = velocity.SignatureUntrusted
+	}
+	if err := policy.Check(&v); err != nil {
+		return &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"commit": {err.Error()}},
+		}
+	}
+	if v.Status != velocity.SignatureVerified {
+		status := v.Status
+		if status == velocity.SignatureUnknown {
+			status = "unknown"
+		}
+		velocity.GetLogger().Warn("building commit without a trusted signature",
+			zap.String("project", t.Commit.Project.Slug),
+			zap.String("commit", t.Commit.Hash),
+			zap.String("signature", status),
+		)
+	}
+
+	return nil
+}
+
 func (m *BuildManager) Update(b *Build) error {
-	if err := m.db.save(b); err != nil {
+	if err := m.db.Save(b); err != nil {
 		return err
 	}
 	for _, br := range m.brokers {
@@ -96,16 +190,49 @@ func (m *BuildManager) Update(b *Build) error {
 	return nil
 }
 
+// Delete removes a build that isn't running along with its steps, streams and logs.
+func (m *BuildManager) Delete(b *Build) error {
+	if b.Status == velocity.StateRunning {
+		return ErrBuildRunning
+	}
+	if m.inUse(b) {
+		return ErrBuildInUse
+	}
+
+	for _, s := range m.stepManager.GetStepsForBuild(b) {
+		for _, stream := range m.streamManager.GetStreamsForStep(s) {
+			if err := m.streamManager.delete(stream); err != nil {
+				return err
+			}
+		}
+		if err := m.stepManager.delete(s); err != nil {
+			return err
+		}
+	}
+	if err := m.db.Delete(b); err != nil {
+		return err
+	}
+
+	for _, br := range m.brokers {
+		br.EmitAll(&domain.Emit{
+			Event:   EventBuildDelete,
+			Payload: b,
+		})
+	}
+
+	return nil
+}
+
 func (m *BuildManager) GetBuildByID(id string) (*Build, error) {
-	return GetBuildByID(m.db.DB, id)
+	return m.db.GetByID(id)
 }
 
 func (m *BuildManager) GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Build, int) {
-	return m.db.getAllForProject(p, q)
+	return m.db.GetAllForProject(p, q)
 }
 
 func (m *BuildManager) GetAllForCommit(c *githistory.Commit, q *domain.PagingQuery) ([]*Build, int) {
-	return m.db.getAllForCommit(c, q)
+	return m.db.GetAllForCommit(c, q)
 }
 
 // func (m *BuildManager) GetAllForBranch(b *githistory.Branch, q *domain.PagingQuery) ([]*Build, int) {
@@ -113,13 +240,50 @@ func (m *BuildManager) GetAllForCommit(c *githistory.Commit, q *domain.PagingQue
 // }
 
 func (m *BuildManager) GetAllForTask(t *task.Task, q *domain.PagingQuery) ([]*Build, int) {
-	return m.db.getAllForTask(t, q)
+	return m.db.GetAllForTask(t, q)
 }
 
 func (m *BuildManager) GetRunningBuilds() ([]*Build, int) {
-	return m.db.getRunningBuilds()
+	return m.db.GetRunningBuilds()
 }
 
 func (m *BuildManager) GetWaitingBuilds() ([]*Build, int) {
-	return m.db.getWaitingBuilds()
+	return m.db.GetWaitingBuilds()
+}
+
+// GetQueue returns the waiting builds in the order that they should be started. Builds
+// with a higher priority go first. Otherwise projects take turns, so that a project with
+// many waiting builds doesn't hold up the others, and each project's builds go oldest first.
+func (m *BuildManager) GetQueue() []*Build {
+	builds, _ := m.db.GetWaitingBuilds()
+	sort.SliceStable(builds, func(i, j int) bool {
+		if builds[i].Priority != builds[j].Priority {
+			return builds[i].Priority > builds[j].Priority
+		}
+		return builds[i].CreatedAt.Before(builds[j].CreatedAt)
+	})
+
+	// each build's turn is how many builds of its project are ahead of it at its priority
+	turns := map[string]int{}
+	turn := map[*Build]int{}
+	for _, b := range builds {
+		key := fmt.Sprintf("%s:%d", projectID(b), b.Priority)
+		turn[b] = turns[key]
+		turns[key]++
+	}
+	sort.SliceStable(builds, func(i, j int) bool {
+		if builds[i].Priority != builds[j].Priority {
+			return builds[i].Priority > builds[j].Priority
+		}
+		return turn[builds[i]] < turn[builds[j]]
+	})
+
+	return builds
+}
+
+func projectID(b *Build) string {
+	if b.Task == nil || b.Task.Commit == nil || b.Task.Commit.Project == nil {
+		return ""
+	}
+	return b.Task.Commit.Project.ID
 }
@@ -0,0 +1,132 @@
+package build
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type buildSQLiteDB struct {
+	*sql.DB
+	tasks task.Repository
+}
+
+// NewBuildSQLiteRepository returns a build repository backed by a migrated SQLite database.
+func NewBuildSQLiteRepository(db *sql.DB) BuildRepository {
+	return &buildSQLiteDB{
+		DB:    db,
+		tasks: task.NewSQLiteRepository(db),
+	}
+}
+
+const sqliteBuildColumns = `id, task_id, commit_id, project_id, parameters, status, created_at, updated_at, started_at, completed_at, priority, reason`
+
+func (db *buildSQLiteDB) Save(b *Build) error {
+	params, err := json.Marshal(b.Parameters)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO builds (`+sqliteBuildColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.ID, b.Task.ID, b.Task.Commit.ID, b.Task.Commit.Project.ID, params, b.Status,
+		b.CreatedAt, b.UpdatedAt, b.StartedAt, b.CompletedAt, b.Priority, b.Reason,
+	)
+	return err
+}
+
+func (db *buildSQLiteDB) Delete(b *Build) error {
+	_, err := db.Exec(`DELETE FROM builds WHERE id = ?`, b.ID)
+	return err
+}
+
+// find returns the builds selected by the query, loading their tasks once the rows have
+// been read.
+func (db *buildSQLiteDB) find(query string, args ...interface{}) (r []*Build) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	taskIDs := []string{}
+	for rows.Next() {
+		b := &Build{Parameters: map[string]string{}}
+		var taskID, commitID, projectID string
+		var params []byte
+		if err := rows.Scan(
+			&b.ID, &taskID, &commitID, &projectID, &params, &b.Status,
+			&b.CreatedAt, &b.UpdatedAt, &b.StartedAt, &b.CompletedAt, &b.Priority, &b.Reason,
+		); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		if err := json.Unmarshal(params, &b.Parameters); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+		}
+		r = append(r, b)
+		taskIDs = append(taskIDs, taskID)
+	}
+	rows.Close()
+
+	tasks := map[string]*task.Task{}
+	for i, b := range r {
+		t, ok := tasks[taskIDs[i]]
+		if !ok {
+			if t, err = db.tasks.GetByID(taskIDs[i]); err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+			tasks[taskIDs[i]] = t
+		}
+		b.Task = t
+	}
+
+	return r
+}
+
+// page returns a page of the builds matching the where clause, newest first, along with
+// how many builds match it.
+func (db *buildSQLiteDB) page(pQ *domain.PagingQuery, where string, args ...interface{}) (r []*Build, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM builds WHERE `+where, args...).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+	query := `SELECT ` + sqliteBuildColumns + ` FROM builds WHERE ` + where + ` ORDER BY created_at DESC`
+	if pQ != nil {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, pQ.Limit, (pQ.Page-1)*pQ.Limit)
+	}
+
+	return db.find(query, args...), t
+}
+
+func (db *buildSQLiteDB) GetByID(id string) (*Build, error) {
+	r := db.find(`SELECT `+sqliteBuildColumns+` FROM builds WHERE id = ?`, id)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *buildSQLiteDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) ([]*Build, int) {
+	return db.page(pQ, `project_id = ?`, p.ID)
+}
+
+func (db *buildSQLiteDB) GetAllForCommit(c *githistory.Commit, pQ *domain.PagingQuery) ([]*Build, int) {
+	return db.page(pQ, `commit_id = ?`, c.ID)
+}
+
+func (db *buildSQLiteDB) GetAllForTask(t *task.Task, pQ *domain.PagingQuery) ([]*Build, int) {
+	return db.page(pQ, `task_id = ?`, t.ID)
+}
+
+func (db *buildSQLiteDB) GetRunningBuilds() ([]*Build, int) {
+	return db.page(nil, `status = ?`, velocity.StateRunning)
+}
+
+func (db *buildSQLiteDB) GetWaitingBuilds() ([]*Build, int) {
+	return db.page(nil, `status = ?`, velocity.StateWaiting)
+}
@@ -23,7 +23,9 @@ type StormBuild struct {
 	CommitID    string `storm:"index"`
 	ProjectID   string `storm:"index"`
 	Parameters  []byte
+	Priority    int
 	Status      string
+	Reason      string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	StartedAt   time.Time
@@ -45,7 +47,9 @@ func (s *StormBuild) toBuild(db *storm.DB) *Build {
 		ID:          s.ID,
 		Task:        t,
 		Parameters:  params,
+		Priority:    s.Priority,
 		Status:      s.Status,
+		Reason:      s.Reason,
 		CreatedAt:   s.CreatedAt,
 		UpdatedAt:   s.UpdatedAt,
 		StartedAt:   s.StartedAt,
@@ -64,7 +68,9 @@ func (b *Build) toStormBuild() *StormBuild {
 		CommitID:    b.Task.Commit.ID,
 		ProjectID:   b.Task.Commit.Project.ID,
 		Parameters:  paramsJson,
+		Priority:    b.Priority,
 		Status:      b.Status,
+		Reason:      b.Reason,
 		CreatedAt:   b.CreatedAt,
 		UpdatedAt:   b.UpdatedAt,
 		StartedAt:   b.StartedAt,
@@ -76,12 +82,17 @@ type buildStormDB struct {
 	*storm.DB
 }
 
+// NewBuildStormRepository returns a build repository backed by storm.
+func NewBuildStormRepository(db *storm.DB) BuildRepository {
+	return newBuildStormDB(db)
+}
+
 func newBuildStormDB(db *storm.DB) *buildStormDB {
 	db.Init(&StormBuild{})
 	return &buildStormDB{db}
 }
 
-func (db *buildStormDB) save(b *Build) error {
+func (db *buildStormDB) Save(b *Build) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -95,7 +106,21 @@ func (db *buildStormDB) save(b *Build) error {
 	return tx.Commit()
 }
 
-func (db *buildStormDB) getAllForProject(p *project.Project, pQ *domain.PagingQuery) (r []*Build, t int) {
+func (db *buildStormDB) Delete(b *Build) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteStruct(b.toStormBuild()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *buildStormDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) (r []*Build, t int) {
 	t = 0
 	query := db.Select(q.Eq("ProjectID", p.ID)).OrderBy("CreatedAt").Reverse()
 	t, err := query.Count(&StormBuild{})
@@ -115,7 +140,7 @@ func (db *buildStormDB) getAllForProject(p *project.Project, pQ *domain.PagingQu
 	return r, t
 }
 
-func (db *buildStormDB) getAllForCommit(c *githistory.Commit, pQ *domain.PagingQuery) (r []*Build, t int) {
+func (db *buildStormDB) GetAllForCommit(c *githistory.Commit, pQ *domain.PagingQuery) (r []*Build, t int) {
 	t = 0
 	query := db.Select(q.Eq("CommitID", c.ID)).OrderBy("CreatedAt").Reverse()
 	t, err := query.Count(&StormBuild{})
@@ -135,7 +160,7 @@ func (db *buildStormDB) getAllForCommit(c *githistory.Commit, pQ *domain.PagingQ
 	return r, t
 }
 
-func (db *buildStormDB) getAllForTask(tsk *task.Task, pQ *domain.PagingQuery) (r []*Build, t int) {
+func (db *buildStormDB) GetAllForTask(tsk *task.Task, pQ *domain.PagingQuery) (r []*Build, t int) {
 	t = 0
 	query := db.Select(q.Eq("TaskID", tsk.ID)).OrderBy("CreatedAt").Reverse()
 	t, err := query.Count(&StormBuild{})
@@ -155,7 +180,7 @@ func (db *buildStormDB) getAllForTask(tsk *task.Task, pQ *domain.PagingQuery) (r
 	return r, t
 }
 
-func (db *buildStormDB) getRunningBuilds() (r []*Build, t int) {
+func (db *buildStormDB) GetRunningBuilds() (r []*Build, t int) {
 	t = 0
 	query := db.Select(q.Eq("Status", velocity.StateRunning)).OrderBy("CreatedAt").Reverse()
 	t, err := query.Count(&StormBuild{})
@@ -174,7 +199,7 @@ func (db *buildStormDB) getRunningBuilds() (r []*Build, t int) {
 	return r, t
 }
 
-func (db *buildStormDB) getWaitingBuilds() (r []*Build, t int) {
+func (db *buildStormDB) GetWaitingBuilds() (r []*Build, t int) {
 	t = 0
 	query := db.Select(q.Eq("Status", velocity.StateWaiting)).OrderBy("CreatedAt").Reverse()
 	t, err := query.Count(&StormBuild{})
@@ -193,6 +218,10 @@ func (db *buildStormDB) getWaitingBuilds() (r []*Build, t int) {
 	return r, t
 }
 
+func (db *buildStormDB) GetByID(id string) (*Build, error) {
+	return GetBuildByID(db.DB, id)
+}
+
 func GetBuildByID(db *storm.DB, id string) (*Build, error) {
 	var sB StormBuild
 	if err := db.One("ID", id, &sB); err != nil {
@@ -201,3 +230,26 @@ func GetBuildByID(db *storm.DB, id string) (*Build, error) {
 	}
 	return sB.toBuild(db), nil
 }
+
+// MigrateBuildsStorm copies the builds in a storm database into another repository,
+// skipping builds of tasks that no longer exist.
+func MigrateBuildsStorm(from *storm.DB, to BuildRepository) (int, error) {
+	var stormBuilds []*StormBuild
+	if err := from.All(&stormBuilds); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	n := 0
+	for _, sB := range stormBuilds {
+		b := sB.toBuild(from)
+		if b.Task == nil || b.Task.Commit == nil || b.Task.Commit.Project == nil {
+			velocity.GetLogger().Warn("skipping build without task", zap.String("buildID", b.ID))
+			continue
+		}
+		if err := to.Save(b); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
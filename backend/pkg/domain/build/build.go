@@ -11,10 +11,14 @@ type Build struct {
 	ID         string            `json:"id"`
 	Task       *task.Task        `json:"task"`
 	Parameters map[string]string `json:"parameters"`
+	// Priority puts the build ahead of waiting builds with a lower priority
+	Priority int `json:"priority"`
 
 	// Steps []*Step `json:"buildSteps"`
 
 	Status string `json:"status"`
+	// Reason explains why a waiting build hasn't been started
+	Reason string `json:"reason"`
 
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
@@ -21,6 +21,7 @@ type BuildSuite struct {
 	suite.Suite
 	storm          *storm.DB
 	dbPath         string
+	logsPath       string
 	projectManager *project.Manager
 	commitManager  *githistory.CommitManager
 	branchManager  *githistory.BranchManager
@@ -52,19 +53,24 @@ func (s *BuildSuite) SetupTest() {
 	if err != nil {
 		panic(err)
 	}
+	s.logsPath, err = ioutil.TempDir("", "velocity-logs")
+	if err != nil {
+		panic(err)
+	}
 
 	validator, translator := domain.NewValidator()
-	s.projectManager = project.NewManager(s.storm, validator, translator, syncMock)
-	s.commitManager = githistory.NewCommitManager(s.storm)
-	s.branchManager = githistory.NewBranchManager(s.storm)
-	s.taskManager = task.NewManager(s.storm, s.projectManager, s.branchManager, s.commitManager)
-	s.stepManager = build.NewStepManager(s.storm)
-	s.streamManager = build.NewStreamManager(s.storm)
+	s.projectManager = project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
+	s.branchManager = githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
+	s.taskManager = task.NewManager(task.NewStormRepository(s.storm), s.projectManager, s.branchManager, s.commitManager)
+	s.stepManager = build.NewStepManager(build.NewStepStormRepository(s.storm))
+	s.streamManager = build.NewStreamManager(build.NewStreamStormRepository(s.storm), build.NewFileLogStore(s.logsPath))
 }
 
 func (s *BuildSuite) TearDownTest() {
 	s.wg.Wait()
 	defer os.Remove(s.dbPath)
+	defer os.RemoveAll(s.logsPath)
 	s.storm.Close()
 }
 
@@ -80,7 +86,7 @@ func (s *BuildSuite) TestNewBuild() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	b, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -95,6 +101,46 @@ func (s *BuildSuite) TestNewBuild() {
 	s.Len(steps, len(tsk.VTask.Steps))
 }
 
+func (s *BuildSuite) TestNewBuildRequiresTrustedSignature() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
+		Address: "testGit",
+	})
+	trustedKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIFXfY5tqRQBPB6ZUBcw8YBAs2OkqIkeNwH7W8Vx4Ee4q velocity"
+	errs := s.projectManager.UpdateSignaturePolicy(p, velocity.SignaturePolicy{
+		Mode:        velocity.SignaturePolicyRequire,
+		TrustedKeys: []string{trustedKey},
+	})
+	s.Nil(errs)
+
+	br := s.branchManager.Create(p, "testBranch")
+	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	c.Signature = velocity.SignatureVerification{Status: velocity.SignatureUnsigned}
+	s.commitManager.Update(c)
+
+	tsk := s.taskManager.Create(c, &velocity.Task{
+		Name: "testTask",
+	}, velocity.NewSetup())
+
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
+	b, errs := m.Create(tsk, map[string]string{})
+	s.Nil(b)
+	s.NotNil(errs)
+	s.Contains(errs.ErrorMap, "commit")
+
+	// commits synchronised before signatures were verified have to be verified first
+	tsk.Commit.Signature = velocity.SignatureVerification{}
+	b, errs = m.Create(tsk, map[string]string{})
+	s.Nil(b)
+	s.NotNil(errs)
+	s.Contains(errs.ErrorMap["commit"][0], "synchronise the project")
+
+	fingerprints, _ := velocity.TrustedKeyFingerprints([]string{trustedKey})
+	tsk.Commit.Signature = velocity.SignatureVerification{Status: velocity.SignatureVerified, Fingerprint: fingerprints[0]}
+	b, errs = m.Create(tsk, map[string]string{})
+	s.Nil(errs)
+	s.NotNil(b)
+}
+
 func (s *BuildSuite) TestUpdateBuild() {
 	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
 		Address: "testGit",
@@ -107,7 +153,7 @@ func (s *BuildSuite) TestUpdateBuild() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	b, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -128,7 +174,7 @@ func (s *BuildSuite) TestGetBuildsForProject() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	b, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -153,7 +199,7 @@ func (s *BuildSuite) TestGetBuildsForCommit() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	b, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -178,7 +224,7 @@ func (s *BuildSuite) TestGetBuildsForTask() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	b, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -203,7 +249,7 @@ func (s *BuildSuite) TestGetRunningBuilds() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	b, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -230,7 +276,7 @@ func (s *BuildSuite) TestGetWaitingBuilds() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	_, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -255,7 +301,7 @@ func (s *BuildSuite) TestGetBuildByID() {
 		Name: "testTask",
 	}, velocity.NewSetup())
 
-	m := build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 	params := map[string]string{}
 	b, errs := m.Create(tsk, params)
 	s.Nil(errs)
@@ -264,3 +310,122 @@ func (s *BuildSuite) TestGetBuildByID() {
 	s.Nil(err)
 	s.Equal(b, rB)
 }
+
+func (s *BuildSuite) TestDeleteBuild() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
+		Address: "testGit",
+	})
+	br := s.branchManager.Create(p, "testBranch")
+	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	tsk := s.taskManager.Create(c, &velocity.Task{
+		Name: "testTask",
+	}, velocity.NewSetup())
+
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
+	b, _ := m.Create(tsk, map[string]string{})
+	stream := s.streamManager.GetStreamsForStep(s.stepManager.GetStepsForBuild(b)[0])[0]
+	s.streamManager.CreateStreamLine(stream, 1, time.Now().UTC(), "output")
+
+	b.Status = velocity.StateRunning
+	s.Equal(build.ErrBuildRunning, m.Delete(b))
+
+	b.Status = velocity.StateFailed
+	s.Nil(m.Delete(b))
+	_, err := m.GetBuildByID(b.ID)
+	s.NotNil(err)
+	s.Len(s.stepManager.GetStepsForBuild(b), 0)
+	_, err = s.streamManager.GetByID(stream.ID)
+	s.NotNil(err)
+	_, total := s.streamManager.GetStreamLines(stream, domain.NewPagingQuery())
+	s.Equal(0, total)
+}
+
+func (s *BuildSuite) TestPruneBuilds() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
+		Address: "testGit",
+	})
+	s.Nil(s.projectManager.UpdateRetentionPolicy(p, &project.RetentionPolicy{KeepBuilds: 1}))
+	br := s.branchManager.Create(p, "testBranch")
+	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	tsk := s.taskManager.Create(c, &velocity.Task{
+		Name: "testTask",
+	}, velocity.NewSetup())
+
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
+	start := time.Now().UTC().Add(-time.Hour)
+	builds := []*build.Build{}
+	for i, status := range []string{velocity.StateSuccess, velocity.StateFailed, velocity.StateFailed, velocity.StateWaiting} {
+		b, _ := m.Create(tsk, map[string]string{})
+		b.Status = status
+		b.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		m.Update(b)
+		builds = append(builds, b)
+	}
+
+	// the other project keeps everything
+	other, _ := s.projectManager.Create("otherProject", velocity.GitRepository{
+		Address: "otherGit",
+	})
+	otherBr := s.branchManager.Create(other, "testBranch")
+	otherC := s.commitManager.Create(otherBr, other, "123456", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	otherTsk := s.taskManager.Create(otherC, &velocity.Task{
+		Name: "testTask",
+	}, velocity.NewSetup())
+	otherB, _ := m.Create(otherTsk, map[string]string{})
+	otherB.Status = velocity.StateFailed
+	m.Update(otherB)
+
+	pruner := build.NewPruner(m, s.projectManager, s.branchManager, project.RetentionPolicy{}, &s.wg)
+	s.Equal(1, pruner.Prune(time.Now().UTC()))
+
+	// the latest successful build of the branch, the latest finished build and the waiting
+	// build are kept
+	for i, b := range builds {
+		_, err := m.GetBuildByID(b.ID)
+		if i == 1 {
+			s.NotNil(err)
+		} else {
+			s.Nil(err)
+		}
+	}
+	_, err := m.GetBuildByID(otherB.ID)
+	s.Nil(err)
+
+	s.Equal(0, pruner.Prune(time.Now().UTC()))
+}
+
+func (s *BuildSuite) TestGetQueue() {
+	m := build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
+	tasks := []*task.Task{}
+	for _, name := range []string{"projectA", "projectB"} {
+		p, _ := s.projectManager.Create(name, velocity.GitRepository{
+			Address: name,
+		})
+		br := s.branchManager.Create(p, "testBranch")
+		c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+		tasks = append(tasks, s.taskManager.Create(c, &velocity.Task{
+			Name: "testTask",
+		}, velocity.NewSetup()))
+	}
+
+	start := time.Now().UTC().Add(-time.Hour)
+	ids := []string{}
+	for i, t := range []*task.Task{tasks[0], tasks[0], tasks[0], tasks[1], tasks[1]} {
+		priority := 0
+		if i == 4 {
+			priority = 10
+		}
+		b, _ := m.CreateWithPriority(t, map[string]string{}, priority)
+		b.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		m.Update(b)
+		ids = append(ids, b.ID)
+	}
+
+	queue := m.GetQueue()
+	queueIDs := []string{}
+	for _, b := range queue {
+		queueIDs = append(queueIDs, b.ID)
+	}
+	// the priority build first, then the projects take turns
+	s.Equal([]string{ids[4], ids[0], ids[3], ids[1], ids[2]}, queueIDs)
+}
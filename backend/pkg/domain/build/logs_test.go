@@ -0,0 +1,94 @@
+package build_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+)
+
+func appendLines(t *testing.T, logs build.LogStore, streamID string, from, to int) {
+	for i := from; i <= to; i++ {
+		assert.Nil(t, logs.Append(&build.StreamLine{
+			StreamID:   streamID,
+			LineNumber: i,
+			Timestamp:  time.Now().UTC(),
+			Output:     fmt.Sprintf("line %d\n", i),
+		}))
+	}
+}
+
+func TestFileLogStoreCompressesSegmentsAndPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "velocity-logs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	logs := build.NewFileLogStore(dir)
+
+	appendLines(t, logs, "stream", 1, 2500)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "stream", "*"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "stream", "000000-1000.log.gz"),
+		filepath.Join(dir, "stream", "000001-1000.log.gz"),
+		filepath.Join(dir, "stream", "000002.log"),
+	}, files)
+
+	lines, total := logs.GetLines("stream", &domain.PagingQuery{Limit: 10, Page: 100})
+	assert.Equal(t, 2500, total)
+	assert.Len(t, lines, 10)
+	assert.Equal(t, 991, lines[0].LineNumber)
+	assert.Equal(t, 1000, lines[9].LineNumber)
+
+	lines, _ = logs.GetLines("stream", &domain.PagingQuery{Limit: 300, Page: 9})
+	assert.Len(t, lines, 100)
+	assert.Equal(t, "line 2401\n", lines[0].Output)
+
+	assert.Nil(t, logs.Close("stream"))
+	files, _ = filepath.Glob(filepath.Join(dir, "stream", "*"))
+	assert.Equal(t, filepath.Join(dir, "stream", "000002-500.log.gz"), files[2])
+
+	// late and repeated lines are merged into their compressed segment
+	assert.Nil(t, logs.Append(&build.StreamLine{StreamID: "stream", LineNumber: 5, Output: "again\n"}))
+	lines, total = logs.GetLines("stream", &domain.PagingQuery{Limit: 1, Page: 5})
+	assert.Equal(t, 2500, total)
+	assert.Equal(t, "again\n", lines[0].Output)
+
+	lines, total = logs.GetLines("missing", &domain.PagingQuery{Limit: 10, Page: 1})
+	assert.Equal(t, 0, total)
+	assert.Len(t, lines, 0)
+}
+
+func TestFileLogStoreKeepsLatestProgressLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "velocity-logs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	logs := build.NewFileLogStore(dir)
+
+	appendLines(t, logs, "stream", 1, 3)
+	for _, o := range []string{"10%\r", "50%\r"} {
+		assert.Nil(t, logs.Append(&build.StreamLine{StreamID: "stream", LineNumber: 2, Output: o}))
+	}
+
+	lines, total := logs.GetLines("stream", &domain.PagingQuery{Limit: 10, Page: 1})
+	assert.Equal(t, 4, total)
+	outputs := []string{}
+	for _, l := range lines {
+		outputs = append(outputs, l.Output)
+	}
+	assert.Equal(t, []string{"line 1\n", "line 2\n", "50%\r", "line 3\n"}, outputs)
+
+	lines, _ = logs.GetLines("stream", &domain.PagingQuery{Limit: 2, Page: 2})
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "50%\r", lines[0].Output)
+
+	assert.Nil(t, logs.Append(&build.StreamLine{StreamID: "progress", LineNumber: 0, Output: "pulling\r"}))
+	lines, total = logs.GetLines("progress", &domain.PagingQuery{Limit: 10, Page: 1})
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "pulling\r", lines[0].Output)
+}
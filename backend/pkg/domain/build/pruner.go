@@ -0,0 +1,157 @@
+package build
+
+import (
+	"sync"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+// pruneInterval is how often the retention policies are applied.
+const pruneInterval = time.Hour
+
+type pruner struct {
+	buildManager   *BuildManager
+	projectManager *project.Manager
+	branchManager  *githistory.BranchManager
+	policy         project.RetentionPolicy
+	stop           bool
+	wg             *sync.WaitGroup
+}
+
+// NewPruner returns a worker that deletes the builds that the retention policy of their
+// project, or otherwise the given policy, no longer keeps.
+func NewPruner(
+	buildManager *BuildManager,
+	projectManager *project.Manager,
+	branchManager *githistory.BranchManager,
+	policy project.RetentionPolicy,
+	wg *sync.WaitGroup,
+) *pruner {
+	return &pruner{
+		buildManager:   buildManager,
+		projectManager: projectManager,
+		branchManager:  branchManager,
+		policy:         policy,
+		stop:           false,
+		wg:             wg,
+	}
+}
+
+func (p *pruner) StartWorker() {
+	p.wg.Add(1)
+	velocity.GetLogger().Info("==> started build pruner")
+	var lastPruned time.Time
+	for p.stop == false {
+		if time.Since(lastPruned) >= pruneInterval {
+			if n := p.Prune(time.Now().UTC()); n > 0 {
+				velocity.GetLogger().Info("pruned builds", zap.Int("amount", n))
+			}
+			lastPruned = time.Now()
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	velocity.GetLogger().Info("==> stopped build pruner")
+	p.wg.Done()
+}
+
+func (p *pruner) StopWorker() {
+	p.stop = true
+}
+
+// Prune deletes the builds that are no longer kept as of the given time and returns how
+// many were deleted.
+func (p *pruner) Prune(now time.Time) int {
+	projects := []*project.Project{}
+	pQ := &domain.PagingQuery{Limit: 100, Page: 1}
+	for {
+		ps, total := p.projectManager.GetAll(pQ)
+		projects = append(projects, ps...)
+		if len(ps) < 1 || len(projects) >= total {
+			break
+		}
+		pQ.Page++
+	}
+
+	deleted := 0
+	for _, pr := range projects {
+		policy := p.policy
+		if pr.Retention != nil {
+			policy = *pr.Retention
+		}
+		if !policy.Prunes() {
+			continue
+		}
+		for _, b := range p.expiredBuilds(pr, &policy, now) {
+			if err := p.buildManager.Delete(b); err != nil {
+				velocity.GetLogger().Error("could not prune build", zap.String("buildID", b.ID), zap.Error(err))
+				continue
+			}
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// expiredBuilds returns the finished builds of a project that none of the rules of the
+// policy keep.
+func (p *pruner) expiredBuilds(pr *project.Project, policy *project.RetentionPolicy, now time.Time) (r []*Build) {
+	builds := []*Build{}
+	pQ := &domain.PagingQuery{Limit: 100, Page: 1}
+	for {
+		bs, total := p.buildManager.GetAllForProject(pr, pQ)
+		builds = append(builds, bs...)
+		if len(bs) < 1 || len(builds) >= total {
+			break
+		}
+		pQ.Page++
+	}
+
+	keepAfter := now.AddDate(0, 0, -policy.KeepDays)
+	// builds are newest first, so the first of each task and branch are the latest
+	taskBuilds := map[string]int{}
+	successfulBranches := map[string]bool{}
+	for _, b := range builds {
+		if b.Task == nil || b.Task.Commit == nil {
+			continue
+		}
+		if b.Status == velocity.StateWaiting || b.Status == velocity.StateRunning {
+			continue
+		}
+		// a pipeline that is still running needs the builds of its stages to advance
+		if p.buildManager.inUse(b) {
+			continue
+		}
+		taskBuilds[b.Task.Slug]++
+
+		keep := false
+		if policy.KeepBuilds > 0 && taskBuilds[b.Task.Slug] <= policy.KeepBuilds {
+			keep = true
+		}
+		if policy.KeepDays > 0 && b.CreatedAt.After(keepAfter) {
+			keep = true
+		}
+		if b.Status == velocity.StateSuccess {
+			branches, _ := p.branchManager.GetAllForCommit(b.Task.Commit, &domain.PagingQuery{Limit: 100, Page: 1})
+			for _, br := range branches {
+				if !successfulBranches[br.ID] {
+					successfulBranches[br.ID] = true
+					keep = true
+				}
+			}
+		}
+
+		if !keep {
+			r = append(r, b)
+		}
+	}
+
+	return r
+}
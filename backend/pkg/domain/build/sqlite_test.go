@@ -0,0 +1,266 @@
+package build_test
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/suite"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+type SQLiteSuite struct {
+	suite.Suite
+	db             *sql.DB
+	dir            string
+	logs           build.LogStore
+	projectManager *project.Manager
+	commitManager  *githistory.CommitManager
+	branchManager  *githistory.BranchManager
+	taskManager    *task.Manager
+	stepManager    *build.StepManager
+	streamManager  *build.StreamManager
+	buildManager   *build.BuildManager
+}
+
+func TestSQLiteSuite(t *testing.T) {
+	suite.Run(t, new(SQLiteSuite))
+}
+
+func (s *SQLiteSuite) SetupTest() {
+	os.Setenv("ENCRYPTION_KEY", "test")
+	var err error
+	s.dir, err = ioutil.TempDir("", "velocity-sqlite")
+	if err != nil {
+		panic(err)
+	}
+	s.db, err = domain.OpenSQLiteDB(s.dir + "/architect.sqlite")
+	if err != nil {
+		panic(err)
+	}
+
+	validator, translator := domain.NewValidator()
+	s.projectManager = project.NewManager(project.NewSQLiteRepository(s.db), validator, translator, syncMock)
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitSQLiteRepository(s.db))
+	s.branchManager = githistory.NewBranchManager(githistory.NewBranchSQLiteRepository(s.db))
+	s.taskManager = task.NewManager(task.NewSQLiteRepository(s.db), s.projectManager, s.branchManager, s.commitManager)
+	s.stepManager = build.NewStepManager(build.NewStepSQLiteRepository(s.db))
+	s.logs = build.NewFileLogStore(s.dir + "/logs")
+	s.streamManager = build.NewStreamManager(build.NewStreamSQLiteRepository(s.db), s.logs)
+	s.buildManager = build.NewBuildManager(build.NewBuildSQLiteRepository(s.db), s.stepManager, s.streamManager)
+}
+
+func (s *SQLiteSuite) TearDownTest() {
+	s.db.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *SQLiteSuite) TestGetAllForProjectPagesNewestFirst() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{Address: "testGit"})
+	other, _ := s.projectManager.Create("otherProject", velocity.GitRepository{Address: "otherGit"})
+
+	br := s.branchManager.Create(p, "master")
+	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	tsk := s.taskManager.Create(c, &velocity.Task{Name: "testTask"}, velocity.NewSetup())
+	otherBr := s.branchManager.Create(other, "master")
+	otherC := s.commitManager.Create(otherBr, other, "123456", "other commit", "me@velocityci.io", time.Now().UTC(), "")
+	otherTsk := s.taskManager.Create(otherC, &velocity.Task{Name: "testTask"}, velocity.NewSetup())
+
+	start := time.Now().UTC().Add(-time.Hour)
+	ids := []string{}
+	for i := 0; i < 3; i++ {
+		b, errs := s.buildManager.Create(tsk, map[string]string{"n": []string{"a", "b", "c"}[i]})
+		s.Nil(errs)
+		b.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		s.Nil(s.buildManager.Update(b))
+		ids = append(ids, b.ID)
+	}
+	_, errs := s.buildManager.Create(otherTsk, map[string]string{})
+	s.Nil(errs)
+
+	builds, total := s.buildManager.GetAllForProject(p, &domain.PagingQuery{Limit: 2, Page: 1})
+	s.Equal(3, total)
+	s.Len(builds, 2)
+	s.Equal(ids[2], builds[0].ID)
+	s.Equal(ids[1], builds[1].ID)
+	s.Equal(map[string]string{"n": "c"}, builds[0].Parameters)
+	s.Equal(tsk.ID, builds[0].Task.ID)
+	s.Equal(p.ID, builds[0].Task.Commit.Project.ID)
+
+	builds, total = s.buildManager.GetAllForProject(p, &domain.PagingQuery{Limit: 2, Page: 2})
+	s.Equal(3, total)
+	s.Len(builds, 1)
+	s.Equal(ids[0], builds[0].ID)
+
+	waiting, total := s.buildManager.GetWaitingBuilds()
+	s.Equal(4, total)
+	s.Len(waiting, 4)
+}
+
+func (s *SQLiteSuite) TestStepsAndStreamLines() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{Address: "testGit"})
+	br := s.branchManager.Create(p, "master")
+	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	tsk := s.taskManager.Create(c, &velocity.Task{Name: "testTask"}, velocity.NewSetup())
+	b, _ := s.buildManager.Create(tsk, map[string]string{})
+
+	steps := s.stepManager.GetStepsForBuild(b)
+	s.Len(steps, 1)
+	s.Equal(b.ID, steps[0].Build.ID)
+	s.Equal("setup", (*steps[0].VStep).GetType())
+
+	streams := s.streamManager.GetStreamsForStep(steps[0])
+	s.Len(streams, 1)
+	s.streamManager.CreateStreamLine(streams[0], 2, time.Now().UTC(), "second")
+	s.streamManager.CreateStreamLine(streams[0], 1, time.Now().UTC(), "first")
+
+	lines, total := s.streamManager.GetStreamLines(streams[0], &domain.PagingQuery{Limit: 10, Page: 1})
+	s.Equal(2, total)
+	s.Equal("first\n", lines[0].Output)
+	s.Equal("second\n", lines[1].Output)
+}
+
+func (s *SQLiteSuite) TestMigrateStorm() {
+	f, err := ioutil.TempFile("", "")
+	s.Nil(err)
+	f.Close()
+	os.Remove(f.Name())
+	defer os.Remove(f.Name())
+	stormDB, err := storm.Open(f.Name())
+	s.Nil(err)
+	defer stormDB.Close()
+
+	validator, translator := domain.NewValidator()
+	projectManager := project.NewManager(project.NewStormRepository(stormDB), validator, translator, syncMock)
+	commitManager := githistory.NewCommitManager(githistory.NewCommitStormRepository(stormDB))
+	branchManager := githistory.NewBranchManager(githistory.NewBranchStormRepository(stormDB))
+	taskManager := task.NewManager(task.NewStormRepository(stormDB), projectManager, branchManager, commitManager)
+	stepManager := build.NewStepManager(build.NewStepStormRepository(stormDB))
+	streamManager := build.NewStreamManager(build.NewStreamStormRepository(stormDB), s.logs)
+	buildManager := build.NewBuildManager(build.NewBuildStormRepository(stormDB), stepManager, streamManager)
+
+	p, _ := projectManager.Create("testProject", velocity.GitRepository{Address: "testGit", Password: "s3cret"})
+	br := branchManager.Create(p, "master")
+	c := commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	tsk := taskManager.Create(c, &velocity.Task{Name: "testTask"}, velocity.NewSetup())
+	b, _ := buildManager.Create(tsk, map[string]string{"VERSION": "1.0.0"})
+	stream := streamManager.GetStreamsForStep(stepManager.GetStepsForBuild(b)[0])[0]
+	// lines used to be kept in storm
+	s.Nil(stormDB.Save(&build.StormStreamLine{
+		ID:         stream.ID + ":1",
+		StreamID:   stream.ID,
+		LineNumber: 1,
+		Timestamp:  time.Now().UTC(),
+		Output:     "hello\n",
+	}))
+
+	migrations := []func() (int, error){
+		func() (int, error) { return project.MigrateStorm(stormDB, project.NewSQLiteRepository(s.db)) },
+		func() (int, error) {
+			return githistory.MigrateBranchesStorm(stormDB, githistory.NewBranchSQLiteRepository(s.db))
+		},
+		func() (int, error) {
+			return githistory.MigrateCommitsStorm(stormDB, githistory.NewCommitSQLiteRepository(s.db))
+		},
+		func() (int, error) { return task.MigrateStorm(stormDB, task.NewSQLiteRepository(s.db)) },
+		func() (int, error) { return build.MigrateBuildsStorm(stormDB, build.NewBuildSQLiteRepository(s.db)) },
+		func() (int, error) { return build.MigrateStepsStorm(stormDB, build.NewStepSQLiteRepository(s.db)) },
+		func() (int, error) { return build.MigrateStreamsStorm(stormDB, build.NewStreamSQLiteRepository(s.db)) },
+		func() (int, error) { return build.MigrateStreamLinesStorm(stormDB, s.logs) },
+	}
+	for _, migrate := range migrations {
+		n, err := migrate()
+		s.Nil(err)
+		s.Equal(1, n)
+	}
+
+	sP, err := s.projectManager.GetBySlug(p.Slug)
+	s.Nil(err)
+	s.Equal("s3cret", sP.Config.Password)
+	sBr, err := s.branchManager.GetByProjectAndName(sP, "master")
+	s.Nil(err)
+	sC, err := s.commitManager.GetByProjectAndHash(sP, "abcdef")
+	s.Nil(err)
+	s.True(s.branchManager.HasCommit(sBr, sC))
+
+	builds, total := s.buildManager.GetAllForProject(sP, &domain.PagingQuery{Limit: 10, Page: 1})
+	s.Equal(1, total)
+	s.Equal(b.ID, builds[0].ID)
+	s.Equal(map[string]string{"VERSION": "1.0.0"}, builds[0].Parameters)
+	s.Equal("testTask", builds[0].Task.VTask.Name)
+
+	sStream, err := s.streamManager.GetByID(stream.ID)
+	s.Nil(err)
+	lines, total := s.streamManager.GetStreamLines(sStream, &domain.PagingQuery{Limit: 10, Page: 1})
+	s.Equal(1, total)
+	s.Equal("hello\n", lines[0].Output)
+
+	// moved lines are removed from storm
+	n, err := build.MigrateStreamLinesStorm(stormDB, s.logs)
+	s.Nil(err)
+	s.Equal(0, n)
+
+	// migrations that have already been applied are skipped
+	s.Nil(domain.MigrateSQLiteDB(s.db))
+}
+
+// recordingLogStore keeps the lines appended to it in order.
+type recordingLogStore struct {
+	build.LogStore
+	appended []*build.StreamLine
+	closed   []string
+}
+
+func (s *recordingLogStore) Append(l *build.StreamLine) error {
+	s.appended = append(s.appended, l)
+	return nil
+}
+
+func (s *recordingLogStore) Close(streamID string) error {
+	s.closed = append(s.closed, streamID)
+	return nil
+}
+
+func (s *SQLiteSuite) TestMigrateStreamLinesStormInOrder() {
+	f, err := ioutil.TempFile("", "")
+	s.Nil(err)
+	f.Close()
+	os.Remove(f.Name())
+	defer os.Remove(f.Name())
+	stormDB, err := storm.Open(f.Name())
+	s.Nil(err)
+	defer stormDB.Close()
+
+	// storm orders lines by ID, which puts e.g. line 10 before line 2
+	streamIDs := []string{"a4d5b1c0-stream", "f1e2d3c4-stream"}
+	for _, streamID := range streamIDs {
+		for i := 1; i <= 12; i++ {
+			s.Nil(stormDB.Save(&build.StormStreamLine{
+				ID:         fmt.Sprintf("%s:%d", streamID, i),
+				StreamID:   streamID,
+				LineNumber: i,
+				Timestamp:  time.Now().UTC(),
+				Output:     fmt.Sprintf("line %d\n", i),
+			}))
+		}
+	}
+
+	logs := &recordingLogStore{}
+	n, err := build.MigrateStreamLinesStorm(stormDB, logs)
+	s.Nil(err)
+	s.Equal(24, n)
+	s.Equal(streamIDs, logs.closed)
+	for i, l := range logs.appended {
+		s.Equal(streamIDs[i/12], l.StreamID)
+		s.Equal(i%12+1, l.LineNumber)
+	}
+}
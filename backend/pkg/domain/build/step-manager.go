@@ -3,7 +3,6 @@ package build
 import (
 	"time"
 
-	"github.com/asdine/storm"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
@@ -14,16 +13,24 @@ const (
 	EventStepUpdate = "step:update"
 )
 
+// StepRepository stores the steps of builds.
+type StepRepository interface {
+	Save(s *Step) error
+	Delete(s *Step) error
+	GetByID(id string) (*Step, error)
+	GetAllForBuild(b *Build) []*Step
+}
+
 type StepManager struct {
-	db      *stepStormDB
+	db      StepRepository
 	brokers []domain.Broker
 }
 
 func NewStepManager(
-	db *storm.DB,
+	db StepRepository,
 ) *StepManager {
 	m := &StepManager{
-		db:      newStepStormDB(db),
+		db:      db,
 		brokers: []domain.Broker{},
 	}
 	return m
@@ -47,16 +54,20 @@ func (m *StepManager) create(
 		UpdatedAt: time.Now().UTC(),
 		// Streams:   []*Stream{},
 	}
-	m.db.save(s)
+	m.db.Save(s)
 	return s
 }
 
+func (m *StepManager) delete(s *Step) error {
+	return m.db.Delete(s)
+}
+
 func (m *StepManager) GetStepsForBuild(b *Build) []*Step {
-	return getStepsByBuildID(m.db.DB, b.ID)
+	return m.db.GetAllForBuild(b)
 }
 
 func (m *StepManager) Update(s *Step) error {
-	if err := m.db.save(s); err != nil {
+	if err := m.db.Save(s); err != nil {
 		return err
 	}
 	for _, b := range m.brokers {
@@ -70,5 +81,5 @@ func (m *StepManager) Update(s *Step) error {
 }
 
 func (m *StepManager) GetByID(id string) (*Step, error) {
-	return GetStepByID(m.db.DB, id)
+	return m.db.GetByID(id)
 }
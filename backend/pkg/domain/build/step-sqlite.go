@@ -0,0 +1,90 @@
+package build
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type stepSQLiteDB struct {
+	*sql.DB
+	builds BuildRepository
+}
+
+// NewStepSQLiteRepository returns a step repository backed by a migrated SQLite database.
+func NewStepSQLiteRepository(db *sql.DB) StepRepository {
+	return &stepSQLiteDB{
+		DB:     db,
+		builds: NewBuildSQLiteRepository(db),
+	}
+}
+
+const sqliteStepColumns = `id, build_id, number, vstep, status, updated_at, started_at, completed_at`
+
+func (db *stepSQLiteDB) Save(s *Step) error {
+	vStep, err := json.Marshal(s.VStep)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO steps (`+sqliteStepColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, s.Build.ID, s.Number, vStep, s.Status, s.UpdatedAt, s.StartedAt, s.CompletedAt,
+	)
+	return err
+}
+
+func (db *stepSQLiteDB) Delete(s *Step) error {
+	_, err := db.Exec(`DELETE FROM steps WHERE id = ?`, s.ID)
+	return err
+}
+
+// find returns the steps selected by the query, loading their build once the rows have
+// been read.
+func (db *stepSQLiteDB) find(query string, args ...interface{}) (r []*Step) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	buildIDs := []string{}
+	for rows.Next() {
+		s := &Step{}
+		var buildID string
+		var vStep []byte
+		if err := rows.Scan(&s.ID, &buildID, &s.Number, &vStep, &s.Status, &s.UpdatedAt, &s.StartedAt, &s.CompletedAt); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		s.VStep = unmarshalVStep(vStep)
+		r = append(r, s)
+		buildIDs = append(buildIDs, buildID)
+	}
+	rows.Close()
+
+	builds := map[string]*Build{}
+	for i, s := range r {
+		b, ok := builds[buildIDs[i]]
+		if !ok {
+			if b, err = db.builds.GetByID(buildIDs[i]); err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+			builds[buildIDs[i]] = b
+		}
+		s.Build = b
+	}
+
+	return r
+}
+
+func (db *stepSQLiteDB) GetByID(id string) (*Step, error) {
+	r := db.find(`SELECT `+sqliteStepColumns+` FROM steps WHERE id = ?`, id)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *stepSQLiteDB) GetAllForBuild(b *Build) []*Step {
+	return db.find(`SELECT `+sqliteStepColumns+` FROM steps WHERE build_id = ? ORDER BY number`, b.ID)
+}
@@ -23,18 +23,6 @@ type StormStep struct {
 }
 
 func (s *StormStep) toStep(db *storm.DB) *Step {
-	var gStep map[string]interface{}
-	err := json.Unmarshal(s.VStep, &gStep)
-	if err != nil {
-		velocity.GetLogger().Error("error", zap.Error(err))
-	}
-
-	vStep, err := velocity.DetermineStepFromInterface(gStep)
-	if err != nil {
-		velocity.GetLogger().Error("error", zap.Error(err))
-	} else {
-		json.Unmarshal(s.VStep, vStep)
-	}
 	b, err := GetBuildByID(db, s.BuildID)
 	if err != nil {
 		velocity.GetLogger().Error("error", zap.Error(err))
@@ -47,7 +35,7 @@ func (s *StormStep) toStep(db *storm.DB) *Step {
 		UpdatedAt:   s.UpdatedAt,
 		StartedAt:   s.StartedAt,
 		CompletedAt: s.CompletedAt,
-		VStep:       &vStep,
+		VStep:       unmarshalVStep(s.VStep),
 	}
 }
 
@@ -72,12 +60,17 @@ type stepStormDB struct {
 	*storm.DB
 }
 
+// NewStepStormRepository returns a step repository backed by storm.
+func NewStepStormRepository(db *storm.DB) StepRepository {
+	return newStepStormDB(db)
+}
+
 func newStepStormDB(db *storm.DB) *stepStormDB {
 	db.Init(&StormStep{})
 	return &stepStormDB{db}
 }
 
-func (db *stepStormDB) save(s *Step) error {
+func (db *stepStormDB) Save(s *Step) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -91,6 +84,28 @@ func (db *stepStormDB) save(s *Step) error {
 	return tx.Commit()
 }
 
+func (db *stepStormDB) Delete(s *Step) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteStruct(s.toStormStep()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *stepStormDB) GetByID(id string) (*Step, error) {
+	return GetStepByID(db.DB, id)
+}
+
+func (db *stepStormDB) GetAllForBuild(b *Build) []*Step {
+	return getStepsByBuildID(db.DB, b.ID)
+}
+
 func GetStepByID(db *storm.DB, id string) (*Step, error) {
 	var sS StormStep
 	if err := db.One("ID", id, &sS); err != nil {
@@ -111,3 +126,26 @@ func getStepsByBuildID(db *storm.DB, buildID string) (r []*Step) {
 
 	return r
 }
+
+// MigrateStepsStorm copies the build steps in a storm database into another repository,
+// skipping steps of builds that no longer exist.
+func MigrateStepsStorm(from *storm.DB, to StepRepository) (int, error) {
+	var stormSteps []*StormStep
+	if err := from.All(&stormSteps); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	n := 0
+	for _, sS := range stormSteps {
+		s := sS.toStep(from)
+		if s.Build == nil {
+			velocity.GetLogger().Warn("skipping step without build", zap.String("stepID", s.ID))
+			continue
+		}
+		if err := to.Save(s); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -73,3 +73,18 @@ func (s *Step) UnmarshalJSON(b []byte) error {
 
 	return nil
 }
+
+// unmarshalVStep decodes a stored velocity step into the step type it was saved as.
+func unmarshalVStep(b []byte) *velocity.Step {
+	var gStep map[string]interface{}
+	if err := json.Unmarshal(b, &gStep); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	vStep, err := velocity.DetermineStepFromInterface(gStep)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	} else {
+		json.Unmarshal(b, vStep)
+	}
+	return &vStep
+}
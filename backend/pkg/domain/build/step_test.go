@@ -21,6 +21,7 @@ type StepSuite struct {
 	suite.Suite
 	storm          *storm.DB
 	dbPath         string
+	logsPath       string
 	projectManager *project.Manager
 	commitManager  *githistory.CommitManager
 	branchManager  *githistory.BranchManager
@@ -49,19 +50,24 @@ func (s *StepSuite) SetupTest() {
 	if err != nil {
 		panic(err)
 	}
+	s.logsPath, err = ioutil.TempDir("", "velocity-logs")
+	if err != nil {
+		panic(err)
+	}
 
 	validator, translator := domain.NewValidator()
-	s.projectManager = project.NewManager(s.storm, validator, translator, syncMock)
-	s.commitManager = githistory.NewCommitManager(s.storm)
-	s.branchManager = githistory.NewBranchManager(s.storm)
-	s.taskManager = task.NewManager(s.storm, s.projectManager, s.branchManager, s.commitManager)
-	s.stepManager = build.NewStepManager(s.storm)
-	s.streamManager = build.NewStreamManager(s.storm)
-	s.buildManager = build.NewBuildManager(s.storm, s.stepManager, s.streamManager)
+	s.projectManager = project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
+	s.branchManager = githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
+	s.taskManager = task.NewManager(task.NewStormRepository(s.storm), s.projectManager, s.branchManager, s.commitManager)
+	s.stepManager = build.NewStepManager(build.NewStepStormRepository(s.storm))
+	s.streamManager = build.NewStreamManager(build.NewStreamStormRepository(s.storm), build.NewFileLogStore(s.logsPath))
+	s.buildManager = build.NewBuildManager(build.NewBuildStormRepository(s.storm), s.stepManager, s.streamManager)
 }
 
 func (s *StepSuite) TearDownTest() {
 	defer os.Remove(s.dbPath)
+	defer os.RemoveAll(s.logsPath)
 	s.wg.Wait()
 	s.storm.Close()
 }
@@ -0,0 +1,430 @@
+package build
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+// LogStore keeps the output lines of streams out of the database.
+type LogStore interface {
+	// Append stores a line, replacing any line with the same number. Lines ending in "\r"
+	// are progress lines, of which only the latest is kept.
+	Append(l *StreamLine) error
+	// GetLines returns a page of the lines of a stream in line number order and the total
+	// number of lines.
+	GetLines(streamID string, q *domain.PagingQuery) ([]*StreamLine, int)
+	// Close compresses what is left of a stream once it has finished.
+	Close(streamID string) error
+	// Delete removes all of the lines of a stream.
+	Delete(streamID string) error
+}
+
+// logSegmentLines is how many line numbers each segment file covers.
+const logSegmentLines = 1000
+
+const logProgressFile = "progress.json"
+
+// fileLogStore keeps each stream in its own directory of segment files. The segment a
+// stream is being written to holds a JSON line per stream line, and is gzipped, with its
+// line count in its name, once the stream moves on to the next segment or finishes. This
+// lets a page be found without reading the segments before it.
+type fileLogStore struct {
+	dir string
+
+	mu sync.Mutex
+	// open is the segment that each stream was last appended to
+	open map[string]int
+}
+
+// NewFileLogStore returns a log store that keeps its files in the given directory.
+func NewFileLogStore(dir string) LogStore {
+	os.MkdirAll(dir, os.ModePerm)
+	return &fileLogStore{
+		dir:  dir,
+		open: map[string]int{},
+	}
+}
+
+type logLine struct {
+	LineNumber int       `json:"n"`
+	Timestamp  time.Time `json:"t"`
+	Output     string    `json:"o"`
+}
+
+func (l *logLine) toStreamLine(streamID string) *StreamLine {
+	return &StreamLine{
+		StreamID:   streamID,
+		LineNumber: l.LineNumber,
+		Timestamp:  l.Timestamp,
+		Output:     l.Output,
+	}
+}
+
+type logSegment struct {
+	number     int
+	compressed bool
+	// lines is only known without reading the segment when it is compressed
+	lines int
+}
+
+func (s *logSegment) name() string {
+	if s.compressed {
+		return fmt.Sprintf("%06d-%d.log.gz", s.number, s.lines)
+	}
+	return fmt.Sprintf("%06d.log", s.number)
+}
+
+func segmentNumber(lineNumber int) int {
+	if lineNumber < 1 {
+		return 0
+	}
+	return (lineNumber - 1) / logSegmentLines
+}
+
+func (s *fileLogStore) streamDir(streamID string) string {
+	return filepath.Join(s.dir, filepath.Base(streamID))
+}
+
+func (s *fileLogStore) Append(l *StreamLine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.streamDir(l.StreamID)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	line := &logLine{
+		LineNumber: l.LineNumber,
+		Timestamp:  l.Timestamp,
+		Output:     l.Output,
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(l.Output, "\r") {
+		return writeFileAtomic(filepath.Join(dir, logProgressFile), b)
+	}
+
+	number := segmentNumber(l.LineNumber)
+	prev, ok := s.open[l.StreamID]
+	if !ok || prev != number {
+		// lines almost always arrive in order, so only a segment that isn't the one being
+		// written to can have been compressed already
+		segments, err := listSegments(dir)
+		if err != nil {
+			return err
+		}
+		for _, seg := range segments {
+			if seg.number == number && seg.compressed {
+				lines, err := readSegment(dir, seg)
+				if err != nil {
+					return err
+				}
+				rewritten, err := writeSegment(dir, number, append(lines, line))
+				if err != nil || rewritten == seg.name() {
+					return err
+				}
+				return os.Remove(filepath.Join(dir, seg.name()))
+			}
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, (&logSegment{number: number}).name()), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	s.open[l.StreamID] = number
+	if ok && prev < number {
+		return compressSegment(dir, prev)
+	}
+	return nil
+}
+
+func (s *fileLogStore) GetLines(streamID string, pQ *domain.PagingQuery) (r []*StreamLine, t int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.streamDir(streamID)
+	segments, err := listSegments(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			velocity.GetLogger().Error("error", zap.Error(err))
+		}
+		return r, 0
+	}
+	progress, err := readProgress(dir)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+
+	// the progress line follows the line it was written after, in that line's segment
+	progressSegment := -1
+	progressOnly := false
+	if progress != nil {
+		progressSegment = segmentNumber(progress.LineNumber)
+		i := sort.Search(len(segments), func(i int) bool { return segments[i].number >= progressSegment })
+		if i == len(segments) || segments[i].number != progressSegment {
+			progressOnly = true
+			segments = append(segments[:i], append([]*logSegment{{number: progressSegment, compressed: true}}, segments[i:]...)...)
+		}
+	}
+
+	// open segments are read to count them, so they're kept for the page
+	read := map[int][]*logLine{}
+	for _, seg := range segments {
+		if !seg.compressed {
+			lines, err := readSegment(dir, seg)
+			if err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+			read[seg.number] = lines
+			seg.lines = len(lines)
+		}
+		if seg.number == progressSegment {
+			seg.lines++
+		}
+		t += seg.lines
+	}
+
+	offset := (pQ.Page - 1) * pQ.Limit
+	for _, seg := range segments {
+		if len(r) >= pQ.Limit {
+			break
+		}
+		if offset >= seg.lines {
+			offset -= seg.lines
+			continue
+		}
+		lines, ok := read[seg.number]
+		if !ok && !(progressOnly && seg.number == progressSegment) {
+			if lines, err = readSegment(dir, seg); err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+		}
+		if seg.number == progressSegment {
+			i := sort.Search(len(lines), func(i int) bool { return lines[i].LineNumber > progress.LineNumber })
+			lines = append(lines[:i:i], append([]*logLine{progress}, lines[i:]...)...)
+		}
+		for _, l := range lines[offset:] {
+			if len(r) >= pQ.Limit {
+				break
+			}
+			r = append(r, l.toStreamLine(streamID))
+		}
+		offset = 0
+	}
+
+	return r, t
+}
+
+func (s *fileLogStore) Close(streamID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.open, streamID)
+	dir := s.streamDir(streamID)
+	segments, err := listSegments(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, seg := range segments {
+		if !seg.compressed {
+			if err := compressSegment(dir, seg.number); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fileLogStore) Delete(streamID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.open, streamID)
+	return os.RemoveAll(s.streamDir(streamID))
+}
+
+// listSegments returns the segments of a stream in order.
+func listSegments(dir string) ([]*logSegment, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byNumber := map[int]*logSegment{}
+	for _, f := range files {
+		seg := &logSegment{}
+		if strings.HasSuffix(f.Name(), ".log.gz") {
+			if _, err := fmt.Sscanf(f.Name(), "%d-%d.log.gz", &seg.number, &seg.lines); err != nil {
+				continue
+			}
+			seg.compressed = true
+		} else if strings.HasSuffix(f.Name(), ".log") {
+			if _, err := fmt.Sscanf(f.Name(), "%d.log", &seg.number); err != nil {
+				continue
+			}
+		} else {
+			continue
+		}
+		// a compressed segment has everything its open segment had, the open segment is
+		// only left behind if the architect stopped while compressing
+		if existing, ok := byNumber[seg.number]; ok && existing.compressed {
+			continue
+		}
+		byNumber[seg.number] = seg
+	}
+
+	segments := []*logSegment{}
+	for _, seg := range byNumber {
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].number < segments[j].number })
+	return segments, nil
+}
+
+// readSegment returns the lines of a segment in order, keeping the last of any lines
+// that were written more than once.
+func readSegment(dir string, seg *logSegment) ([]*logLine, error) {
+	f, err := os.Open(filepath.Join(dir, seg.name()))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if seg.compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	byNumber := map[int]*logLine{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		l := &logLine{}
+		if err := json.Unmarshal(scanner.Bytes(), l); err != nil {
+			// the last line can be cut short if the architect stopped while writing it
+			continue
+		}
+		byNumber[l.LineNumber] = l
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	lines := make([]*logLine, 0, len(byNumber))
+	for _, l := range byNumber {
+		lines = append(lines, l)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].LineNumber < lines[j].LineNumber })
+	return lines, nil
+}
+
+// compressSegment replaces an open segment with a compressed one.
+func compressSegment(dir string, number int) error {
+	seg := &logSegment{number: number}
+	lines, err := readSegment(dir, seg)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if _, err := writeSegment(dir, number, lines); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, seg.name()))
+}
+
+// writeSegment writes the given lines to a compressed segment and returns its name.
+func writeSegment(dir string, number int, lines []*logLine) (string, error) {
+	byNumber := map[int]*logLine{}
+	for _, l := range lines {
+		byNumber[l.LineNumber] = l
+	}
+	seg := &logSegment{number: number, compressed: true, lines: len(byNumber)}
+
+	tmp, err := ioutil.TempFile(dir, "segment")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	gz := gzip.NewWriter(tmp)
+	encoder := json.NewEncoder(gz)
+	for _, l := range lines {
+		if byNumber[l.LineNumber] != l {
+			continue
+		}
+		if err := encoder.Encode(l); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return seg.name(), os.Rename(tmp.Name(), filepath.Join(dir, seg.name()))
+}
+
+func readProgress(dir string) (*logLine, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, logProgressFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	l := &logLine{}
+	return l, json.Unmarshal(b, l)
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
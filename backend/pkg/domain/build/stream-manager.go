@@ -5,7 +5,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/asdine/storm"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
@@ -16,17 +15,28 @@ const (
 	EventStreamLineCreate = "streamLine:new"
 )
 
+// StreamRepository stores the output streams of steps. Their lines are kept in a LogStore.
+type StreamRepository interface {
+	Save(s *Stream) error
+	Delete(s *Stream) error
+	GetByID(id string) (*Stream, error)
+	GetAllForStep(s *Step) []*Stream
+}
+
 type StreamManager struct {
-	db *streamStormDB
+	db   StreamRepository
+	logs LogStore
 
 	brokers []domain.Broker
 }
 
 func NewStreamManager(
-	db *storm.DB,
+	db StreamRepository,
+	logs LogStore,
 ) *StreamManager {
 	m := &StreamManager{
-		db:      newStreamStormDB(db),
+		db:      db,
+		logs:    logs,
 		brokers: []domain.Broker{},
 	}
 	return m
@@ -46,16 +56,24 @@ func (m *StreamManager) create(
 		Name: name,
 	}
 
-	m.db.save(stream)
+	m.db.Save(stream)
 	return stream
 }
 
 func (m *StreamManager) update(s *Stream) error {
-	return m.db.save(s)
+	return m.db.Save(s)
+}
+
+// delete removes the stream along with its lines.
+func (m *StreamManager) delete(s *Stream) error {
+	if err := m.logs.Delete(s.ID); err != nil {
+		return err
+	}
+	return m.db.Delete(s)
 }
 
 func (m *StreamManager) GetByID(id string) (*Stream, error) {
-	return GetStreamByID(m.db.DB, id)
+	return m.db.GetByID(id)
 }
 
 func (m *StreamManager) CreateStreamLine(
@@ -72,7 +90,14 @@ func (m *StreamManager) CreateStreamLine(
 	}
 	if !strings.HasSuffix(sL.Output, "\r") {
 		sL.Output = fmt.Sprintf("%s\n", strings.TrimSpace(sL.Output))
-		m.db.saveStreamLine(sL)
+	}
+	if err := m.logs.Append(sL); err != nil {
+		velocity.GetLogger().Error("could not append stream line", zap.String("streamID", stream.ID), zap.Error(err))
+	}
+	if stream.Status == velocity.StateSuccess || stream.Status == velocity.StateFailed {
+		if err := m.logs.Close(stream.ID); err != nil {
+			velocity.GetLogger().Error("could not close stream logs", zap.String("streamID", stream.ID), zap.Error(err))
+		}
 	}
 	for _, b := range m.brokers {
 		b.EmitAll(&domain.Emit{
@@ -85,15 +110,15 @@ func (m *StreamManager) CreateStreamLine(
 }
 
 func (m *StreamManager) GetStreamsForStep(s *Step) []*Stream {
-	return getStreamsByStepID(m.db.DB, s.ID)
+	return m.db.GetAllForStep(s)
 }
 
 func (m *StreamManager) GetStreamLines(s *Stream, q *domain.PagingQuery) ([]*StreamLine, int) {
-	return m.db.getLinesByStream(s, q)
+	return m.logs.GetLines(s.ID, q)
 }
 
 func (m *StreamManager) Update(stream *Stream) error {
-	if err := m.db.save(stream); err != nil {
+	if err := m.db.Save(stream); err != nil {
 		return err
 	}
 	// for _, b := range m.brokers {
@@ -105,12 +130,3 @@ func (m *StreamManager) Update(stream *Stream) error {
 
 	return nil
 }
-
-func GetStreamByID(db *storm.DB, id string) (*Stream, error) {
-	var sS StormStream
-	if err := db.One("ID", id, &sS); err != nil {
-		velocity.GetLogger().Error("error", zap.Error(err))
-		return nil, err
-	}
-	return sS.toStream(db), nil
-}
@@ -0,0 +1,127 @@
+package build
+
+import (
+	"database/sql"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type streamSQLiteDB struct {
+	*sql.DB
+	steps StepRepository
+}
+
+// NewStreamSQLiteRepository returns a stream repository backed by a migrated SQLite database.
+func NewStreamSQLiteRepository(db *sql.DB) StreamRepository {
+	return &streamSQLiteDB{
+		DB:    db,
+		steps: NewStepSQLiteRepository(db),
+	}
+}
+
+const sqliteStreamColumns = `id, step_id, name, status`
+
+func (db *streamSQLiteDB) Save(s *Stream) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO streams (`+sqliteStreamColumns+`) VALUES (?, ?, ?, ?)`,
+		s.ID, s.Step.ID, s.Name, s.Status,
+	)
+	return err
+}
+
+func (db *streamSQLiteDB) Delete(s *Stream) error {
+	_, err := db.Exec(`DELETE FROM streams WHERE id = ?`, s.ID)
+	return err
+}
+
+// find returns the streams selected by the query, loading their step once the rows have
+// been read.
+func (db *streamSQLiteDB) find(query string, args ...interface{}) (r []*Stream) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	stepIDs := []string{}
+	for rows.Next() {
+		s := &Stream{}
+		var stepID string
+		if err := rows.Scan(&s.ID, &stepID, &s.Name, &s.Status); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		r = append(r, s)
+		stepIDs = append(stepIDs, stepID)
+	}
+	rows.Close()
+
+	steps := map[string]*Step{}
+	for i, s := range r {
+		step, ok := steps[stepIDs[i]]
+		if !ok {
+			if step, err = db.steps.GetByID(stepIDs[i]); err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+			steps[stepIDs[i]] = step
+		}
+		s.Step = step
+	}
+
+	return r
+}
+
+func (db *streamSQLiteDB) GetByID(id string) (*Stream, error) {
+	r := db.find(`SELECT `+sqliteStreamColumns+` FROM streams WHERE id = ?`, id)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *streamSQLiteDB) GetAllForStep(s *Step) []*Stream {
+	return db.find(`SELECT `+sqliteStreamColumns+` FROM streams WHERE step_id = ? ORDER BY rowid`, s.ID)
+}
+
+// MigrateStreamLinesSQLite moves the stream lines in a SQLite database into a log store,
+// removing them from the database once they have all been copied.
+func MigrateStreamLinesSQLite(db *sql.DB, to LogStore) (int, error) {
+	rows, err := db.Query(`SELECT stream_id, line_number, timestamp, output FROM stream_lines
+		ORDER BY stream_id, line_number`)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	streamID := ""
+	for rows.Next() {
+		l := &StreamLine{}
+		if err := rows.Scan(&l.StreamID, &l.LineNumber, &l.Timestamp, &l.Output); err != nil {
+			rows.Close()
+			return n, err
+		}
+		if l.StreamID != streamID && streamID != "" {
+			if err := to.Close(streamID); err != nil {
+				rows.Close()
+				return n, err
+			}
+		}
+		streamID = l.StreamID
+		if err := to.Append(l); err != nil {
+			rows.Close()
+			return n, err
+		}
+		n++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if n < 1 {
+		return n, nil
+	}
+	if err := to.Close(streamID); err != nil {
+		return n, err
+	}
+
+	_, err = db.Exec(`DELETE FROM stream_lines`)
+	return n, err
+}
@@ -1,10 +1,9 @@
 package build
 
 import (
-	"fmt"
+	"sort"
 	"time"
 
-	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 	"go.uber.org/zap"
 
@@ -45,12 +44,17 @@ type streamStormDB struct {
 	*storm.DB
 }
 
+// NewStreamStormRepository returns a stream repository backed by storm.
+func NewStreamStormRepository(db *storm.DB) StreamRepository {
+	return newStreamStormDB(db)
+}
+
 func newStreamStormDB(db *storm.DB) *streamStormDB {
 	db.Init(&StormStep{})
 	return &streamStormDB{db}
 }
 
-func (db *streamStormDB) save(s *Stream) error {
+func (db *streamStormDB) Save(s *Stream) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -63,6 +67,37 @@ func (db *streamStormDB) save(s *Stream) error {
 	return tx.Commit()
 }
 
+func (db *streamStormDB) Delete(s *Stream) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteStruct(s.toStormStream()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *streamStormDB) GetByID(id string) (*Stream, error) {
+	return GetStreamByID(db.DB, id)
+}
+
+func (db *streamStormDB) GetAllForStep(s *Step) []*Stream {
+	return getStreamsByStepID(db.DB, s.ID)
+}
+
+func GetStreamByID(db *storm.DB, id string) (*Stream, error) {
+	var sS StormStream
+	if err := db.One("ID", id, &sS); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return nil, err
+	}
+	return sS.toStream(db), nil
+}
+
 func getStreamsByStepID(db *storm.DB, stepID string) (r []*Stream) {
 	query := db.Select(q.Eq("StepID", stepID))
 	var StormStreams []*StormStream
@@ -75,6 +110,7 @@ func getStreamsByStepID(db *storm.DB, stepID string) (r []*Stream) {
 	return r
 }
 
+// StormStreamLine is how stream lines were kept before they were moved into a LogStore.
 type StormStreamLine struct {
 	ID         string `storm:"id"`
 	StreamID   string `storm:"index"`
@@ -83,16 +119,6 @@ type StormStreamLine struct {
 	Output     string
 }
 
-func toStormStreamLine(sL *StreamLine) *StormStreamLine {
-	return &StormStreamLine{
-		ID:         fmt.Sprintf("%s:%d", sL.StreamID, sL.LineNumber),
-		StreamID:   sL.StreamID,
-		LineNumber: sL.LineNumber,
-		Timestamp:  sL.Timestamp,
-		Output:     sL.Output,
-	}
-}
-
 func toStreamLine(sL *StormStreamLine) *StreamLine {
 	return &StreamLine{
 		StreamID:   sL.StreamID,
@@ -102,36 +128,71 @@ func toStreamLine(sL *StormStreamLine) *StreamLine {
 	}
 }
 
-func (db *streamStormDB) saveStreamLine(streamLine *StreamLine) error {
-	tx, err := db.Begin(true)
-	if err != nil {
-		return err
+// MigrateStreamsStorm copies the step output streams in a storm database into another
+// repository, skipping streams of steps that no longer exist.
+func MigrateStreamsStorm(from *storm.DB, to StreamRepository) (int, error) {
+	var stormStreams []*StormStream
+	if err := from.All(&stormStreams); err != nil && err != storm.ErrNotFound {
+		return 0, err
 	}
-
-	if err := tx.Save(toStormStreamLine(streamLine)); err != nil {
-		tx.Rollback()
-		return err
+	n := 0
+	for _, sS := range stormStreams {
+		s := sS.toStream(from)
+		if s.Step == nil {
+			velocity.GetLogger().Warn("skipping stream without step", zap.String("streamID", s.ID))
+			continue
+		}
+		if err := to.Save(s); err != nil {
+			return n, err
+		}
+		n++
 	}
-	return tx.Commit()
+
+	return n, nil
 }
 
-func (db *streamStormDB) getLinesByStream(s *Stream, pQ *domain.PagingQuery) (r []*StreamLine, t int) {
-	t = 0
-	query := db.Select(q.Eq("StreamID", s.ID))
-	t, err := query.Count(&StormStreamLine{})
-	if err != nil {
-		velocity.GetLogger().Error("error", zap.Error(err))
-		return r, t
+// MigrateStreamLinesStorm moves the stream lines in a storm database into a log store,
+// removing them from the database once they have all been copied.
+func MigrateStreamLinesStorm(from *storm.DB, to LogStore) (int, error) {
+	n := 0
+	lines := []*StreamLine{}
+	// flush moves the lines of a stream in order and closes it
+	flush := func() error {
+		sort.Slice(lines, func(i, j int) bool { return lines[i].LineNumber < lines[j].LineNumber })
+		for _, l := range lines {
+			if err := to.Append(l); err != nil {
+				return err
+			}
+		}
+		if err := to.Close(lines[0].StreamID); err != nil {
+			return err
+		}
+		lines = []*StreamLine{}
+		return nil
 	}
-
-	var stormStreamLines []*StormStreamLine
-	query.OrderBy("LineNumber").Limit(pQ.Limit).Skip((pQ.Page - 1) * pQ.Limit)
-	query.Find(&stormStreamLines)
-
-	for _, ssL := range stormStreamLines {
-		r = append(r, toStreamLine(ssL))
+	// lines are read one at a time as there are far more of them than anything else. They
+	// come out ordered by their "<streamID>:<line number>" ID, so grouped by stream but with
+	// e.g. line 10 before line 2, and only one stream is held at a time to be sorted.
+	err := from.Select().Each(new(StormStreamLine), func(record interface{}) error {
+		l := toStreamLine(record.(*StormStreamLine))
+		if len(lines) > 0 && l.StreamID != lines[0].StreamID {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		lines = append(lines, l)
+		n++
+		return nil
+	})
+	if err != nil && err != storm.ErrNotFound {
+		return n, err
+	}
+	if n < 1 {
+		return n, nil
+	}
+	if err := flush(); err != nil {
+		return n, err
 	}
 
-	return r, t
-
+	return n, from.Drop(&StormStreamLine{})
 }
@@ -1,6 +1,15 @@
 package builder
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
 
 type Transport interface {
 	WriteJSON(interface{}) error
@@ -9,17 +18,176 @@ type Transport interface {
 }
 
 const (
-	stateReady = "ready"
-	stateBusy  = "busy"
-	stateError = "error"
+	stateReady        = "ready"
+	stateBusy         = "busy"
+	stateError        = "error"
+	stateDisconnected = "disconnected"
+)
+
+// Capabilities that builders can advertise when connecting
+const (
+	CapabilityShell = "shell"
 )
 
+// CapabilitiesHeader is the header builders advertise their capabilities on
+const CapabilitiesHeader = "X-Velocity-Capabilities"
+
+// LabelsHeader is the header builders advertise their labels on, e.g. "arch=arm64,trusted"
+const LabelsHeader = "X-Velocity-Labels"
+
+// SlotsHeader is the header builders advertise how many builds they can run at once on
+const SlotsHeader = "X-Velocity-Slots"
+
+// IDHeader is the header builders identify themselves on, so that they are given back their
+// running builds when they reconnect
+const IDHeader = "X-Velocity-Builder-ID"
+
 type Builder struct {
-	ID        string
-	State     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID string
+	// State is ready while the builder has a free slot and busy once all of them are used
+	State        string
+	Capabilities []string
+	Labels       []string
+	Slots        int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+
+	ws Transport
+	// writeLock serialises the commands sent to the builder
+	writeLock sync.Mutex
+
+	lock sync.RWMutex
+	// builds are the builds running on the builder by ID
+	builds map[string]*build.Build
+	// lastSequence is the sequence number of the last message handled from the builder
+	lastSequence int
+	// expiry fails the running builds if the builder doesn't reconnect in time
+	expiry *time.Timer
+}
+
+func (b *Builder) transport() Transport {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.ws
+}
+
+// send writes a command to the builder if it is connected.
+func (b *Builder) send(c *BuilderCtrlMessage) error {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+	ws := b.transport()
+	if ws == nil {
+		return fmt.Errorf("builder %s is not connected", b.ID)
+	}
+	return ws.WriteJSON(c)
+}
+
+// handled records the sequence number of a message from the builder and returns whether
+// it is new.
+func (b *Builder) handled(sequence int) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if sequence <= b.lastSequence {
+		return false
+	}
+	b.lastSequence = sequence
+	return true
+}
+
+// FreeSlots returns how many more builds the builder can run.
+func (b *Builder) FreeSlots() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.Slots - len(b.builds)
+}
+
+// Builds returns the builds running on the builder, oldest first.
+func (b *Builder) Builds() []*build.Build {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	r := []*build.Build{}
+	for _, bu := range b.builds {
+		r = append(r, bu)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].StartedAt.Before(r[j].StartedAt) })
+	return r
+}
+
+func (b *Builder) isRunning(buildID string) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	_, ok := b.builds[buildID]
+	return ok
+}
+
+func (b *Builder) addBuild(bu *build.Build) {
+	b.lock.Lock()
+	b.builds[bu.ID] = bu
+	b.lock.Unlock()
+	b.updateState()
+}
+
+func (b *Builder) removeBuild(buildID string) {
+	b.lock.Lock()
+	delete(b.builds, buildID)
+	b.lock.Unlock()
+	b.updateState()
+}
+
+func (b *Builder) updateState() {
+	if b.transport() == nil {
+		b.State = stateDisconnected
+	} else if b.FreeSlots() > 0 {
+		b.State = stateReady
+	} else {
+		b.State = stateBusy
+	}
+	b.UpdatedAt = time.Now().UTC()
+}
+
+func (b *Builder) HasCapability(c string) bool {
+	for _, bC := range b.Capabilities {
+		if bC == c {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLabel returns whether the builder satisfies a label required by a task. A requirement
+// of "key=value" needs a label with that value, while "key" is satisfied by any label with
+// that key.
+func (b *Builder) HasLabel(required string) bool {
+	for _, l := range b.Labels {
+		if l == required {
+			return true
+		}
+		if !strings.Contains(required, "=") && strings.SplitN(l, "=", 2)[0] == required {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRun returns whether the builder has all of the capabilities and labels required to run the given task.
+func (b *Builder) CanRun(t *velocity.Task) bool {
+	for _, c := range RequiredCapabilities(t) {
+		if !b.HasCapability(c) {
+			return false
+		}
+	}
+	for _, l := range t.Requires {
+		if !b.HasLabel(l) {
+			return false
+		}
+	}
+	return true
+}
 
-	ws      Transport
-	Command *BuilderCtrlMessage
+func RequiredCapabilities(t *velocity.Task) []string {
+	r := []string{}
+	if t.HasStepType("shell") {
+		r = append(r, CapabilityShell)
+	}
+	return r
 }
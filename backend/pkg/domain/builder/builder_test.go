@@ -0,0 +1,143 @@
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+func newTestBuilder(id string, slots int, t Transport) *Builder {
+	return &Builder{
+		ID:        id,
+		Slots:     slots,
+		CreatedAt: time.Now().UTC(),
+		ws:        t,
+		builds:    map[string]*build.Build{},
+	}
+}
+
+func TestBuilderSlots(t *testing.T) {
+	tests := []struct {
+		name      string
+		slots     int
+		add       []string
+		remove    []string
+		freeSlots int
+		state     string
+	}{
+		{name: "idle", slots: 2, freeSlots: 2, state: stateReady},
+		{name: "partly used", slots: 2, add: []string{"a"}, freeSlots: 1, state: stateReady},
+		{name: "exhausted", slots: 2, add: []string{"a", "b"}, freeSlots: 0, state: stateBusy},
+		{name: "same build added twice", slots: 2, add: []string{"a", "a"}, freeSlots: 1, state: stateReady},
+		{name: "released", slots: 2, add: []string{"a", "b"}, remove: []string{"a"}, freeSlots: 1, state: stateReady},
+		{name: "unknown build released", slots: 1, add: []string{"a"}, remove: []string{"b"}, freeSlots: 0, state: stateBusy},
+		{name: "all released", slots: 1, add: []string{"a"}, remove: []string{"a"}, freeSlots: 1, state: stateReady},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newTestBuilder("builder", test.slots, newFakeTransport())
+			for _, id := range test.add {
+				b.addBuild(&build.Build{ID: id})
+			}
+			for _, id := range test.remove {
+				b.removeBuild(id)
+			}
+			b.updateState()
+
+			assert.Equal(t, test.freeSlots, b.FreeSlots())
+			assert.Equal(t, test.state, b.State)
+			for _, id := range test.add {
+				assert.Equal(t, !contains(test.remove, id), b.isRunning(id))
+			}
+		})
+	}
+}
+
+func TestManagerReady(t *testing.T) {
+	m := NewManager(nil, nil, nil, nil, time.Hour)
+	createdAt := time.Now().UTC()
+
+	free := newTestBuilder("free", 2, newFakeTransport())
+	free.CreatedAt = createdAt.Add(time.Minute)
+	m.builders[free.ID] = free
+
+	partlyUsed := newTestBuilder("partly-used", 2, newFakeTransport())
+	partlyUsed.CreatedAt = createdAt
+	partlyUsed.addBuild(&build.Build{ID: "a"})
+	m.builders[partlyUsed.ID] = partlyUsed
+
+	exhausted := newTestBuilder("exhausted", 1, newFakeTransport())
+	exhausted.addBuild(&build.Build{ID: "b"})
+	m.builders[exhausted.ID] = exhausted
+
+	disconnected := newTestBuilder("disconnected", 1, nil)
+	m.builders[disconnected.ID] = disconnected
+
+	// longest connected first
+	assert.Equal(t, []*Builder{partlyUsed, free}, m.ready())
+	busy, _ := m.GetBusy(domain.NewPagingQuery())
+	assert.Equal(t, []*Builder{exhausted}, busy)
+
+	exhausted.removeBuild("b")
+	partlyUsed.addBuild(&build.Build{ID: "c"})
+	assert.Equal(t, []*Builder{exhausted, free}, m.ready())
+}
+
+func TestBuilderCanRun(t *testing.T) {
+	shell := &velocity.Task{Steps: []velocity.Step{velocity.NewShell()}}
+	tests := []struct {
+		name         string
+		capabilities []string
+		labels       []string
+		task         *velocity.Task
+		canRun       bool
+	}{
+		{name: "no requirements", task: &velocity.Task{}, canRun: true},
+		{name: "shell capability", capabilities: []string{CapabilityShell}, task: shell, canRun: true},
+		{name: "missing shell capability", task: shell, canRun: false},
+		{name: "key and value", labels: []string{"arch=arm64"}, task: &velocity.Task{Requires: []string{"arch=arm64"}}, canRun: true},
+		{name: "other value", labels: []string{"arch=amd64"}, task: &velocity.Task{Requires: []string{"arch=arm64"}}, canRun: false},
+		{name: "key only", labels: []string{"arch=amd64"}, task: &velocity.Task{Requires: []string{"arch"}}, canRun: true},
+		{name: "flag", labels: []string{"trusted"}, task: &velocity.Task{Requires: []string{"trusted"}}, canRun: true},
+		{name: "flag needs the key", labels: []string{"trusted=false"}, task: &velocity.Task{Requires: []string{"trusted=true"}}, canRun: false},
+		{name: "value isn't a key", labels: []string{"arch=trusted"}, task: &velocity.Task{Requires: []string{"trusted"}}, canRun: false},
+		{name: "all labels", labels: []string{"arch=arm64", "trusted"}, task: &velocity.Task{Requires: []string{"arch=arm64", "trusted"}}, canRun: true},
+		{name: "one of the labels", labels: []string{"arch=arm64"}, task: &velocity.Task{Requires: []string{"arch=arm64", "trusted"}}, canRun: false},
+		{
+			name:         "labels and capabilities",
+			capabilities: []string{CapabilityShell},
+			labels:       []string{"trusted"},
+			task:         &velocity.Task{Steps: shell.Steps, Requires: []string{"trusted"}},
+			canRun:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newTestBuilder("builder", 1, newFakeTransport())
+			b.Capabilities = test.capabilities
+			b.Labels = test.labels
+			assert.Equal(t, test.canRun, b.CanRun(test.task))
+
+			m := NewManager(nil, nil, nil, nil, time.Hour)
+			m.builders[b.ID] = b
+			// busy builders are still eligible
+			b.addBuild(&build.Build{ID: "a"})
+			assert.Equal(t, test.canRun, m.hasEligible(test.task))
+		})
+	}
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -2,10 +2,13 @@ package builder
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
 
 	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
 
@@ -21,7 +24,11 @@ const (
 )
 
 type Manager struct {
+	// lock guards builders. It is never held while taking the lock of a builder.
+	lock     sync.RWMutex
 	builders map[string]*Builder
+	// reconnectGracePeriod is how long the builds of a disconnected builder are kept running
+	reconnectGracePeriod time.Duration
 
 	brokers []domain.Broker
 
@@ -36,14 +43,16 @@ func NewManager(
 	knownhostManager *knownhost.Manager,
 	stepManager *build.StepManager,
 	streamManager *build.StreamManager,
+	reconnectGracePeriod time.Duration,
 ) *Manager {
 	return &Manager{
-		buildManager:     buildManager,
-		knownHostManager: knownhostManager,
-		brokers:          []domain.Broker{},
-		stepManager:      stepManager,
-		streamManager:    streamManager,
-		builders:         map[string]*Builder{},
+		buildManager:         buildManager,
+		knownHostManager:     knownhostManager,
+		brokers:              []domain.Broker{},
+		stepManager:          stepManager,
+		streamManager:        streamManager,
+		builders:             map[string]*Builder{},
+		reconnectGracePeriod: reconnectGracePeriod,
 	}
 }
 
@@ -51,43 +60,162 @@ func (m *Manager) AddBroker(b domain.Broker) {
 	m.brokers = append(m.brokers, b)
 }
 
-func (m *Manager) CreateBuilder(t Transport) *Builder {
-	b := &Builder{
-		ID:        uuid.NewV4().String(),
-		State:     stateReady,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
+// CreateBuilder adds a connected builder, or gives a builder that has reconnected back its
+// running builds and asks it to replay the messages that were lost.
+func (m *Manager) CreateBuilder(t Transport, id string, capabilities []string, labels []string, slots int) *Builder {
+	if slots < 1 {
+		slots = 1
+	}
+	if id == "" {
+		id = uuid.NewV4().String()
+	}
+
+	if b, err := m.GetByID(id); err == nil && m.reattach(b, t, capabilities, labels, slots) {
+		return b
+	}
 
-		ws: t,
+	b := &Builder{
+		ID:           id,
+		State:        stateReady,
+		Capabilities: capabilities,
+		Labels:       labels,
+		Slots:        slots,
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+
+		ws:     t,
+		builds: map[string]*build.Build{},
 	}
+	b.send(newResumeCommand(0))
 	m.Save(b)
 
-	go m.monitor(b)
+	go m.monitor(b, t)
 
 	return b
 }
 
-func (m *Manager) Exists(id string) bool {
-	if _, ok := m.builders[id]; ok {
-		return true
+// reattach gives a builder that has reconnected its new connection. It returns false if the
+// builder has just expired, in which case it is added again as a new builder.
+func (m *Manager) reattach(b *Builder, t Transport, capabilities []string, labels []string, slots int) bool {
+	b.lock.Lock()
+	if !m.has(b) {
+		b.lock.Unlock()
+		return false
 	}
-	return false
+	if b.expiry != nil {
+		b.expiry.Stop()
+		b.expiry = nil
+	}
+	old := b.ws
+	b.ws = t
+	b.Capabilities = capabilities
+	b.Labels = labels
+	b.Slots = slots
+	lastSequence := b.lastSequence
+	b.lock.Unlock()
+	if old != nil {
+		// the previous connection is only noticed as dropped once it is written to
+		old.Close()
+	}
+	b.updateState()
+	velocity.GetLogger().Info("builder reconnected", zap.String("builderID", b.ID), zap.Int("sequence", lastSequence))
+
+	b.send(newResumeCommand(lastSequence))
+	m.Save(b)
+	go m.monitor(b, t)
+	return true
 }
 
-func (m *Manager) WebsocketConnected(id string) bool {
-	if m.Exists(id) {
-		if m.builders[id].ws != nil {
-			return true
+// disconnect keeps the builds of a builder whose connection dropped for the reconnect grace
+// period, after which they are failed.
+func (m *Manager) disconnect(b *Builder, t Transport) {
+	b.lock.Lock()
+	if b.ws != t {
+		// the builder has already reconnected
+		b.lock.Unlock()
+		return
+	}
+	b.ws = nil
+	running := len(b.builds)
+	b.lock.Unlock()
+
+	if running < 1 || m.reconnectGracePeriod <= 0 {
+		m.Delete(b)
+		return
+	}
+
+	velocity.GetLogger().Warn("builder disconnected, waiting for it to reconnect",
+		zap.String("builderID", b.ID),
+		zap.Int("builds", running),
+		zap.Duration("grace period", m.reconnectGracePeriod),
+	)
+	b.updateState()
+	m.Save(b)
+	b.lock.Lock()
+	var expiry *time.Timer
+	expiry = time.AfterFunc(m.reconnectGracePeriod, func() {
+		b.lock.Lock()
+		if b.expiry != expiry || b.ws != nil {
+			// the builder reconnected before the timer could be stopped
+			b.lock.Unlock()
+			return
 		}
+		b.expiry = nil
+		// a reconnect from now on adds the builder again instead of reattaching to it
+		m.remove(b)
+		b.lock.Unlock()
+
+		velocity.GetLogger().Error("builder did not reconnect", zap.String("builderID", b.ID))
+		m.Delete(b)
+	})
+	b.expiry = expiry
+	b.lock.Unlock()
+}
+
+func (m *Manager) Exists(id string) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	_, ok := m.builders[id]
+	return ok
+}
+
+// has returns whether the given builder is still the one known by its ID.
+func (m *Manager) has(b *Builder) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.builders[b.ID] == b
+}
+
+// remove forgets the given builder, unless it has already been replaced.
+func (m *Manager) remove(b *Builder) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.builders[b.ID] == b {
+		delete(m.builders, b.ID)
 	}
-	return false
+}
+
+// all returns the known builders, so that they can be looked at without holding the lock.
+func (m *Manager) all() []*Builder {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	r := make([]*Builder, 0, len(m.builders))
+	for _, v := range m.builders {
+		r = append(r, v)
+	}
+	return r
+}
+
+func (m *Manager) WebsocketConnected(id string) bool {
+	b, err := m.GetByID(id)
+	return err == nil && b.transport() != nil
 }
 
 func (m *Manager) GetAll(q *domain.PagingQuery) (r []*Builder, t int) {
 	t = 0
 
 	skipCounter := 0
-	for _, v := range m.builders {
+	for _, v := range m.all() {
 		if len(r) >= q.Limit {
 			break
 		}
@@ -102,30 +230,52 @@ func (m *Manager) GetAll(q *domain.PagingQuery) (r []*Builder, t int) {
 }
 
 func (m *Manager) GetReady(q *domain.PagingQuery) (r []*Builder, t int) {
-	t = 0
-
-	skipCounter := 0
-	for _, v := range m.builders {
+	ready := m.ready()
+	for i, v := range ready {
 		if len(r) >= q.Limit {
 			break
 		}
-		if skipCounter < (q.Page-1)*q.Limit {
-			skipCounter++
-			break
+		if i < (q.Page-1)*q.Limit {
+			continue
 		}
-		if v.State == stateReady {
+		r = append(r, v)
+	}
+
+	return r, len(ready)
+}
+
+// hasEligible returns whether any connected builder, ready or not, can run the given task.
+func (m *Manager) hasEligible(t *velocity.Task) bool {
+	for _, v := range m.all() {
+		if v.CanRun(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ready returns the connected builders with a free slot, longest connected first.
+func (m *Manager) ready() (r []*Builder) {
+	for _, v := range m.all() {
+		if v.FreeSlots() > 0 && v.transport() != nil {
 			r = append(r, v)
 		}
 	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].CreatedAt.Equal(r[j].CreatedAt) {
+			return r[i].ID < r[j].ID
+		}
+		return r[i].CreatedAt.Before(r[j].CreatedAt)
+	})
 
-	return r, len(r) + skipCounter*q.Limit
+	return r
 }
 
 func (m *Manager) GetBusy(q *domain.PagingQuery) (r []*Builder, t int) {
 	t = 0
 
 	skipCounter := 0
-	for _, v := range m.builders {
+	for _, v := range m.all() {
 		if len(r) >= q.Limit {
 			break
 		}
@@ -133,7 +283,7 @@ func (m *Manager) GetBusy(q *domain.PagingQuery) (r []*Builder, t int) {
 			skipCounter++
 			break
 		}
-		if v.State == stateBusy {
+		if v.FreeSlots() < 1 {
 			r = append(r, v)
 		}
 	}
@@ -143,12 +293,14 @@ func (m *Manager) GetBusy(q *domain.PagingQuery) (r []*Builder, t int) {
 
 func (m *Manager) Save(b *Builder) {
 	var ev string
-	if m.Exists(b.ID) {
+	m.lock.Lock()
+	if _, ok := m.builders[b.ID]; ok {
 		ev = EventUpdate
 	} else {
 		ev = EventCreate
 	}
 	m.builders[b.ID] = b
+	m.lock.Unlock()
 	for _, b := range m.brokers {
 		b.EmitAll(&domain.Emit{
 			Topic:   "builders",
@@ -159,32 +311,39 @@ func (m *Manager) Save(b *Builder) {
 }
 
 func (m *Manager) GetByID(id string) (*Builder, error) {
-	if m.Exists(id) {
-		return m.builders[id], nil
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if b, ok := m.builders[id]; ok {
+		return b, nil
 	}
 	return nil, fmt.Errorf("could not find builder %s", id)
 }
 
 func (m *Manager) Delete(b *Builder) {
-	if b.Command.Command == "build" {
-		build := b.Command.Payload.(*BuildCtrl).Build
+	b.lock.Lock()
+	if b.expiry != nil {
+		b.expiry.Stop()
+		b.expiry = nil
+	}
+	b.lock.Unlock()
+	for _, build := range b.Builds() {
 		build.Status = velocity.StateFailed
 		m.buildManager.Update(build)
 	}
-	delete(m.builders, b.ID)
+	m.remove(b)
 }
 
 func (m *Manager) StartBuild(builder *Builder, b *build.Build) {
-	builder.State = stateBusy
+	builder.addBuild(b)
 	m.Save(builder)
 
 	// Add knownhosts
 	knownHosts, _ := m.knownHostManager.GetAll(domain.NewPagingQuery())
-	builder.Command = newKnownHostsCommand(knownHosts)
-	builder.ws.WriteJSON(builder.Command)
+	builder.send(newKnownHostsCommand(knownHosts))
 
 	// Start build
 	b.Status = velocity.StateRunning
+	b.Reason = ""
 	m.buildManager.Update(b)
 
 	steps := m.stepManager.GetStepsForBuild(b)
@@ -193,6 +352,11 @@ func (m *Manager) StartBuild(builder *Builder, b *build.Build) {
 		streams = append(streams, m.streamManager.GetStreamsForStep(s)...)
 	}
 
-	builder.Command = newBuildCommand(b, steps, streams)
-	builder.ws.WriteJSON(builder.Command)
+	if err := builder.send(newBuildCommand(b, steps, streams)); err != nil {
+		velocity.GetLogger().Error("could not send build to builder", zap.String("buildID", b.ID), zap.String("builderID", builder.ID), zap.Error(err))
+		builder.removeBuild(b.ID)
+		m.Save(builder)
+		b.Status = velocity.StateWaiting
+		m.buildManager.Update(b)
+	}
 }
@@ -0,0 +1,284 @@
+package builder
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// fakeTransport is a builder connection that hands the architect the messages queued on it,
+// and drops once they have all been read.
+type fakeTransport struct {
+	messages chan *BuilderRespMessage
+	closed   chan struct{}
+	close    sync.Once
+
+	lock     sync.Mutex
+	commands []*BuilderCtrlMessage
+}
+
+func newFakeTransport(messages ...*BuilderRespMessage) *fakeTransport {
+	t := &fakeTransport{
+		messages: make(chan *BuilderRespMessage, 100),
+		closed:   make(chan struct{}),
+	}
+	for _, m := range messages {
+		t.messages <- m
+	}
+	return t
+}
+
+// drop lets the architect read the queued messages before the connection drops.
+func (t *fakeTransport) drop() {
+	close(t.messages)
+}
+
+func (t *fakeTransport) WriteJSON(v interface{}) error {
+	select {
+	case <-t.closed:
+		return errors.New("connection closed")
+	default:
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.commands = append(t.commands, v.(*BuilderCtrlMessage))
+	return nil
+}
+
+func (t *fakeTransport) ReadJSON(v interface{}) error {
+	select {
+	case m, ok := <-t.messages:
+		if !ok {
+			return io.EOF
+		}
+		// go through JSON as the messages of a real builder would
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	case <-t.closed:
+		return io.EOF
+	}
+}
+
+func (t *fakeTransport) Close() error {
+	t.close.Do(func() { close(t.closed) })
+	return nil
+}
+
+// sent returns the commands sent to the builder.
+func (t *fakeTransport) sent(command string) []*BuilderCtrlMessage {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	r := []*BuilderCtrlMessage{}
+	for _, c := range t.commands {
+		if c.Command == command {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// waitFor polls a condition that is met by the monitor of a builder in the background.
+func waitFor(condition func() bool) bool {
+	for i := 0; i < 200; i++ {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+type ManagerSuite struct {
+	suite.Suite
+	db            *sql.DB
+	dir           string
+	taskManager   *task.Manager
+	commitManager *githistory.CommitManager
+	branchManager *githistory.BranchManager
+	stepManager   *build.StepManager
+	streamManager *build.StreamManager
+	buildManager  *build.BuildManager
+	manager       *Manager
+	task          *task.Task
+}
+
+func TestManagerSuite(t *testing.T) {
+	suite.Run(t, new(ManagerSuite))
+}
+
+func (s *ManagerSuite) SetupTest() {
+	os.Setenv("ENCRYPTION_KEY", "test")
+	var err error
+	s.dir, err = ioutil.TempDir("", "velocity-builder")
+	if err != nil {
+		panic(err)
+	}
+	s.db, err = domain.OpenSQLiteDB(s.dir + "/architect.sqlite")
+	if err != nil {
+		panic(err)
+	}
+
+	validator, translator := domain.NewValidator()
+	projectManager := project.NewManager(project.NewSQLiteRepository(s.db), validator, translator, func(*velocity.GitRepository) (bool, error) {
+		return true, nil
+	})
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitSQLiteRepository(s.db))
+	s.branchManager = githistory.NewBranchManager(githistory.NewBranchSQLiteRepository(s.db))
+	s.taskManager = task.NewManager(task.NewSQLiteRepository(s.db), projectManager, s.branchManager, s.commitManager)
+	s.stepManager = build.NewStepManager(build.NewStepSQLiteRepository(s.db))
+	s.streamManager = build.NewStreamManager(build.NewStreamSQLiteRepository(s.db), build.NewFileLogStore(s.dir+"/logs"))
+	s.buildManager = build.NewBuildManager(build.NewBuildSQLiteRepository(s.db), s.stepManager, s.streamManager)
+	knownHostManager := knownhost.NewManager(knownhost.NewSQLiteRepository(s.db), validator, translator, s.dir)
+	s.manager = NewManager(s.buildManager, knownHostManager, s.stepManager, s.streamManager, time.Hour)
+
+	p, _ := projectManager.Create("testProject", velocity.GitRepository{Address: "testGit"})
+	br := s.branchManager.Create(p, "master")
+	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	s.task = s.taskManager.Create(c, &velocity.Task{Name: "testTask"}, velocity.NewSetup())
+}
+
+func (s *ManagerSuite) TearDownTest() {
+	s.db.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *ManagerSuite) startBuild(b *Builder) *build.Build {
+	bu, errs := s.buildManager.Create(s.task, map[string]string{})
+	s.Nil(errs)
+	s.manager.StartBuild(b, bu)
+	return bu
+}
+
+func (s *ManagerSuite) buildStatus(id string) string {
+	b, err := s.buildManager.GetBuildByID(id)
+	s.Nil(err)
+	return b.Status
+}
+
+func (s *ManagerSuite) TestDisconnectWithoutBuildsRemovesBuilder() {
+	t := newFakeTransport()
+	b := s.manager.CreateBuilder(t, "builder-1", nil, nil, 1)
+	s.True(s.manager.Exists(b.ID))
+	s.Len(t.sent(CommandResume), 1)
+
+	t.drop()
+	s.True(waitFor(func() bool { return !s.manager.Exists(b.ID) }))
+}
+
+func (s *ManagerSuite) TestReconnectWithinGracePeriodKeepsBuilds() {
+	t := newFakeTransport()
+	b := s.manager.CreateBuilder(t, "builder-1", nil, nil, 2)
+	bu := s.startBuild(b)
+	s.Len(t.sent(CommandBuild), 1)
+
+	t.drop()
+	s.True(waitFor(func() bool { return b.transport() == nil }))
+	s.Equal(stateDisconnected, b.State)
+	s.Empty(s.manager.ready())
+	s.Equal(velocity.StateRunning, s.buildStatus(bu.ID))
+
+	reconnected := newFakeTransport()
+	s.Equal(b, s.manager.CreateBuilder(reconnected, "builder-1", nil, nil, 2))
+	s.True(b.isRunning(bu.ID))
+	s.Equal(stateReady, b.State)
+	s.Len(reconnected.sent(CommandResume), 1)
+
+	// the expiry was stopped
+	time.Sleep(20 * time.Millisecond)
+	s.True(s.manager.Exists(b.ID))
+	s.Equal(velocity.StateRunning, s.buildStatus(bu.ID))
+}
+
+func (s *ManagerSuite) TestGracePeriodExpiryFailsBuilds() {
+	s.manager.reconnectGracePeriod = 10 * time.Millisecond
+	t := newFakeTransport()
+	b := s.manager.CreateBuilder(t, "builder-1", nil, nil, 1)
+	bu := s.startBuild(b)
+
+	t.drop()
+	s.True(waitFor(func() bool { return s.buildStatus(bu.ID) == velocity.StateFailed }))
+	s.False(s.manager.Exists(b.ID))
+
+	// a builder that reconnects too late is added again without its builds
+	reconnected := s.manager.CreateBuilder(newFakeTransport(), "builder-1", nil, nil, 1)
+	s.NotEqual(b, reconnected)
+	s.Empty(reconnected.Builds())
+}
+
+func (s *ManagerSuite) TestReplayedMessagesAreHandledOnce() {
+	t := newFakeTransport()
+	b := s.manager.CreateBuilder(t, "builder-1", nil, nil, 1)
+	bu := s.startBuild(b)
+	step := s.stepManager.GetStepsForBuild(bu)[0]
+	stream := s.streamManager.GetStreamsForStep(step)[0]
+
+	line := func(sequence int, lineNumber int) *BuilderRespMessage {
+		return &BuilderRespMessage{
+			Type:     MessageLog,
+			Sequence: sequence,
+			Data: &BuilderStreamLineMessage{
+				BuildID:    bu.ID,
+				StepID:     step.ID,
+				StreamID:   stream.ID,
+				LineNumber: lineNumber,
+				Status:     velocity.StateRunning,
+				Output:     "output",
+			},
+		}
+	}
+	t.messages <- line(1, 1)
+	t.messages <- line(2, 2)
+	t.drop()
+	s.True(waitFor(func() bool { return b.transport() == nil }))
+
+	// the builder replays everything after what it was last acknowledged for
+	reconnected := newFakeTransport(line(1, 1), line(2, 2), line(3, 3), &BuilderRespMessage{
+		Type: MessageBuilds,
+		Data: &BuilderBuildsMessage{BuildIDs: []string{bu.ID}},
+	})
+	s.manager.CreateBuilder(reconnected, "builder-1", nil, nil, 1)
+	resume := reconnected.sent(CommandResume)
+	s.Len(resume, 1)
+	s.Equal(2, resume[0].Payload.(*SequenceCtrl).Sequence)
+
+	reconnected.drop()
+	s.True(waitFor(func() bool { return b.transport() == nil }))
+	_, total := s.streamManager.GetStreamLines(stream, domain.NewPagingQuery())
+	s.Equal(3, total)
+	s.Equal(3, b.lastSequence)
+	s.True(b.isRunning(bu.ID))
+}
+
+func (s *ManagerSuite) TestBuildsMessageFailsLostBuilds() {
+	t := newFakeTransport()
+	b := s.manager.CreateBuilder(t, "builder-1", nil, nil, 2)
+	kept := s.startBuild(b)
+	lost := s.startBuild(b)
+	s.Equal(0, b.FreeSlots())
+
+	s.manager.builderBuildsMessage(&BuilderBuildsMessage{BuildIDs: []string{kept.ID}}, b)
+
+	s.True(b.isRunning(kept.ID))
+	s.False(b.isRunning(lost.ID))
+	s.Equal(1, b.FreeSlots())
+	s.Equal(velocity.StateRunning, s.buildStatus(kept.ID))
+	s.Equal(velocity.StateFailed, s.buildStatus(lost.ID))
+}
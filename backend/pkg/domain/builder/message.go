@@ -11,6 +11,14 @@ import (
 const (
 	CommandBuild      = "build"
 	CommandKnownHosts = "knownhosts"
+	CommandResume     = "resume"
+	CommandAck        = "ack"
+)
+
+// Message types sent by builders
+const (
+	MessageLog    = "log"
+	MessageBuilds = "builds"
 )
 
 type BuilderCtrlMessage struct {
@@ -35,6 +43,28 @@ func newBuildCommand(b *build.Build, steps []*build.Step, streams []*build.Strea
 	}
 }
 
+// SequenceCtrl carries the sequence number of the last message the architect has handled
+// from a builder. It is sent as a resume command when a builder connects, so that the
+// builder replays what came after it, and as an ack so that the builder can forget what
+// came before it.
+type SequenceCtrl struct {
+	Sequence int `json:"sequence"`
+}
+
+func newResumeCommand(sequence int) *BuilderCtrlMessage {
+	return &BuilderCtrlMessage{
+		Command: CommandResume,
+		Payload: &SequenceCtrl{Sequence: sequence},
+	}
+}
+
+func newAckCommand(sequence int) *BuilderCtrlMessage {
+	return &BuilderCtrlMessage{
+		Command: CommandAck,
+		Payload: &SequenceCtrl{Sequence: sequence},
+	}
+}
+
 type KnownHostCtrl struct {
 	KnownHosts []*knownhost.KnownHost `json:"knownHosts"`
 }
@@ -83,6 +113,13 @@ func (c *BuilderCtrlMessage) UnmarshalJSON(b []byte) error {
 			return err
 		}
 		c.Payload = &d
+	} else if c.Command == CommandResume || c.Command == CommandAck {
+		d := SequenceCtrl{}
+		err := json.Unmarshal(rawData, &d)
+		if err != nil {
+			return err
+		}
+		c.Payload = &d
 	} else {
 		return fmt.Errorf("unsupported type in json.Unmarshal: %s", c.Command)
 	}
@@ -99,9 +136,18 @@ type BuilderStreamLineMessage struct {
 	Output     string `json:"output"`
 }
 
+// BuilderBuildsMessage lists the builds a builder is running once it has replayed its
+// messages after connecting.
+type BuilderBuildsMessage struct {
+	BuildIDs []string `json:"buildIds"`
+}
+
 type BuilderRespMessage struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Type string `json:"type"`
+	// Sequence numbers the messages a builder has to deliver, so that replayed messages
+	// are only handled once. Messages without one are only sent on the current connection.
+	Sequence int         `json:"sequence"`
+	Data     interface{} `json:"data"`
 }
 
 func (c *BuilderRespMessage) UnmarshalJSON(b []byte) error {
@@ -118,6 +164,12 @@ func (c *BuilderRespMessage) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	if val, _ := objMap["sequence"]; val != nil {
+		if err := json.Unmarshal(*val, &c.Sequence); err != nil {
+			return err
+		}
+	}
+
 	// Deserialize Data by command
 	var rawData json.RawMessage
 	err = json.Unmarshal(*objMap["data"], &rawData)
@@ -125,13 +177,20 @@ func (c *BuilderRespMessage) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	if c.Type == "log" {
+	if c.Type == MessageLog {
 		d := BuilderStreamLineMessage{}
 		err := json.Unmarshal(rawData, &d)
 		if err != nil {
 			return err
 		}
 		c.Data = &d
+	} else if c.Type == MessageBuilds {
+		d := BuilderBuildsMessage{}
+		err := json.Unmarshal(rawData, &d)
+		if err != nil {
+			return err
+		}
+		c.Data = &d
 	} else {
 		return fmt.Errorf("unsupported type in json.Unmarshal: %s", c.Type)
 	}
@@ -7,29 +7,71 @@ import (
 	"go.uber.org/zap"
 )
 
-func (m *Manager) monitor(b *Builder) {
+// ackInterval is how many messages are handled from a builder before telling it which it
+// no longer has to keep.
+const ackInterval = 100
+
+func (m *Manager) monitor(b *Builder, t Transport) {
 	for {
 		message := BuilderRespMessage{}
-		err := b.ws.ReadJSON(&message)
+		err := t.ReadJSON(&message)
 		if err != nil {
 			velocity.GetLogger().Error("could not read websocket message", zap.Error(err))
-			m.Delete(b)
-			b.ws.Close()
+			t.Close()
+			m.disconnect(b, t)
 			return
 		}
 
+		// messages are replayed after reconnecting, some of which may have been handled
+		if message.Sequence > 0 && !b.handled(message.Sequence) {
+			continue
+		}
+
 		switch message.Type {
-		case "log":
+		case MessageLog:
 			m.builderLogMessage(message.Data.(*BuilderStreamLineMessage), b)
 			break
+		case MessageBuilds:
+			m.builderBuildsMessage(message.Data.(*BuilderBuildsMessage), b)
+			break
 		default:
 			velocity.GetLogger().Error("got invalid message type from builder", zap.String("message type", message.Type))
 		}
 
+		if message.Sequence > 0 && message.Sequence%ackInterval == 0 {
+			b.send(newAckCommand(message.Sequence))
+		}
+	}
+}
+
+// builderBuildsMessage fails the builds the architect thinks are running on a builder that
+// the builder no longer has, e.g. because it was restarted while disconnected.
+func (m *Manager) builderBuildsMessage(bM *BuilderBuildsMessage, builder *Builder) {
+	running := map[string]bool{}
+	for _, id := range bM.BuildIDs {
+		running[id] = true
+	}
+	for _, b := range builder.Builds() {
+		if running[b.ID] {
+			continue
+		}
+		velocity.GetLogger().Error("builder lost build", zap.String("buildID", b.ID), zap.String("builderID", builder.ID))
+		b.Status = velocity.StateFailed
+		b.CompletedAt = time.Now().UTC()
+		m.buildManager.Update(b)
+		builder.removeBuild(b.ID)
+		m.Save(builder)
 	}
 }
 
 func (m *Manager) builderLogMessage(sL *BuilderStreamLineMessage, builder *Builder) {
+	// builders share one connection between all of their builds, so lines are only
+	// accepted for the builds that were started on them
+	if !builder.isRunning(sL.BuildID) {
+		velocity.GetLogger().Error("got log for build not running on builder", zap.String("buildID", sL.BuildID), zap.String("builderID", builder.ID))
+		return
+	}
+
 	stream, err := m.streamManager.GetByID(sL.StreamID)
 	if err != nil {
 		velocity.GetLogger().Error("could not get stream", zap.String("streamID", sL.StreamID), zap.Error(err))
@@ -96,7 +138,7 @@ func (m *Manager) builderLogMessage(sL *BuilderStreamLineMessage, builder *Build
 		b.CompletedAt = time.Now().UTC()
 		m.buildManager.Update(b)
 
-		builder.State = stateReady
+		builder.removeBuild(b.ID)
 		m.Save(builder)
 	}
 
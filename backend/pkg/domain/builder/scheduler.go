@@ -2,7 +2,6 @@ package builder
 
 import (
 	"sync"
-	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
@@ -11,10 +10,14 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
 )
 
+// buildScheduler starts waiting builds on ready builders. It is added as a broker to the
+// build and builder managers so that it schedules whenever a build is created or finishes,
+// or a builder connects or becomes ready.
 type buildScheduler struct {
 	buildManager   *build.BuildManager
 	builderManager *Manager
-	stop           bool
+	wake           chan struct{}
+	stop           chan struct{}
 	wg             *sync.WaitGroup
 }
 
@@ -22,7 +25,8 @@ func NewScheduler(builderManager *Manager, buildManager *build.BuildManager, wg
 	return &buildScheduler{
 		builderManager: builderManager,
 		buildManager:   buildManager,
-		stop:           false,
+		wake:           make(chan struct{}, 1),
+		stop:           make(chan struct{}),
 		wg:             wg,
 	}
 }
@@ -32,31 +36,85 @@ func (bS *buildScheduler) StartWorker() {
 	// Requeue builds
 	runningBuilds, _ := bS.buildManager.GetRunningBuilds()
 	for _, runningBuild := range runningBuilds {
-		runningBuild.Status = "waiting"
+		runningBuild.Status = velocity.StateWaiting
 		bS.buildManager.Update(runningBuild)
 	}
 	velocity.GetLogger().Info("==> started build scheduler")
-	for bS.stop == false {
-		waitingBuilds, _ := bS.buildManager.GetWaitingBuilds()
-
-		for _, waitingBuild := range waitingBuilds {
-			// Queue on any idle worker
-			activeBuilders, count := bS.builderManager.GetReady(domain.NewPagingQuery())
-			velocity.GetLogger().Debug("got slaves", zap.Int("amount", count))
-			for _, builder := range activeBuilders {
-				bS.builderManager.StartBuild(builder, waitingBuild)
-				velocity.GetLogger().Info("starting build", zap.String("buildID", waitingBuild.ID), zap.String("builderID", builder.ID))
-				break
-			}
+	bS.schedule()
+	for {
+		select {
+		case <-bS.wake:
+			bS.schedule()
+		case <-bS.stop:
+			velocity.GetLogger().Info("==> stopped build scheduler")
+			bS.wg.Done()
+			return
+		}
+	}
+}
+
+func (bS *buildScheduler) StopWorker() {
+	close(bS.stop)
+}
+
+func (bS *buildScheduler) EmitAll(em *domain.Emit) {
+	switch em.Event {
+	case build.EventBuildCreate, build.EventBuildUpdate, EventCreate, EventUpdate:
+		// a pending wake up already covers this
+		select {
+		case bS.wake <- struct{}{}:
+		default:
 		}
+	}
+}
+
+// schedule starts as many builds from the queue as there are ready builders that can run
+// them, without going over the concurrent build limit of their project.
+func (bS *buildScheduler) schedule() {
+	queue := bS.buildManager.GetQueue()
+	if len(queue) < 1 {
+		return
+	}
 
-		time.Sleep(1 * time.Second)
+	running := map[string]int{}
+	runningBuilds, _ := bS.buildManager.GetRunningBuilds()
+	for _, b := range runningBuilds {
+		if b.Task != nil && b.Task.Commit != nil && b.Task.Commit.Project != nil {
+			running[b.Task.Commit.Project.ID]++
+		}
 	}
 
-	velocity.GetLogger().Info("==> stopped build scheduler")
-	bS.wg.Done()
+	for _, waitingBuild := range queue {
+		if waitingBuild.Task == nil || waitingBuild.Task.Commit == nil || waitingBuild.Task.Commit.Project == nil {
+			continue
+		}
+		if !bS.builderManager.hasEligible(waitingBuild.Task.VTask) {
+			bS.setReason(waitingBuild, build.ReasonNoEligibleBuilder)
+			continue
+		}
+		bS.setReason(waitingBuild, "")
+
+		p := waitingBuild.Task.Commit.Project
+		if p.MaxConcurrentBuilds > 0 && running[p.ID] >= p.MaxConcurrentBuilds {
+			continue
+		}
+
+		for _, builder := range bS.builderManager.ready() {
+			if !builder.CanRun(waitingBuild.Task.VTask) {
+				continue
+			}
+			bS.builderManager.StartBuild(builder, waitingBuild)
+			running[p.ID]++
+			velocity.GetLogger().Info("starting build", zap.String("buildID", waitingBuild.ID), zap.String("builderID", builder.ID))
+			break
+		}
+	}
 }
 
-func (bS *buildScheduler) StopWorker() {
-	bS.stop = true
+func (bS *buildScheduler) setReason(b *build.Build, reason string) {
+	if b.Reason == reason {
+		return
+	}
+	b.Reason = reason
+	bS.buildManager.Update(b)
 }
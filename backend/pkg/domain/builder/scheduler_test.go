@@ -0,0 +1,46 @@
+package builder
+
+import (
+	"sync"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+func (s *ManagerSuite) TestScheduleWaitsForEligibleBuilder() {
+	armTask := s.taskManager.Create(s.task.Commit, &velocity.Task{Name: "armTask", Requires: []string{"arch=arm64"}}, velocity.NewSetup())
+	armBuild, errs := s.buildManager.Create(armTask, map[string]string{})
+	s.Nil(errs)
+	scheduler := NewScheduler(s.manager, s.buildManager, &sync.WaitGroup{})
+
+	// no builders at all
+	scheduler.schedule()
+	armBuild, _ = s.buildManager.GetBuildByID(armBuild.ID)
+	s.Equal(velocity.StateWaiting, armBuild.Status)
+	s.Equal(build.ReasonNoEligibleBuilder, armBuild.Reason)
+
+	// only builders without the label, which still run the builds they can
+	amd := s.manager.CreateBuilder(newFakeTransport(), "amd64", nil, []string{"arch=amd64"}, 2)
+	anyBuild, errs := s.buildManager.Create(s.task, map[string]string{})
+	s.Nil(errs)
+	scheduler.schedule()
+	armBuild, _ = s.buildManager.GetBuildByID(armBuild.ID)
+	s.Equal(velocity.StateWaiting, armBuild.Status)
+	s.Equal(build.ReasonNoEligibleBuilder, armBuild.Reason)
+	s.True(amd.isRunning(anyBuild.ID))
+	s.False(amd.isRunning(armBuild.ID))
+
+	// an eligible builder that is busy clears the reason, but doesn't start the build
+	arm := s.manager.CreateBuilder(newFakeTransport(), "arm64", nil, []string{"arch=arm64"}, 1)
+	arm.addBuild(&build.Build{ID: "other"})
+	scheduler.schedule()
+	armBuild, _ = s.buildManager.GetBuildByID(armBuild.ID)
+	s.Equal(velocity.StateWaiting, armBuild.Status)
+	s.Empty(armBuild.Reason)
+
+	arm.removeBuild("other")
+	scheduler.schedule()
+	armBuild, _ = s.buildManager.GetBuildByID(armBuild.ID)
+	s.Equal(velocity.StateRunning, armBuild.Status)
+	s.True(arm.isRunning(armBuild.ID))
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/asdine/storm"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 
 	uuid "github.com/satori/go.uuid"
@@ -17,14 +16,24 @@ const (
 	EventBranchUpdate = "branch:update"
 )
 
+// BranchRepository stores branches and which commits they contain.
+type BranchRepository interface {
+	Save(b *Branch) error
+	GetByID(id string) (*Branch, error)
+	GetByProjectAndName(p *project.Project, name string) (*Branch, error)
+	GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Branch, int)
+	GetAllForCommit(c *Commit, q *domain.PagingQuery) ([]*Branch, int)
+	HasCommit(b *Branch, c *Commit) bool
+}
+
 type BranchManager struct {
-	db      *branchStormDB
+	db      BranchRepository
 	brokers []domain.Broker
 }
 
-func NewBranchManager(db *storm.DB) *BranchManager {
+func NewBranchManager(db BranchRepository) *BranchManager {
 	return &BranchManager{
-		db:      newBranchStormDB(db),
+		db:      db,
 		brokers: []domain.Broker{},
 	}
 }
@@ -44,7 +53,7 @@ func (m *BranchManager) Create(
 		LastUpdated: time.Now().UTC(),
 		Active:      true,
 	}
-	m.db.save(b)
+	m.db.Save(b)
 
 	for _, broker := range m.brokers {
 		broker.EmitAll(&domain.Emit{
@@ -58,7 +67,7 @@ func (m *BranchManager) Create(
 }
 
 func (m *BranchManager) Update(b *Branch) error {
-	if err := m.db.save(b); err != nil {
+	if err := m.db.Save(b); err != nil {
 		return err
 	}
 	for _, br := range m.brokers {
@@ -72,17 +81,17 @@ func (m *BranchManager) Update(b *Branch) error {
 }
 
 func (m *BranchManager) GetByProjectAndName(p *project.Project, name string) (*Branch, error) {
-	return m.db.getByProjectAndName(p, name)
+	return m.db.GetByProjectAndName(p, name)
 }
 
 func (m *BranchManager) GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Branch, int) {
-	return m.db.getAllForProject(p, q)
+	return m.db.GetAllForProject(p, q)
 }
 
 func (m *BranchManager) GetAllForCommit(c *Commit, q *domain.PagingQuery) ([]*Branch, int) {
-	return m.db.getAllForCommit(c, q)
+	return m.db.GetAllForCommit(c, q)
 }
 
 func (m *BranchManager) HasCommit(b *Branch, c *Commit) bool {
-	return m.db.hasCommit(b, c)
+	return m.db.HasCommit(b, c)
 }
@@ -0,0 +1,118 @@
+package githistory
+
+import (
+	"database/sql"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type branchSQLiteDB struct {
+	*sql.DB
+	projects project.Repository
+}
+
+// NewBranchSQLiteRepository returns a branch repository backed by a migrated SQLite database.
+func NewBranchSQLiteRepository(db *sql.DB) BranchRepository {
+	return &branchSQLiteDB{
+		DB:       db,
+		projects: project.NewSQLiteRepository(db),
+	}
+}
+
+const sqliteBranchColumns = `id, project_id, name, last_updated, active`
+
+func (db *branchSQLiteDB) Save(b *Branch) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO branches (`+sqliteBranchColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		b.ID, b.Project.ID, b.Name, b.LastUpdated, b.Active,
+	)
+	return err
+}
+
+// find returns the branches selected by the query, loading their projects once the rows
+// have been read.
+func (db *branchSQLiteDB) find(query string, args ...interface{}) (r []*Branch) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	projectIDs := []string{}
+	for rows.Next() {
+		b := &Branch{}
+		var projectID string
+		if err := rows.Scan(&b.ID, &projectID, &b.Name, &b.LastUpdated, &b.Active); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		r = append(r, b)
+		projectIDs = append(projectIDs, projectID)
+	}
+	rows.Close()
+
+	projects := map[string]*project.Project{}
+	for i, b := range r {
+		p, ok := projects[projectIDs[i]]
+		if !ok {
+			if p, err = db.projects.GetByID(projectIDs[i]); err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+			projects[projectIDs[i]] = p
+		}
+		b.Project = p
+	}
+
+	return r
+}
+
+func (db *branchSQLiteDB) first(query string, args ...interface{}) (*Branch, error) {
+	r := db.find(query, args...)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *branchSQLiteDB) GetByID(id string) (*Branch, error) {
+	return db.first(`SELECT `+sqliteBranchColumns+` FROM branches WHERE id = ?`, id)
+}
+
+func (db *branchSQLiteDB) GetByProjectAndName(p *project.Project, name string) (*Branch, error) {
+	return db.first(`SELECT `+sqliteBranchColumns+` FROM branches WHERE project_id = ? AND name = ? LIMIT 1`, p.ID, name)
+}
+
+func (db *branchSQLiteDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) (r []*Branch, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM branches WHERE project_id = ?`, p.ID).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	return db.find(`SELECT `+sqliteBranchColumns+` FROM branches WHERE project_id = ? ORDER BY name LIMIT ? OFFSET ?`,
+		p.ID, pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	), t
+}
+
+func (db *branchSQLiteDB) GetAllForCommit(c *Commit, pQ *domain.PagingQuery) (r []*Branch, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM branch_commits WHERE commit_id = ?`, c.ID).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	return db.find(`SELECT `+sqliteBranchColumns+` FROM branches
+		WHERE id IN (SELECT branch_id FROM branch_commits WHERE commit_id = ?)
+		ORDER BY name LIMIT ? OFFSET ?`,
+		c.ID, pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	), t
+}
+
+func (db *branchSQLiteDB) HasCommit(b *Branch, c *Commit) bool {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM branch_commits WHERE branch_id = ? AND commit_id = ?`, b.ID, c.ID).Scan(&n); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return false
+	}
+
+	return n > 0
+}
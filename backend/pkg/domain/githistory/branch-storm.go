@@ -62,13 +62,18 @@ type branchStormDB struct {
 	*storm.DB
 }
 
+// NewBranchStormRepository returns a branch repository backed by storm.
+func NewBranchStormRepository(db *storm.DB) BranchRepository {
+	return newBranchStormDB(db)
+}
+
 func newBranchStormDB(db *storm.DB) *branchStormDB {
 	db.Init(&Branch{})
 	db.Init(&Commit{})
 	return &branchStormDB{db}
 }
 
-func (db *branchStormDB) save(b *Branch) error {
+func (db *branchStormDB) Save(b *Branch) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -82,7 +87,7 @@ func (db *branchStormDB) save(b *Branch) error {
 	return tx.Commit()
 }
 
-func (db *branchStormDB) getAllForProject(p *project.Project, pQ *domain.PagingQuery) (r []*Branch, t int) {
+func (db *branchStormDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) (r []*Branch, t int) {
 	t = 0
 	query := db.Select(q.Eq("ProjectID", p.ID))
 	t, err := query.Count(&StormBranch{})
@@ -100,7 +105,7 @@ func (db *branchStormDB) getAllForProject(p *project.Project, pQ *domain.PagingQ
 	return r, t
 }
 
-func (db *branchStormDB) getAllForCommit(c *Commit, pQ *domain.PagingQuery) (r []*Branch, t int) {
+func (db *branchStormDB) GetAllForCommit(c *Commit, pQ *domain.PagingQuery) (r []*Branch, t int) {
 	t = 0
 	query := db.Select(q.Eq("CommitID", c.ID))
 	t, err := query.Count(&branchCommitStorm{})
@@ -126,7 +131,7 @@ func (db *branchStormDB) getAllForCommit(c *Commit, pQ *domain.PagingQuery) (r [
 	return r, t
 }
 
-func (db *branchStormDB) hasCommit(b *Branch, c *Commit) bool {
+func (db *branchStormDB) HasCommit(b *Branch, c *Commit) bool {
 	query := db.Select(q.And(q.Eq("CommitID", c.ID), q.Eq("BranchID", b.ID)))
 	if err := query.First(&branchCommitStorm{}); err != nil {
 		return false
@@ -135,6 +140,10 @@ func (db *branchStormDB) hasCommit(b *Branch, c *Commit) bool {
 	return true
 }
 
+func (db *branchStormDB) GetByID(id string) (*Branch, error) {
+	return GetBranchByID(db.DB, id)
+}
+
 func GetBranchByID(db *storm.DB, id string) (*Branch, error) {
 	var b StormBranch
 	if err := db.One("ID", id, &b); err != nil {
@@ -143,7 +152,7 @@ func GetBranchByID(db *storm.DB, id string) (*Branch, error) {
 	return b.ToBranch(db), nil
 }
 
-func (db *branchStormDB) getByProjectAndName(p *project.Project, name string) (*Branch, error) {
+func (db *branchStormDB) GetByProjectAndName(p *project.Project, name string) (*Branch, error) {
 	query := db.Select(q.And(q.Eq("ProjectID", p.ID), q.Eq("Name", name)))
 	var b StormBranch
 	if err := query.First(&b); err != nil {
@@ -152,3 +161,26 @@ func (db *branchStormDB) getByProjectAndName(p *project.Project, name string) (*
 
 	return b.ToBranch(db.DB), nil
 }
+
+// MigrateBranchesStorm copies the branches in a storm database into another repository,
+// skipping branches of projects that no longer exist.
+func MigrateBranchesStorm(from *storm.DB, to BranchRepository) (int, error) {
+	var stormBranches []*StormBranch
+	if err := from.All(&stormBranches); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	n := 0
+	for _, sB := range stormBranches {
+		b := sB.ToBranch(from)
+		if b.Project == nil {
+			velocity.GetLogger().Warn("skipping branch without project", zap.String("branchID", b.ID))
+			continue
+		}
+		if err := to.Save(b); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
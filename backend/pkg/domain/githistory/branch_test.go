@@ -45,8 +45,8 @@ func (s *BranchSuite) SetupTest() {
 	syncMock := func(*velocity.GitRepository) (bool, error) {
 		return true, nil
 	}
-	s.projectManager = project.NewManager(s.storm, validator, translator, syncMock)
-	s.commitManager = githistory.NewCommitManager(s.storm)
+	s.projectManager = project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
 }
 
 func (s *BranchSuite) TearDownTest() {
@@ -59,7 +59,7 @@ func (s *BranchSuite) TestCreate() {
 		Address: "testGit",
 	})
 
-	m := githistory.NewBranchManager(s.storm)
+	m := githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
 
 	b := m.Create(p, "testBranch")
 	s.NotNil(b)
@@ -73,7 +73,7 @@ func (s *BranchSuite) TestUpdate() {
 		Address: "testGit",
 	})
 
-	m := githistory.NewBranchManager(s.storm)
+	m := githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
 
 	b := m.Create(p, "testBranch")
 
@@ -92,7 +92,7 @@ func (s *BranchSuite) TestGetByProjectAndName() {
 		Address: "testGit",
 	})
 
-	m := githistory.NewBranchManager(s.storm)
+	m := githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
 
 	m.Create(p, "testBranch")
 
@@ -106,7 +106,7 @@ func (s *BranchSuite) TestGetAllForProject() {
 		Address: "testGit",
 	})
 
-	m := githistory.NewBranchManager(s.storm)
+	m := githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
 
 	b1 := m.Create(p, "testBranch")
 	b2 := m.Create(p, "2estBranch")
@@ -124,7 +124,7 @@ func (s *BranchSuite) TestGetAllForCommit() {
 		Address: "testGit",
 	})
 
-	m := githistory.NewBranchManager(s.storm)
+	m := githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
 
 	b1 := m.Create(p, "testBranch")
 	b2 := m.Create(p, "2estBranch")
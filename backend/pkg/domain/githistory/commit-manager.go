@@ -3,7 +3,6 @@ package githistory
 import (
 	"time"
 
-	"github.com/asdine/storm"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
@@ -15,16 +14,26 @@ const (
 	EventCommitUpdate = "commit:update"
 )
 
+// CommitRepository stores commits.
+type CommitRepository interface {
+	// Save saves the commit and adds it to the branch when one is given.
+	Save(c *Commit, b *Branch) error
+	GetByID(id string) (*Commit, error)
+	GetByProjectAndHash(p *project.Project, hash string) (*Commit, error)
+	GetAllForProject(p *project.Project, q *CommitQuery) ([]*Commit, int)
+	GetAllForBranch(b *Branch, q *domain.PagingQuery) ([]*Commit, int)
+}
+
 type CommitManager struct {
-	db      *commitStormDB
+	db      CommitRepository
 	brokers []domain.Broker
 }
 
 func NewCommitManager(
-	db *storm.DB,
+	db CommitRepository,
 ) *CommitManager {
 	m := &CommitManager{
-		db:      newCommitStormDB(db),
+		db:      db,
 		brokers: []domain.Broker{},
 	}
 	return m
@@ -52,7 +61,7 @@ func (m *CommitManager) Create(
 		Signed:    signed,
 		CreatedAt: date.UTC(),
 	}
-	m.db.saveCommitToBranch(c, b)
+	m.db.Save(c, b)
 	for _, b := range m.brokers {
 		b.EmitAll(&domain.Emit{
 			Topic:   "commits",
@@ -63,8 +72,21 @@ func (m *CommitManager) Create(
 	return c
 }
 
+func (m *CommitManager) Update(c *Commit) error {
+	if err := m.db.Save(c, nil); err != nil {
+		return err
+	}
+	for _, broker := range m.brokers {
+		broker.EmitAll(&domain.Emit{
+			Event:   EventCommitUpdate,
+			Payload: c,
+		})
+	}
+	return nil
+}
+
 func (m *CommitManager) AddCommitToBranch(c *Commit, b *Branch) error {
-	if err := m.db.saveCommitToBranch(c, b); err != nil {
+	if err := m.db.Save(c, b); err != nil {
 		return err
 	}
 	for _, broker := range m.brokers {
@@ -83,13 +105,13 @@ func (m *CommitManager) AddCommitToBranch(c *Commit, b *Branch) error {
 }
 
 func (m *CommitManager) GetAllForProject(p *project.Project, q *CommitQuery) ([]*Commit, int) {
-	return m.db.getAllForProject(p, q)
+	return m.db.GetAllForProject(p, q)
 }
 
 func (m *CommitManager) GetAllForBranch(b *Branch, q *domain.PagingQuery) ([]*Commit, int) {
-	return m.db.getAllForBranch(b, q)
+	return m.db.GetAllForBranch(b, q)
 }
 
 func (m *CommitManager) GetByProjectAndHash(p *project.Project, hash string) (*Commit, error) {
-	return m.db.getByProjectAndHash(p, hash)
+	return m.db.GetByProjectAndHash(p, hash)
 }
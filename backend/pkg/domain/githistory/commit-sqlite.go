@@ -0,0 +1,149 @@
+package githistory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type commitSQLiteDB struct {
+	*sql.DB
+	projects project.Repository
+}
+
+// NewCommitSQLiteRepository returns a commit repository backed by a migrated SQLite database.
+func NewCommitSQLiteRepository(db *sql.DB) CommitRepository {
+	return &commitSQLiteDB{
+		DB:       db,
+		projects: project.NewSQLiteRepository(db),
+	}
+}
+
+const sqliteCommitColumns = `id, project_id, hash, author, created_at, message, signed, signature`
+
+func (db *commitSQLiteDB) Save(c *Commit, b *Branch) error {
+	signature, err := json.Marshal(c.Signature)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO commits (`+sqliteCommitColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID, c.Project.ID, c.Hash, c.Author, c.CreatedAt, c.Message, c.Signed, signature,
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// commits that are only tagged don't belong to a branch
+	if b != nil {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO branch_commits (branch_id, commit_id) VALUES (?, ?)`, b.ID, c.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// find returns the commits selected by the query, loading their projects once the rows
+// have been read.
+func (db *commitSQLiteDB) find(query string, args ...interface{}) (r []*Commit) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	projectIDs := []string{}
+	for rows.Next() {
+		c := &Commit{}
+		var projectID string
+		var signature []byte
+		if err := rows.Scan(&c.ID, &projectID, &c.Hash, &c.Author, &c.CreatedAt, &c.Message, &c.Signed, &signature); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		if err := json.Unmarshal(signature, &c.Signature); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+		}
+		r = append(r, c)
+		projectIDs = append(projectIDs, projectID)
+	}
+	rows.Close()
+
+	projects := map[string]*project.Project{}
+	for i, c := range r {
+		p, ok := projects[projectIDs[i]]
+		if !ok {
+			if p, err = db.projects.GetByID(projectIDs[i]); err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+			projects[projectIDs[i]] = p
+		}
+		c.Project = p
+	}
+
+	return r
+}
+
+func (db *commitSQLiteDB) GetByID(id string) (*Commit, error) {
+	r := db.find(`SELECT `+sqliteCommitColumns+` FROM commits WHERE id = ?`, id)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *commitSQLiteDB) GetByProjectAndHash(p *project.Project, hash string) (*Commit, error) {
+	r := db.find(`SELECT `+sqliteCommitColumns+` FROM commits WHERE project_id = ? AND hash = ? LIMIT 1`, p.ID, hash)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *commitSQLiteDB) GetAllForProject(p *project.Project, pQ *CommitQuery) (r []*Commit, t int) {
+	where := `project_id = ?`
+	args := []interface{}{p.ID}
+	if len(pQ.Branches) > 0 {
+		where += ` AND id IN (
+			SELECT branch_commits.commit_id FROM branch_commits
+			JOIN branches ON branches.id = branch_commits.branch_id
+			WHERE branches.project_id = ? AND branches.name IN (?` + strings.Repeat(`, ?`, len(pQ.Branches)-1) + `)
+		)`
+		args = append(args, p.ID)
+		for _, b := range pQ.Branches {
+			args = append(args, b)
+		}
+	}
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM commits WHERE `+where, args...).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	return db.find(`SELECT `+sqliteCommitColumns+` FROM commits WHERE `+where+` ORDER BY created_at DESC LIMIT ? OFFSET ?`,
+		append(args, pQ.Limit, (pQ.Page-1)*pQ.Limit)...,
+	), t
+}
+
+func (db *commitSQLiteDB) GetAllForBranch(b *Branch, pQ *domain.PagingQuery) (r []*Commit, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM branch_commits WHERE branch_id = ?`, b.ID).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	return db.find(`SELECT `+sqliteCommitColumns+` FROM commits
+		WHERE id IN (SELECT commit_id FROM branch_commits WHERE branch_id = ?)
+		ORDER BY created_at DESC LIMIT ? OFFSET ?`,
+		b.ID, pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	), t
+}
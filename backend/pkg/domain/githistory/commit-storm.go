@@ -19,6 +19,7 @@ type StormCommit struct {
 	CreatedAt time.Time
 	Message   string
 	Signed    string
+	Signature velocity.SignatureVerification
 }
 
 func (s *StormCommit) ToCommit(db *storm.DB) *Commit {
@@ -34,6 +35,7 @@ func (s *StormCommit) ToCommit(db *storm.DB) *Commit {
 		CreatedAt: s.CreatedAt,
 		Message:   s.Message,
 		Signed:    s.Signed,
+		Signature: s.Signature,
 	}
 }
 
@@ -46,6 +48,7 @@ func (c *Commit) ToStormCommit() *StormCommit {
 		CreatedAt: c.CreatedAt,
 		Message:   c.Message,
 		Signed:    c.Signed,
+		Signature: c.Signature,
 	}
 }
 
@@ -53,13 +56,18 @@ type commitStormDB struct {
 	*storm.DB
 }
 
+// NewCommitStormRepository returns a commit repository backed by storm.
+func NewCommitStormRepository(db *storm.DB) CommitRepository {
+	return newCommitStormDB(db)
+}
+
 func newCommitStormDB(db *storm.DB) *commitStormDB {
 	db.Init(&Branch{})
 	db.Init(&Commit{})
 	return &commitStormDB{db}
 }
 
-func (db *commitStormDB) saveCommitToBranch(c *Commit, b *Branch) error {
+func (db *commitStormDB) Save(c *Commit, b *Branch) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -70,16 +78,19 @@ func (db *commitStormDB) saveCommitToBranch(c *Commit, b *Branch) error {
 		return err
 	}
 
-	bC := newBranchCommitStorm(b, c)
-	if err := tx.Save(bC); err != nil {
-		tx.Rollback()
-		return err
+	// commits that are only tagged don't belong to a branch
+	if b != nil {
+		bC := newBranchCommitStorm(b, c)
+		if err := tx.Save(bC); err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
 	return tx.Commit()
 }
 
-func (db *commitStormDB) getByProjectAndHash(p *project.Project, hash string) (*Commit, error) {
+func (db *commitStormDB) GetByProjectAndHash(p *project.Project, hash string) (*Commit, error) {
 	query := db.Select(q.And(q.Eq("ProjectID", p.ID), q.Eq("Hash", hash)))
 	var c StormCommit
 	if err := query.First(&c); err != nil {
@@ -89,7 +100,7 @@ func (db *commitStormDB) getByProjectAndHash(p *project.Project, hash string) (*
 	return c.ToCommit(db.DB), nil
 }
 
-func (db *commitStormDB) getAllForProject(p *project.Project, pQ *CommitQuery) (r []*Commit, t int) {
+func (db *commitStormDB) GetAllForProject(p *project.Project, pQ *CommitQuery) (r []*Commit, t int) {
 	if len(pQ.Branches) > 0 {
 		return db.getAllForProjectBranchFilter(p, pQ)
 	}
@@ -155,7 +166,7 @@ func isStormBranchInBranchNames(sBs []*StormBranch, branches []string) bool {
 	return false
 }
 
-func (db *commitStormDB) getAllForBranch(b *Branch, pQ *domain.PagingQuery) (r []*Commit, t int) {
+func (db *commitStormDB) GetAllForBranch(b *Branch, pQ *domain.PagingQuery) (r []*Commit, t int) {
 	t = 0
 
 	query := db.Select(q.Eq("BranchID", b.ID))
@@ -182,6 +193,10 @@ func (db *commitStormDB) getAllForBranch(b *Branch, pQ *domain.PagingQuery) (r [
 	return r, t
 }
 
+func (db *commitStormDB) GetByID(id string) (*Commit, error) {
+	return GetCommitByID(db.DB, id)
+}
+
 func GetCommitByID(db *storm.DB, id string) (*Commit, error) {
 	var c StormCommit
 	if err := db.One("ID", id, &c); err != nil {
@@ -189,3 +204,44 @@ func GetCommitByID(db *storm.DB, id string) (*Commit, error) {
 	}
 	return c.ToCommit(db), nil
 }
+
+// MigrateCommitsStorm copies the commits in a storm database, and the branches they are
+// on, into another repository. Branches must be migrated first.
+func MigrateCommitsStorm(from *storm.DB, to CommitRepository) (int, error) {
+	var stormCommits []*StormCommit
+	if err := from.All(&stormCommits); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	n := 0
+	for _, sC := range stormCommits {
+		c := sC.ToCommit(from)
+		if c.Project == nil {
+			velocity.GetLogger().Warn("skipping commit without project", zap.String("commitID", c.ID))
+			continue
+		}
+		if err := to.Save(c, nil); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	var branchCommits []*branchCommitStorm
+	if err := from.All(&branchCommits); err != nil && err != storm.ErrNotFound {
+		return n, err
+	}
+	for _, bC := range branchCommits {
+		b, err := GetBranchByID(from, bC.BranchID)
+		if err != nil || b.Project == nil {
+			continue
+		}
+		c, err := to.GetByID(bC.CommitID)
+		if err != nil {
+			continue
+		}
+		if err := to.Save(c, b); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
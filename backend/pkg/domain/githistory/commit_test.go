@@ -45,8 +45,8 @@ func (s *CommitSuite) SetupTest() {
 	syncMock := func(*velocity.GitRepository) (bool, error) {
 		return true, nil
 	}
-	s.projectManager = project.NewManager(s.storm, validator, translator, syncMock)
-	s.branchManager = githistory.NewBranchManager(s.storm)
+	s.projectManager = project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+	s.branchManager = githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
 }
 
 func (s *CommitSuite) TearDownTest() {
@@ -56,7 +56,7 @@ func (s *CommitSuite) TearDownTest() {
 
 func (s *CommitSuite) TestNew() {
 
-	m := githistory.NewCommitManager(s.storm)
+	m := githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
 
 	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
 		Address: "testGit",
@@ -77,7 +77,7 @@ func (s *CommitSuite) TestNew() {
 }
 
 func (s *CommitSuite) TestGetByProjectAndHash() {
-	m := githistory.NewCommitManager(s.storm)
+	m := githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
 
 	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
 		Address: "testGit",
@@ -96,7 +96,7 @@ func (s *CommitSuite) TestGetByProjectAndHash() {
 }
 
 func (s *CommitSuite) TestGetAllForProject() {
-	m := githistory.NewCommitManager(s.storm)
+	m := githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
 
 	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
 		Address: "testGit",
@@ -137,7 +137,7 @@ func (s *CommitSuite) TestGetAllForProject() {
 }
 
 func (s *CommitSuite) TestGetAllForProjectBranchFilter() {
-	m := githistory.NewCommitManager(s.storm)
+	m := githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
 
 	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
 		Address: "testGit",
@@ -183,7 +183,7 @@ func (s *CommitSuite) TestGetAllForProjectBranchFilter() {
 }
 
 func (s *CommitSuite) TestGetAllForBranch() {
-	m := githistory.NewCommitManager(s.storm)
+	m := githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
 
 	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
 		Address: "testGit",
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 )
 
 type Commit struct {
@@ -14,6 +15,8 @@ type Commit struct {
 	CreatedAt time.Time        `json:"createdAt"`
 	Message   string           `json:"message"`
 	Signed    string           `json:"signed"`
+
+	Signature velocity.SignatureVerification `json:"signature"`
 }
 
 type CommitQuery struct {
@@ -37,3 +40,11 @@ type Branch struct {
 	LastUpdated time.Time        `json:"lastUpdated"`
 	Active      bool             `json:"active"`
 }
+
+type Tag struct {
+	ID          string           `json:"id"`
+	Project     *project.Project `json:"project"`
+	Name        string           `json:"name"`
+	Commit      *Commit          `json:"commit"`
+	LastUpdated time.Time        `json:"lastUpdated"`
+}
@@ -0,0 +1,108 @@
+package githistory
+
+import (
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+)
+
+// Event constants
+const (
+	EventTagCreate = "tag:new"
+	EventTagUpdate = "tag:update"
+	EventTagDelete = "tag:delete"
+)
+
+// TagRepository stores tags.
+type TagRepository interface {
+	Save(t *Tag) error
+	Delete(t *Tag) error
+	GetByProjectAndName(p *project.Project, name string) (*Tag, error)
+	GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Tag, int)
+	GetAllForCommit(c *Commit) []*Tag
+}
+
+type TagManager struct {
+	db      TagRepository
+	brokers []domain.Broker
+}
+
+func NewTagManager(db TagRepository) *TagManager {
+	return &TagManager{
+		db:      db,
+		brokers: []domain.Broker{},
+	}
+}
+
+func (m *TagManager) AddBroker(b domain.Broker) {
+	m.brokers = append(m.brokers, b)
+}
+
+func (m *TagManager) Create(
+	c *Commit,
+	name string,
+) *Tag {
+	t := &Tag{
+		ID:          uuid.NewV3(uuid.NewV1(), c.Project.ID).String(),
+		Project:     c.Project,
+		Name:        name,
+		Commit:      c,
+		LastUpdated: time.Now().UTC(),
+	}
+	m.db.Save(t)
+
+	for _, broker := range m.brokers {
+		broker.EmitAll(&domain.Emit{
+			Topic:   "tags",
+			Event:   EventTagCreate,
+			Payload: t,
+		})
+	}
+
+	return t
+}
+
+func (m *TagManager) Update(t *Tag) error {
+	t.LastUpdated = time.Now().UTC()
+	if err := m.db.Save(t); err != nil {
+		return err
+	}
+	for _, br := range m.brokers {
+		br.EmitAll(&domain.Emit{
+			Topic:   fmt.Sprintf("tag:%s", t.ID),
+			Event:   EventTagUpdate,
+			Payload: t,
+		})
+	}
+	return nil
+}
+
+// Delete removes a tag that no longer exists in the repository.
+func (m *TagManager) Delete(t *Tag) error {
+	if err := m.db.Delete(t); err != nil {
+		return err
+	}
+	for _, br := range m.brokers {
+		br.EmitAll(&domain.Emit{
+			Topic:   fmt.Sprintf("tag:%s", t.ID),
+			Event:   EventTagDelete,
+			Payload: t,
+		})
+	}
+	return nil
+}
+
+func (m *TagManager) GetByProjectAndName(p *project.Project, name string) (*Tag, error) {
+	return m.db.GetByProjectAndName(p, name)
+}
+
+func (m *TagManager) GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Tag, int) {
+	return m.db.GetAllForProject(p, q)
+}
+
+func (m *TagManager) GetAllForCommit(c *Commit) []*Tag {
+	return m.db.GetAllForCommit(c)
+}
@@ -0,0 +1,94 @@
+package githistory
+
+import (
+	"database/sql"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type tagSQLiteDB struct {
+	*sql.DB
+	commits CommitRepository
+}
+
+// NewTagSQLiteRepository returns a tag repository backed by a migrated SQLite database.
+func NewTagSQLiteRepository(db *sql.DB) TagRepository {
+	return &tagSQLiteDB{
+		DB:      db,
+		commits: NewCommitSQLiteRepository(db),
+	}
+}
+
+const sqliteTagColumns = `id, project_id, commit_id, name, last_updated`
+
+func (db *tagSQLiteDB) Save(t *Tag) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO tags (`+sqliteTagColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		t.ID, t.Project.ID, t.Commit.ID, t.Name, t.LastUpdated,
+	)
+	return err
+}
+
+func (db *tagSQLiteDB) Delete(t *Tag) error {
+	_, err := db.Exec(`DELETE FROM tags WHERE id = ?`, t.ID)
+	return err
+}
+
+// find returns the tags selected by the query, loading their commits once the rows have
+// been read. A tag's project is its commit's project.
+func (db *tagSQLiteDB) find(query string, args ...interface{}) (r []*Tag) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	commitIDs := []string{}
+	for rows.Next() {
+		t := &Tag{}
+		var projectID, commitID string
+		if err := rows.Scan(&t.ID, &projectID, &commitID, &t.Name, &t.LastUpdated); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		r = append(r, t)
+		commitIDs = append(commitIDs, commitID)
+	}
+	rows.Close()
+
+	for i, t := range r {
+		c, err := db.commits.GetByID(commitIDs[i])
+		if err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		t.Commit = c
+		t.Project = c.Project
+	}
+
+	return r
+}
+
+func (db *tagSQLiteDB) GetByProjectAndName(p *project.Project, name string) (*Tag, error) {
+	r := db.find(`SELECT `+sqliteTagColumns+` FROM tags WHERE project_id = ? AND name = ? LIMIT 1`, p.ID, name)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *tagSQLiteDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) (r []*Tag, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tags WHERE project_id = ?`, p.ID).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	return db.find(`SELECT `+sqliteTagColumns+` FROM tags WHERE project_id = ? ORDER BY last_updated DESC LIMIT ? OFFSET ?`,
+		p.ID, pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	), t
+}
+
+func (db *tagSQLiteDB) GetAllForCommit(c *Commit) []*Tag {
+	return db.find(`SELECT `+sqliteTagColumns+` FROM tags WHERE commit_id = ?`, c.ID)
+}
@@ -0,0 +1,152 @@
+package githistory
+
+import (
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type StormTag struct {
+	ID          string `storm:"id"`
+	ProjectID   string `storm:"index"`
+	CommitID    string `storm:"index"`
+	Name        string
+	LastUpdated time.Time
+}
+
+func (s *StormTag) ToTag(db *storm.DB) *Tag {
+	p, err := project.GetByID(db, s.ProjectID)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	c, err := GetCommitByID(db, s.CommitID)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	return &Tag{
+		ID:          s.ID,
+		Project:     p,
+		Name:        s.Name,
+		Commit:      c,
+		LastUpdated: s.LastUpdated,
+	}
+}
+
+func (t *Tag) ToStormTag() *StormTag {
+	return &StormTag{
+		ID:          t.ID,
+		ProjectID:   t.Project.ID,
+		CommitID:    t.Commit.ID,
+		Name:        t.Name,
+		LastUpdated: t.LastUpdated,
+	}
+}
+
+type tagStormDB struct {
+	*storm.DB
+}
+
+// NewTagStormRepository returns a tag repository backed by storm.
+func NewTagStormRepository(db *storm.DB) TagRepository {
+	return newTagStormDB(db)
+}
+
+func newTagStormDB(db *storm.DB) *tagStormDB {
+	db.Init(&Tag{})
+	return &tagStormDB{db}
+}
+
+func (db *tagStormDB) Save(t *Tag) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Save(t.ToStormTag()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *tagStormDB) Delete(t *Tag) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteStruct(&StormTag{ID: t.ID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *tagStormDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) (r []*Tag, t int) {
+	t = 0
+	query := db.Select(q.Eq("ProjectID", p.ID)).OrderBy("LastUpdated").Reverse()
+	t, err := query.Count(&StormTag{})
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, t
+	}
+	query.Limit(pQ.Limit).Skip((pQ.Page - 1) * pQ.Limit)
+	var stormTags []*StormTag
+	query.Find(&stormTags)
+	for _, sT := range stormTags {
+		r = append(r, sT.ToTag(db.DB))
+	}
+
+	return r, t
+}
+
+func (db *tagStormDB) GetAllForCommit(c *Commit) (r []*Tag) {
+	query := db.Select(q.Eq("CommitID", c.ID))
+	var stormTags []*StormTag
+	query.Find(&stormTags)
+	for _, sT := range stormTags {
+		r = append(r, sT.ToTag(db.DB))
+	}
+
+	return r
+}
+
+func (db *tagStormDB) GetByProjectAndName(p *project.Project, name string) (*Tag, error) {
+	query := db.Select(q.And(q.Eq("ProjectID", p.ID), q.Eq("Name", name)))
+	var t StormTag
+	if err := query.First(&t); err != nil {
+		return nil, err
+	}
+
+	return t.ToTag(db.DB), nil
+}
+
+// MigrateTagsStorm copies the tags in a storm database into another repository, skipping
+// tags of commits that no longer exist.
+func MigrateTagsStorm(from *storm.DB, to TagRepository) (int, error) {
+	var stormTags []*StormTag
+	if err := from.All(&stormTags); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	n := 0
+	for _, sT := range stormTags {
+		t := sT.ToTag(from)
+		if t.Project == nil || t.Commit == nil {
+			velocity.GetLogger().Warn("skipping tag without commit", zap.String("tagID", t.ID))
+			continue
+		}
+		if err := to.Save(t); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -0,0 +1,133 @@
+package githistory_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/suite"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+type TagSuite struct {
+	suite.Suite
+	storm          *storm.DB
+	dbPath         string
+	projectManager *project.Manager
+	commitManager  *githistory.CommitManager
+}
+
+func TestTagSuite(t *testing.T) {
+	suite.Run(t, new(TagSuite))
+}
+
+func (s *TagSuite) SetupTest() {
+	// Retrieve a temporary path.
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		panic(err)
+	}
+	s.dbPath = f.Name()
+	f.Close()
+	os.Remove(s.dbPath)
+	// Open the database.
+	s.storm, err = storm.Open(s.dbPath)
+	if err != nil {
+		panic(err)
+	}
+
+	validator, translator := domain.NewValidator()
+	syncMock := func(*velocity.GitRepository) (bool, error) {
+		return true, nil
+	}
+	s.projectManager = project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
+}
+
+func (s *TagSuite) TearDownTest() {
+	defer os.Remove(s.dbPath)
+	s.storm.Close()
+}
+
+func (s *TagSuite) TestCreate() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
+		Address: "testGit",
+	})
+	c := s.commitManager.Create(nil, p, "abcdef", "test commit", "me@velocityci.io", time.Now(), "")
+
+	m := githistory.NewTagManager(githistory.NewTagStormRepository(s.storm))
+
+	t := m.Create(c, "v1.0.0")
+	s.NotNil(t)
+
+	s.Equal(p, t.Project)
+	s.Equal(c, t.Commit)
+	s.Equal("v1.0.0", t.Name)
+}
+
+func (s *TagSuite) TestUpdate() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
+		Address: "testGit",
+	})
+	c1 := s.commitManager.Create(nil, p, "abcdef", "test commit", "me@velocityci.io", time.Now(), "")
+	c2 := s.commitManager.Create(nil, p, "123456", "test commit 2", "me@velocityci.io", time.Now(), "")
+
+	m := githistory.NewTagManager(githistory.NewTagStormRepository(s.storm))
+
+	t := m.Create(c1, "latest")
+	t.Commit = c2
+	err := m.Update(t)
+	s.Nil(err)
+
+	uT, err := m.GetByProjectAndName(p, "latest")
+	s.Nil(err)
+	s.Equal(c2.Hash, uT.Commit.Hash)
+	s.Empty(m.GetAllForCommit(c1))
+	s.Len(m.GetAllForCommit(c2), 1)
+}
+
+func (s *TagSuite) TestGetAllForProject() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
+		Address: "testGit",
+	})
+	c := s.commitManager.Create(nil, p, "abcdef", "test commit", "me@velocityci.io", time.Now(), "")
+
+	m := githistory.NewTagManager(githistory.NewTagStormRepository(s.storm))
+
+	t1 := m.Create(c, "v1.0.0")
+	t2 := m.Create(c, "v1.0.1")
+
+	ts, total := m.GetAllForProject(p, &domain.PagingQuery{Limit: 5, Page: 1})
+	s.Equal(2, total)
+	s.Len(ts, 2)
+	s.Contains(ts, t1)
+	s.Contains(ts, t2)
+
+	_, err := m.GetByProjectAndName(p, "v2.0.0")
+	s.NotNil(err)
+}
+
+func (s *TagSuite) TestDelete() {
+	p, _ := s.projectManager.Create("testProject", velocity.GitRepository{
+		Address: "testGit",
+	})
+	c := s.commitManager.Create(nil, p, "abcdef", "test commit", "me@velocityci.io", time.Now(), "")
+
+	m := githistory.NewTagManager(githistory.NewTagStormRepository(s.storm))
+
+	t1 := m.Create(c, "v1.0.0")
+	t2 := m.Create(c, "v1.0.1")
+	s.Nil(m.Delete(t1))
+
+	_, err := m.GetByProjectAndName(p, "v1.0.0")
+	s.NotNil(err)
+	ts, total := m.GetAllForProject(p, &domain.PagingQuery{Limit: 5, Page: 1})
+	s.Equal(1, total)
+	s.Equal(t2.ID, ts[0].ID)
+	s.Len(m.GetAllForCommit(c), 1)
+}
@@ -2,8 +2,10 @@ package knownhost
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
+	"path/filepath"
 
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 	"go.uber.org/zap"
@@ -34,41 +36,28 @@ func NewFileManager(homedir string) *FileManager {
 	return fM
 }
 
-func (m FileManager) save(e *KnownHost) error {
-	// if m.Exists(e.Entry) {
-	// 	return nil
-	// }
-	f, err := os.OpenFile(m.knownHostsPath, os.O_APPEND|os.O_WRONLY|os.O_SYNC, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	_, err = f.WriteString(fmt.Sprintf("%s\n", e.Entry))
+// WriteAll replaces the known hosts file in one go, so that builds cloning at the same time
+// never see it empty.
+func (m FileManager) WriteAll(kHs []*KnownHost) {
+	tmp, err := ioutil.TempFile(filepath.Dir(m.knownHostsPath), "known_hosts")
 	if err != nil {
-		return err
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return
 	}
-
-	velocity.GetLogger().Debug("added entry to known-hosts file", zap.String("entry", e.Entry), zap.String("path", m.knownHostsPath))
-
-	return nil
-}
-
-func (m FileManager) clear() error {
-	f, err := os.OpenFile(m.knownHostsPath, os.O_RDWR|os.O_SYNC, 0644)
-	if err != nil {
-		return err
+	defer os.Remove(tmp.Name())
+	for _, k := range kHs {
+		if _, err := tmp.WriteString(fmt.Sprintf("%s\n", k.Entry)); err != nil {
+			tmp.Close()
+			velocity.GetLogger().Error("error", zap.Error(err))
+			return
+		}
 	}
-	defer f.Close()
-	f.Truncate(0)
-	return nil
-}
-
-func (m FileManager) WriteAll(kHs []*KnownHost) {
-	if err := m.clear(); err != nil {
+	if err := tmp.Close(); err != nil {
 		velocity.GetLogger().Error("error", zap.Error(err))
+		return
 	}
-	for _, k := range kHs {
-		m.save(k)
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), m.knownHostsPath); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
 	}
 }
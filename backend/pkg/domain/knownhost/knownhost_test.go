@@ -4,9 +4,11 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/velocity-ci/velocity/backend/pkg/domain/knownhost"
@@ -44,7 +46,7 @@ func (s *KnownHostSuite) SetupTest() {
 		log.Fatal(err)
 	}
 	validator, translator := domain.NewValidator()
-	s.kHM = knownhost.NewManager(s.storm, validator, translator, dir)
+	s.kHM = knownhost.NewManager(knownhost.NewStormRepository(s.storm), validator, translator, dir)
 }
 
 func (s *KnownHostSuite) TearDownTest() {
@@ -123,3 +125,19 @@ func (s *KnownHostSuite) TestDelete() {
 
 	s.False(m.Exists(entry))
 }
+
+func TestFileManagerWriteAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vci")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	fM := knownhost.NewFileManager(dir)
+
+	fM.WriteAll([]*knownhost.KnownHost{{Entry: "a.example ssh-ed25519 AAAA"}, {Entry: "b.example ssh-ed25519 BBBB"}})
+	fM.WriteAll([]*knownhost.KnownHost{{Entry: "c.example ssh-ed25519 CCCC"}})
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, ".ssh", "known_hosts"))
+	assert.Nil(t, err)
+	assert.Equal(t, "c.example ssh-ed25519 CCCC\n", string(b))
+	files, _ := ioutil.ReadDir(filepath.Join(dir, ".ssh"))
+	assert.Len(t, files, 1)
+}
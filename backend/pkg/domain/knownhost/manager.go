@@ -3,7 +3,6 @@ package knownhost
 import (
 	"fmt"
 
-	"github.com/asdine/storm"
 	"github.com/go-playground/universal-translator"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
@@ -17,21 +16,30 @@ const (
 	EventDelete = "knownhost:delete"
 )
 
+// Repository stores known hosts.
+type Repository interface {
+	Save(k *KnownHost) error
+	Delete(k *KnownHost) error
+	GetByID(id string) (*KnownHost, error)
+	Exists(entry string) bool
+	GetAll(q *domain.PagingQuery) ([]*KnownHost, int)
+}
+
 type Manager struct {
 	validator   *validator
-	db          *stormDB
+	db          Repository
 	fileManager *FileManager
 	brokers     []domain.Broker
 }
 
 func NewManager(
-	db *storm.DB,
+	db Repository,
 	validator *govalidator.Validate,
 	translator ut.Translator,
 	homedir string,
 ) *Manager {
 	m := &Manager{
-		db:          newStormDB(db),
+		db:          db,
 		brokers:     []domain.Broker{},
 		fileManager: NewFileManager(homedir),
 	}
@@ -65,7 +73,7 @@ func (m *Manager) Create(entry string) (*KnownHost, *domain.ValidationErrors) {
 		k.MD5Fingerprint = ssh.FingerprintLegacyMD5(pubKey)
 	}
 
-	m.db.save(k)
+	m.db.Save(k)
 	kH, _ := m.GetAll(&domain.PagingQuery{Limit: 100})
 	m.fileManager.WriteAll(kH)
 
@@ -81,7 +89,7 @@ func (m *Manager) Create(entry string) (*KnownHost, *domain.ValidationErrors) {
 }
 
 func (m *Manager) Delete(k *KnownHost) error {
-	if err := m.db.delete(k); err != nil {
+	if err := m.db.Delete(k); err != nil {
 		return err
 	}
 	kH, _ := m.GetAll(&domain.PagingQuery{Limit: 100})
@@ -97,9 +105,9 @@ func (m *Manager) Delete(k *KnownHost) error {
 }
 
 func (m *Manager) Exists(entry string) bool {
-	return m.db.exists(entry)
+	return m.db.Exists(entry)
 }
 
 func (m *Manager) GetAll(q *domain.PagingQuery) ([]*KnownHost, int) {
-	return m.db.getAll(q)
+	return m.db.GetAll(q)
 }
@@ -0,0 +1,88 @@
+package knownhost
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type sqliteDB struct {
+	*sql.DB
+}
+
+// NewSQLiteRepository returns a known host repository backed by a migrated SQLite database.
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &sqliteDB{db}
+}
+
+const sqliteKnownHostColumns = `id, entry, hosts, comment, sha256_fingerprint, md5_fingerprint`
+
+func scanKnownHost(row domain.SQLScanner) (*KnownHost, error) {
+	k := &KnownHost{}
+	var hosts []byte
+	if err := row.Scan(&k.ID, &k.Entry, &hosts, &k.Comment, &k.SHA256Fingerprint, &k.MD5Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(hosts, &k.Hosts); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	return k, nil
+}
+
+func (db *sqliteDB) Save(k *KnownHost) error {
+	hosts, err := json.Marshal(k.Hosts)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO known_hosts (`+sqliteKnownHostColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		k.ID, k.Entry, hosts, k.Comment, k.SHA256Fingerprint, k.MD5Fingerprint,
+	)
+	return err
+}
+
+func (db *sqliteDB) Delete(k *KnownHost) error {
+	_, err := db.Exec(`DELETE FROM known_hosts WHERE id = ?`, k.ID)
+	return err
+}
+
+func (db *sqliteDB) GetByID(id string) (*KnownHost, error) {
+	return scanKnownHost(db.QueryRow(`SELECT `+sqliteKnownHostColumns+` FROM known_hosts WHERE id = ?`, id))
+}
+
+func (db *sqliteDB) Exists(entry string) bool {
+	var id string
+	if err := db.QueryRow(`SELECT id FROM known_hosts WHERE entry = ?`, entry).Scan(&id); err != nil {
+		return false
+	}
+
+	return true
+}
+
+func (db *sqliteDB) GetAll(pQ *domain.PagingQuery) (r []*KnownHost, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM known_hosts`).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	rows, err := db.Query(`SELECT `+sqliteKnownHostColumns+` FROM known_hosts ORDER BY rowid LIMIT ? OFFSET ?`,
+		pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, t
+	}
+	defer rows.Close()
+	for rows.Next() {
+		k, err := scanKnownHost(rows)
+		if err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		r = append(r, k)
+	}
+
+	return r, t
+}
@@ -43,12 +43,17 @@ type stormDB struct {
 	*storm.DB
 }
 
+// NewStormRepository returns a known host repository backed by storm.
+func NewStormRepository(db *storm.DB) Repository {
+	return newStormDB(db)
+}
+
 func newStormDB(db *storm.DB) *stormDB {
 	db.Init(&KnownHost{})
 	return &stormDB{db}
 }
 
-func (db *stormDB) save(kH *KnownHost) error {
+func (db *stormDB) Save(kH *KnownHost) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -62,7 +67,7 @@ func (db *stormDB) save(kH *KnownHost) error {
 	return tx.Commit()
 }
 
-func (db *stormDB) delete(kH *KnownHost) error {
+func (db *stormDB) Delete(kH *KnownHost) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -73,7 +78,7 @@ func (db *stormDB) delete(kH *KnownHost) error {
 	return tx.Commit()
 }
 
-func (db *stormDB) exists(entry string) bool {
+func (db *stormDB) Exists(entry string) bool {
 	query := db.Select(q.Eq("Entry", entry))
 	var kH StormKnownHost
 	if err := query.First(&kH); err != nil {
@@ -83,7 +88,7 @@ func (db *stormDB) exists(entry string) bool {
 	return true
 }
 
-func (db *stormDB) getAll(pQ *domain.PagingQuery) (r []*KnownHost, t int) {
+func (db *stormDB) GetAll(pQ *domain.PagingQuery) (r []*KnownHost, t int) {
 	t = 0
 	t, err := db.Count(&StormKnownHost{})
 	if err != nil {
@@ -103,6 +108,10 @@ func (db *stormDB) getAll(pQ *domain.PagingQuery) (r []*KnownHost, t int) {
 	return r, t
 }
 
+func (db *stormDB) GetByID(id string) (*KnownHost, error) {
+	return GetByID(db.DB, id)
+}
+
 func GetByID(db *storm.DB, id string) (*KnownHost, error) {
 	var kH StormKnownHost
 	if err := db.One("ID", id, &kH); err != nil {
@@ -110,3 +119,18 @@ func GetByID(db *storm.DB, id string) (*KnownHost, error) {
 	}
 	return kH.ToKnownHost(), nil
 }
+
+// MigrateStorm copies the known hosts in a storm database into another repository.
+func MigrateStorm(from *storm.DB, to Repository) (int, error) {
+	var stormKnownHosts []*StormKnownHost
+	if err := from.All(&stormKnownHosts); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	for _, k := range stormKnownHosts {
+		if err := to.Save(k.ToKnownHost()); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(stormKnownHosts), nil
+}
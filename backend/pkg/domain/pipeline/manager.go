@@ -0,0 +1,232 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosimple/slug"
+	uuid "github.com/satori/go.uuid"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+// Event constants
+const (
+	EventCreate = "pipeline:new"
+	EventUpdate = "pipeline:update"
+)
+
+// Repository stores pipelines.
+type Repository interface {
+	Save(p *Pipeline) error
+	GetByID(id string) (*Pipeline, error)
+	GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Pipeline, int)
+	GetAllForCommit(c *githistory.Commit, q *domain.PagingQuery) ([]*Pipeline, int)
+	// GetIncompleteForCommit returns the pipelines of a commit that may still start builds.
+	GetIncompleteForCommit(commitID string) ([]*Pipeline, int)
+}
+
+type Manager struct {
+	db           Repository
+	taskManager  *task.Manager
+	buildManager *build.BuildManager
+	brokers      []domain.Broker
+	lock         sync.Mutex
+}
+
+// NewManager returns a pipeline manager. It follows the builds of running pipelines so
+// it must be added as a broker to the build manager, and as a dependent so that those
+// builds aren't deleted.
+func NewManager(
+	db Repository,
+	taskManager *task.Manager,
+	buildManager *build.BuildManager,
+) *Manager {
+	return &Manager{
+		db:           db,
+		taskManager:  taskManager,
+		buildManager: buildManager,
+		brokers:      []domain.Broker{},
+	}
+}
+
+func (m *Manager) AddBroker(b domain.Broker) {
+	m.brokers = append(m.brokers, b)
+}
+
+// Create starts a pipeline of the project's stages for the given commit by creating the
+// builds of its first stage.
+func (m *Manager) Create(
+	c *githistory.Commit,
+	params map[string]string,
+) (*Pipeline, *domain.ValidationErrors) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stageConfigs := c.Project.RepositoryConfig.Stages
+	if len(stageConfigs) < 1 {
+		return nil, &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"stages": {"no stages are configured for this project"}},
+		}
+	}
+
+	stages := []*Stage{}
+	missingTasks := []string{}
+	for _, sC := range stageConfigs {
+		for _, name := range sC.Tasks {
+			if _, err := m.taskManager.GetByCommitAndSlug(c, slug.Make(name)); err != nil {
+				missingTasks = append(missingTasks, name)
+			}
+		}
+		stages = append(stages, &Stage{
+			Name:   sC.Name,
+			Tasks:  sC.Tasks,
+			Status: velocity.StateWaiting,
+			Builds: []*build.Build{},
+		})
+	}
+	if len(missingTasks) > 0 {
+		return nil, &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"stages": {
+				fmt.Sprintf("tasks not found at commit %s: %s", c.Hash, strings.Join(missingTasks, ", ")),
+			}},
+		}
+	}
+
+	timestamp := time.Now().UTC()
+	p := &Pipeline{
+		ID:         uuid.NewV3(uuid.NewV1(), c.ID).String(),
+		Commit:     c,
+		Parameters: params,
+		Stages:     stages,
+		Status:     velocity.StateWaiting,
+		CreatedAt:  timestamp,
+		UpdatedAt:  timestamp,
+	}
+
+	if err := m.startStage(p, 0); err != nil {
+		return nil, err
+	}
+	p.rollup()
+	m.db.Save(p)
+
+	for _, br := range m.brokers {
+		br.EmitAll(&domain.Emit{
+			Event:   EventCreate,
+			Payload: p,
+		})
+	}
+
+	return p, nil
+}
+
+// startStage creates a build for each task of the stage. The build scheduler runs the
+// builds of a stage concurrently when there are enough builders.
+func (m *Manager) startStage(p *Pipeline, i int) *domain.ValidationErrors {
+	s := p.Stages[i]
+	for _, name := range s.Tasks {
+		t, err := m.taskManager.GetByCommitAndSlug(p.Commit, slug.Make(name))
+		if err != nil {
+			s.Error = fmt.Sprintf("task %s not found", name)
+			return &domain.ValidationErrors{ErrorMap: map[string][]string{"stages": {s.Error}}}
+		}
+		b, errs := m.buildManager.Create(t, p.Parameters)
+		if errs != nil {
+			s.Error = fmt.Sprintf("could not create build for %s", name)
+			return errs
+		}
+		s.Builds = append(s.Builds, b)
+	}
+	velocity.GetLogger().Info("started pipeline stage",
+		zap.String("pipelineID", p.ID),
+		zap.String("stage", s.Name),
+		zap.Int("builds", len(s.Builds)),
+	)
+
+	return nil
+}
+
+func (m *Manager) Update(p *Pipeline) error {
+	p.UpdatedAt = time.Now().UTC()
+	if err := m.db.Save(p); err != nil {
+		return err
+	}
+	for _, br := range m.brokers {
+		br.EmitAll(&domain.Emit{
+			Event:   EventUpdate,
+			Payload: p,
+		})
+	}
+
+	return nil
+}
+
+// EmitAll follows build updates to roll up the status of the pipelines they belong to and
+// start the next stage of a pipeline once all of the builds in its current stage succeed.
+func (m *Manager) EmitAll(em *domain.Emit) {
+	b, ok := em.Payload.(*build.Build)
+	if !ok || em.Event != build.EventBuildUpdate || b.Task == nil || b.Task.Commit == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	pipelines, _ := m.db.GetIncompleteForCommit(b.Task.Commit.ID)
+	for _, p := range pipelines {
+		if !p.hasBuild(b.ID) {
+			continue
+		}
+		m.progress(p)
+	}
+}
+
+// DependsOn returns whether the build belongs to a pipeline that hasn't completed, which
+// needs its builds to work out when to start the next stage.
+func (m *Manager) DependsOn(b *build.Build) bool {
+	if b.Task == nil || b.Task.Commit == nil {
+		return false
+	}
+	pipelines, _ := m.db.GetIncompleteForCommit(b.Task.Commit.ID)
+	for _, p := range pipelines {
+		if p.hasBuild(b.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) progress(p *Pipeline) {
+	next := p.rollup()
+	if next > 0 {
+		if err := m.startStage(p, next); err != nil {
+			velocity.GetLogger().Error("could not start pipeline stage",
+				zap.String("pipelineID", p.ID),
+				zap.String("stage", p.Stages[next].Name),
+				zap.Any("errors", err.ErrorMap),
+			)
+		}
+		p.rollup()
+	}
+
+	m.Update(p)
+}
+
+func (m *Manager) GetByID(id string) (*Pipeline, error) {
+	return m.db.GetByID(id)
+}
+
+func (m *Manager) GetAllForProject(p *project.Project, q *domain.PagingQuery) ([]*Pipeline, int) {
+	return m.db.GetAllForProject(p, q)
+}
+
+func (m *Manager) GetAllForCommit(c *githistory.Commit, q *domain.PagingQuery) ([]*Pipeline, int) {
+	return m.db.GetAllForCommit(c, q)
+}
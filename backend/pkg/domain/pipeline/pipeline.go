@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+// Pipeline runs the stages of a repository's .velocity.yml for a commit. The builds of a
+// stage run concurrently and the next stage only starts once all of them have succeeded.
+type Pipeline struct {
+	ID         string             `json:"id"`
+	Commit     *githistory.Commit `json:"commit"`
+	Parameters map[string]string  `json:"parameters"`
+	Stages     []*Stage           `json:"stages"`
+
+	Status string `json:"status"`
+
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+type Stage struct {
+	Name   string         `json:"name"`
+	Tasks  []string       `json:"tasks"`
+	Status string         `json:"status"`
+	Error  string         `json:"error"`
+	Builds []*build.Build `json:"builds"`
+
+	// missing are the builds of the stage that no longer exist
+	missing []string
+}
+
+func (p Pipeline) String() string {
+	j, _ := json.Marshal(p)
+	return string(j)
+}
+
+func (p *Pipeline) hasBuild(id string) bool {
+	for _, s := range p.Stages {
+		for _, b := range s.Builds {
+			if b.ID == id {
+				return true
+			}
+		}
+		for _, m := range s.missing {
+			if m == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isComplete(status string) bool {
+	return status == velocity.StateSuccess || status == velocity.StateFailed
+}
+
+// rollup derives the stage status from the statuses of its builds.
+func (s *Stage) rollup() {
+	if s.Error != "" {
+		s.Status = velocity.StateFailed
+		return
+	}
+	// a stage whose builds have gone can't succeed, and mustn't be started again
+	if len(s.missing) > 0 {
+		s.Status = velocity.StateFailed
+		return
+	}
+
+	s.Status = velocity.StateWaiting
+	if len(s.Builds) < 1 {
+		return
+	}
+	complete := 0
+	for _, b := range s.Builds {
+		if b.Status == velocity.StateFailed {
+			s.Status = velocity.StateFailed
+			return
+		}
+		if b.Status != velocity.StateWaiting {
+			s.Status = velocity.StateRunning
+		}
+		if b.Status == velocity.StateSuccess {
+			complete++
+		}
+	}
+	if complete == len(s.Builds) {
+		s.Status = velocity.StateSuccess
+	}
+}
+
+// rollup derives the pipeline status from its stages, returning the index of the next
+// stage to start or -1 if there isn't one.
+func (p *Pipeline) rollup() int {
+	next := -1
+	p.Status = velocity.StateSuccess
+	for i, s := range p.Stages {
+		s.rollup()
+		if s.Status == velocity.StateFailed {
+			p.Status = velocity.StateFailed
+			break
+		}
+		if s.Status == velocity.StateSuccess {
+			continue
+		}
+		if len(s.Builds) < 1 && s.Error == "" {
+			next = i
+		}
+		p.Status = velocity.StateRunning
+		if i == 0 && s.Status == velocity.StateWaiting {
+			p.Status = velocity.StateWaiting
+		}
+		break
+	}
+
+	if p.Status != velocity.StateWaiting && p.StartedAt.IsZero() {
+		p.StartedAt = time.Now().UTC()
+	}
+	if isComplete(p.Status) && p.CompletedAt.IsZero() {
+		p.CompletedAt = time.Now().UTC()
+	}
+
+	return next
+}
@@ -0,0 +1,211 @@
+package pipeline_test
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/suite"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/pipeline"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+type PipelineSuite struct {
+	suite.Suite
+	storm          *storm.DB
+	dbPath         string
+	logsPath       string
+	projectManager *project.Manager
+	commitManager  *githistory.CommitManager
+	branchManager  *githistory.BranchManager
+	taskManager    *task.Manager
+	buildManager   *build.BuildManager
+}
+
+var syncMock = func(*velocity.GitRepository) (bool, error) {
+	return true, nil
+}
+
+func TestPipelineSuite(t *testing.T) {
+	suite.Run(t, new(PipelineSuite))
+}
+
+func (s *PipelineSuite) SetupTest() {
+	// Retrieve a temporary path.
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		panic(err)
+	}
+	s.dbPath = f.Name()
+	f.Close()
+	os.Remove(s.dbPath)
+	// Open the database.
+	s.storm, err = storm.Open(s.dbPath)
+	if err != nil {
+		panic(err)
+	}
+	s.logsPath, err = ioutil.TempDir("", "velocity-logs")
+	if err != nil {
+		panic(err)
+	}
+
+	validator, translator := domain.NewValidator()
+	s.projectManager = project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
+	s.branchManager = githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
+	s.taskManager = task.NewManager(task.NewStormRepository(s.storm), s.projectManager, s.branchManager, s.commitManager)
+	s.buildManager = build.NewBuildManager(build.NewBuildStormRepository(s.storm), build.NewStepManager(build.NewStepStormRepository(s.storm)), build.NewStreamManager(build.NewStreamStormRepository(s.storm), build.NewFileLogStore(s.logsPath)))
+}
+
+func (s *PipelineSuite) TearDownTest() {
+	defer os.Remove(s.dbPath)
+	defer os.RemoveAll(s.logsPath)
+	s.storm.Close()
+}
+
+func (s *PipelineSuite) createCommit(name string, stages []velocity.StageConfig, tasks ...string) *githistory.Commit {
+	p, _ := s.projectManager.Create(name, velocity.GitRepository{
+		Address: "testGit",
+	})
+	p.RepositoryConfig.Stages = stages
+
+	br := s.branchManager.Create(p, "testBranch")
+	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
+	for _, name := range tasks {
+		s.taskManager.Create(c, &velocity.Task{Name: name}, velocity.NewSetup())
+	}
+
+	return c
+}
+
+func (s *PipelineSuite) completeBuilds(builds []*build.Build, status string) {
+	for _, b := range builds {
+		b.Status = status
+		s.buildManager.Update(b)
+	}
+}
+
+func (s *PipelineSuite) TestCreateRequiresStages() {
+	c := s.createCommit("noStages", []velocity.StageConfig{}, "test")
+
+	m := pipeline.NewManager(pipeline.NewStormRepository(s.storm), s.taskManager, s.buildManager)
+	p, errs := m.Create(c, map[string]string{})
+	s.Nil(p)
+	s.Contains(errs.ErrorMap, "stages")
+
+	c = s.createCommit("missingTask", []velocity.StageConfig{
+		{Name: "test", Tasks: []string{"test", "lint"}},
+	}, "test")
+	p, errs = m.Create(c, map[string]string{})
+	s.Nil(p)
+	s.Contains(errs.ErrorMap["stages"][0], "lint")
+}
+
+func (s *PipelineSuite) TestStagesRunInOrder() {
+	c := s.createCommit("testProject", []velocity.StageConfig{
+		{Name: "test", Tasks: []string{"unit tests", "lint"}},
+		{Name: "deploy", Tasks: []string{"deploy"}},
+	}, "unit tests", "lint", "deploy")
+
+	m := pipeline.NewManager(pipeline.NewStormRepository(s.storm), s.taskManager, s.buildManager)
+	s.buildManager.AddBroker(m)
+
+	p, errs := m.Create(c, map[string]string{"VERSION": "1.0.0"})
+	s.Nil(errs)
+	s.Equal(velocity.StateWaiting, p.Status)
+	s.Len(p.Stages[0].Builds, 2)
+	s.Len(p.Stages[1].Builds, 0)
+	s.Equal("1.0.0", p.Stages[0].Builds[0].Parameters["VERSION"])
+
+	s.completeBuilds(p.Stages[0].Builds[:1], velocity.StateSuccess)
+	p, _ = m.GetByID(p.ID)
+	s.Equal(velocity.StateRunning, p.Status)
+	s.Equal(velocity.StateRunning, p.Stages[0].Status)
+	s.Len(p.Stages[1].Builds, 0)
+
+	s.completeBuilds(p.Stages[0].Builds[1:], velocity.StateSuccess)
+	p, _ = m.GetByID(p.ID)
+	s.Equal(velocity.StateSuccess, p.Stages[0].Status)
+	s.Len(p.Stages[1].Builds, 1)
+	s.Equal("deploy", p.Stages[1].Builds[0].Task.Slug)
+
+	s.completeBuilds(p.Stages[1].Builds, velocity.StateSuccess)
+	p, _ = m.GetByID(p.ID)
+	s.Equal(velocity.StateSuccess, p.Status)
+	s.False(p.CompletedAt.IsZero())
+
+	ps, total := m.GetAllForCommit(c, domain.NewPagingQuery())
+	s.Equal(1, total)
+	s.Equal(p.ID, ps[0].ID)
+}
+
+func (s *PipelineSuite) TestFailedStageStopsPipeline() {
+	c := s.createCommit("testProject", []velocity.StageConfig{
+		{Name: "test", Tasks: []string{"unit tests", "lint"}},
+		{Name: "deploy", Tasks: []string{"deploy"}},
+	}, "unit tests", "lint", "deploy")
+
+	m := pipeline.NewManager(pipeline.NewStormRepository(s.storm), s.taskManager, s.buildManager)
+	s.buildManager.AddBroker(m)
+
+	p, errs := m.Create(c, map[string]string{})
+	s.Nil(errs)
+
+	s.completeBuilds(p.Stages[0].Builds[:1], velocity.StateSuccess)
+	s.completeBuilds(p.Stages[0].Builds[1:], velocity.StateFailed)
+	p, _ = m.GetByID(p.ID)
+	s.Equal(velocity.StateFailed, p.Status)
+	s.Equal(velocity.StateFailed, p.Stages[0].Status)
+	s.Equal(velocity.StateWaiting, p.Stages[1].Status)
+	s.Len(p.Stages[1].Builds, 0)
+
+	ps, total := m.GetAllForProject(c.Project, domain.NewPagingQuery())
+	s.Equal(1, total)
+	s.Len(ps, 1)
+}
+
+func (s *PipelineSuite) TestRunningPipelineKeepsBuilds() {
+	c := s.createCommit("testProject", []velocity.StageConfig{
+		{Name: "test", Tasks: []string{"unit tests", "lint"}},
+		{Name: "deploy", Tasks: []string{"deploy"}},
+	}, "unit tests", "lint", "deploy")
+
+	m := pipeline.NewManager(pipeline.NewStormRepository(s.storm), s.taskManager, s.buildManager)
+	s.buildManager.AddBroker(m)
+	s.buildManager.AddDependent(m)
+
+	p, errs := m.Create(c, map[string]string{})
+	s.Nil(errs)
+	s.completeBuilds(p.Stages[0].Builds[:1], velocity.StateSuccess)
+	// a later build of the same task is the latest successful build of the branch, so only
+	// the pipeline keeps the stage 0 build
+	tsk, err := s.taskManager.GetByCommitAndSlug(c, "unit-tests")
+	s.Nil(err)
+	later, errs := s.buildManager.Create(tsk, map[string]string{})
+	s.Nil(errs)
+	s.completeBuilds([]*build.Build{later}, velocity.StateSuccess)
+
+	pruner := build.NewPruner(s.buildManager, s.projectManager, s.branchManager, project.RetentionPolicy{KeepBuilds: 1}, &sync.WaitGroup{})
+	s.Equal(0, pruner.Prune(time.Now().UTC()))
+	s.Equal(build.ErrBuildInUse, s.buildManager.Delete(p.Stages[0].Builds[0]))
+
+	p, _ = m.GetByID(p.ID)
+	s.Equal(velocity.StateRunning, p.Status)
+	s.Len(p.Stages[0].Builds, 2)
+
+	// a build that has gone anyway fails its stage instead of starting it again
+	s.Nil(build.NewBuildStormRepository(s.storm).Delete(p.Stages[0].Builds[0]))
+	s.completeBuilds(p.Stages[0].Builds[1:], velocity.StateSuccess)
+	p, _ = m.GetByID(p.ID)
+	s.Equal(velocity.StateFailed, p.Status)
+	s.Equal(velocity.StateFailed, p.Stages[0].Status)
+	s.Len(p.Stages[1].Builds, 0)
+}
@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type sqliteDB struct {
+	*sql.DB
+	commits githistory.CommitRepository
+	builds  build.BuildRepository
+}
+
+// NewSQLiteRepository returns a pipeline repository backed by a migrated SQLite database.
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &sqliteDB{
+		DB:      db,
+		commits: githistory.NewCommitSQLiteRepository(db),
+		builds:  build.NewBuildSQLiteRepository(db),
+	}
+}
+
+const sqlitePipelineColumns = `id, commit_id, project_id, status, parameters, stages, created_at, updated_at, started_at, completed_at`
+
+// sqliteStage is a stage as it is stored, with its builds referenced by ID.
+type sqliteStage struct {
+	Name     string   `json:"name"`
+	Tasks    []string `json:"tasks"`
+	Status   string   `json:"status"`
+	Error    string   `json:"error"`
+	BuildIDs []string `json:"buildIds"`
+}
+
+func (db *sqliteDB) Save(p *Pipeline) error {
+	params, err := json.Marshal(p.Parameters)
+	if err != nil {
+		return err
+	}
+	stages := []sqliteStage{}
+	for _, s := range p.Stages {
+		buildIDs := []string{}
+		for _, b := range s.Builds {
+			buildIDs = append(buildIDs, b.ID)
+		}
+		buildIDs = append(buildIDs, s.missing...)
+		stages = append(stages, sqliteStage{
+			Name:     s.Name,
+			Tasks:    s.Tasks,
+			Status:   s.Status,
+			Error:    s.Error,
+			BuildIDs: buildIDs,
+		})
+	}
+	stagesJSON, err := json.Marshal(stages)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO pipelines (`+sqlitePipelineColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.Commit.ID, p.Commit.Project.ID, p.Status, params, stagesJSON,
+		p.CreatedAt, p.UpdatedAt, p.StartedAt, p.CompletedAt,
+	)
+	return err
+}
+
+// find returns the pipelines selected by the query, loading their commits and builds once
+// the rows have been read.
+func (db *sqliteDB) find(query string, args ...interface{}) (r []*Pipeline) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	commitIDs := []string{}
+	stages := [][]sqliteStage{}
+	for rows.Next() {
+		p := &Pipeline{Parameters: map[string]string{}}
+		var commitID, projectID string
+		var params, stagesJSON []byte
+		if err := rows.Scan(
+			&p.ID, &commitID, &projectID, &p.Status, &params, &stagesJSON,
+			&p.CreatedAt, &p.UpdatedAt, &p.StartedAt, &p.CompletedAt,
+		); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		if err := json.Unmarshal(params, &p.Parameters); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+		}
+		var sS []sqliteStage
+		if err := json.Unmarshal(stagesJSON, &sS); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+		}
+		r = append(r, p)
+		commitIDs = append(commitIDs, commitID)
+		stages = append(stages, sS)
+	}
+	rows.Close()
+
+	for i, p := range r {
+		if p.Commit, err = db.commits.GetByID(commitIDs[i]); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+		}
+		p.Stages = []*Stage{}
+		for _, sS := range stages[i] {
+			stage := &Stage{
+				Name:   sS.Name,
+				Tasks:  sS.Tasks,
+				Status: sS.Status,
+				Error:  sS.Error,
+				Builds: []*build.Build{},
+			}
+			for _, id := range sS.BuildIDs {
+				b, err := db.builds.GetByID(id)
+				if err != nil {
+					velocity.GetLogger().Warn("missing pipeline build", zap.String("buildID", id), zap.Error(err))
+					stage.missing = append(stage.missing, id)
+					continue
+				}
+				stage.Builds = append(stage.Builds, b)
+			}
+			p.Stages = append(p.Stages, stage)
+		}
+	}
+
+	return r
+}
+
+func (db *sqliteDB) page(pQ *domain.PagingQuery, where string, args ...interface{}) (r []*Pipeline, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pipelines WHERE `+where, args...).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+	query := `SELECT ` + sqlitePipelineColumns + ` FROM pipelines WHERE ` + where + ` ORDER BY created_at DESC`
+	if pQ != nil {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, pQ.Limit, (pQ.Page-1)*pQ.Limit)
+	}
+
+	return db.find(query, args...), t
+}
+
+func (db *sqliteDB) GetByID(id string) (*Pipeline, error) {
+	r := db.find(`SELECT `+sqlitePipelineColumns+` FROM pipelines WHERE id = ?`, id)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *sqliteDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) ([]*Pipeline, int) {
+	return db.page(pQ, `project_id = ?`, p.ID)
+}
+
+func (db *sqliteDB) GetAllForCommit(c *githistory.Commit, pQ *domain.PagingQuery) ([]*Pipeline, int) {
+	return db.page(pQ, `commit_id = ?`, c.ID)
+}
+
+func (db *sqliteDB) GetIncompleteForCommit(commitID string) ([]*Pipeline, int) {
+	return db.page(nil, `commit_id = ? AND status IN (?, ?)`, commitID, velocity.StateWaiting, velocity.StateRunning)
+}
@@ -0,0 +1,218 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/build"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type StormPipeline struct {
+	ID          string `storm:"id"`
+	CommitID    string `storm:"index"`
+	ProjectID   string `storm:"index"`
+	Status      string `storm:"index"`
+	Parameters  []byte
+	Stages      []StormStage
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+type StormStage struct {
+	Name     string
+	Tasks    []string
+	Status   string
+	Error    string
+	BuildIDs []string
+}
+
+func (s *StormPipeline) toPipeline(db *storm.DB) *Pipeline {
+	params := map[string]string{}
+	err := json.Unmarshal(s.Parameters, &params)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	c, err := githistory.GetCommitByID(db, s.CommitID)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+
+	stages := []*Stage{}
+	for _, sS := range s.Stages {
+		stage := &Stage{
+			Name:   sS.Name,
+			Tasks:  sS.Tasks,
+			Status: sS.Status,
+			Error:  sS.Error,
+			Builds: []*build.Build{},
+		}
+		for _, id := range sS.BuildIDs {
+			b, err := build.GetBuildByID(db, id)
+			if err != nil {
+				velocity.GetLogger().Warn("missing pipeline build", zap.String("buildID", id), zap.Error(err))
+				stage.missing = append(stage.missing, id)
+				continue
+			}
+			stage.Builds = append(stage.Builds, b)
+		}
+		stages = append(stages, stage)
+	}
+
+	return &Pipeline{
+		ID:          s.ID,
+		Commit:      c,
+		Parameters:  params,
+		Stages:      stages,
+		Status:      s.Status,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+		StartedAt:   s.StartedAt,
+		CompletedAt: s.CompletedAt,
+	}
+}
+
+func (p *Pipeline) toStormPipeline() *StormPipeline {
+	paramsJson, err := json.Marshal(p.Parameters)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+
+	stages := []StormStage{}
+	for _, s := range p.Stages {
+		buildIDs := []string{}
+		for _, b := range s.Builds {
+			buildIDs = append(buildIDs, b.ID)
+		}
+		buildIDs = append(buildIDs, s.missing...)
+		stages = append(stages, StormStage{
+			Name:     s.Name,
+			Tasks:    s.Tasks,
+			Status:   s.Status,
+			Error:    s.Error,
+			BuildIDs: buildIDs,
+		})
+	}
+
+	return &StormPipeline{
+		ID:          p.ID,
+		CommitID:    p.Commit.ID,
+		ProjectID:   p.Commit.Project.ID,
+		Status:      p.Status,
+		Parameters:  paramsJson,
+		Stages:      stages,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		StartedAt:   p.StartedAt,
+		CompletedAt: p.CompletedAt,
+	}
+}
+
+type stormDB struct {
+	*storm.DB
+}
+
+// NewStormRepository returns a pipeline repository backed by storm.
+func NewStormRepository(db *storm.DB) Repository {
+	return newStormDB(db)
+}
+
+func newStormDB(db *storm.DB) *stormDB {
+	db.Init(&StormPipeline{})
+	return &stormDB{db}
+}
+
+func (db *stormDB) Save(p *Pipeline) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Save(p.toStormPipeline()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *stormDB) find(matcher q.Matcher, pQ *domain.PagingQuery) (r []*Pipeline, t int) {
+	t = 0
+	query := db.Select(matcher).OrderBy("CreatedAt").Reverse()
+	t, err := query.Count(&StormPipeline{})
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, t
+	}
+
+	var stormPipelines []StormPipeline
+	if pQ != nil {
+		query.Limit(pQ.Limit).Skip((pQ.Page - 1) * pQ.Limit)
+	}
+	query.Find(&stormPipelines)
+
+	for _, sP := range stormPipelines {
+		r = append(r, sP.toPipeline(db.DB))
+	}
+
+	return r, t
+}
+
+func (db *stormDB) GetAllForProject(p *project.Project, pQ *domain.PagingQuery) ([]*Pipeline, int) {
+	return db.find(q.Eq("ProjectID", p.ID), pQ)
+}
+
+func (db *stormDB) GetAllForCommit(c *githistory.Commit, pQ *domain.PagingQuery) ([]*Pipeline, int) {
+	return db.find(q.Eq("CommitID", c.ID), pQ)
+}
+
+func (db *stormDB) GetIncompleteForCommit(commitID string) ([]*Pipeline, int) {
+	return db.find(q.And(
+		q.Eq("CommitID", commitID),
+		q.In("Status", []string{velocity.StateWaiting, velocity.StateRunning}),
+	), nil)
+}
+
+func (db *stormDB) GetByID(id string) (*Pipeline, error) {
+	return GetByID(db.DB, id)
+}
+
+func GetByID(db *storm.DB, id string) (*Pipeline, error) {
+	var sP StormPipeline
+	if err := db.One("ID", id, &sP); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return nil, err
+	}
+	return sP.toPipeline(db), nil
+}
+
+// MigrateStorm copies the pipelines in a storm database into another repository, skipping
+// pipelines of commits that no longer exist.
+func MigrateStorm(from *storm.DB, to Repository) (int, error) {
+	var stormPipelines []*StormPipeline
+	if err := from.All(&stormPipelines); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	n := 0
+	for _, sP := range stormPipelines {
+		p := sP.toPipeline(from)
+		if p.Commit == nil || p.Commit.Project == nil {
+			velocity.GetLogger().Warn("skipping pipeline without commit", zap.String("pipelineID", p.ID))
+			continue
+		}
+		if err := to.Save(p); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
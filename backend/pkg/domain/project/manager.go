@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/asdine/storm"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/gosimple/slug"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
 	govalidator "gopkg.in/go-playground/validator.v9"
 )
 
@@ -20,21 +20,31 @@ const (
 	EventDelete = "project:delete"
 )
 
+// Repository stores projects.
+type Repository interface {
+	Save(p *Project) error
+	Delete(p *Project) error
+	GetByID(id string) (*Project, error)
+	GetBySlug(slug string) (*Project, error)
+	GetByName(name string) (*Project, error)
+	GetAll(q *domain.PagingQuery) ([]*Project, int)
+}
+
 type Manager struct {
 	validator *validator
-	db        *stormDB
+	db        Repository
 	validate  func(r *velocity.GitRepository) (bool, error)
 	brokers   []domain.Broker
 }
 
 func NewManager(
-	db *storm.DB,
+	db Repository,
 	validator *govalidator.Validate,
 	translator ut.Translator,
 	validateFunc func(r *velocity.GitRepository) (bool, error),
 ) *Manager {
 	m := &Manager{
-		db:       newStormDB(db),
+		db:       db,
 		validate: validateFunc,
 		brokers:  []domain.Broker{},
 	}
@@ -61,7 +71,7 @@ func (m *Manager) Create(name string, config velocity.GitRepository) (*Project,
 	p.ID = uuid.NewV1().String()
 	p.Slug = slug.Make(p.Name)
 
-	m.db.save(p)
+	m.db.Save(p)
 
 	for _, b := range m.brokers {
 		b.EmitAll(&domain.Emit{
@@ -75,7 +85,7 @@ func (m *Manager) Create(name string, config velocity.GitRepository) (*Project,
 }
 
 func (m *Manager) Update(p *Project) error {
-	if err := m.db.save(p); err != nil {
+	if err := m.db.Save(p); err != nil {
 		return err
 	}
 	for _, b := range m.brokers {
@@ -88,8 +98,72 @@ func (m *Manager) Update(p *Project) error {
 	return nil
 }
 
+// UpdateSignaturePolicy sets which commits of the project may be built.
+func (m *Manager) UpdateSignaturePolicy(p *Project, policy velocity.SignaturePolicy) *domain.ValidationErrors {
+	if err := policy.Validate(); err != nil {
+		return &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"signatures": {err.Error()}},
+		}
+	}
+
+	p.Signatures = policy
+	p.UpdatedAt = time.Now().UTC()
+	if err := m.Update(p); err != nil {
+		velocity.GetLogger().Error("could not update signature policy", zap.String("project", p.Slug), zap.Error(err))
+		return &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"signatures": {"could not save signature policy"}},
+		}
+	}
+
+	return nil
+}
+
+// UpdateRetentionPolicy sets how long the builds of the project are kept for, or uses the
+// architect's retention policy when it is nil.
+func (m *Manager) UpdateRetentionPolicy(p *Project, policy *RetentionPolicy) *domain.ValidationErrors {
+	if policy != nil {
+		if err := policy.Validate(); err != nil {
+			return &domain.ValidationErrors{
+				ErrorMap: map[string][]string{"retention": {err.Error()}},
+			}
+		}
+	}
+
+	p.Retention = policy
+	p.UpdatedAt = time.Now().UTC()
+	if err := m.Update(p); err != nil {
+		velocity.GetLogger().Error("could not update retention policy", zap.String("project", p.Slug), zap.Error(err))
+		return &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"retention": {"could not save retention policy"}},
+		}
+	}
+
+	return nil
+}
+
+// UpdateMaxConcurrentBuilds limits how many builds of the project can run at once, 0
+// removes the limit.
+func (m *Manager) UpdateMaxConcurrentBuilds(p *Project, max int) *domain.ValidationErrors {
+	if max < 0 {
+		return &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"maxConcurrentBuilds": {"cannot be negative"}},
+		}
+	}
+
+	p.MaxConcurrentBuilds = max
+	p.UpdatedAt = time.Now().UTC()
+	if err := m.Update(p); err != nil {
+		velocity.GetLogger().Error("could not update max concurrent builds", zap.String("project", p.Slug), zap.Error(err))
+		return &domain.ValidationErrors{
+			ErrorMap: map[string][]string{"maxConcurrentBuilds": {"could not save max concurrent builds"}},
+		}
+	}
+
+	return nil
+}
+
 func (m *Manager) Delete(p *Project) error {
-	if err := m.db.delete(p); err != nil {
+	if err := m.db.Delete(p); err != nil {
 		return err
 	}
 	for _, b := range m.brokers {
@@ -110,13 +184,13 @@ func (m *Manager) Exists(name string) bool {
 }
 
 func (m *Manager) GetAll(q *domain.PagingQuery) ([]*Project, int) {
-	return m.db.getAll(q)
+	return m.db.GetAll(q)
 }
 
 func (m *Manager) GetByName(name string) (*Project, error) {
-	return m.db.getByName(name)
+	return m.db.GetByName(name)
 }
 
 func (m *Manager) GetBySlug(slug string) (*Project, error) {
-	return m.db.getBySlug(slug)
+	return m.db.GetBySlug(slug)
 }
@@ -1,19 +1,47 @@
 package project
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 )
 
 type Project struct {
-	ID            string                 `json:"id"`
-	Slug          string                 `json:"slug"`
-	Name          string                 `json:"name" validate:"required,projectUnique"`
-	Config        velocity.GitRepository `json:"repoConfig"`
-	CreatedAt     time.Time              `json:"createdAt"`
-	UpdatedAt     time.Time              `json:"updatedAt"`
-	Synchronising bool                   `json:"synchronising"`
+	ID            string                   `json:"id"`
+	Slug          string                   `json:"slug"`
+	Name          string                   `json:"name" validate:"required,projectUnique"`
+	Config        velocity.GitRepository   `json:"repoConfig"`
+	CreatedAt     time.Time                `json:"createdAt"`
+	UpdatedAt     time.Time                `json:"updatedAt"`
+	Synchronising bool                     `json:"synchronising"`
+	Signatures    velocity.SignaturePolicy `json:"signatures"`
+	// Retention overrides the architect's retention policy when set
+	Retention *RetentionPolicy `json:"retention"`
+	// MaxConcurrentBuilds limits how many builds of the project can run at once, 0 is unlimited
+	MaxConcurrentBuilds int `json:"maxConcurrentBuilds"`
 
 	velocity.RepositoryConfig
 }
+
+// RetentionPolicy decides which finished builds are pruned. A build is kept while any of
+// the rules keep it, and the latest successful build of each branch is always kept. A
+// policy without any rules keeps everything.
+type RetentionPolicy struct {
+	// KeepBuilds is how many of the latest builds of each task are kept
+	KeepBuilds int `json:"keepBuilds"`
+	// KeepDays is how many days builds are kept for
+	KeepDays int `json:"keepDays"`
+}
+
+func (p *RetentionPolicy) Validate() error {
+	if p.KeepBuilds < 0 || p.KeepDays < 0 {
+		return fmt.Errorf("retention rules cannot be negative")
+	}
+	return nil
+}
+
+// Prunes returns whether the policy prunes anything.
+func (p *RetentionPolicy) Prunes() bool {
+	return p.KeepBuilds > 0 || p.KeepDays > 0
+}
@@ -28,6 +28,7 @@ func TestProjectSuite(t *testing.T) {
 }
 
 func (s *ProjectSuite) SetupTest() {
+	os.Setenv("ENCRYPTION_KEY", "test")
 	// Retrieve a temporary path.
 	f, err := ioutil.TempFile("", "")
 	if err != nil {
@@ -50,7 +51,7 @@ func (s *ProjectSuite) TearDownTest() {
 
 func (s *ProjectSuite) TestValidNew() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	p, errs := m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -69,7 +70,7 @@ func (s *ProjectSuite) TestSSHInvalidCreate() {
 	syncMock := func(*velocity.GitRepository) (bool, error) {
 		return false, velocity.SSHKeyError("")
 	}
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	p, errs := m.Create("Test Project", velocity.GitRepository{
 		Address:    "testGit",
@@ -84,7 +85,7 @@ func (s *ProjectSuite) TestSSHInvalidCreate() {
 
 func (s *ProjectSuite) TestDuplicateCreate() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	p, _ := m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -101,7 +102,7 @@ func (s *ProjectSuite) TestDuplicateCreate() {
 
 func (s *ProjectSuite) TestUpdate() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	eP, _ := m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -117,7 +118,7 @@ func (s *ProjectSuite) TestUpdate() {
 
 func (s *ProjectSuite) TestExists() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -128,7 +129,7 @@ func (s *ProjectSuite) TestExists() {
 
 func (s *ProjectSuite) TestDelete() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	p, _ := m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -142,7 +143,7 @@ func (s *ProjectSuite) TestDelete() {
 
 func (s *ProjectSuite) TestList() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	p, _ := m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -160,7 +161,7 @@ func (s *ProjectSuite) TestList() {
 
 func (s *ProjectSuite) TestGetByName() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	p, _ := m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -173,7 +174,7 @@ func (s *ProjectSuite) TestGetByName() {
 
 func (s *ProjectSuite) TestGetBySlug() {
 	validator, translator := domain.NewValidator()
-	m := project.NewManager(s.storm, validator, translator, syncMock)
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
 
 	p, _ := m.Create("Test Project", velocity.GitRepository{
 		Address: "testGit",
@@ -183,3 +184,51 @@ func (s *ProjectSuite) TestGetBySlug() {
 	s.Nil(err)
 	s.Equal(p, pR)
 }
+
+func (s *ProjectSuite) TestCredentialsEncryptedAtRest() {
+	validator, translator := domain.NewValidator()
+	m := project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+
+	eP, _ := m.Create("Test Project", velocity.GitRepository{
+		Address:  "https://example.com/velocity.git",
+		Username: "velocity",
+		Password: "s3cret-token",
+	})
+
+	var sP project.StormProject
+	err := s.storm.One("ID", eP.ID, &sP)
+	s.Nil(err)
+	s.NotEqual("s3cret-token", sP.Config.Password)
+	s.NotEmpty(sP.Config.Password)
+
+	p, _ := m.GetByName("Test Project")
+	s.Equal("s3cret-token", p.Config.Password)
+	s.Equal("velocity", p.Config.Username)
+}
+
+func (s *ProjectSuite) TestCredentialsNeedEncryptionKey() {
+	repository := project.NewStormRepository(s.storm)
+	p := &project.Project{
+		ID:   "test-project",
+		Slug: "test-project",
+		Name: "Test Project",
+		Config: velocity.GitRepository{
+			Address:  "https://example.com/velocity.git",
+			Password: "s3cret-token",
+		},
+	}
+	s.Nil(repository.Save(p))
+
+	// credentials are neither stored in plain text nor blanked without the key
+	os.Setenv("ENCRYPTION_KEY", "")
+	defer os.Setenv("JWT_SECRET", os.Getenv("JWT_SECRET"))
+	os.Setenv("JWT_SECRET", "")
+	s.NotNil(repository.Save(p))
+	_, err := repository.GetByID(p.ID)
+	s.NotNil(err)
+
+	os.Setenv("ENCRYPTION_KEY", "test")
+	saved, err := repository.GetByID(p.ID)
+	s.Nil(err)
+	s.Equal("s3cret-token", saved.Config.Password)
+}
@@ -0,0 +1,119 @@
+package project
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type sqliteDB struct {
+	*sql.DB
+}
+
+// NewSQLiteRepository returns a project repository backed by a migrated SQLite database.
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &sqliteDB{db}
+}
+
+const sqliteProjectColumns = `id, slug, name, config, created_at, updated_at, synchronising, signatures, repository_config, retention, max_concurrent_builds`
+
+func scanProject(row domain.SQLScanner) (*Project, error) {
+	p := &Project{}
+	var config, signatures, repositoryConfig, retention []byte
+	if err := row.Scan(
+		&p.ID, &p.Slug, &p.Name, &config, &p.CreatedAt, &p.UpdatedAt, &p.Synchronising, &signatures, &repositoryConfig, &retention, &p.MaxConcurrentBuilds,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(config, &p.Config); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	if err := json.Unmarshal(signatures, &p.Signatures); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	if err := json.Unmarshal(repositoryConfig, &p.RepositoryConfig); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	if err := json.Unmarshal(retention, &p.Retention); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+	}
+	decrypted, err := decryptRepository(p.Config)
+	if err != nil {
+		return nil, err
+	}
+	p.Config = decrypted
+	return p, nil
+}
+
+func (db *sqliteDB) Save(p *Project) error {
+	encrypted, err := encryptRepository(p.Config)
+	if err != nil {
+		return err
+	}
+	config, err := json.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+	signatures, err := json.Marshal(p.Signatures)
+	if err != nil {
+		return err
+	}
+	repositoryConfig, err := json.Marshal(p.RepositoryConfig)
+	if err != nil {
+		return err
+	}
+	retention, err := json.Marshal(p.Retention)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO projects (`+sqliteProjectColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.Slug, p.Name, config, p.CreatedAt, p.UpdatedAt, p.Synchronising, signatures, repositoryConfig, retention, p.MaxConcurrentBuilds,
+	)
+	return err
+}
+
+func (db *sqliteDB) Delete(p *Project) error {
+	_, err := db.Exec(`DELETE FROM projects WHERE id = ?`, p.ID)
+	return err
+}
+
+func (db *sqliteDB) GetByID(id string) (*Project, error) {
+	return scanProject(db.QueryRow(`SELECT `+sqliteProjectColumns+` FROM projects WHERE id = ?`, id))
+}
+
+func (db *sqliteDB) GetBySlug(slug string) (*Project, error) {
+	return scanProject(db.QueryRow(`SELECT `+sqliteProjectColumns+` FROM projects WHERE slug = ?`, slug))
+}
+
+func (db *sqliteDB) GetByName(name string) (*Project, error) {
+	return scanProject(db.QueryRow(`SELECT `+sqliteProjectColumns+` FROM projects WHERE name = ? LIMIT 1`, name))
+}
+
+func (db *sqliteDB) GetAll(pQ *domain.PagingQuery) (r []*Project, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM projects`).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	rows, err := db.Query(`SELECT `+sqliteProjectColumns+` FROM projects ORDER BY created_at LIMIT ? OFFSET ?`,
+		pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, t
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		r = append(r, p)
+	}
+
+	return r, t
+}
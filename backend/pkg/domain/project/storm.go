@@ -1,6 +1,7 @@
 package project
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/asdine/storm"
@@ -11,58 +12,110 @@ import (
 )
 
 type StormProject struct {
-	ID               string `storm:"id"`
-	Slug             string `storm:"index"`
-	Name             string
-	Config           velocity.GitRepository
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
-	Synchronising    bool
-	RepositoryConfig velocity.RepositoryConfig
+	ID                  string `storm:"id"`
+	Slug                string `storm:"index"`
+	Name                string
+	Config              velocity.GitRepository
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Synchronising       bool
+	Signatures          velocity.SignaturePolicy
+	Retention           *RetentionPolicy
+	MaxConcurrentBuilds int
+	RepositoryConfig    velocity.RepositoryConfig
 }
 
-func (s *StormProject) ToProject() *Project {
-	return &Project{
-		ID:               s.ID,
-		Slug:             s.Slug,
-		Name:             s.Name,
-		Config:           s.Config,
-		CreatedAt:        s.CreatedAt,
-		UpdatedAt:        s.UpdatedAt,
-		Synchronising:    s.Synchronising,
-		RepositoryConfig: s.RepositoryConfig,
+func (s *StormProject) ToProject() (*Project, error) {
+	config, err := decryptRepository(s.Config)
+	if err != nil {
+		return nil, err
 	}
+	return &Project{
+		ID:                  s.ID,
+		Slug:                s.Slug,
+		Name:                s.Name,
+		Config:              config,
+		CreatedAt:           s.CreatedAt,
+		UpdatedAt:           s.UpdatedAt,
+		Synchronising:       s.Synchronising,
+		Signatures:          s.Signatures,
+		Retention:           s.Retention,
+		MaxConcurrentBuilds: s.MaxConcurrentBuilds,
+		RepositoryConfig:    s.RepositoryConfig,
+	}, nil
 }
 
-func (p *Project) toStormProject() *StormProject {
+func (p *Project) toStormProject() (*StormProject, error) {
+	config, err := encryptRepository(p.Config)
+	if err != nil {
+		return nil, err
+	}
 	return &StormProject{
-		ID:               p.ID,
-		Slug:             p.Slug,
-		Name:             p.Name,
-		Config:           p.Config,
-		CreatedAt:        p.CreatedAt,
-		UpdatedAt:        p.UpdatedAt,
-		Synchronising:    p.Synchronising,
-		RepositoryConfig: p.RepositoryConfig,
+		ID:                  p.ID,
+		Slug:                p.Slug,
+		Name:                p.Name,
+		Config:              config,
+		CreatedAt:           p.CreatedAt,
+		UpdatedAt:           p.UpdatedAt,
+		Synchronising:       p.Synchronising,
+		Signatures:          p.Signatures,
+		Retention:           p.Retention,
+		MaxConcurrentBuilds: p.MaxConcurrentBuilds,
+		RepositoryConfig:    p.RepositoryConfig,
+	}, nil
+}
+
+// encryptRepository returns a copy of the repository config with its credentials encrypted for storage.
+func encryptRepository(c velocity.GitRepository) (velocity.GitRepository, error) {
+	var err error
+	if c.PrivateKey, err = domain.EncryptSecret(c.PrivateKey); err != nil {
+		return c, fmt.Errorf("could not encrypt private key for %s: %s", c.Address, err)
+	}
+	if c.Password, err = domain.EncryptSecret(c.Password); err != nil {
+		return c, fmt.Errorf("could not encrypt password for %s: %s", c.Address, err)
+	}
+	return c, nil
+}
+
+// decryptRepository returns a copy of the repository config with its credentials decrypted.
+// It fails rather than blanking them, so that they aren't lost by saving the project again.
+func decryptRepository(c velocity.GitRepository) (velocity.GitRepository, error) {
+	var err error
+	if c.PrivateKey, err = domain.DecryptSecret(c.PrivateKey); err != nil {
+		return c, fmt.Errorf("could not decrypt private key for %s: %s", c.Address, err)
+	}
+	if c.Password, err = domain.DecryptSecret(c.Password); err != nil {
+		return c, fmt.Errorf("could not decrypt password for %s: %s", c.Address, err)
 	}
+	return c, nil
 }
 
 type stormDB struct {
 	*storm.DB
 }
 
+// NewStormRepository returns a project repository backed by storm.
+func NewStormRepository(db *storm.DB) Repository {
+	return newStormDB(db)
+}
+
 func newStormDB(db *storm.DB) *stormDB {
 	db.Init(&Project{})
 	return &stormDB{db}
 }
 
-func (db *stormDB) save(p *Project) error {
+func (db *stormDB) Save(p *Project) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
 	}
 
-	if err := tx.Save(p.toStormProject()); err != nil {
+	s, err := p.toStormProject()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Save(s); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -70,38 +123,38 @@ func (db *stormDB) save(p *Project) error {
 	return tx.Commit()
 }
 
-func (db *stormDB) delete(p *Project) error {
+func (db *stormDB) Delete(p *Project) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
 	}
 
-	tx.DeleteStruct(p.toStormProject())
+	tx.DeleteStruct(&StormProject{ID: p.ID})
 
 	return tx.Commit()
 }
 
-func (db *stormDB) getBySlug(slug string) (*Project, error) {
+func (db *stormDB) GetBySlug(slug string) (*Project, error) {
 	query := db.Select(q.Eq("Slug", slug))
 	var p StormProject
 	if err := query.First(&p); err != nil {
 		return nil, err
 	}
 
-	return p.ToProject(), nil
+	return p.ToProject()
 }
 
-func (db *stormDB) getByName(name string) (*Project, error) {
+func (db *stormDB) GetByName(name string) (*Project, error) {
 	query := db.Select(q.Eq("Name", name))
 	var p StormProject
 	if err := query.First(&p); err != nil {
 		return nil, err
 	}
 
-	return p.ToProject(), nil
+	return p.ToProject()
 }
 
-func (db *stormDB) getAll(pQ *domain.PagingQuery) (r []*Project, t int) {
+func (db *stormDB) GetAll(pQ *domain.PagingQuery) (r []*Project, t int) {
 	t = 0
 	t, err := db.Count(&StormProject{})
 	if err != nil {
@@ -114,17 +167,45 @@ func (db *stormDB) getAll(pQ *domain.PagingQuery) (r []*Project, t int) {
 	var StormProjects []*StormProject
 	query.Find(&StormProjects)
 
-	for _, p := range StormProjects {
-		r = append(r, p.ToProject())
+	for _, sp := range StormProjects {
+		p, err := sp.ToProject()
+		if err != nil {
+			velocity.GetLogger().Error("error", zap.String("project", sp.Slug), zap.Error(err))
+			continue
+		}
+		r = append(r, p)
 	}
 
 	return r, t
 }
 
+func (db *stormDB) GetByID(id string) (*Project, error) {
+	return GetByID(db.DB, id)
+}
+
 func GetByID(db *storm.DB, id string) (*Project, error) {
 	var p StormProject
 	if err := db.One("ID", id, &p); err != nil {
 		return nil, err
 	}
-	return p.ToProject(), nil
+	return p.ToProject()
+}
+
+// MigrateStorm copies the projects in a storm database into another repository.
+func MigrateStorm(from *storm.DB, to Repository) (int, error) {
+	var stormProjects []*StormProject
+	if err := from.All(&stormProjects); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	for _, sp := range stormProjects {
+		p, err := sp.ToProject()
+		if err != nil {
+			return 0, err
+		}
+		if err := to.Save(p); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(stormProjects), nil
 }
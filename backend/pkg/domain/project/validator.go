@@ -32,6 +32,7 @@ func newValidator(
 	v.validate.RegisterStructValidation(v.validateProjectRepository, Project{})
 	v.validate.RegisterTranslation("hostKeyError", trans, registerFuncRepository, translationFuncRepository)
 	v.validate.RegisterTranslation("privateKeyError", trans, registerFuncKey, translationFuncKey)
+	v.validate.RegisterTranslation("credentialsError", trans, registerFuncCredentials, translationFuncCredentials)
 
 	return v
 }
@@ -85,6 +86,9 @@ func (v *validator) validateProjectRepository(sl govalidator.StructLevel) {
 		case velocity.SSHKeyError:
 			sl.ReportError(p.Config.PrivateKey, "key", "key", "privateKeyError", "")
 			break
+		case velocity.GitAuthError:
+			sl.ReportError(p.Config.Password, "password", "password", "credentialsError", "")
+			break
 		default:
 			velocity.GetLogger().Error("error", zap.Error(err))
 			// sl.ReportError(p.Config.Address, "repository", "repository", "", "")
@@ -111,3 +115,13 @@ func translationFuncKey(ut ut.Translator, fe govalidator.FieldError) string {
 
 	return t
 }
+
+func registerFuncCredentials(ut ut.Translator) error {
+	return ut.Add("credentialsError", "Could not authenticate with repository! Check the username and password or token.", true)
+}
+
+func translationFuncCredentials(ut ut.Translator, fe govalidator.FieldError) string {
+	t, _ := ut.T("credentialsError", fe.Field())
+
+	return t
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+)
+
+const encryptedSecretPrefix = "enc:v1:"
+
+var warnEncryptionKey sync.Once
+
+// ErrNoEncryptionKey is returned when secrets can't be encrypted or decrypted because
+// neither ENCRYPTION_KEY nor JWT_SECRET is set.
+var ErrNoEncryptionKey = errors.New("no encryption key, set ENCRYPTION_KEY")
+
+// getEncryptionKey derives the AES-256 key used for secrets at rest from ENCRYPTION_KEY,
+// falling back to JWT_SECRET for existing installations.
+func getEncryptionKey() ([]byte, error) {
+	k := os.Getenv("ENCRYPTION_KEY")
+	if k == "" {
+		k = os.Getenv("JWT_SECRET")
+		if k == "" {
+			return nil, ErrNoEncryptionKey
+		}
+		warnEncryptionKey.Do(func() {
+			velocity.GetLogger().Warn("ENCRYPTION_KEY not set, using JWT_SECRET to encrypt secrets")
+		})
+	}
+	key := sha256.Sum256([]byte(k))
+	return key[:], nil
+}
+
+// CheckEncryptionKey returns an error if there is no key to encrypt secrets at rest with.
+func CheckEncryptionKey() error {
+	_, err := getEncryptionKey()
+	return err
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := getEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret encrypts a value for storage with AES-GCM.
+func EncryptSecret(plaintext string) (string, error) {
+	if plaintext == "" || strings.HasPrefix(plaintext, encryptedSecretPrefix) {
+		return plaintext, nil
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts a value from EncryptSecret. Values stored before encryption
+// was introduced are returned unchanged.
+func DecryptSecret(s string) (string, error) {
+	if !strings.HasPrefix(s, encryptedSecretPrefix) {
+		return s, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedSecretPrefix))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted secret too short")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
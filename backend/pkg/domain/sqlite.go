@@ -0,0 +1,227 @@
+package domain
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+// sqliteMigrations are applied in order and recorded in the schema_migrations table, so
+// new migrations must only ever be appended.
+var sqliteMigrations = [][]string{
+	{
+		`CREATE TABLE users (
+			id TEXT PRIMARY KEY,
+			username TEXT NOT NULL UNIQUE,
+			hashed_password TEXT NOT NULL
+		)`,
+		`CREATE TABLE known_hosts (
+			id TEXT PRIMARY KEY,
+			entry TEXT NOT NULL,
+			hosts TEXT NOT NULL,
+			comment TEXT NOT NULL,
+			sha256_fingerprint TEXT NOT NULL,
+			md5_fingerprint TEXT NOT NULL
+		)`,
+		`CREATE INDEX known_hosts_entry ON known_hosts (entry)`,
+		`CREATE TABLE projects (
+			id TEXT PRIMARY KEY,
+			slug TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			config TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			synchronising BOOLEAN NOT NULL,
+			signatures TEXT NOT NULL,
+			repository_config TEXT NOT NULL
+		)`,
+		`CREATE INDEX projects_name ON projects (name)`,
+		`CREATE TABLE branches (
+			id TEXT PRIMARY KEY,
+			project_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			last_updated DATETIME NOT NULL,
+			active BOOLEAN NOT NULL
+		)`,
+		`CREATE INDEX branches_project_name ON branches (project_id, name)`,
+		`CREATE TABLE commits (
+			id TEXT PRIMARY KEY,
+			project_id TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			author TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			message TEXT NOT NULL,
+			signed TEXT NOT NULL,
+			signature TEXT NOT NULL
+		)`,
+		`CREATE INDEX commits_project_hash ON commits (project_id, hash)`,
+		`CREATE INDEX commits_project_created_at ON commits (project_id, created_at)`,
+		`CREATE TABLE branch_commits (
+			branch_id TEXT NOT NULL,
+			commit_id TEXT NOT NULL,
+			PRIMARY KEY (branch_id, commit_id)
+		)`,
+		`CREATE INDEX branch_commits_commit ON branch_commits (commit_id)`,
+		`CREATE TABLE tags (
+			id TEXT PRIMARY KEY,
+			project_id TEXT NOT NULL,
+			commit_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			last_updated DATETIME NOT NULL
+		)`,
+		`CREATE INDEX tags_project_name ON tags (project_id, name)`,
+		`CREATE INDEX tags_project_last_updated ON tags (project_id, last_updated)`,
+		`CREATE INDEX tags_commit ON tags (commit_id)`,
+		`CREATE TABLE tasks (
+			id TEXT PRIMARY KEY,
+			commit_id TEXT NOT NULL,
+			slug TEXT NOT NULL,
+			vtask TEXT NOT NULL
+		)`,
+		`CREATE INDEX tasks_commit_slug ON tasks (commit_id, slug)`,
+		`CREATE TABLE builds (
+			id TEXT PRIMARY KEY,
+			task_id TEXT NOT NULL,
+			commit_id TEXT NOT NULL,
+			project_id TEXT NOT NULL,
+			parameters TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			started_at DATETIME NOT NULL,
+			completed_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX builds_project_created_at ON builds (project_id, created_at)`,
+		`CREATE INDEX builds_commit_created_at ON builds (commit_id, created_at)`,
+		`CREATE INDEX builds_task_created_at ON builds (task_id, created_at)`,
+		`CREATE INDEX builds_status_created_at ON builds (status, created_at)`,
+		`CREATE TABLE steps (
+			id TEXT PRIMARY KEY,
+			build_id TEXT NOT NULL,
+			number INTEGER NOT NULL,
+			vstep TEXT NOT NULL,
+			status TEXT NOT NULL,
+			updated_at DATETIME NOT NULL,
+			started_at DATETIME NOT NULL,
+			completed_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX steps_build_number ON steps (build_id, number)`,
+		`CREATE TABLE streams (
+			id TEXT PRIMARY KEY,
+			step_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			status TEXT NOT NULL
+		)`,
+		`CREATE INDEX streams_step ON streams (step_id)`,
+		`CREATE TABLE stream_lines (
+			stream_id TEXT NOT NULL,
+			line_number INTEGER NOT NULL,
+			timestamp DATETIME NOT NULL,
+			output TEXT NOT NULL,
+			PRIMARY KEY (stream_id, line_number)
+		)`,
+		`CREATE TABLE pipelines (
+			id TEXT PRIMARY KEY,
+			commit_id TEXT NOT NULL,
+			project_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			parameters TEXT NOT NULL,
+			stages TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			started_at DATETIME NOT NULL,
+			completed_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX pipelines_project_created_at ON pipelines (project_id, created_at)`,
+		`CREATE INDEX pipelines_commit_created_at ON pipelines (commit_id, created_at)`,
+	},
+	{
+		`ALTER TABLE projects ADD COLUMN retention TEXT NOT NULL DEFAULT 'null'`,
+	},
+	{
+		`ALTER TABLE builds ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE projects ADD COLUMN max_concurrent_builds INTEGER NOT NULL DEFAULT 0`,
+	},
+	{
+		`ALTER TABLE builds ADD COLUMN reason TEXT NOT NULL DEFAULT ''`,
+	},
+}
+
+// SQLScanner is implemented by *sql.Row and *sql.Rows so that repositories can share
+// their scanning between single rows and result sets.
+type SQLScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func NewSQLiteDB(path string) *sql.DB {
+	db, err := OpenSQLiteDB(path)
+	if err != nil {
+		velocity.GetLogger().Fatal("could not create sqlite DB", zap.Error(err))
+	}
+
+	return db
+}
+
+// OpenSQLiteDB opens the SQLite database at the given path, creating it if needed, and
+// brings its schema up to date.
+func OpenSQLiteDB(path string) (*sql.DB, error) {
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, os.ModePerm)
+	// readers don't block the writer in WAL mode and writers wait for each other
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", path))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := MigrateSQLiteDB(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// MigrateSQLiteDB applies the schema migrations that haven't been applied to the database yet.
+func MigrateSQLiteDB(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range sqliteMigrations[i] {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("could not apply migration %d: %s", i+1, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, i+1, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		velocity.GetLogger().Info("applied sqlite migration", zap.Int("version", i+1))
+	}
+
+	return nil
+}
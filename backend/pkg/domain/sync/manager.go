@@ -17,6 +17,7 @@ type Manager struct {
 	taskManager    *task.Manager
 	branchManager  *githistory.BranchManager
 	commitManager  *githistory.CommitManager
+	tagManager     *githistory.TagManager
 }
 
 func NewManager(
@@ -24,12 +25,14 @@ func NewManager(
 	taskManager *task.Manager,
 	branchManager *githistory.BranchManager,
 	commitManager *githistory.CommitManager,
+	tagManager *githistory.TagManager,
 ) *Manager {
 	return &Manager{
 		projectManager: projectManager,
 		taskManager:    taskManager,
 		branchManager:  branchManager,
 		commitManager:  commitManager,
+		tagManager:     tagManager,
 	}
 }
 
@@ -50,14 +53,14 @@ func (m *Manager) Sync(p *project.Project) (*project.Project, error) {
 
 func sync(p *project.Project, m *Manager) {
 	velocity.GetLogger().Info("synchronising project", zap.String("slug", p.Slug))
-	xd, _ := os.Getwd()
-	defer os.Chdir(xd)
 	defer finishSync(p, m)
 	// clone
-	repo, err := velocity.Clone(&p.Config, velocity.NewBlankEmitter().GetStreamWriter("clone"), &velocity.CloneOptions{
-		Bare:      false,
-		Full:      false,
-		Submodule: true,
+	writer := velocity.NewBlankEmitter().GetStreamWriter("clone")
+	repo, err := velocity.Clone(&p.Config, writer, &velocity.CloneOptions{
+		Bare:  false,
+		Full:  false,
+		Depth: 1,
+		Tags:  true,
 	})
 	if err != nil {
 		velocity.GetLogger().Info("could not clone repository", zap.Error(err))
@@ -74,11 +77,17 @@ func sync(p *project.Project, m *Manager) {
 
 	// clone further
 	if p.RepositoryConfig.Git.Depth > 1 {
-
+		err = repo.Fetch(&p.Config, writer, &velocity.CloneOptions{
+			Depth: p.RepositoryConfig.Git.Depth,
+			Tags:  true,
+		})
+		if err != nil {
+			velocity.GetLogger().Error("could not fetch repository history", zap.Error(err), zap.Int("depth", p.RepositoryConfig.Git.Depth))
+		}
 	}
 
 	// sync tasks
-	err = syncTasks(p, repo, m.taskManager, m.branchManager, m.commitManager)
+	err = syncTasks(p, repo, m.taskManager, m.branchManager, m.commitManager, m.tagManager)
 }
 
 func finishSync(p *project.Project, m *Manager) {
@@ -1,19 +1,12 @@
 package sync
 
 import (
-	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"strings"
-
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/project"
 	"github.com/velocity-ci/velocity/backend/pkg/domain/task"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity"
 	"go.uber.org/zap"
-	yaml "gopkg.in/yaml.v2"
 )
 
 func syncTasks(
@@ -22,9 +15,9 @@ func syncTasks(
 	taskManager *task.Manager,
 	branchManager *githistory.BranchManager,
 	commitManager *githistory.CommitManager,
+	tagManager *githistory.TagManager,
 ) error {
 	branches := repo.GetBranches()
-
 	for _, branchName := range branches {
 		b, err := branchManager.GetByProjectAndName(p, branchName)
 		if err != nil {
@@ -38,53 +31,18 @@ func syncTasks(
 			zap.String("message", rawCommit.Message),
 			zap.Time("at", rawCommit.AuthorDate),
 		)
-		c, err := commitManager.GetByProjectAndHash(p, rawCommit.SHA)
 
+		c, err := commitManager.GetByProjectAndHash(p, rawCommit.SHA)
 		if err != nil {
-			c = commitManager.Create(
-				b,
-				p,
-				rawCommit.SHA,
-				rawCommit.Message,
-				rawCommit.AuthorEmail,
-				rawCommit.AuthorDate,
-				rawCommit.Signed,
-			)
-			velocity.GetLogger().Info("created commit",
-				zap.String("project", p.Slug),
-				zap.String("sha", c.Hash),
-				zap.String("branch", branchName),
-			)
-
-			err = repo.Checkout(rawCommit.SHA)
-
+			c, err = syncCommit(p, repo, rawCommit, b, taskManager, commitManager)
 			if err != nil {
 				velocity.GetLogger().Error("error", zap.Error(err))
 				break
 			}
-
-			if _, err := os.Stat(fmt.Sprintf("%s/tasks/", repo.Directory)); err == nil {
-				os.Chdir(repo.Directory)
-				filepath.Walk(fmt.Sprintf("%s/tasks/", repo.Directory), func(path string, f os.FileInfo, err error) error {
-					if !f.IsDir() && strings.HasSuffix(f.Name(), ".yml") || strings.HasSuffix(f.Name(), ".yaml") {
-						taskYml, _ := ioutil.ReadFile(fmt.Sprintf("%s/tasks/%s", repo.Directory, f.Name()))
-						var t velocity.Task
-						err := yaml.Unmarshal(taskYml, &t)
-						if err != nil {
-							velocity.GetLogger().Error("error", zap.Error(err))
-						} else {
-							taskManager.Create(c, &t, velocity.NewSetup())
-							velocity.GetLogger().Info("created task",
-								zap.String("project", p.Slug),
-								zap.String("sha", c.Hash),
-								zap.String("task", t.Name),
-							)
-						}
-					}
-					return nil
-				})
-			}
-		} else if !branchManager.HasCommit(b, c) {
+		} else {
+			verifyCommit(p, repo, c, commitManager)
+		}
+		if !branchManager.HasCommit(b, c) {
 			velocity.GetLogger().Info("added commit to branch",
 				zap.String("project", p.Slug),
 				zap.String("sha", c.Hash),
@@ -96,16 +54,159 @@ func syncTasks(
 
 	// Set remaining local branches as inactive.
 	allKnownBranches, _ := branchManager.GetAllForProject(p, &domain.PagingQuery{Limit: 100, Page: 1})
-
 	localOnlyBranches := removeRemoteBranches(allKnownBranches, branches)
 	for _, b := range localOnlyBranches {
 		b.Active = false
 		branchManager.Update(b)
 	}
 
+	tags, err := repo.GetTags()
+	if err != nil {
+		// without the tags of the repository, none can be told to have been deleted
+		velocity.GetLogger().Error("could not list tags", zap.String("project", p.Slug), zap.Error(err))
+		return err
+	}
+	for _, tagName := range tags {
+		rawCommit := repo.GetCommitAtTag(tagName)
+		c, err := commitManager.GetByProjectAndHash(p, rawCommit.SHA)
+		if err != nil {
+			c, err = syncCommit(p, repo, rawCommit, nil, taskManager, commitManager)
+			if err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+				continue
+			}
+		} else {
+			verifyCommit(p, repo, c, commitManager)
+		}
+
+		t, err := tagManager.GetByProjectAndName(p, tagName)
+		if err != nil {
+			tagManager.Create(c, tagName)
+			velocity.GetLogger().Info("created tag",
+				zap.String("project", p.Slug),
+				zap.String("sha", c.Hash),
+				zap.String("tag", tagName),
+			)
+		} else if t.Commit == nil || t.Commit.ID != c.ID {
+			// tag has been moved
+			t.Commit = c
+			tagManager.Update(t)
+			velocity.GetLogger().Info("moved tag",
+				zap.String("project", p.Slug),
+				zap.String("sha", c.Hash),
+				zap.String("tag", tagName),
+			)
+		}
+	}
+
+	// Remove tags that have been deleted from the repository.
+	for _, t := range removeRemoteTags(allTags(p, tagManager), tags) {
+		if err := tagManager.Delete(t); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		velocity.GetLogger().Info("deleted tag",
+			zap.String("project", p.Slug),
+			zap.String("tag", t.Name),
+		)
+	}
+
 	return nil
 }
 
+// allTags returns every tag known for a project.
+func allTags(p *project.Project, tagManager *githistory.TagManager) (r []*githistory.Tag) {
+	q := &domain.PagingQuery{Limit: 100, Page: 1}
+	for {
+		tags, total := tagManager.GetAllForProject(p, q)
+		r = append(r, tags...)
+		if len(tags) < 1 || len(r) >= total {
+			return r
+		}
+		q.Page++
+	}
+}
+
+// syncCommit creates the given commit, on a branch if given, along with its tasks.
+func syncCommit(
+	p *project.Project,
+	repo *velocity.RawRepository,
+	rawCommit *velocity.RawCommit,
+	b *githistory.Branch,
+	taskManager *task.Manager,
+	commitManager *githistory.CommitManager,
+) (*githistory.Commit, error) {
+	c := commitManager.Create(
+		b,
+		p,
+		rawCommit.SHA,
+		rawCommit.Message,
+		rawCommit.AuthorEmail,
+		rawCommit.AuthorDate,
+		rawCommit.Signed,
+	)
+	velocity.GetLogger().Info("created commit",
+		zap.String("project", p.Slug),
+		zap.String("sha", c.Hash),
+	)
+	verifyCommit(p, repo, c, commitManager)
+
+	if err := repo.Checkout(rawCommit.SHA); err != nil {
+		return c, err
+	}
+
+	tasks, err := velocity.DiscoverTasks(repo.Directory)
+	if err != nil {
+		// keep the commit so that the rest of the repository is synchronised
+		velocity.GetLogger().Error("could not discover tasks", zap.String("project", p.Slug), zap.String("sha", c.Hash), zap.Error(err))
+		return c, nil
+	}
+	for _, t := range tasks {
+		taskManager.Create(c, t, velocity.NewSetup())
+		velocity.GetLogger().Info("created task",
+			zap.String("project", p.Slug),
+			zap.String("sha", c.Hash),
+			zap.String("task", t.Name),
+		)
+	}
+
+	return c, nil
+}
+
+// verifyCommit updates the signature verification of a commit against the project's trusted keys.
+func verifyCommit(
+	p *project.Project,
+	repo *velocity.RawRepository,
+	c *githistory.Commit,
+	commitManager *githistory.CommitManager,
+) {
+	v, err := repo.VerifySignature(c.Hash, p.Signatures.TrustedKeys)
+	if err != nil {
+		velocity.GetLogger().Error("could not verify commit signature", zap.String("sha", c.Hash), zap.Error(err))
+		return
+	}
+	if *v != c.Signature {
+		c.Signature = *v
+		commitManager.Update(c)
+	}
+}
+
+func removeRemoteTags(haystack []*githistory.Tag, names []string) (r []*githistory.Tag) {
+	for _, t := range haystack {
+		found := false
+		for _, n := range names {
+			if t.Name == n {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r = append(r, t)
+		}
+	}
+	return r
+}
+
 func removeRemoteBranches(haystack []*githistory.Branch, names []string) (r []*githistory.Branch) {
 	for _, b := range haystack {
 		found := false
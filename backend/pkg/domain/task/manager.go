@@ -1,7 +1,6 @@
 package task
 
 import (
-	"github.com/asdine/storm"
 	"github.com/gosimple/slug"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
@@ -15,8 +14,16 @@ const (
 	EventCreate = "task:new"
 )
 
+// Repository stores the tasks found at commits.
+type Repository interface {
+	Save(t *Task) error
+	GetByID(id string) (*Task, error)
+	GetByCommitAndSlug(c *githistory.Commit, slug string) (*Task, error)
+	GetAllForCommit(c *githistory.Commit, q *domain.PagingQuery) ([]*Task, int)
+}
+
 type Manager struct {
-	db             *stormDB
+	db             Repository
 	projectManager *project.Manager
 	branchManager  *githistory.BranchManager
 	commitManager  *githistory.CommitManager
@@ -24,13 +31,13 @@ type Manager struct {
 }
 
 func NewManager(
-	db *storm.DB,
+	db Repository,
 	projectManager *project.Manager,
 	branchManager *githistory.BranchManager,
 	commitManager *githistory.CommitManager,
 ) *Manager {
 	m := &Manager{
-		db:             newStormDB(db),
+		db:             db,
 		projectManager: projectManager,
 		branchManager:  branchManager,
 		commitManager:  commitManager,
@@ -56,7 +63,7 @@ func (m *Manager) Create(
 		Slug:   slug.Make(vTask.Name),
 	}
 
-	m.db.save(t)
+	m.db.Save(t)
 
 	// for _, b := range m.brokers {
 	// 	b.EmitAll(&domain.Emit{
@@ -70,9 +77,9 @@ func (m *Manager) Create(
 }
 
 func (m *Manager) GetByCommitAndSlug(c *githistory.Commit, slug string) (*Task, error) {
-	return m.db.getByCommitAndSlug(c, slug)
+	return m.db.GetByCommitAndSlug(c, slug)
 }
 
 func (m *Manager) GetAllForCommit(c *githistory.Commit, q *domain.PagingQuery) ([]*Task, int) {
-	return m.db.getAllForCommit(c, q)
+	return m.db.GetAllForCommit(c, q)
 }
@@ -0,0 +1,104 @@
+package task
+
+import (
+	"database/sql"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/domain/githistory"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type sqliteDB struct {
+	*sql.DB
+	commits githistory.CommitRepository
+}
+
+// NewSQLiteRepository returns a task repository backed by a migrated SQLite database.
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &sqliteDB{
+		DB:      db,
+		commits: githistory.NewCommitSQLiteRepository(db),
+	}
+}
+
+const sqliteTaskColumns = `id, commit_id, slug, vtask`
+
+func (db *sqliteDB) Save(t *Task) error {
+	vTask, err := json.Marshal(t.VTask)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO tasks (`+sqliteTaskColumns+`) VALUES (?, ?, ?, ?)`,
+		t.ID, t.Commit.ID, t.Slug, vTask,
+	)
+	return err
+}
+
+// find returns the tasks selected by the query, loading their commits once the rows have
+// been read.
+func (db *sqliteDB) find(query string, args ...interface{}) (r []*Task) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r
+	}
+	commitIDs := []string{}
+	for rows.Next() {
+		t := &Task{VTask: &velocity.Task{}}
+		var commitID string
+		var vTask []byte
+		if err := rows.Scan(&t.ID, &commitID, &t.Slug, &vTask); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		if err := json.Unmarshal(vTask, t.VTask); err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+		}
+		r = append(r, t)
+		commitIDs = append(commitIDs, commitID)
+	}
+	rows.Close()
+
+	commits := map[string]*githistory.Commit{}
+	for i, t := range r {
+		c, ok := commits[commitIDs[i]]
+		if !ok {
+			if c, err = db.commits.GetByID(commitIDs[i]); err != nil {
+				velocity.GetLogger().Error("error", zap.Error(err))
+			}
+			commits[commitIDs[i]] = c
+		}
+		t.Commit = c
+	}
+
+	return r
+}
+
+func (db *sqliteDB) GetByID(id string) (*Task, error) {
+	r := db.find(`SELECT `+sqliteTaskColumns+` FROM tasks WHERE id = ?`, id)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *sqliteDB) GetByCommitAndSlug(c *githistory.Commit, slug string) (*Task, error) {
+	r := db.find(`SELECT `+sqliteTaskColumns+` FROM tasks WHERE commit_id = ? AND slug = ? LIMIT 1`, c.ID, slug)
+	if len(r) < 1 {
+		return nil, sql.ErrNoRows
+	}
+	return r[0], nil
+}
+
+func (db *sqliteDB) GetAllForCommit(c *githistory.Commit, pQ *domain.PagingQuery) (r []*Task, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE commit_id = ?`, c.ID).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	return db.find(`SELECT `+sqliteTaskColumns+` FROM tasks WHERE commit_id = ? ORDER BY slug LIMIT ? OFFSET ?`,
+		c.ID, pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	), t
+}
@@ -53,12 +53,17 @@ type stormDB struct {
 	*storm.DB
 }
 
+// NewStormRepository returns a task repository backed by storm.
+func NewStormRepository(db *storm.DB) Repository {
+	return newStormDB(db)
+}
+
 func newStormDB(db *storm.DB) *stormDB {
 	db.Init(&StormTask{})
 	return &stormDB{db}
 }
 
-func (db *stormDB) save(t *Task) error {
+func (db *stormDB) Save(t *Task) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -72,7 +77,7 @@ func (db *stormDB) save(t *Task) error {
 	return tx.Commit()
 }
 
-func (db *stormDB) getByCommitAndSlug(commit *githistory.Commit, name string) (*Task, error) {
+func (db *stormDB) GetByCommitAndSlug(commit *githistory.Commit, name string) (*Task, error) {
 	query := db.Select(q.And(q.Eq("CommitID", commit.ID), q.Eq("Slug", name)))
 	var t StormTask
 	if err := query.First(&t); err != nil {
@@ -82,7 +87,7 @@ func (db *stormDB) getByCommitAndSlug(commit *githistory.Commit, name string) (*
 	return t.ToTask(db.DB), nil
 }
 
-func (db *stormDB) getAllForCommit(commit *githistory.Commit, pQ *domain.PagingQuery) (r []*Task, t int) {
+func (db *stormDB) GetAllForCommit(commit *githistory.Commit, pQ *domain.PagingQuery) (r []*Task, t int) {
 	t = 0
 	query := db.Select(q.Eq("CommitID", commit.ID))
 	t, err := query.Count(&StormTask{})
@@ -101,6 +106,10 @@ func (db *stormDB) getAllForCommit(commit *githistory.Commit, pQ *domain.PagingQ
 	return r, t
 }
 
+func (db *stormDB) GetByID(id string) (*Task, error) {
+	return GetByID(db.DB, id)
+}
+
 func GetByID(db *storm.DB, id string) (*Task, error) {
 	var sT StormTask
 	if err := db.One("ID", id, &sT); err != nil {
@@ -108,3 +117,26 @@ func GetByID(db *storm.DB, id string) (*Task, error) {
 	}
 	return sT.ToTask(db), nil
 }
+
+// MigrateStorm copies the tasks in a storm database into another repository, skipping
+// tasks of commits that no longer exist.
+func MigrateStorm(from *storm.DB, to Repository) (int, error) {
+	var stormTasks []*StormTask
+	if err := from.All(&stormTasks); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	n := 0
+	for _, sT := range stormTasks {
+		t := sT.ToTask(from)
+		if t.Commit == nil {
+			velocity.GetLogger().Warn("skipping task without commit", zap.String("taskID", t.ID))
+			continue
+		}
+		if err := to.Save(t); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -47,9 +47,9 @@ func (s *CommitSuite) SetupTest() {
 	syncMock := func(*velocity.GitRepository) (bool, error) {
 		return true, nil
 	}
-	s.projectManager = project.NewManager(s.storm, validator, translator, syncMock)
-	s.commitManager = githistory.NewCommitManager(s.storm)
-	s.branchManager = githistory.NewBranchManager(s.storm)
+	s.projectManager = project.NewManager(project.NewStormRepository(s.storm), validator, translator, syncMock)
+	s.commitManager = githistory.NewCommitManager(githistory.NewCommitStormRepository(s.storm))
+	s.branchManager = githistory.NewBranchManager(githistory.NewBranchStormRepository(s.storm))
 }
 
 func (s *CommitSuite) TearDownTest() {
@@ -66,7 +66,7 @@ func (s *CommitSuite) TestCreate() {
 
 	c := s.commitManager.Create(br, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
 
-	m := task.NewManager(s.storm, s.projectManager, s.branchManager, s.commitManager)
+	m := task.NewManager(task.NewStormRepository(s.storm), s.projectManager, s.branchManager, s.commitManager)
 	setupStep := velocity.NewSetup()
 	tsk := m.Create(c, &velocity.Task{
 		Name: "testTask",
@@ -87,7 +87,7 @@ func (s *CommitSuite) TestGetByCommitAndSlug() {
 	b := s.branchManager.Create(p, "testBranch")
 	c := s.commitManager.Create(b, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
 
-	m := task.NewManager(s.storm, s.projectManager, s.branchManager, s.commitManager)
+	m := task.NewManager(task.NewStormRepository(s.storm), s.projectManager, s.branchManager, s.commitManager)
 	tsk := m.Create(c, &velocity.Task{
 		Name: "testTask",
 	}, velocity.NewSetup())
@@ -107,7 +107,7 @@ func (s *CommitSuite) TestGetAllForCommit() {
 	b := s.branchManager.Create(p, "testBranch")
 	c := s.commitManager.Create(b, p, "abcdef", "test commit", "me@velocityci.io", time.Now().UTC(), "")
 
-	m := task.NewManager(s.storm, s.projectManager, s.branchManager, s.commitManager)
+	m := task.NewManager(task.NewStormRepository(s.storm), s.projectManager, s.branchManager, s.commitManager)
 	tsk1 := m.Create(c, &velocity.Task{
 		Name: "testTask",
 	}, velocity.NewSetup())
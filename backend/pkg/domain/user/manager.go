@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/asdine/storm"
 	ut "github.com/go-playground/universal-translator"
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/domain"
@@ -20,19 +19,28 @@ const (
 	EventDelete = "user:delete"
 )
 
+// Repository stores users.
+type Repository interface {
+	Save(u *User) error
+	Delete(u *User) error
+	GetByID(id string) (*User, error)
+	GetByUsername(username string) (*User, error)
+	GetAll(q *domain.PagingQuery) ([]*User, int)
+}
+
 type Manager struct {
 	validator *validator
-	db        *stormDB
+	db        Repository
 	brokers   []domain.Broker
 }
 
 func NewManager(
-	db *storm.DB,
+	db Repository,
 	validator *govalidator.Validate,
 	translator ut.Translator,
 ) *Manager {
 	m := &Manager{
-		db:      newStormDB(db),
+		db:      db,
 		brokers: []domain.Broker{},
 	}
 	m.validator = newValidator(validator, translator, m)
@@ -74,7 +82,7 @@ func (m *Manager) Create(username, password string) (*User, *domain.ValidationEr
 	u.ID = uuid.NewV1().String()
 	u.hashPassword(password)
 
-	if err := m.db.save(u); err != nil {
+	if err := m.db.Save(u); err != nil {
 		velocity.GetLogger().Error("error", zap.Error(err))
 		return nil, nil
 	}
@@ -91,7 +99,7 @@ func (m *Manager) Create(username, password string) (*User, *domain.ValidationEr
 }
 
 func (m *Manager) Update(u *User) error {
-	if err := m.db.save(u); err != nil {
+	if err := m.db.Save(u); err != nil {
 		return err
 	}
 	for _, b := range m.brokers {
@@ -105,7 +113,7 @@ func (m *Manager) Update(u *User) error {
 }
 
 func (m *Manager) Delete(u *User) error {
-	if err := m.db.delete(u); err != nil {
+	if err := m.db.Delete(u); err != nil {
 		return err
 	}
 	for _, b := range m.brokers {
@@ -126,9 +134,9 @@ func (m *Manager) Exists(username string) bool {
 }
 
 func (m *Manager) GetByUsername(username string) (*User, error) {
-	return m.db.getByUsername(username)
+	return m.db.GetByUsername(username)
 }
 
 func (m *Manager) GetAll(q *domain.PagingQuery) ([]*User, int) {
-	return m.db.getAll(q)
+	return m.db.GetAll(q)
 }
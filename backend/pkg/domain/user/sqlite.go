@@ -0,0 +1,74 @@
+package user
+
+import (
+	"database/sql"
+
+	"github.com/velocity-ci/velocity/backend/pkg/domain"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity"
+	"go.uber.org/zap"
+)
+
+type sqliteDB struct {
+	*sql.DB
+}
+
+// NewSQLiteRepository returns a user repository backed by a migrated SQLite database.
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &sqliteDB{db}
+}
+
+const sqliteUserColumns = `id, username, hashed_password`
+
+func scanUser(row domain.SQLScanner) (*User, error) {
+	u := &User{}
+	if err := row.Scan(&u.ID, &u.Username, &u.HashedPassword); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (db *sqliteDB) Save(u *User) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO users (`+sqliteUserColumns+`) VALUES (?, ?, ?)`,
+		u.ID, u.Username, u.HashedPassword,
+	)
+	return err
+}
+
+func (db *sqliteDB) Delete(u *User) error {
+	_, err := db.Exec(`DELETE FROM users WHERE id = ?`, u.ID)
+	return err
+}
+
+func (db *sqliteDB) GetByID(id string) (*User, error) {
+	return scanUser(db.QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE id = ?`, id))
+}
+
+func (db *sqliteDB) GetByUsername(username string) (*User, error) {
+	return scanUser(db.QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE username = ?`, username))
+}
+
+func (db *sqliteDB) GetAll(pQ *domain.PagingQuery) (r []*User, t int) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&t); err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, 0
+	}
+
+	rows, err := db.Query(`SELECT `+sqliteUserColumns+` FROM users ORDER BY username LIMIT ? OFFSET ?`,
+		pQ.Limit, (pQ.Page-1)*pQ.Limit,
+	)
+	if err != nil {
+		velocity.GetLogger().Error("error", zap.Error(err))
+		return r, t
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			velocity.GetLogger().Error("error", zap.Error(err))
+			continue
+		}
+		r = append(r, u)
+	}
+
+	return r, t
+}
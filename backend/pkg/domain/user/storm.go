@@ -34,12 +34,17 @@ type stormDB struct {
 	*storm.DB
 }
 
+// NewStormRepository returns a user repository backed by storm.
+func NewStormRepository(db *storm.DB) Repository {
+	return newStormDB(db)
+}
+
 func newStormDB(db *storm.DB) *stormDB {
 	db.Init(&User{})
 	return &stormDB{db}
 }
 
-func (db *stormDB) save(u *User) error {
+func (db *stormDB) Save(u *User) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -53,7 +58,7 @@ func (db *stormDB) save(u *User) error {
 	return tx.Commit()
 }
 
-func (db *stormDB) delete(u *User) error {
+func (db *stormDB) Delete(u *User) error {
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
@@ -64,7 +69,7 @@ func (db *stormDB) delete(u *User) error {
 	return tx.Commit()
 }
 
-func (db *stormDB) getByUsername(username string) (*User, error) {
+func (db *stormDB) GetByUsername(username string) (*User, error) {
 	query := db.Select(q.Eq("Username", username))
 	var u StormUser
 	if err := query.First(&u); err != nil {
@@ -74,6 +79,10 @@ func (db *stormDB) getByUsername(username string) (*User, error) {
 	return u.ToUser(), nil
 }
 
+func (db *stormDB) GetByID(id string) (*User, error) {
+	return GetByID(db.DB, id)
+}
+
 func GetByID(db *storm.DB, id string) (*User, error) {
 	var u StormUser
 	if err := db.One("ID", id, &u); err != nil {
@@ -82,7 +91,7 @@ func GetByID(db *storm.DB, id string) (*User, error) {
 	return u.ToUser(), nil
 }
 
-func (db *stormDB) getAll(pQ *domain.PagingQuery) (r []*User, t int) {
+func (db *stormDB) GetAll(pQ *domain.PagingQuery) (r []*User, t int) {
 	t = 0
 	t, err := db.Count(&StormUser{})
 	if err != nil {
@@ -101,3 +110,18 @@ func (db *stormDB) getAll(pQ *domain.PagingQuery) (r []*User, t int) {
 
 	return r, t
 }
+
+// MigrateStorm copies the users in a storm database into another repository.
+func MigrateStorm(from *storm.DB, to Repository) (int, error) {
+	var stormUsers []*StormUser
+	if err := from.All(&stormUsers); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	for _, u := range stormUsers {
+		if err := to.Save(u.ToUser()); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(stormUsers), nil
+}
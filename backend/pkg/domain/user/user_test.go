@@ -37,7 +37,7 @@ func (s *UserSuite) SetupTest() {
 		panic(err)
 	}
 	validator, translator := domain.NewValidator()
-	s.uM = user.NewManager(s.storm, validator, translator)
+	s.uM = user.NewManager(user.NewStormRepository(s.storm), validator, translator)
 }
 
 func (s *UserSuite) TearDownTest() {
@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -76,6 +77,37 @@ type serviceRunner struct {
 	params map[string]Parameter
 }
 
+// Docker labels applied to every container, network and image velocity creates
+const (
+	LabelOwner   = "owner"
+	LabelBuild   = "velocity-ci.build"
+	LabelStep    = "velocity-ci.step"
+	LabelProject = "velocity-ci.project"
+
+	ownerVelocity = "velocity-ci"
+)
+
+func getDockerLabels(t *Task, s Step) map[string]string {
+	buildID := t.BuildID
+	if buildID == "" {
+		buildID = t.RunID
+	}
+	stepNumber := ""
+	for i, tS := range t.Steps {
+		if tS == s {
+			stepNumber = strconv.Itoa(i)
+			break
+		}
+	}
+
+	return map[string]string{
+		LabelOwner:   ownerVelocity,
+		LabelBuild:   buildID,
+		LabelStep:    stepNumber,
+		LabelProject: t.Project,
+	}
+}
+
 func getContainerName(serviceName string) string {
 	return fmt.Sprintf(
 		"vci-%s-%x",
@@ -133,14 +165,16 @@ func getAuthToken(image string, dockerRegistries []DockerRegistry) string {
 	return ""
 }
 
-func (sR *serviceRunner) PullOrBuild(dockerRegistries []DockerRegistry) {
+func (sR *serviceRunner) PullOrBuild(dockerRegistries []DockerRegistry, workspace string) {
 	imageIDProgress = map[string]string{}
 	if sR.build != nil && (sR.build.Dockerfile != "" || sR.build.Context != "") {
 		authConfigs := getAuthConfigsMap(dockerRegistries)
 		err := buildContainer(
+			workspace,
 			sR.build.Context,
 			sR.build.Dockerfile,
 			[]string{getImageName(sR.name)},
+			sR.containerConfig.Labels,
 			sR.params,
 			sR.writer,
 			authConfigs,
@@ -269,17 +303,18 @@ func (sR *serviceRunner) Stop() {
 }
 
 func buildContainer(
+	workspace string,
 	buildContext string,
 	dockerfile string,
 	tags []string,
+	labels map[string]string,
 	parameters map[string]Parameter,
 	writer io.Writer,
 	authConfigs map[string]types.AuthConfig,
 ) error {
 	GetLogger().Debug("building image", zap.String("Dockerfile", dockerfile), zap.String("build context", buildContext))
 
-	cwd, _ := os.Getwd()
-	buildContext = fmt.Sprintf("%s/%s", cwd, buildContext)
+	buildContext = fmt.Sprintf("%s/%s", workspace, buildContext)
 
 	excludes, err := readDockerignore(buildContext)
 	if err != nil {
@@ -306,6 +341,7 @@ func buildContainer(
 		Remove:      true,
 		Dockerfile:  dockerfile,
 		Tags:        tags,
+		Labels:      labels,
 	})
 	if err != nil {
 		return err
@@ -335,14 +371,18 @@ func handleOutput(body io.ReadCloser, parameters map[string]Parameter, writer io
 		}
 
 		if o != "*" {
-			for _, p := range parameters {
-				if p.IsSecret {
-					o = strings.Replace(o, p.Value, "***", -1)
-				}
-			}
-			writer.Write([]byte(o))
+			writer.Write([]byte(maskSecrets(o, parameters)))
+		}
+	}
+}
+
+func maskSecrets(o string, parameters map[string]Parameter) string {
+	for _, p := range parameters {
+		if p.IsSecret && p.Value != "" {
+			o = strings.Replace(o, p.Value, "***", -1)
 		}
 	}
+	return o
 }
 
 func handleLogOutput(b []byte) string {
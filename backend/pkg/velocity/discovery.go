@@ -0,0 +1,153 @@
+package velocity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var repositoryConfigFiles = []string{".velocity.yaml", ".velocity.yml"}
+
+// GetRepositoryConfig reads the .velocity.yaml or .velocity.yml in the project root, returning
+// the default configuration if there isn't one.
+func GetRepositoryConfig(projectRoot string) (*RepositoryConfig, error) {
+	for _, f := range repositoryConfigFiles {
+		repoYaml, err := ioutil.ReadFile(filepath.Join(projectRoot, f))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		c := NewRepositoryConfig()
+		if err := yaml.Unmarshal(repoYaml, c); err != nil {
+			return nil, fmt.Errorf("%s: %s", f, err)
+		}
+		return c, nil
+	}
+
+	GetLogger().Debug("could not find repository config .velocity.yaml or .velocity.yml", zap.String("projectRoot", projectRoot))
+	return NewRepositoryConfig(), nil
+}
+
+func isTaskFile(path string) bool {
+	for _, f := range repositoryConfigFiles {
+		if filepath.Base(path) == f {
+			return false
+		}
+	}
+	return strings.HasSuffix(path, ".yml") || strings.HasSuffix(path, ".yaml")
+}
+
+// findTaskFiles returns the task files matched by a tasksPath entry, which is either a
+// directory that is searched recursively, a task file or a glob pattern of either.
+func findTaskFiles(projectRoot string, pattern string) ([]string, error) {
+	root, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(root, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tasksPath %s: %s", pattern, err)
+	}
+
+	files := []string{}
+	for _, m := range matches {
+		if m != root && !strings.HasPrefix(m, root+string(filepath.Separator)) {
+			return nil, fmt.Errorf("tasksPath %s is outside of the project", pattern)
+		}
+		err := filepath.Walk(m, func(path string, f os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if f.IsDir() && path != m && strings.HasPrefix(f.Name(), ".") {
+				// e.g. .git
+				return filepath.SkipDir
+			}
+			if !f.IsDir() && isTaskFile(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// FindTaskFiles returns the task files matched by the given tasksPath entries, in order
+// and without duplicates.
+func FindTaskFiles(projectRoot string, tasksPaths []string) ([]string, error) {
+	files := []string{}
+	seenFiles := map[string]bool{}
+	for _, pattern := range tasksPaths {
+		matches, err := findTaskFiles(projectRoot, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range matches {
+			if seenFiles[f] {
+				continue
+			}
+			seenFiles[f] = true
+			files = append(files, f)
+		}
+	}
+
+	return files, nil
+}
+
+// DiscoverTasks parses the tasks found in the tasksPath of the project's repository
+// configuration. Tasks that can't be parsed, or have the name of a task that was already
+// found, are logged and skipped.
+func DiscoverTasks(projectRoot string) ([]*Task, error) {
+	config, err := GetRepositoryConfig(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiscoverTasksIn(projectRoot, config.Project.GetTasksPaths())
+}
+
+// DiscoverTasksIn parses the tasks found in the given tasksPath entries rather than those
+// of the project's repository configuration.
+func DiscoverTasksIn(projectRoot string, tasksPaths []string) ([]*Task, error) {
+	files, err := FindTaskFiles(projectRoot, tasksPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []*Task{}
+	taskFiles := map[string]string{}
+	for _, f := range files {
+		taskYml, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		t, err := ParseTask(taskYml, projectRoot)
+		if err != nil {
+			GetLogger().Error("could not parse task", zap.String("file", f), zap.Error(err))
+			continue
+		}
+		if other, ok := taskFiles[t.Name]; ok {
+			GetLogger().Warn("skipped task with duplicate name",
+				zap.String("task", t.Name),
+				zap.String("file", f),
+				zap.String("first", other),
+			)
+			continue
+		}
+		taskFiles[t.Name] = f
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
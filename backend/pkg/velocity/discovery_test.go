@@ -0,0 +1,98 @@
+package velocity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeProjectFiles(t *testing.T, root string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+}
+
+func taskNames(tasks []*Task) []string {
+	names := []string{}
+	for _, t := range tasks {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestDiscoverTasksDefaultsToNestedTasksDirectory(t *testing.T) {
+	root, err := ioutil.TempDir("", "velocity-discovery")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	writeProjectFiles(t, root, map[string]string{
+		"tasks/build.yml":          "name: build\n",
+		"tasks/release/deploy.yml": "name: deploy\n",
+		"tasks/release/notes.txt":  "not a task\n",
+		"other/ignored.yml":        "name: ignored\n",
+	})
+
+	tasks, err := DiscoverTasks(root)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "deploy"}, taskNames(tasks))
+}
+
+func TestDiscoverTasksHonoursTasksPathGlobs(t *testing.T) {
+	root, err := ioutil.TempDir("", "velocity-discovery")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	writeProjectFiles(t, root, map[string]string{
+		".velocity.yml": `
+project:
+  tasksPath:
+  - services/*/tasks
+  - ci/release.yml
+`,
+		"services/api/tasks/test-api.yml": "name: test-api\n",
+		"services/web/tasks/test-web.yml": "name: test-web\n",
+		"services/web/tasks/dupe.yml":     "name: test-api\n",
+		"ci/release.yml":                  "name: release\n",
+		"tasks/ignored.yml":               "name: ignored\n",
+	})
+
+	tasks, err := DiscoverTasks(root)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test-api", "test-web", "release"}, taskNames(tasks))
+
+	// relative project roots give the same tasks
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+	tasks, err = DiscoverTasks(".")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test-api", "test-web", "release"}, taskNames(tasks))
+
+	writeProjectFiles(t, root, map[string]string{
+		".velocity.yml": "project:\n  tasksPath: ../\n",
+	})
+	_, err = DiscoverTasks(root)
+	assert.NotNil(t, err)
+}
+
+func TestDiscoverTasksInOverridesTasksPath(t *testing.T) {
+	root, err := ioutil.TempDir("", "velocity-discovery")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	writeProjectFiles(t, root, map[string]string{
+		".velocity.yml":     "project:\n  tasksPath: ci\n",
+		"ci/release.yml":    "name: release\n",
+		"local/build.yml":   "name: build\n",
+		"local/nested.yaml": "name: nested\n",
+	})
+
+	tasks, err := DiscoverTasksIn(root, []string{"local"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "nested"}, taskNames(tasks))
+}
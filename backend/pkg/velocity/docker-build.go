@@ -62,9 +62,11 @@ func (dB *DockerBuild) Execute(emitter Emitter, t *Task) error {
 	authConfigs := getAuthConfigsMap(t.Docker.Registries)
 
 	err := buildContainer(
+		t.Workspace,
 		dB.Context,
 		dB.Dockerfile,
 		dB.Tags,
+		getDockerLabels(t, dB),
 		t.ResolvedParameters,
 		writer,
 		authConfigs,
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -41,7 +40,7 @@ func (s *DockerCompose) UnmarshalYamlInterface(y map[interface{}]interface{}) er
 		s.ComposeFile = x.(string)
 		break
 	}
-	return s.parseDockerComposeFile()
+	return nil
 }
 
 func (dC DockerCompose) GetDetails() string {
@@ -56,12 +55,8 @@ func (dC *DockerCompose) SetParams(params map[string]Parameter) error {
 	return nil
 }
 
-func (dC *DockerCompose) parseDockerComposeFile() error {
-	dir, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-	dockerComposeYml, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", dir, dC.ComposeFile))
+func (dC *DockerCompose) parseDockerComposeFile(projectRoot string) error {
+	dockerComposeYml, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", projectRoot, dC.ComposeFile))
 	if err != nil {
 		return err
 	}
@@ -83,7 +78,7 @@ func (dC *DockerCompose) parseDockerComposeFile() error {
 
 func (dC *DockerCompose) Execute(emitter Emitter, t *Task) error {
 
-	err := dC.parseDockerComposeFile()
+	err := dC.parseDockerComposeFile(t.Workspace)
 	if err != nil {
 		return err
 	}
@@ -96,7 +91,7 @@ func (dC *DockerCompose) Execute(emitter Emitter, t *Task) error {
 	ctx := context.Background()
 
 	networkResp, err := cli.NetworkCreate(ctx, fmt.Sprintf("vci-%s", dC.GetRunID()), types.NetworkCreate{
-		Labels: map[string]string{"owner": "velocity-ci"},
+		Labels: getDockerLabels(t, dC),
 	})
 	if err != nil {
 		GetLogger().Error("could not create docker network", zap.String("err", err.Error()))
@@ -114,7 +109,7 @@ func (dC *DockerCompose) Execute(emitter Emitter, t *Task) error {
 		s := dC.Contents.Services[serviceName]
 
 		// generate containerConfig + hostConfig
-		containerConfig, hostConfig, networkConfig := dC.generateContainerAndHostConfig(s, networkResp.ID)
+		containerConfig, hostConfig, networkConfig := dC.generateContainerAndHostConfig(s, t.Workspace, networkResp.ID, getDockerLabels(t, dC))
 
 		// Create service runners
 		sR := newServiceRunner(
@@ -137,7 +132,7 @@ func (dC *DockerCompose) Execute(emitter Emitter, t *Task) error {
 
 	// Pull/Build images
 	for _, serviceRunner := range services {
-		serviceRunner.PullOrBuild(t.Docker.Registries)
+		serviceRunner.PullOrBuild(t.Docker.Registries, t.Workspace)
 	}
 
 	// Create services
@@ -189,9 +184,8 @@ func (dC *DockerCompose) String() string {
 	return string(j)
 }
 
-func (dC *DockerCompose) generateContainerAndHostConfig(s dockerComposeService, networkID string) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
+func (dC *DockerCompose) generateContainerAndHostConfig(s dockerComposeService, projectRoot string, networkID string, labels map[string]string) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
 	env := []string{}
-	projectRoot, _ := os.Getwd()
 	for k, v := range s.Environment {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
@@ -220,6 +214,7 @@ func (dC *DockerCompose) generateContainerAndHostConfig(s dockerComposeService,
 		Env:        env,
 		Volumes:    volumes,
 		WorkingDir: s.WorkingDir,
+		Labels:     labels,
 	}
 
 	links := []string{}
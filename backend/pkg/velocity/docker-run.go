@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -122,12 +121,6 @@ func (dR *DockerRun) Execute(emitter Emitter, t *Task) error {
 	for k, v := range dR.Environment {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
-	cwd, _ := os.Getwd()
-
-	// Only used for Docker-based CLI. Unsupported right now.
-	// if os.Getenv("SIB_CWD") != "" {
-	// 	cwd = os.Getenv("SIB_CWD")
-	// }
 
 	config := &container.Config{
 		Image: dR.Image,
@@ -137,11 +130,12 @@ func (dR *DockerRun) Execute(emitter Emitter, t *Task) error {
 		},
 		WorkingDir: fmt.Sprintf("%s/%s", dR.MountPoint, dR.WorkingDir),
 		Env:        env,
+		Labels:     getDockerLabels(t, dR),
 	}
 
 	hostConfig := &container.HostConfig{
 		Binds: []string{
-			fmt.Sprintf("%s:%s", cwd, dR.MountPoint),
+			fmt.Sprintf("%s:%s", t.Workspace, dR.MountPoint),
 		},
 	}
 
@@ -150,7 +144,7 @@ func (dR *DockerRun) Execute(emitter Emitter, t *Task) error {
 	ctx := context.Background()
 
 	networkResp, err := cli.NetworkCreate(ctx, fmt.Sprintf("vci-%s", dR.GetRunID()), types.NetworkCreate{
-		Labels: map[string]string{"owner": "velocity-ci"},
+		Labels: getDockerLabels(t, dR),
 	})
 	if err != nil {
 		GetLogger().Error("could not create docker network", zap.Error(err))
@@ -171,7 +165,7 @@ func (dR *DockerRun) Execute(emitter Emitter, t *Task) error {
 		networkResp.ID,
 	)
 
-	sR.PullOrBuild(t.Docker.Registries)
+	sR.PullOrBuild(t.Docker.Registries, t.Workspace)
 	sR.Create()
 	stopServicesChannel := make(chan string, 32)
 	wg.Add(1)
@@ -0,0 +1,299 @@
+package velocity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const pluginTimeout = 2 * time.Minute
+
+// EventPayload is the JSON document given to plugins on stdin when one of their events fires.
+type EventPayload struct {
+	Event      string            `json:"event"`
+	BuildID    string            `json:"buildId"`
+	Project    string            `json:"project"`
+	Status     string            `json:"status"`
+	Timings    EventTimings      `json:"timings"`
+	Task       EventTask         `json:"task"`
+	Step       *EventStep        `json:"step,omitempty"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+type EventTask struct {
+	Name    string       `json:"name"`
+	Status  string       `json:"status"`
+	Timings EventTimings `json:"timings"`
+}
+
+type EventStep struct {
+	Number      int          `json:"number"`
+	Type        string       `json:"type"`
+	Description string       `json:"description"`
+	Status      string       `json:"status"`
+	Timings     EventTimings `json:"timings"`
+}
+
+type EventTimings struct {
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+func newEventTimings() EventTimings {
+	return EventTimings{StartedAt: time.Now().UTC()}
+}
+
+func (e *EventTimings) complete() {
+	t := time.Now().UTC()
+	e.CompletedAt = &t
+}
+
+// EventDispatcher fires build, task and step events at the plugins configured in a
+// repository's .velocity.yml. Plugins are downloaded into the task workspace so events
+// are held back until the setup step has created it.
+type EventDispatcher struct {
+	plugins []PluginConfig
+	task    *Task
+	emitter Emitter
+
+	status  string
+	timings EventTimings
+	step    *EventStep
+	pending []*EventPayload
+	// writer is the output of the current, or last, step that plugin failures are reported on
+	writer StreamWriter
+}
+
+func NewEventDispatcher(plugins []PluginConfig, t *Task, emitter Emitter) *EventDispatcher {
+	return &EventDispatcher{
+		plugins: plugins,
+		task:    t,
+		emitter: emitter,
+		status:  StateWaiting,
+		pending: []*EventPayload{},
+	}
+}
+
+// Start fires the build and task start events.
+func (d *EventDispatcher) Start() {
+	d.status = StateRunning
+	d.timings = newEventTimings()
+	d.dispatch(EventBuildStart, "")
+	d.dispatch(EventTaskStart, d.task.Name)
+}
+
+// StepStart fires the start event for the given step.
+func (d *EventDispatcher) StepStart(number int, s Step) {
+	d.step = &EventStep{
+		Number:      number,
+		Type:        s.GetType(),
+		Description: s.GetDescription(),
+		Status:      StateRunning,
+		Timings:     newEventTimings(),
+	}
+	if streams := s.GetOutputStreams(); len(streams) > 0 {
+		d.writer = d.emitter.GetStreamWriter(streams[0])
+	}
+	d.dispatch(EventStepStart, d.step.Description)
+}
+
+// StepComplete fires the completion events for the current step.
+func (d *EventDispatcher) StepComplete(err error) {
+	if d.step == nil {
+		return
+	}
+	d.step.Timings.complete()
+	d.step.Status = statusFromError(err)
+	d.dispatch(EventStepComplete, d.step.Description)
+	if err != nil {
+		d.dispatch(EventStepFail, d.step.Description)
+	} else {
+		d.dispatch(EventStepSuccess, d.step.Description)
+	}
+	d.step = nil
+}
+
+// Complete fires the task and build completion events.
+func (d *EventDispatcher) Complete(err error) {
+	d.timings.complete()
+	d.status = statusFromError(err)
+	d.dispatch(EventTaskComplete, d.task.Name)
+	if err != nil {
+		d.dispatch(EventTaskFail, d.task.Name)
+	} else {
+		d.dispatch(EventTaskSuccess, d.task.Name)
+	}
+	d.dispatch(EventBuildComplete, "")
+	if err != nil {
+		d.dispatch(EventBuildFail, "")
+	} else {
+		d.dispatch(EventBuildSuccess, "")
+	}
+
+	if len(d.pending) > 0 {
+		GetLogger().Warn("dropped plugin events without a workspace", zap.String("buildID", d.task.BuildID), zap.Int("events", len(d.pending)))
+		d.pending = []*EventPayload{}
+	}
+}
+
+func statusFromError(err error) string {
+	if err != nil {
+		return StateFailed
+	}
+	return StateSuccess
+}
+
+// matchesEvent reports whether a configured plugin event matches the fired event. Task and
+// step events may be narrowed with a modifier e.g. TASK_COMPLETE-<task_name>.
+func matchesEvent(configured string, event string, modifier string) bool {
+	if configured == event {
+		return true
+	}
+	return modifier != "" && configured == fmt.Sprintf("%s-%s", event, modifier)
+}
+
+func (d *EventDispatcher) dispatch(event string, modifier string) {
+	if d.task.Workspace != "" {
+		for _, pending := range d.pending {
+			d.send(pending, pending.modifier())
+		}
+		d.pending = []*EventPayload{}
+	}
+
+	if !d.hasListener(event, modifier) {
+		return
+	}
+
+	p := d.newPayload(event)
+	if d.task.Workspace == "" {
+		d.pending = append(d.pending, p)
+		return
+	}
+	d.send(p, modifier)
+}
+
+func (d *EventDispatcher) hasListener(event string, modifier string) bool {
+	for _, plugin := range d.plugins {
+		for _, e := range plugin.Events {
+			if matchesEvent(e, event, modifier) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *EventDispatcher) newPayload(event string) *EventPayload {
+	p := &EventPayload{
+		Event:      event,
+		BuildID:    d.task.BuildID,
+		Project:    d.task.Project,
+		Status:     d.status,
+		Timings:    d.timings,
+		Parameters: map[string]string{},
+		Task: EventTask{
+			Name:    d.task.Name,
+			Status:  d.status,
+			Timings: d.timings,
+		},
+	}
+	if d.step != nil {
+		s := *d.step
+		p.Step = &s
+	}
+	for _, param := range d.task.ResolvedParameters {
+		if !param.IsSecret {
+			p.Parameters[param.Name] = param.Value
+		}
+	}
+
+	return p
+}
+
+// modifier returns the task or step name that the event was fired for.
+func (p *EventPayload) modifier() string {
+	if strings.HasPrefix(p.Event, "STEP_") && p.Step != nil {
+		return p.Step.Description
+	}
+	if strings.HasPrefix(p.Event, "TASK_") {
+		return p.Task.Name
+	}
+	return ""
+}
+
+// send runs every plugin listening for the event. Plugin failures are logged and shown
+// as a warning in the step output, but never fail the build.
+func (d *EventDispatcher) send(p *EventPayload, modifier string) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		GetLogger().Error("could not marshal event payload", zap.String("event", p.Event), zap.Error(err))
+		return
+	}
+
+	for _, plugin := range d.plugins {
+		for _, e := range plugin.Events {
+			if !matchesEvent(e, p.Event, modifier) {
+				continue
+			}
+			if err := d.runPlugin(plugin, p.Event, payload); err != nil {
+				msg := maskSecrets(err.Error(), d.task.ResolvedParameters)
+				GetLogger().Error("plugin failed",
+					zap.String("plugin", plugin.Use),
+					zap.String("event", p.Event),
+					zap.String("buildID", p.BuildID),
+					zap.String("error", msg),
+				)
+				if d.writer != nil {
+					d.writer.Write([]byte(fmt.Sprintf("%sWARNING: plugin %s failed on %s: %s\x1b[0m", warnANSI, plugin.Use, p.Event, msg)))
+				}
+			}
+			break
+		}
+	}
+}
+
+func (d *EventDispatcher) runPlugin(plugin PluginConfig, event string, payload []byte) error {
+	type output struct {
+		State string `json:"state"`
+		Error string `json:"error"`
+	}
+
+	bin, err := getBinary(plugin.Use, d.task.Workspace)
+	if err != nil {
+		return err
+	}
+
+	env := append(getShellEnvironment(), fmt.Sprintf("VELOCITY_EVENT=%s", event))
+	for k, v := range plugin.Arguments {
+		for _, param := range d.task.ResolvedParameters {
+			v = strings.Replace(v, fmt.Sprintf("${%s}", param.Name), param.Value, -1)
+		}
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin)
+	cmd.Dir = d.task.Workspace
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(payload)
+
+	cmdOutBytes, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+	var dOutput output
+	json.Unmarshal(cmdOutBytes, &dOutput)
+	if dOutput.State != "success" {
+		return fmt.Errorf("%s: %s", dOutput.State, dOutput.Error)
+	}
+
+	GetLogger().Debug("dispatched event to plugin", zap.String("plugin", plugin.Use), zap.String("event", event))
+	return nil
+}
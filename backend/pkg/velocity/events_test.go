@@ -0,0 +1,127 @@
+package velocity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesEvent(t *testing.T) {
+	assert.True(t, matchesEvent("TASK_COMPLETE", EventTaskComplete, "build"))
+	assert.True(t, matchesEvent("TASK_COMPLETE-build", EventTaskComplete, "build"))
+	assert.False(t, matchesEvent("TASK_COMPLETE-deploy", EventTaskComplete, "build"))
+	assert.False(t, matchesEvent("TASK_COMPLETE", EventTaskFail, "build"))
+	assert.False(t, matchesEvent("BUILD_COMPLETE-", EventBuildComplete, ""))
+}
+
+func TestEventDispatcherRunsMatchingPlugins(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "velocity-events")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+	assert.Nil(t, makeVelocityDirs(workspace))
+
+	os.Setenv("BUILDER_SECRET", "builder-s3cret")
+	defer os.Unsetenv("BUILDER_SECRET")
+
+	eventsFile := filepath.Join(workspace, "events.jsonl")
+	envFile := filepath.Join(workspace, "env")
+	for name, script := range map[string]string{
+		"notify":      `cat >> "$EVENTS_FILE"; echo >> "$EVENTS_FILE"; echo '{"state":"success"}'`,
+		"broken":      `echo '{"state":"error","error":"webhook s3cret rejected"}'`,
+		"environment": `env > "$ENV_FILE"; echo '{"state":"success"}'`,
+	} {
+		err := ioutil.WriteFile(
+			filepath.Join(workspace, ".velocityci", "plugins", name),
+			[]byte(fmt.Sprintf("#!/bin/sh\n%s\n", script)),
+			0755,
+		)
+		assert.Nil(t, err)
+	}
+
+	writer := &bufferWriter{}
+	task := &Task{
+		Name:    "build",
+		BuildID: "abc",
+		Project: "velocity",
+		ResolvedParameters: map[string]Parameter{
+			"GIT_BRANCH": {Name: "GIT_BRANCH", Value: "master"},
+			"TOKEN":      {Name: "TOKEN", Value: "s3cret", IsSecret: true},
+		},
+	}
+	d := NewEventDispatcher([]PluginConfig{
+		{
+			Use:       "https://example.com/notify",
+			Arguments: map[string]string{"EVENTS_FILE": eventsFile},
+			Events:    []string{EventBuildStart, "STEP_FAIL-Run tests", "TASK_COMPLETE-build", "TASK_COMPLETE-deploy"},
+		},
+		{
+			Use:       "https://example.com/broken",
+			Arguments: map[string]string{"TOKEN": "${TOKEN}"},
+			Events:    []string{EventBuildComplete},
+		},
+		{
+			Use:       "https://example.com/environment",
+			Arguments: map[string]string{"ENV_FILE": envFile},
+			Events:    []string{EventBuildStart},
+		},
+	}, task, &bufferEmitter{writer: writer})
+
+	d.Start()
+	// events before the setup step has created the workspace are held back
+	_, err = os.Stat(eventsFile)
+	assert.True(t, os.IsNotExist(err))
+	task.Workspace = workspace
+
+	compile := NewShell()
+	compile.Description = "Compile"
+	d.StepStart(1, compile)
+	d.StepComplete(nil)
+	test := NewShell()
+	test.Description = "Run tests"
+	d.StepStart(2, test)
+	d.StepComplete(fmt.Errorf("Non-zero exit code: 1"))
+	d.Complete(fmt.Errorf("Non-zero exit code: 1"))
+
+	out, err := ioutil.ReadFile(eventsFile)
+	assert.Nil(t, err)
+	payloads := []EventPayload{}
+	for _, l := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		var p EventPayload
+		assert.Nil(t, json.Unmarshal([]byte(l), &p))
+		payloads = append(payloads, p)
+	}
+
+	assert.Len(t, payloads, 3)
+	assert.Equal(t, EventBuildStart, payloads[0].Event)
+
+	assert.Equal(t, EventStepFail, payloads[1].Event)
+	assert.Equal(t, "Run tests", payloads[1].Step.Description)
+	assert.Equal(t, StateFailed, payloads[1].Step.Status)
+	assert.NotNil(t, payloads[1].Step.Timings.CompletedAt)
+
+	assert.Equal(t, EventTaskComplete, payloads[2].Event)
+	assert.Equal(t, "abc", payloads[2].BuildID)
+	assert.Equal(t, StateFailed, payloads[2].Status)
+	assert.Nil(t, payloads[2].Step)
+	assert.Equal(t, map[string]string{"GIT_BRANCH": "master"}, payloads[2].Parameters)
+
+	// the failure of the broken plugin is shown on the output of the last step, masked
+	assert.Len(t, writer.lines, 1)
+	assert.Contains(t, writer.lines[0], "WARNING: plugin https://example.com/broken failed on BUILD_COMPLETE")
+	assert.Contains(t, writer.lines[0], "webhook *** rejected")
+	assert.NotContains(t, writer.lines[0], "s3cret")
+
+	// plugins only get the builder's environment that shell steps get
+	env, err := ioutil.ReadFile(envFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(env), "VELOCITY_EVENT=BUILD_START\n")
+	assert.Contains(t, string(env), fmt.Sprintf("ENV_FILE=%s\n", envFile))
+	assert.Contains(t, string(env), fmt.Sprintf("PATH=%s\n", os.Getenv("PATH")))
+	assert.NotContains(t, string(env), "BUILDER_SECRET")
+}
@@ -1,13 +1,13 @@
 package velocity
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
-	"net"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -32,20 +32,26 @@ func (s HostKeyError) Error() string {
 }
 
 type GitRepository struct {
-	Address    string        `json:"address"`
-	PrivateKey string        `json:"privateKey"`
-	PublicKey  ssh.PublicKey `json:"-"`
-	Agent      agent.Agent   `json:"-"`
+	Address    string `json:"address"`
+	PrivateKey string `json:"privateKey"`
+	// Username and Password authenticate https:// remotes. Password may also be an access token.
+	Username  string        `json:"username"`
+	Password  string        `json:"password"`
+	PublicKey ssh.PublicKey `json:"-"`
+	Agent     agent.Agent   `json:"-"`
 }
 
 const WorkspaceDir = "/opt/velocityci/workspaces"
 
+// getUniqueWorkspace creates a new directory for a clone, which builders running at the same
+// time never share.
 func getUniqueWorkspace(r *GitRepository) (string, error) {
-	psuedoRandom := rand.NewSource(time.Now().UnixNano())
-	randNumber := rand.New(psuedoRandom)
-	dir := fmt.Sprintf("%s/_%s-%d", WorkspaceDir, slug.Make(r.Address), randNumber.Int63())
-	os.RemoveAll(dir)
-	err := os.MkdirAll(dir, os.ModePerm)
+	err := os.MkdirAll(WorkspaceDir, os.ModePerm)
+	if err != nil {
+		GetLogger().Fatal("could not create unique workspace", zap.Error(err))
+		return "", err
+	}
+	dir, err := ioutil.TempDir(WorkspaceDir, fmt.Sprintf("_%s-", slug.Make(r.Address)))
 	if err != nil {
 		GetLogger().Fatal("could not create unique workspace", zap.Error(err))
 		return "", err
@@ -54,71 +60,49 @@ func getUniqueWorkspace(r *GitRepository) (string, error) {
 	return dir, nil
 }
 
-func handleGitSSH(r *GitRepository) (ssh.Signer, agent.Agent, error) {
-	key, err := ssh.ParseRawPrivateKey([]byte(r.PrivateKey))
-	if err != nil {
-		return nil, nil, err.(SSHKeyError)
-	}
-	if sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
-		a := agent.NewClient(sshAgent)
-		a.Add(agent.AddedKey{PrivateKey: key})
-		signer, _ := ssh.NewSignerFromKey(key)
-		GetLogger().Debug("added ssh key to ssh-agent", zap.String("address", r.Address))
-		return signer, a, nil
-	}
-
-	return nil, nil, fmt.Errorf("ssh-agent not found")
+// gitCommand returns a git command line that runs in the given directory.
+func gitCommand(dir string, args ...string) []string {
+	return append([]string{"git", "-C", dir}, args...)
 }
 
-func initWorkspace(r *GitRepository) (string, error) {
+func initWorkspace(r *GitRepository) (string, *gitAuth, error) {
 	dir, _ := getUniqueWorkspace(r)
-	os.Chdir(dir)
-	shCmd := []string{"git", "init"}
+	shCmd := gitCommand(dir, "init")
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	<-c.Start()
 
-	shCmd = []string{"git", "remote", "add", "origin", r.Address}
+	shCmd = gitCommand(dir, "remote", "add", "origin", r.Address)
 	c = cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	<-c.Start()
 
-	if r.Address[:3] == "git" {
-		signer, agent, err := handleGitSSH(r)
-		if err != nil {
-			return "", err
-		}
-		r.Agent = agent
-		r.PublicKey = signer.PublicKey()
+	auth, err := newGitAuth(r, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
 	}
 
-	return dir, nil
-}
-
-func cleanSSHAgent(r *GitRepository) {
-	if r.Agent != nil {
-		r.Agent.Remove(r.PublicKey)
-		GetLogger().Debug("removed ssh key from ssh-agent", zap.String("address", r.Address))
-	}
+	return dir, auth, nil
 }
 
 func Validate(r *GitRepository) (bool, error) {
-	wd, _ := os.Getwd()
-	defer os.Chdir(wd)
-
-	dir, err := initWorkspace(r)
+	dir, auth, err := initWorkspace(r)
 	if err != nil {
 		return false, err
 	}
-	defer cleanSSHAgent(r)
-	os.Chdir(dir)
+	defer os.RemoveAll(dir)
+	defer auth.clean(r)
 
-	shCmd := []string{"git", "ls-remote"}
+	shCmd := auth.command("ls-remote")
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
 	if s.Exit != 0 {
-		err := fmt.Errorf(strings.Join(s.Stderr, " "))
-		if strings.Contains(err.Error(), "Host key verification failed") {
-			err = HostKeyError(err.Error())
+		o := auth.mask(strings.Join(s.Stderr, " "))
+		var err error = errors.New(o)
+		if strings.Contains(o, "Host key verification failed") {
+			err = HostKeyError(o)
+		} else if isAuthFailure(o) {
+			err = GitAuthError(o)
 		}
 		return false, err
 	}
@@ -127,60 +111,180 @@ func Validate(r *GitRepository) (bool, error) {
 }
 
 type CloneOptions struct {
-	Bare      bool
-	Full      bool
+	Bare bool
+	Full bool
+	// Depth is how much history to fetch when not Full, defaults to 1.
+	Depth     int
 	Submodule bool
-	Commit    string
+	// Tags fetches all tags along with the branches.
+	Tags bool
+	// Commit is fetched and checked out if given.
+	Commit string
 }
 
+// maxFetchDepth is the deepest shallow fetch tried before fetching the full history.
+const maxFetchDepth = 1000
+
 func Clone(
 	r *GitRepository,
 	writer io.Writer,
 	cloneOpts *CloneOptions,
 ) (*RawRepository, error) {
-	wd, _ := os.Getwd()
-	defer os.Chdir(wd)
-
-	dir, err := initWorkspace(r)
+	dir, auth, err := initWorkspace(r)
 	if err != nil {
 		return nil, err
 	}
-	defer cleanSSHAgent(r)
-	os.Chdir(dir)
+	defer auth.clean(r)
+
+	GetLogger().Info("cloning repository", zap.String("address", r.Address))
+
+	repo := &RawRepository{Directory: dir}
+	if err := repo.fetch(auth, writer, cloneOpts); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	GetLogger().Info("cloned repository", zap.String("address", r.Address))
+
+	return repo, nil
+}
+
+// Fetch fetches into an existing clone of the given repository e.g. to deepen its history.
+func (r *RawRepository) Fetch(
+	repository *GitRepository,
+	writer io.Writer,
+	fetchOpts *CloneOptions,
+) error {
+	auth, err := newGitAuth(repository, r.Directory)
+	if err != nil {
+		return err
+	}
+	defer auth.clean(repository)
+
+	return r.fetch(auth, writer, fetchOpts)
+}
+
+func (r *RawRepository) fetch(auth *gitAuth, writer io.Writer, opts *CloneOptions) error {
+	depth := 0
+	if !opts.Full {
+		depth = opts.Depth
+		if depth < 1 {
+			depth = 1
+		}
+	}
 
-	shCmd := []string{"git", "fetch", "--progress"}
+	if len(opts.Commit) < 1 {
+		s := runGitStreaming(auth, writer, fetchCommand(auth, opts, depth, "origin"))
+		if err := handleStatusError(s); err != nil {
+			return err
+		}
+		if s.Exit != 0 {
+			return fetchError(s)
+		}
+		return nil
+	}
 
-	if cloneOpts.Bare {
+	// Most servers allow fetching a commit directly, otherwise fetch the branches
+	// progressively deeper until the commit is found.
+	s := runGitStreaming(auth, writer, fetchCommand(auth, opts, depth, "origin", opts.Commit))
+	if err := handleStatusError(s); err != nil {
+		return err
+	}
+	for d := depth; !r.hasCommit(opts.Commit); d *= 4 {
+		if d > 0 && d <= maxFetchDepth {
+			writer.Write([]byte(fmt.Sprintf("Fetching branches with depth %d", d)))
+			runGitStreaming(auth, writer, fetchCommand(auth, opts, d, "origin"))
+			continue
+		}
+		// fetch the full history as a last resort
+		s = runGitStreaming(auth, writer, r.unshallowCommand(auth, opts))
+		if !r.hasCommit(opts.Commit) {
+			if s.Exit != 0 {
+				return fetchError(s)
+			}
+			return fmt.Errorf("could not find commit %s", opts.Commit)
+		}
+	}
+
+	s = runGitStreaming(auth, writer, auth.command("checkout", "--force", opts.Commit))
+	if s.Exit != 0 {
+		return fetchError(s)
+	}
+
+	if opts.Submodule {
+		shCmd := auth.command(append(auth.configArgs(), "submodule", "update", "--init", "--recursive", "--progress")...)
+		s = runGitStreaming(auth, writer, shCmd)
+		if s.Exit != 0 {
+			return fetchError(s)
+		}
+	}
+
+	return nil
+}
+
+func fetchCommand(auth *gitAuth, opts *CloneOptions, depth int, refs ...string) []string {
+	shCmd := auth.command("fetch", "--progress")
+
+	if opts.Bare {
 		shCmd = append(shCmd, "--bare")
 	}
 
-	if !cloneOpts.Full {
-		shCmd = append(shCmd, "--depth=1")
+	if depth > 0 {
+		shCmd = append(shCmd, fmt.Sprintf("--depth=%d", depth))
 	}
 
-	if cloneOpts.Submodule {
-		shCmd = append(shCmd, "--recurse-submodules")
+	if opts.Tags {
+		shCmd = append(shCmd, "--tags")
 	}
 
-	if len(cloneOpts.Commit) > 0 {
-		// shCmd = append(shCmd, "origin", cloneOpts.Commit)
+	return append(shCmd, refs...)
+}
+
+func (r *RawRepository) unshallowCommand(auth *gitAuth, opts *CloneOptions) []string {
+	if _, err := os.Stat(filepath.Join(r.Directory, ".git", "shallow")); err == nil {
+		return append(fetchCommand(auth, opts, 0), "--unshallow", "origin")
 	}
+	return fetchCommand(auth, opts, 0, "origin")
+}
+
+func (r *RawRepository) hasCommit(sha string) bool {
+	shCmd := gitCommand(r.Directory, "cat-file", "-e", fmt.Sprintf("%s^{commit}", sha))
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
 
-	GetLogger().Info("cloning repository", zap.String("cmd", strings.Join(shCmd, " ")))
+	return s.Error == nil && s.Exit == 0
+}
 
+func fetchError(s cmd.Status) error {
+	err := errors.New(strings.Join(s.Stderr, " "))
+	if isAuthFailure(err.Error()) {
+		return GitAuthError(err.Error())
+	}
+	return err
+}
+
+// runGitStreaming runs a git command, writing its output to the writer as it happens.
+func runGitStreaming(auth *gitAuth, writer io.Writer, shCmd []string) cmd.Status {
 	opts := cmd.Options{Buffered: false, Streaming: true}
 	c := cmd.NewCmdOptions(opts, shCmd[0], shCmd[1:len(shCmd)]...)
 	stdOut := []string{}
 	stdErr := []string{}
+	done := make(chan struct{})
+	finished := make(chan struct{})
 	go func() {
+		defer close(finished)
 		for {
 			select {
 			case line := <-c.Stdout:
+				line = auth.mask(line)
 				writer.Write([]byte(line))
 				stdOut = append(stdOut, line)
 			case line := <-c.Stderr:
+				line = auth.mask(line)
 				writer.Write([]byte(line))
 				stdErr = append(stdErr, line)
+			case <-done:
+				return
 			}
 		}
 	}()
@@ -188,24 +292,17 @@ func Clone(
 	for len(c.Stdout) > 0 || len(c.Stderr) > 0 {
 		time.Sleep(10 * time.Millisecond)
 	}
+	close(done)
+	<-finished
 	s.Stdout = stdOut
 	s.Stderr = stdErr
 
-	if err := handleStatusError(s); err != nil {
-		os.RemoveAll(dir)
-		return nil, err
-	}
-
-	GetLogger().Info("cloned repository", zap.String("address", r.Address))
-
-	return &RawRepository{Directory: dir}, nil
+	return s
 }
 
 func (r *RawRepository) GetBranches() (b []string) {
-	r.init()
-	defer r.done()
 
-	shCmd := []string{"git", "branch", "--remote"}
+	shCmd := gitCommand(r.Directory, "branch", "--remote")
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 	for _, line := range s.Stdout {
@@ -220,18 +317,40 @@ func (r *RawRepository) GetBranches() (b []string) {
 	return b
 }
 
+func (r *RawRepository) GetTags() (t []string, err error) {
+	shCmd := gitCommand(r.Directory, "tag", "--list")
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
+	if s.Error != nil {
+		return nil, s.Error
+	}
+	if s.Exit != 0 {
+		return nil, fmt.Errorf("could not list tags: %s", strings.Join(s.Stderr, " "))
+	}
+	for _, line := range s.Stdout {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 {
+			t = append(t, line)
+		}
+	}
+
+	return t, nil
+}
+
+func (r *RawRepository) GetCommitAtTag(tag string) *RawCommit {
+	commitSha := r.RevParse(fmt.Sprintf("%s^{commit}", tag))
+
+	return r.GetCommitInfo(commitSha)
+}
+
 func (r *RawRepository) GetCommitAtHeadOfBranch(branch string) *RawCommit {
-	r.init()
-	defer r.done()
 	commitSha := r.RevParse(fmt.Sprintf("origin/%s", branch))
 
 	return r.GetCommitInfo(commitSha)
 }
 
 func (r *RawRepository) RevParse(obj string) string {
-	r.init()
-	defer r.done()
-	shCmd := []string{"git", "rev-parse", obj}
+	shCmd := gitCommand(r.Directory, "rev-parse", obj)
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
@@ -249,30 +368,10 @@ type RawCommit struct {
 
 type RawRepository struct {
 	Directory string
-	pwd       string
-	sync.RWMutex
-}
-
-func (r *RawRepository) init() {
-	r.RLock()
-	cwd, err := os.Getwd()
-	if err != nil {
-		GetLogger().Fatal("could not get working directory", zap.Error(err))
-	}
-	r.pwd = cwd
-	os.Chdir(r.Directory)
-}
-
-func (r *RawRepository) done() {
-	os.Chdir(r.pwd)
-	r.pwd = ""
-	r.RUnlock()
 }
 
 func (r *RawRepository) GetCommitInfo(sha string) *RawCommit {
-	r.init()
-	defer r.done()
-	shCmd := []string{"git", "show", "-s", `--format=%H%n%aI%n%aE%n%aN%n%GK%n%s`, sha}
+	shCmd := gitCommand(r.Directory, "show", "-s", `--format=%H%n%aI%n%aE%n%aN%n%GK%n%s`, sha)
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
@@ -289,19 +388,39 @@ func (r *RawRepository) GetCommitInfo(sha string) *RawCommit {
 }
 
 func (r *RawRepository) GetCurrentCommitInfo() *RawCommit {
-	r.init()
-	defer r.done()
-	shCmd := []string{"git", "rev-parse", "HEAD"}
+	shCmd := gitCommand(r.Directory, "rev-parse", "HEAD")
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
 	return r.GetCommitInfo(strings.TrimSpace(s.Stdout[0]))
 }
 
+// GetCurrentBranch returns the checked out branch or an empty string if HEAD is detached.
+func (r *RawRepository) GetCurrentBranch() string {
+	shCmd := gitCommand(r.Directory, "rev-parse", "--abbrev-ref", "HEAD")
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
+	if s.Exit != 0 || len(s.Stdout) < 1 || strings.TrimSpace(s.Stdout[0]) == "HEAD" {
+		return ""
+	}
+
+	return strings.TrimSpace(s.Stdout[0])
+}
+
+// GetCurrentTag returns a tag pointing at HEAD or an empty string if there isn't one.
+func (r *RawRepository) GetCurrentTag() string {
+	shCmd := gitCommand(r.Directory, "tag", "--points-at", "HEAD")
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
+	if s.Exit != 0 || len(s.Stdout) < 1 {
+		return ""
+	}
+
+	return strings.TrimSpace(s.Stdout[0])
+}
+
 func (r *RawRepository) GetDescribe() string {
-	r.init()
-	defer r.done()
-	shCmd := []string{"git", "describe", "--always"}
+	shCmd := gitCommand(r.Directory, "describe", "--always")
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
@@ -309,10 +428,8 @@ func (r *RawRepository) GetDescribe() string {
 }
 
 func (r *RawRepository) Clean() error {
-	r.init()
-	defer r.done()
 
-	shCmd := []string{"git", "clean", "-fd"}
+	shCmd := gitCommand(r.Directory, "clean", "-fd")
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
@@ -333,14 +450,12 @@ func handleStatusError(s cmd.Status) error {
 }
 
 func (r *RawRepository) Checkout(ref string) error {
-	r.init()
-	defer r.done()
 
 	if err := r.Clean(); err != nil {
 		return err
 	}
 
-	shCmd := []string{"git", "checkout", "--force", ref}
+	shCmd := gitCommand(r.Directory, "checkout", "--force", ref)
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
@@ -354,9 +469,7 @@ func (r *RawRepository) Checkout(ref string) error {
 }
 
 func (r *RawRepository) GetDefaultBranch() string {
-	r.init()
-	defer r.done()
-	shCmd := []string{"git", "remote", "show", "origin"}
+	shCmd := gitCommand(r.Directory, "remote", "show", "origin")
 	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
 	s := <-c.Start()
 
@@ -364,3 +477,82 @@ func (r *RawRepository) GetDefaultBranch() string {
 
 	return defaultBranch
 }
+
+// ResolveCommit returns the hash of the commit that a revision e.g. a branch or tag refers to.
+func (r *RawRepository) ResolveCommit(rev string) (string, error) {
+	shCmd := gitCommand(r.Directory, "rev-parse", "--verify", "--quiet", fmt.Sprintf("%s^{commit}", rev))
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
+	if err := handleStatusError(s); err != nil {
+		return "", err
+	}
+	if s.Exit != 0 || len(s.Stdout) < 1 {
+		return "", fmt.Errorf("could not find commit %s", rev)
+	}
+
+	return strings.TrimSpace(s.Stdout[0]), nil
+}
+
+// HasRef returns whether the repository has the given reference e.g. refs/heads/master.
+func (r *RawRepository) HasRef(ref string) bool {
+	shCmd := gitCommand(r.Directory, "show-ref", "--verify", "--quiet", ref)
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
+
+	return s.Error == nil && s.Exit == 0
+}
+
+// GetTopLevel returns the root directory of the repository's working tree.
+func (r *RawRepository) GetTopLevel() (string, error) {
+	shCmd := gitCommand(r.Directory, "rev-parse", "--show-toplevel")
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
+	if err := handleStatusError(s); err != nil {
+		return "", err
+	}
+	if s.Exit != 0 || len(s.Stdout) < 1 {
+		return "", fmt.Errorf("%s is not in a git repository", r.Directory)
+	}
+
+	return strings.TrimSpace(s.Stdout[0]), nil
+}
+
+// AddWorktree checks out a commit into a new worktree of the repository in dir, along with
+// its submodules if submodule is set. Unlike a clone the objects are shared with the
+// repository, but none of the uncommitted changes in its working tree are.
+func (r *RawRepository) AddWorktree(dir string, commit string, submodule bool, writer io.Writer) (*RawRepository, error) {
+	auth := &gitAuth{dir: dir, secrets: map[string]Parameter{}}
+	s := runGitStreaming(auth, writer, gitCommand(r.Directory, "worktree", "add", "--detach", dir, commit))
+	if err := handleStatusError(s); err != nil {
+		return nil, err
+	}
+	if s.Exit != 0 {
+		return nil, fetchError(s)
+	}
+
+	worktree := &RawRepository{Directory: dir}
+	if submodule {
+		s = runGitStreaming(auth, writer, auth.command("submodule", "update", "--init", "--recursive"))
+		if s.Exit != 0 {
+			r.RemoveWorktree(dir)
+			return nil, fetchError(s)
+		}
+	}
+
+	GetLogger().Debug("added worktree", zap.String("commit", commit), zap.String("directory", dir))
+
+	return worktree, nil
+}
+
+// RemoveWorktree removes a worktree added by AddWorktree.
+func (r *RawRepository) RemoveWorktree(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	shCmd := gitCommand(r.Directory, "worktree", "prune")
+	c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+	s := <-c.Start()
+
+	return handleStatusError(s)
+}
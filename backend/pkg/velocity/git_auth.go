@@ -0,0 +1,218 @@
+package velocity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-cmd/cmd"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+type GitAuthError string
+
+func (s GitAuthError) Error() string {
+	return string(s)
+}
+
+// gitAuth holds the credentials made available to git for a single workspace.
+// Secrets are written to temporary files outside of the workspace so that they
+// are not visible to task steps and are removed once git has finished with them.
+type gitAuth struct {
+	dir     string
+	env     []string
+	files   []string
+	secrets map[string]Parameter
+	helpers []string
+}
+
+func isHTTPSAddress(address string) bool {
+	return strings.HasPrefix(address, "https://")
+}
+
+func isSSHAddress(address string) bool {
+	if strings.HasPrefix(address, "ssh://") || strings.HasPrefix(address, "git+ssh://") {
+		return true
+	}
+	// scp-like syntax e.g. git@github.com:velocity-ci/velocity.git
+	return !strings.Contains(address, "://") && strings.Contains(address, "@")
+}
+
+func newGitAuth(r *GitRepository, dir string) (*gitAuth, error) {
+	a := &gitAuth{
+		dir:     dir,
+		env:     []string{},
+		files:   []string{},
+		secrets: map[string]Parameter{},
+		helpers: []string{},
+	}
+
+	if isHTTPSAddress(r.Address) {
+		// never fall back to prompting for credentials
+		a.env = append(a.env, "GIT_TERMINAL_PROMPT=0")
+		if len(r.Password) > 0 {
+			if err := a.useCredentialHelper(r); err != nil {
+				a.clean(r)
+				return nil, err
+			}
+		}
+	} else if isSSHAddress(r.Address) {
+		if err := a.useSSHKey(r); err != nil {
+			a.clean(r)
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// command returns a git command line that runs in the workspace with the credentials available.
+func (a *gitAuth) command(args ...string) []string {
+	shCmd := gitCommand(a.dir, args...)
+	if len(a.env) > 0 {
+		return append(append([]string{"env"}, a.env...), shCmd...)
+	}
+	return shCmd
+}
+
+// configArgs returns the credential configuration as command line options, for git
+// commands such as submodule updates that don't read the workspace configuration.
+func (a *gitAuth) configArgs() []string {
+	args := []string{}
+	for _, h := range a.helpers {
+		args = append(args, "-c", fmt.Sprintf("credential.helper=%s", h))
+	}
+	return args
+}
+
+// mask removes any credentials from the given git output.
+func (a *gitAuth) mask(o string) string {
+	return maskSecrets(o, a.secrets)
+}
+
+func (a *gitAuth) clean(r *GitRepository) {
+	if len(a.helpers) > 0 {
+		shCmd := gitCommand(a.dir, "config", "--unset-all", "credential.helper")
+		c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+		<-c.Start()
+	}
+	for _, f := range a.files {
+		os.Remove(f)
+	}
+	if r.Agent != nil {
+		r.Agent.Remove(r.PublicKey)
+		r.Agent = nil
+		GetLogger().Debug("removed ssh key from ssh-agent", zap.String("address", r.Address))
+	}
+}
+
+func (a *gitAuth) addSecret(name string, value string) {
+	a.secrets[name] = Parameter{Name: name, Value: value, IsSecret: true}
+}
+
+func (a *gitAuth) writeTempFile(prefix string, contents string) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	a.files = append(a.files, f.Name())
+	if _, err := f.WriteString(contents); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// useCredentialHelper configures the workspace to read the repository username and
+// password (or token) from a git credential store file.
+func (a *gitAuth) useCredentialHelper(r *GitRepository) error {
+	u, err := url.Parse(r.Address)
+	if err != nil {
+		return err
+	}
+	username := r.Username
+	if username == "" {
+		// most providers accept any username alongside an access token
+		username = "velocity"
+	}
+	a.addSecret("password", r.Password)
+
+	credentials := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		User:   url.UserPassword(username, r.Password),
+	}
+	f, err := a.writeTempFile("velocity-git-credentials", fmt.Sprintf("%s\n", credentials.String()))
+	if err != nil {
+		return err
+	}
+
+	// clear any helpers inherited from the global configuration
+	a.helpers = []string{"", fmt.Sprintf("store --file='%s'", f)}
+	for _, args := range [][]string{
+		{"config", "credential.helper", a.helpers[0]},
+		{"config", "--add", "credential.helper", a.helpers[1]},
+	} {
+		shCmd := gitCommand(a.dir, args...)
+		c := cmd.NewCmd(shCmd[0], shCmd[1:len(shCmd)]...)
+		s := <-c.Start()
+		if s.Error != nil {
+			return s.Error
+		}
+	}
+
+	GetLogger().Debug("configured git credential helper", zap.String("address", r.Address))
+	return nil
+}
+
+// useSSHKey adds the repository private key to the running ssh-agent, or if there
+// isn't one, writes it to a temporary file for GIT_SSH_COMMAND to use.
+func (a *gitAuth) useSSHKey(r *GitRepository) error {
+	key, err := ssh.ParseRawPrivateKey([]byte(r.PrivateKey))
+	if err != nil {
+		return SSHKeyError(err.Error())
+	}
+
+	if sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
+		ag := agent.NewClient(sshAgent)
+		if err := ag.Add(agent.AddedKey{PrivateKey: key}); err == nil {
+			signer, _ := ssh.NewSignerFromKey(key)
+			r.Agent = ag
+			r.PublicKey = signer.PublicKey()
+			GetLogger().Debug("added ssh key to ssh-agent", zap.String("address", r.Address))
+			return nil
+		}
+	}
+
+	privateKey := strings.TrimSpace(r.PrivateKey) + "\n"
+	f, err := a.writeTempFile("velocity-ssh-key", privateKey)
+	if err != nil {
+		return err
+	}
+	a.env = append(a.env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i '%s' -o IdentitiesOnly=yes", f))
+	GetLogger().Debug("using temporary ssh key file", zap.String("address", r.Address))
+
+	return nil
+}
+
+// isAuthFailure reports whether git output indicates that the credentials were rejected.
+func isAuthFailure(o string) bool {
+	for _, m := range []string{
+		"Authentication failed",
+		"could not read Username",
+		"could not read Password",
+		"Permission denied (publickey",
+		"terminal prompts disabled",
+	} {
+		if strings.Contains(o, m) {
+			return true
+		}
+	}
+	return false
+}
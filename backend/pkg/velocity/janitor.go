@@ -0,0 +1,203 @@
+package velocity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+)
+
+// Janitor removes Docker containers, networks and images left behind by velocity,
+// e.g. after a builder crashes mid-build.
+type Janitor struct {
+	// MaxAge is how old a resource has to be before it is considered orphaned.
+	MaxAge time.Duration
+	// ImageThreshold is the Docker disk usage (in bytes) above which images built
+	// by velocity are pruned, oldest first. 0 disables pruning.
+	ImageThreshold int64
+	// IsActive reports whether resources for the given build ID are still in use.
+	IsActive func(buildID string) bool
+}
+
+// janitorClient is the part of the Docker API the janitor uses.
+type janitorClient interface {
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+	NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error)
+	NetworkRemove(ctx context.Context, networkID string) error
+	ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error)
+	ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDelete, error)
+	DiskUsage(ctx context.Context) (types.DiskUsage, error)
+}
+
+type JanitorReport struct {
+	Containers     []string
+	Networks       []string
+	Images         []string
+	ReclaimedBytes int64
+}
+
+func NewJanitor(maxAge time.Duration, imageThreshold int64) *Janitor {
+	return &Janitor{
+		MaxAge:         maxAge,
+		ImageThreshold: imageThreshold,
+		IsActive:       func(string) bool { return false },
+	}
+}
+
+func velocityFilter() filters.Args {
+	f := filters.NewArgs()
+	f.Add("label", fmt.Sprintf("%s=%s", LabelOwner, ownerVelocity))
+	return f
+}
+
+func (j *Janitor) isOrphaned(created time.Time, labels map[string]string) bool {
+	if j.IsActive(labels[LabelBuild]) {
+		return false
+	}
+	return time.Since(created) > j.MaxAge
+}
+
+// Run removes orphaned resources and prunes images if the disk usage threshold is exceeded.
+func (j *Janitor) Run() (*JanitorReport, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, err
+	}
+	return j.run(context.Background(), cli)
+}
+
+func (j *Janitor) run(ctx context.Context, cli janitorClient) (*JanitorReport, error) {
+	r := &JanitorReport{
+		Containers: []string{},
+		Networks:   []string{},
+		Images:     []string{},
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: velocityFilter()})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		if !j.isOrphaned(time.Unix(c.Created, 0), c.Labels) {
+			continue
+		}
+		err := cli.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+		if err != nil {
+			GetLogger().Error("could not remove container", zap.String("containerID", c.ID), zap.Error(err))
+			continue
+		}
+		r.Containers = append(r.Containers, strings.Join(c.Names, ","))
+	}
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: velocityFilter()})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		if !j.isOrphaned(n.Created, n.Labels) {
+			continue
+		}
+		err := cli.NetworkRemove(ctx, n.ID)
+		if err != nil {
+			GetLogger().Error("could not remove docker network", zap.String("networkID", n.ID), zap.Error(err))
+			continue
+		}
+		r.Networks = append(r.Networks, n.Name)
+	}
+
+	images, err := cli.ImageList(ctx, types.ImageListOptions{Filters: velocityFilter()})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(images, func(a, b int) bool { return images[a].Created < images[b].Created })
+
+	// Images from getImageName are only used by the run that built them.
+	remaining := []types.ImageSummary{}
+	for _, i := range images {
+		if isRunImage(i) && j.isOrphaned(time.Unix(i.Created, 0), i.Labels) {
+			if j.removeImage(cli, ctx, i, r) {
+				continue
+			}
+		}
+		remaining = append(remaining, i)
+	}
+
+	if j.ImageThreshold > 0 {
+		usage, err := cli.DiskUsage(ctx)
+		if err != nil {
+			return r, err
+		}
+		used := usage.LayersSize
+		for _, i := range remaining {
+			if used <= j.ImageThreshold {
+				break
+			}
+			if j.IsActive(i.Labels[LabelBuild]) {
+				continue
+			}
+			if j.removeImage(cli, ctx, i, r) {
+				used -= i.Size
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (j *Janitor) removeImage(cli janitorClient, ctx context.Context, i types.ImageSummary, r *JanitorReport) bool {
+	_, err := cli.ImageRemove(ctx, i.ID, types.ImageRemoveOptions{Force: true, PruneChildren: true})
+	if err != nil {
+		GetLogger().Error("could not remove image", zap.String("imageID", i.ID), zap.Error(err))
+		return false
+	}
+	name := i.ID
+	if len(i.RepoTags) > 0 {
+		name = strings.Join(i.RepoTags, ",")
+	}
+	r.Images = append(r.Images, name)
+	r.ReclaimedBytes += i.Size
+	return true
+}
+
+func isRunImage(i types.ImageSummary) bool {
+	if len(i.RepoTags) < 1 {
+		return false
+	}
+	for _, t := range i.RepoTags {
+		if !strings.HasPrefix(t, "vci-") {
+			return false
+		}
+	}
+	return true
+}
+
+// StopRun removes the containers of a run of a task, which fails the step that is running
+// them. Builds of images aren't interrupted.
+func StopRun(runID string) error {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	f := velocityFilter()
+	f.Add("label", fmt.Sprintf("%s=%s", LabelBuild, runID))
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: f})
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		err := cli.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+		if err != nil {
+			GetLogger().Error("could not remove container", zap.String("containerID", c.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
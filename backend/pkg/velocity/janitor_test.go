@@ -0,0 +1,143 @@
+package velocity
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDocker lists the given resources and records which of them are removed.
+type fakeDocker struct {
+	containers []types.Container
+	networks   []types.NetworkResource
+	images     []types.ImageSummary
+	layersSize int64
+	// failing are IDs that can't be removed
+	failing map[string]bool
+
+	removed []string
+}
+
+func (d *fakeDocker) remove(id string) error {
+	if d.failing[id] {
+		return fmt.Errorf("%s is in use", id)
+	}
+	d.removed = append(d.removed, id)
+	return nil
+}
+
+func (d *fakeDocker) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	return d.containers, nil
+}
+
+func (d *fakeDocker) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	return d.remove(containerID)
+}
+
+func (d *fakeDocker) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	return d.networks, nil
+}
+
+func (d *fakeDocker) NetworkRemove(ctx context.Context, networkID string) error {
+	return d.remove(networkID)
+}
+
+func (d *fakeDocker) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	return d.images, nil
+}
+
+func (d *fakeDocker) ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDelete, error) {
+	return nil, d.remove(imageID)
+}
+
+func (d *fakeDocker) DiskUsage(ctx context.Context) (types.DiskUsage, error) {
+	return types.DiskUsage{LayersSize: d.layersSize}, nil
+}
+
+func buildLabels(buildID string) map[string]string {
+	return map[string]string{LabelOwner: ownerVelocity, LabelBuild: buildID}
+}
+
+func TestJanitorIsOrphaned(t *testing.T) {
+	j := NewJanitor(time.Hour, 0)
+	j.IsActive = func(buildID string) bool { return buildID == "active" }
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		buildID  string
+		orphaned bool
+	}{
+		{name: "old", age: 2 * time.Hour, buildID: "finished", orphaned: true},
+		{name: "recent", age: time.Minute, buildID: "finished", orphaned: false},
+		{name: "old but still in use", age: 2 * time.Hour, buildID: "active", orphaned: false},
+		{name: "old without a build", age: 2 * time.Hour, orphaned: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.orphaned, j.isOrphaned(time.Now().Add(-test.age), buildLabels(test.buildID)))
+		})
+	}
+}
+
+func TestIsRunImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		runImage bool
+	}{
+		{name: "run image", tags: []string{"vci-abc:latest"}, runImage: true},
+		{name: "several run tags", tags: []string{"vci-abc:latest", "vci-abc:1"}, runImage: true},
+		{name: "also tagged for a registry", tags: []string{"vci-abc:latest", "registry.example.com/app:1"}, runImage: false},
+		{name: "registry image", tags: []string{"registry.example.com/app:1"}, runImage: false},
+		{name: "untagged", tags: []string{}, runImage: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.runImage, isRunImage(types.ImageSummary{RepoTags: test.tags}))
+		})
+	}
+}
+
+func TestJanitorRun(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	docker := &fakeDocker{
+		containers: []types.Container{
+			{ID: "orphaned-container", Names: []string{"/vci-finished-app"}, Created: old.Unix(), Labels: buildLabels("finished")},
+			{ID: "recent-container", Names: []string{"/vci-new-app"}, Created: recent.Unix(), Labels: buildLabels("new")},
+			{ID: "active-container", Names: []string{"/vci-active-app"}, Created: old.Unix(), Labels: buildLabels("active")},
+			{ID: "stuck-container", Names: []string{"/vci-stuck-app"}, Created: old.Unix(), Labels: buildLabels("finished")},
+		},
+		networks: []types.NetworkResource{
+			{ID: "orphaned-network", Name: "vci-finished", Created: old, Labels: buildLabels("finished")},
+			{ID: "active-network", Name: "vci-active", Created: old, Labels: buildLabels("active")},
+		},
+		images: []types.ImageSummary{
+			// listed newest first, as Docker does
+			{ID: "newer-image", RepoTags: []string{"app:2"}, Created: recent.Unix(), Size: 100, Labels: buildLabels("finished")},
+			{ID: "active-run-image", RepoTags: []string{"vci-active:latest"}, Created: old.Unix(), Size: 100, Labels: buildLabels("active")},
+			{ID: "older-image", RepoTags: []string{"app:1"}, Created: old.Unix() - 2, Size: 100, Labels: buildLabels("finished")},
+			{ID: "active-image", RepoTags: []string{"app:0"}, Created: old.Unix() - 3, Size: 100, Labels: buildLabels("active")},
+			{ID: "orphaned-run-image", RepoTags: []string{"vci-finished:latest"}, Created: old.Unix() - 1, Size: 50, Labels: buildLabels("finished")},
+		},
+		layersSize: 350,
+		failing:    map[string]bool{"stuck-container": true},
+	}
+	j := NewJanitor(time.Hour, 250)
+	j.IsActive = func(buildID string) bool { return buildID == "active" }
+
+	r, err := j.run(context.Background(), docker)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/vci-finished-app"}, r.Containers)
+	assert.Equal(t, []string{"vci-finished"}, r.Networks)
+	// run images are removed once orphaned, and then other images, oldest first, until the
+	// disk usage is under the threshold
+	assert.Equal(t, []string{"vci-finished:latest", "app:1"}, r.Images)
+	assert.Equal(t, int64(150), r.ReclaimedBytes)
+	assert.Equal(t, []string{"orphaned-container", "orphaned-network", "orphaned-run-image", "older-image"}, docker.removed)
+}
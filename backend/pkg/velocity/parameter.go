@@ -21,6 +21,44 @@ type Parameter struct {
 	IsSecret bool   `json:"isSecret"`
 }
 
+// Parameter sources in increasing order of precedence
+const (
+	parameterSourceGit     = "git"
+	parameterSourceProject = "project"
+	parameterSourceTask    = "task"
+)
+
+// resolvedParameters records where each parameter was resolved from in the setup stream.
+type resolvedParameters struct {
+	writer     io.Writer
+	values     map[string]Parameter
+	sources    map[string]string
+	unresolved []string
+}
+
+func newResolvedParameters(writer io.Writer) *resolvedParameters {
+	return &resolvedParameters{
+		writer:  writer,
+		values:  map[string]Parameter{},
+		sources: map[string]string{},
+	}
+}
+
+func (r *resolvedParameters) set(p Parameter, source string) {
+	v := p.Value
+	if p.IsSecret {
+		v = "***"
+	}
+	from := source
+	if previous, ok := r.sources[p.Name]; ok && previous != source {
+		from = fmt.Sprintf("%s, overrides %s", source, previous)
+	}
+	r.writer.Write([]byte(fmt.Sprintf("Set %s: %s (from %s)", p.Name, v, from)))
+
+	r.values[p.Name] = p
+	r.sources[p.Name] = source
+}
+
 type ParameterConfig interface {
 	GetInfo() string
 	GetParameters(writer io.Writer, t *Task, backupResolver BackupResolver) ([]Parameter, error)
@@ -106,19 +144,14 @@ func (p DerivedParameter) GetInfo() string {
 	return p.Use
 }
 
-func getBinary(u string) (binaryLocation string, _ error) {
+func getBinary(u string, workspace string) (binaryLocation string, _ error) {
 
 	parsedURL, err := url.Parse(u)
 	if err != nil {
 		return "", err
 	}
 
-	wd, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-
-	binaryLocation = fmt.Sprintf("%s/.velocityci/plugins/%s", wd, slug.Make(parsedURL.Path))
+	binaryLocation = fmt.Sprintf("%s/.velocityci/plugins/%s", workspace, slug.Make(parsedURL.Path))
 
 	if _, err := os.Stat(binaryLocation); os.IsNotExist(err) {
 		GetLogger().Debug("downloading binary", zap.String("from", u), zap.String("to", binaryLocation))
@@ -147,7 +180,7 @@ func getBinary(u string) (binaryLocation string, _ error) {
 func (p DerivedParameter) GetParameters(writer io.Writer, t *Task, backupResolver BackupResolver) (r []Parameter, _ error) {
 
 	// Download binary from use:
-	bin, err := getBinary(p.Use)
+	bin, err := getBinary(p.Use, t.Workspace)
 	if err != nil {
 		return r, err
 	}
@@ -159,6 +192,7 @@ func (p DerivedParameter) GetParameters(writer io.Writer, t *Task, backupResolve
 	}
 
 	cmd := exec.Command(bin, args...)
+	cmd.Dir = t.Workspace
 	cmd.Env = os.Environ()
 
 	// Run binary
@@ -238,6 +272,50 @@ type derivedOutput struct {
 	State   string            `json:"state"`
 }
 
+// ParameterConfigs are parameter configurations that can be read back from JSON, where
+// each is a DerivedParameter when it has "use", or otherwise a BasicParameter when it has
+// a "name".
+type ParameterConfigs []ParameterConfig
+
+func (c *ParameterConfigs) UnmarshalJSON(b []byte) error {
+	var rawParameters []*json.RawMessage
+	if err := json.Unmarshal(b, &rawParameters); err != nil {
+		return err
+	}
+	if rawParameters == nil {
+		*c = nil
+		return nil
+	}
+	*c = ParameterConfigs{}
+	for _, rawMessage := range rawParameters {
+		var m map[string]interface{}
+		err := json.Unmarshal(*rawMessage, &m)
+		if err != nil {
+			GetLogger().Error("could not unmarshal parameters", zap.Error(err))
+			return err
+		}
+		if _, ok := m["use"]; ok { // derivedParam
+			p := DerivedParameter{}
+			err = json.Unmarshal(*rawMessage, &p)
+			if err != nil {
+				GetLogger().Error("could not unmarshal determined parameter", zap.Error(err))
+				return err
+			}
+			*c = append(*c, p)
+		} else if _, ok := m["name"]; ok { // basicParam
+			p := BasicParameter{}
+			err = json.Unmarshal(*rawMessage, &p)
+			if err != nil {
+				GetLogger().Error("could not unmarshal determined parameter", zap.Error(err))
+				return err
+			}
+			*c = append(*c, p)
+		}
+	}
+
+	return nil
+}
+
 func unmarshalConfigParameters(y interface{}) []ParameterConfig {
 	configParams := []ParameterConfig{}
 	switch x := y.(type) {
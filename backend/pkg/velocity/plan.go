@@ -0,0 +1,272 @@
+package velocity
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Plan describes what running a task would do once its parameters are resolved, without
+// running anything in Docker. Secret values are masked.
+type Plan struct {
+	Task       string           `json:"task"`
+	Parameters []*PlanParameter `json:"parameters"`
+	// Unresolved are derived parameters that can only be resolved in a workspace.
+	Unresolved []string        `json:"unresolved,omitempty"`
+	Registries []*PlanRegistry `json:"registries"`
+	Steps      []*PlanStep     `json:"steps"`
+}
+
+type PlanParameter struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	IsSecret bool   `json:"isSecret"`
+	Source   string `json:"source"`
+}
+
+// PlanRegistry is a Docker registry that would be logged into by the plugin in Use.
+type PlanRegistry struct {
+	Use       string            `json:"use"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+// PlanStep is a step with parameters applied. Step numbers start at 1 as setup isn't included.
+type PlanStep struct {
+	Number      int               `json:"number"`
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	Image       string            `json:"image,omitempty"`
+	Command     []string          `json:"command,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+	WorkingDir  string            `json:"workingDir,omitempty"`
+	Dockerfile  string            `json:"dockerfile,omitempty"`
+	Context     string            `json:"context,omitempty"`
+	// Tags are built by build steps and pushed by push steps.
+	Tags        []string `json:"tags,omitempty"`
+	ComposeFile string   `json:"composeFile,omitempty"`
+	// Services are in the order that they are started.
+	Services []*PlanService `json:"services,omitempty"`
+}
+
+type PlanService struct {
+	Name        string            `json:"name"`
+	Image       string            `json:"image,omitempty"`
+	Dockerfile  string            `json:"dockerfile,omitempty"`
+	Context     string            `json:"context,omitempty"`
+	Command     []string          `json:"command,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+	WorkingDir  string            `json:"workingDir,omitempty"`
+	Links       []string          `json:"links,omitempty"`
+}
+
+// NewPlan resolves the parameters of a task as setup would and applies them to its steps.
+func NewPlan(
+	t *Task,
+	gitParams map[string]Parameter,
+	projectParams []ParameterConfig,
+	backupResolver BackupResolver,
+) (*Plan, error) {
+	parameters, err := resolveParameters(ioutil.Discard, t, gitParams, projectParams, backupResolver)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range t.Steps {
+		s.SetParams(parameters.values)
+	}
+
+	mask := func(s string) string {
+		return maskSecrets(s, parameters.values)
+	}
+	maskAll := func(ss []string) []string {
+		masked := []string{}
+		for _, s := range ss {
+			masked = append(masked, mask(s))
+		}
+		return masked
+	}
+	maskMap := func(m map[string]string) map[string]string {
+		masked := map[string]string{}
+		for k, v := range m {
+			masked[mask(k)] = mask(v)
+		}
+		return masked
+	}
+
+	p := &Plan{
+		Task:       t.Name,
+		Parameters: []*PlanParameter{},
+		Unresolved: parameters.unresolved,
+		Registries: []*PlanRegistry{},
+		Steps:      []*PlanStep{},
+	}
+
+	names := []string{}
+	for name := range parameters.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		param := parameters.values[name]
+		v := param.Value
+		if param.IsSecret {
+			v = "***"
+		}
+		p.Parameters = append(p.Parameters, &PlanParameter{
+			Name:     name,
+			Value:    v,
+			IsSecret: param.IsSecret,
+			Source:   parameters.sources[name],
+		})
+	}
+
+	for _, r := range t.Docker.Registries {
+		args := map[string]string{}
+		for k, v := range r.Arguments {
+			for _, pV := range parameters.values {
+				v = strings.Replace(v, fmt.Sprintf("${%s}", pV.Name), pV.Value, -1)
+				k = strings.Replace(k, fmt.Sprintf("${%s}", pV.Name), pV.Value, -1)
+			}
+			args[k] = v
+		}
+		p.Registries = append(p.Registries, &PlanRegistry{
+			Use:       r.Use,
+			Arguments: maskMap(args),
+		})
+	}
+
+	for i, s := range t.Steps {
+		step := &PlanStep{
+			Number:      i + 1,
+			Type:        s.GetType(),
+			Description: mask(s.GetDescription()),
+		}
+		switch x := s.(type) {
+		case *DockerRun:
+			step.Image = mask(x.Image)
+			step.Command = maskAll(x.Command)
+			step.Environment = maskMap(x.Environment)
+			step.WorkingDir = mask(x.WorkingDir)
+		case *Shell:
+			step.Command = maskAll(x.Command)
+			step.Environment = maskMap(x.Environment)
+			step.WorkingDir = mask(x.WorkingDir)
+		case *DockerBuild:
+			step.Dockerfile = mask(x.Dockerfile)
+			step.Context = mask(x.Context)
+			step.Tags = maskAll(x.Tags)
+		case *DockerPush:
+			step.Tags = maskAll(x.Tags)
+		case *DockerCompose:
+			step.ComposeFile = x.ComposeFile
+			step.Services = []*PlanService{}
+			for _, name := range getServiceOrder(x.Contents.Services, []string{}) {
+				service := x.Contents.Services[name]
+				step.Services = append(step.Services, &PlanService{
+					Name:        name,
+					Image:       mask(service.Image),
+					Dockerfile:  mask(service.Build.Dockerfile),
+					Context:     mask(service.Build.Context),
+					Command:     maskAll(service.Command),
+					Environment: maskMap(service.Environment),
+					WorkingDir:  mask(service.WorkingDir),
+					Links:       service.Links,
+				})
+			}
+		}
+		p.Steps = append(p.Steps, step)
+	}
+
+	return p, nil
+}
+
+// NewWorkspacePlan plans a task in its workspace, with git parameters and project parameters
+// from the repository there.
+func NewWorkspacePlan(t *Task, backupResolver BackupResolver) (*Plan, error) {
+	if err := makeVelocityDirs(t.Workspace); err != nil {
+		return nil, err
+	}
+	repoConfig, err := GetRepositoryConfig(t.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlan(t, getGitParams(t), repoConfig.Parameters, backupResolver)
+}
+
+// WriteText writes the plan for people to read.
+func (p *Plan) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Task: %s\n", p.Task)
+
+	fmt.Fprintf(w, "\nParameters:\n")
+	for _, param := range p.Parameters {
+		fmt.Fprintf(w, "  %s=%s (%s)\n", param.Name, param.Value, param.Source)
+	}
+	for _, use := range p.Unresolved {
+		fmt.Fprintf(w, "  derived from %s when run\n", use)
+	}
+
+	if len(p.Registries) > 0 {
+		fmt.Fprintf(w, "\nRegistries to log into:\n")
+		for _, r := range p.Registries {
+			fmt.Fprintf(w, "  %s\n", r.Use)
+			writeTextEnvironment(w, "    ", r.Arguments)
+		}
+	}
+
+	fmt.Fprintf(w, "\nSteps:\n")
+	for _, s := range p.Steps {
+		fmt.Fprintf(w, "  %d. %s", s.Number, s.Type)
+		if s.Description != "" {
+			fmt.Fprintf(w, ": %s", s.Description)
+		}
+		fmt.Fprintln(w)
+		writeTextField(w, "     ", "image", s.Image)
+		writeTextField(w, "     ", "dockerfile", s.Dockerfile)
+		writeTextField(w, "     ", "context", s.Context)
+		writeTextField(w, "     ", "command", strings.Join(s.Command, " "))
+		writeTextField(w, "     ", "working dir", s.WorkingDir)
+		writeTextEnvironment(w, "     ", s.Environment)
+		switch s.Type {
+		case "build":
+			writeTextField(w, "     ", "build tags", strings.Join(s.Tags, ", "))
+		case "push":
+			writeTextField(w, "     ", "push tags", strings.Join(s.Tags, ", "))
+		}
+		writeTextField(w, "     ", "compose file", s.ComposeFile)
+		if len(s.Services) > 0 {
+			order := []string{}
+			for _, service := range s.Services {
+				order = append(order, service.Name)
+			}
+			writeTextField(w, "     ", "service order", strings.Join(order, ", "))
+		}
+		for _, service := range s.Services {
+			fmt.Fprintf(w, "     %s:\n", service.Name)
+			writeTextField(w, "       ", "image", service.Image)
+			writeTextField(w, "       ", "dockerfile", service.Dockerfile)
+			writeTextField(w, "       ", "context", service.Context)
+			writeTextField(w, "       ", "command", strings.Join(service.Command, " "))
+			writeTextField(w, "       ", "working dir", service.WorkingDir)
+			writeTextField(w, "       ", "links", strings.Join(service.Links, ", "))
+			writeTextEnvironment(w, "       ", service.Environment)
+		}
+	}
+}
+
+func writeTextField(w io.Writer, indent string, name string, value string) {
+	if value != "" {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, name, value)
+	}
+}
+
+func writeTextEnvironment(w io.Writer, indent string, env map[string]string) {
+	keys := []string{}
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s=%s\n", indent, k, env[k])
+	}
+}
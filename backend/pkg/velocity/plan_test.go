@@ -0,0 +1,103 @@
+package velocity
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlanAppliesParametersAndMasksSecrets(t *testing.T) {
+	root, err := ioutil.TempDir("", "velocity-plan")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	writeProjectFiles(t, root, map[string]string{
+		"docker-compose.yml": `
+services:
+  app:
+    image: example/app:${VERSION}
+    links:
+    - db
+  db:
+    image: postgres
+`,
+	})
+
+	task, err := ParseTask([]byte(`
+name: release
+parameters:
+- name: VERSION
+- name: TOKEN
+  secret: true
+docker:
+  registries:
+  - use: https://example.com/registry-login
+    arguments:
+      password: ${TOKEN}
+steps:
+- type: run
+  description: Unit tests
+  image: golang:1.10
+  command: go test ./...
+  environment:
+    API_TOKEN: ${TOKEN}
+- type: build
+  dockerfile: Dockerfile
+  context: .
+  tags:
+  - example/app:${VERSION}
+- type: compose
+  composeFile: docker-compose.yml
+- type: push
+  tags:
+  - example/app:${VERSION}
+  - example/app:${GIT_BRANCH}
+`), root)
+	assert.Nil(t, err)
+
+	p, err := NewPlan(task, map[string]Parameter{
+		"GIT_BRANCH": {Value: "master"},
+	}, nil, mapResolver{"VERSION": "1.2.0", "TOKEN": "s3cret"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []*PlanParameter{
+		{Name: "GIT_BRANCH", Value: "master", Source: parameterSourceGit},
+		{Name: "TOKEN", Value: "***", IsSecret: true, Source: parameterSourceTask},
+		{Name: "VERSION", Value: "1.2.0", Source: parameterSourceTask},
+	}, p.Parameters)
+	assert.Equal(t, map[string]string{"password": "***"}, p.Registries[0].Arguments)
+
+	assert.Len(t, p.Steps, 4)
+	assert.Equal(t, "golang:1.10", p.Steps[0].Image)
+	assert.Equal(t, []string{"go", "test", "./..."}, p.Steps[0].Command)
+	assert.Equal(t, map[string]string{"API_TOKEN": "***"}, p.Steps[0].Environment)
+	assert.Equal(t, []string{"example/app:1.2.0"}, p.Steps[1].Tags)
+	assert.Equal(t, "db", p.Steps[2].Services[0].Name)
+	assert.Equal(t, "app", p.Steps[2].Services[1].Name)
+	assert.Equal(t, 4, p.Steps[3].Number)
+	assert.Equal(t, []string{"example/app:1.2.0", "example/app:master"}, p.Steps[3].Tags)
+
+	out := &bytes.Buffer{}
+	p.WriteText(out)
+	assert.Contains(t, out.String(), "  TOKEN=*** (task)\n")
+	assert.Contains(t, out.String(), "     service order: db, app\n")
+	assert.Contains(t, out.String(), "     push tags: example/app:1.2.0, example/app:master\n")
+	assert.NotContains(t, out.String(), "s3cret")
+}
+
+func TestNewPlanLeavesDerivedParametersWithoutWorkspace(t *testing.T) {
+	task := &Task{
+		Name: "deploy",
+		Parameters: []ParameterConfig{
+			DerivedParameter{Use: "https://example.com/aws-credentials"},
+		},
+		Steps: []Step{},
+	}
+
+	p, err := NewPlan(task, map[string]Parameter{}, nil, mapResolver{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://example.com/aws-credentials"}, p.Unresolved)
+}
@@ -27,7 +27,7 @@ func (p *Plugin) Execute(emitter Emitter, t *Task) error {
 	writer := emitter.GetStreamWriter("plugin")
 	writer.SetStatus(StateRunning)
 
-	bin, err := getBinary(p.Use)
+	bin, err := getBinary(p.Use, t.Workspace)
 	if err != nil {
 		return err
 	}
@@ -38,6 +38,7 @@ func (p *Plugin) Execute(emitter Emitter, t *Task) error {
 	}
 
 	cmd := exec.Command(bin)
+	cmd.Dir = t.Workspace
 	cmd.Env = append(os.Environ(), env...)
 
 	cmdOutBytes, err := cmd.Output()
@@ -8,14 +8,28 @@ type RepositoryConfig struct {
 	Project ProjectConfig `json:"project" yaml:"project"`
 	Git     GitConfig     `json:"git" yaml:"git"`
 
-	Parameters []ParameterConfig `json:"paramaters" yaml:"parameters"`
-	Plugins    []PluginConfig    `json:"plugins" yaml:"plugins"`
-	Stages     []StageConfig     `json:"stages" yaml:"stages"`
+	Parameters ParameterConfigs `json:"paramaters" yaml:"parameters"`
+	Plugins    []PluginConfig   `json:"plugins" yaml:"plugins"`
+	Stages     []StageConfig    `json:"stages" yaml:"stages"`
+	Watch      WatchConfig      `json:"watch" yaml:"watch"`
 }
 
 type ProjectConfig struct {
 	Logo      string `json:"logo" yaml:"logo"`
 	TasksPath string `json:"tasksPath" yaml:"tasksPath"`
+	// TasksPaths is set when tasksPath is given as a list of paths or glob patterns.
+	TasksPaths []string `json:"tasksPaths,omitempty" yaml:"-"`
+}
+
+// GetTasksPaths returns the directories, files or glob patterns to discover tasks in.
+func (p *ProjectConfig) GetTasksPaths() []string {
+	if len(p.TasksPaths) > 0 {
+		return p.TasksPaths
+	}
+	if p.TasksPath != "" {
+		return []string{p.TasksPath}
+	}
+	return []string{"./tasks"}
 }
 
 type GitConfig struct {
@@ -28,11 +42,29 @@ type PluginConfig struct {
 	Events    []string          `json:"events" yaml:"events"`
 }
 
+// WatchConfig configures vcli watch.
+type WatchConfig struct {
+	// Ignore lists .dockerignore style patterns of files that don't trigger a rerun.
+	Ignore []string `json:"ignore" yaml:"ignore"`
+}
+
 type StageConfig struct {
 	Name  string   `json:"name" yaml:"name"`
 	Tasks []string `json:"tasks" yaml:"tasks"`
 }
 
+// NewRepositoryConfig returns the configuration of a repository without a .velocity.yml.
+func NewRepositoryConfig() *RepositoryConfig {
+	return &RepositoryConfig{
+		Project:    unmarshalProjectYaml(nil),
+		Git:        unmarshalGitYaml(nil),
+		Parameters: unmarshalConfigParameters(nil),
+		Plugins:    unmarshalPluginConfigs(nil),
+		Stages:     unmarshalStageConfigs(nil),
+		Watch:      unmarshalWatchYaml(nil),
+	}
+}
+
 func (t *RepositoryConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var repoConfigMap map[string]interface{}
 	err := unmarshal(&repoConfigMap)
@@ -46,6 +78,7 @@ func (t *RepositoryConfig) UnmarshalYAML(unmarshal func(interface{}) error) erro
 	t.Parameters = unmarshalConfigParameters(repoConfigMap["parameters"])
 	t.Plugins = unmarshalPluginConfigs(repoConfigMap["plugins"])
 	t.Stages = unmarshalStageConfigs(repoConfigMap["stages"])
+	t.Watch = unmarshalWatchYaml(repoConfigMap["watch"])
 
 	return nil
 }
@@ -61,8 +94,18 @@ func unmarshalProjectYaml(y interface{}) ProjectConfig {
 		if v, ok := x["logo"].(string); ok {
 			p.Logo = v
 		}
-		if v, ok := x["tasksPath"].(string); ok {
+		switch v := x["tasksPath"].(type) {
+		case string:
 			p.TasksPath = v
+		case []interface{}:
+			for _, path := range v {
+				if path, ok := path.(string); ok {
+					p.TasksPaths = append(p.TasksPaths, path)
+				}
+			}
+			if len(p.TasksPaths) > 0 {
+				p.TasksPath = p.TasksPaths[0]
+			}
 		}
 	}
 
@@ -83,6 +126,24 @@ func unmarshalGitYaml(y interface{}) GitConfig {
 	return g
 }
 
+func unmarshalWatchYaml(y interface{}) WatchConfig {
+	w := WatchConfig{
+		Ignore: []string{},
+	}
+	switch x := y.(type) {
+	case map[interface{}]interface{}:
+		if v, ok := x["ignore"].([]interface{}); ok {
+			for _, pattern := range v {
+				if pattern, ok := pattern.(string); ok {
+					w.Ignore = append(w.Ignore, pattern)
+				}
+			}
+		}
+	}
+
+	return w
+}
+
 func unmarshalPluginConfigs(y interface{}) []PluginConfig {
 	pluginConfigs := []PluginConfig{}
 	switch x := y.(type) {
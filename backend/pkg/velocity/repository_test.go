@@ -1,6 +1,7 @@
 package velocity_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,6 +49,11 @@ stages:
   tasks: 
   - deploy_web
   - deploy_api	
+
+watch:
+  ignore:
+  - "*.log"
+  - web/node_modules
 `
 
 	var repositoryConfig velocity.RepositoryConfig
@@ -99,6 +105,16 @@ stages:
 				Tasks: []string{"deploy_web", "deploy_api"},
 			},
 		},
+		Watch: velocity.WatchConfig{
+			Ignore: []string{"*.log", "web/node_modules"},
+		},
 	}
 	assert.Equal(t, expectedRepositoryConfig, repositoryConfig)
+
+	// projects are stored and sent to builders as JSON
+	b, err := json.Marshal(&repositoryConfig)
+	assert.Nil(t, err)
+	var fromJSON velocity.RepositoryConfig
+	assert.Nil(t, json.Unmarshal(b, &fromJSON))
+	assert.Equal(t, expectedRepositoryConfig, fromJSON)
 }
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"time"
 
@@ -22,6 +23,8 @@ type Setup struct {
 	backupResolver BackupResolver
 	repository     *GitRepository
 	commitHash     string
+	gitConfig      GitConfig
+	signatures     SignaturePolicy
 }
 
 func NewSetup() *Setup {
@@ -37,10 +40,14 @@ func (s *Setup) Init(
 	backupResolver BackupResolver,
 	repository *GitRepository,
 	commitHash string,
+	gitConfig GitConfig,
+	signatures SignaturePolicy,
 ) {
 	s.backupResolver = backupResolver
 	s.repository = repository
 	s.commitHash = commitHash
+	s.gitConfig = gitConfig
+	s.signatures = signatures
 }
 
 func (s *Setup) UnmarshalYamlInterface(y map[interface{}]interface{}) error {
@@ -51,85 +58,84 @@ func (s Setup) GetDetails() string {
 	return ""
 }
 
-func makeVelocityDirs() error {
-	wd, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-
-	os.MkdirAll(fmt.Sprintf("%s/.velocityci/plugins", wd), os.ModePerm)
+// NewRunID returns an ID for a run of a task, used to label and name its Docker resources.
+func NewRunID() string {
+	return fmt.Sprintf("vci-%s", time.Now().Format("060102150405"))
+}
 
-	return nil
+func makeVelocityDirs(workspace string) error {
+	return os.MkdirAll(fmt.Sprintf("%s/.velocityci/plugins", workspace), os.ModePerm)
 }
 
 func (s *Setup) Execute(emitter Emitter, t *Task) error {
 
-	t.RunID = fmt.Sprintf("vci-%s", time.Now().Format("060102150405"))
+	if t.RunID == "" {
+		t.RunID = NewRunID()
+	}
 
 	writer := emitter.GetStreamWriter("setup")
 	writer.SetStatus(StateRunning)
 
 	// Clone repository if necessary
 	if s.repository != nil {
-		// repo, err := Clone(s.repository, false, true, t.Git.Submodule, writer)
-		repo, err := Clone(s.repository, writer, &CloneOptions{Bare: false, Full: false, Submodule: true, Commit: s.commitHash})
+		repo, err := Clone(s.repository, writer, &CloneOptions{
+			Depth:     s.gitConfig.Depth,
+			Submodule: t.Git.Submodule,
+			Commit:    s.commitHash,
+		})
 		if err != nil {
-			GetLogger().Error("could not clone repository", zap.Error(err))
+			GetLogger().Error("could not clone repository", zap.Error(err), zap.String("commit", s.commitHash))
 			writer.SetStatus(StateFailed)
 			writer.Write([]byte(fmt.Sprintf("%s\n### FAILED: %s \x1b[0m", errorANSI, err)))
 			return err
 		}
-		repo.Checkout(s.commitHash)
-		if err != nil {
-			GetLogger().Error("could not checkout", zap.Error(err), zap.String("commit", s.commitHash))
+		t.Workspace = repo.Directory
+
+		if err := checkCommitSignature(writer, repo, s.commitHash, &s.signatures); err != nil {
+			GetLogger().Error("refused commit signature", zap.Error(err), zap.String("commit", s.commitHash))
 			writer.SetStatus(StateFailed)
 			writer.Write([]byte(fmt.Sprintf("%s\n### FAILED: %s \x1b[0m", errorANSI, err)))
 			return err
 		}
-		os.Chdir(repo.Directory)
 	}
 
-	if err := makeVelocityDirs(); err != nil {
+	if t.Workspace == "" {
+		writer.SetStatus(StateFailed)
+		writer.Write([]byte(fmt.Sprintf("%s\n### FAILED: no workspace \x1b[0m", errorANSI)))
+		return fmt.Errorf("no workspace for task %s", t.Name)
+	}
+
+	if err := makeVelocityDirs(t.Workspace); err != nil {
 		return err
 	}
 
-	// Resolve parameters
-	parameters := map[string]Parameter{}
-	for k, v := range getGitParams() {
-		parameters[k] = v
-		writer.Write([]byte(fmt.Sprintf("Set %s: %s", k, v.Value)))
+	// Resolve parameters with task parameters taking precedence over project parameters
+	// from the repository configuration, which take precedence over git parameters.
+	repoConfig, err := GetRepositoryConfig(t.Workspace)
+	if err != nil {
+		writer.SetStatus(StateFailed)
+		writer.Write([]byte(fmt.Sprintf("%s\n### FAILED: could not read repository configuration: %s \x1b[0m", errorANSI, err)))
+		return err
 	}
 
-	// config
-	for _, config := range t.Parameters {
-		writer.Write([]byte(fmt.Sprintf("Resolving parameter %s", config.GetInfo())))
-		params, err := config.GetParameters(writer, t, s.backupResolver)
-		if err != nil {
-			writer.SetStatus(StateFailed)
-			writer.Write([]byte(fmt.Sprintf("could not resolve parameter: %v", err)))
-			return fmt.Errorf("could not resolve %v", err)
-		}
-		for _, param := range params {
-			parameters[param.Name] = param
-			if param.IsSecret {
-				writer.Write([]byte(fmt.Sprintf("Set %s: ***", param.Name)))
-			} else {
-				writer.Write([]byte(fmt.Sprintf("Set %s: %v", param.Name, param.Value)))
-			}
-		}
+	parameters, err := resolveParameters(writer, t, getGitParams(t), repoConfig.Parameters, s.backupResolver)
+	if err != nil {
+		writer.SetStatus(StateFailed)
+		writer.Write([]byte(err.Error()))
+		return err
 	}
 
-	t.ResolvedParameters = parameters
+	t.ResolvedParameters = parameters.values
 
 	// Update params on steps
 	for _, s := range t.Steps {
-		s.SetParams(parameters)
+		s.SetParams(t.ResolvedParameters)
 	}
 
 	// Login to docker registries
 	authedRegistries := []DockerRegistry{}
 	for _, registry := range t.Docker.Registries {
-		r, err := dockerLogin(registry, writer, t.RunID, parameters, t.Docker.Registries)
+		r, err := dockerLogin(registry, writer, t, t.ResolvedParameters)
 		if err != nil || r.Address == "" {
 			writer.SetStatus(StateFailed)
 			writer.Write([]byte(fmt.Sprintf("could not login to Docker registry: %v", err)))
@@ -147,6 +153,77 @@ func (s *Setup) Execute(emitter Emitter, t *Task) error {
 	return nil
 }
 
+// resolveParameters resolves the git parameters, then project parameters from the repository
+// configuration, then task parameters, with later sources taking precedence. Derived parameters
+// need the task workspace to run their plugin, so they are left unresolved without one.
+func resolveParameters(
+	writer io.Writer,
+	t *Task,
+	gitParams map[string]Parameter,
+	projectParams []ParameterConfig,
+	backupResolver BackupResolver,
+) (*resolvedParameters, error) {
+	parameters := newResolvedParameters(writer)
+	gitParamNames := []string{}
+	for k := range gitParams {
+		gitParamNames = append(gitParamNames, k)
+	}
+	sort.Strings(gitParamNames)
+	for _, k := range gitParamNames {
+		p := gitParams[k]
+		p.Name = k
+		parameters.set(p, parameterSourceGit)
+	}
+
+	for _, c := range []struct {
+		source  string
+		configs []ParameterConfig
+	}{
+		{parameterSourceProject, projectParams},
+		{parameterSourceTask, t.Parameters},
+	} {
+		for _, config := range c.configs {
+			if _, ok := config.(DerivedParameter); ok && t.Workspace == "" {
+				parameters.unresolved = append(parameters.unresolved, config.GetInfo())
+				continue
+			}
+			writer.Write([]byte(fmt.Sprintf("Resolving %s parameter %s", c.source, config.GetInfo())))
+			params, err := config.GetParameters(writer, t, backupResolver)
+			if err != nil {
+				return nil, fmt.Errorf("could not resolve %s parameter: %v", c.source, err)
+			}
+			for _, param := range params {
+				parameters.set(param, c.source)
+			}
+		}
+	}
+
+	return parameters, nil
+}
+
+// checkCommitSignature verifies the commit signature if the project has a signature policy.
+func checkCommitSignature(writer io.Writer, repo *RawRepository, sha string, policy *SignaturePolicy) error {
+	if policy.Mode == SignaturePolicyOff {
+		return nil
+	}
+
+	v, err := repo.VerifySignature(sha, policy.TrustedKeys)
+	if err != nil {
+		return fmt.Errorf("could not verify commit signature: %s", err)
+	}
+	if err := policy.Check(v); err != nil {
+		return err
+	}
+
+	if v.Status == SignatureVerified {
+		writer.Write([]byte(fmt.Sprintf("Commit signature verified: %s", v.Fingerprint)))
+	} else {
+		writer.Write([]byte(fmt.Sprintf("%sWARNING: commit signature is %s %s\x1b[0m", warnANSI, v.Status, v.Fingerprint)))
+	}
+
+	return nil
+}
+
 func (s *Setup) SetParams(params map[string]Parameter) error {
 	return nil
 }
@@ -155,13 +232,21 @@ func (s Setup) Validate(params map[string]Parameter) error {
 	return nil
 }
 
-func getGitParams() map[string]Parameter {
-	path, _ := os.Getwd()
-
-	repo := &RawRepository{Directory: path}
+func getGitParams(t *Task) map[string]Parameter {
+	repo := &RawRepository{Directory: t.Workspace}
 
 	rawCommit := repo.GetCurrentCommitInfo()
 
+	// The architect knows which branch or tag is being built, the workspace is a detached checkout.
+	branch := t.Branch
+	if branch == "" {
+		branch = repo.GetCurrentBranch()
+	}
+	tag := t.Tag
+	if tag == "" {
+		tag = repo.GetCurrentTag()
+	}
+
 	return map[string]Parameter{
 		"GIT_COMMIT_LONG_SHA": {
 			Value:    rawCommit.SHA,
@@ -171,10 +256,14 @@ func getGitParams() map[string]Parameter {
 			Value:    rawCommit.SHA[:7],
 			IsSecret: false,
 		},
-		// "GIT_BRANCH": {
-		// 	Value:    branch,
-		// 	IsSecret: false,
-		// },
+		"GIT_BRANCH": {
+			Value:    branch,
+			IsSecret: false,
+		},
+		"GIT_TAG": {
+			Value:    tag,
+			IsSecret: false,
+		},
 		"GIT_DESCRIBE": {
 			Value:    repo.GetDescribe(),
 			IsSecret: false,
@@ -194,7 +283,7 @@ func getGitParams() map[string]Parameter {
 	}
 }
 
-func dockerLogin(registry DockerRegistry, writer io.Writer, RunID string, parameters map[string]Parameter, authConfigs []DockerRegistry) (r DockerRegistry, _ error) {
+func dockerLogin(registry DockerRegistry, writer io.Writer, t *Task, parameters map[string]Parameter) (r DockerRegistry, _ error) {
 
 	type registryAuthConfig struct {
 		Username      string `json:"username"`
@@ -204,7 +293,7 @@ func dockerLogin(registry DockerRegistry, writer io.Writer, RunID string, parame
 		State         string `json:"state"`
 	}
 
-	bin, err := getBinary(registry.Use)
+	bin, err := getBinary(registry.Use, t.Workspace)
 	if err != nil {
 		return r, err
 	}
@@ -219,6 +308,7 @@ func dockerLogin(registry DockerRegistry, writer io.Writer, RunID string, parame
 	}
 
 	cmd := exec.Command(bin)
+	cmd.Dir = t.Workspace
 	cmd.Env = append(os.Environ(), extraEnv...)
 
 	cmdOutBytes, err := cmd.Output()
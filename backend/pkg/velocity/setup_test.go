@@ -0,0 +1,72 @@
+package velocity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferEmitter struct {
+	writer *bufferWriter
+}
+
+func (e *bufferEmitter) GetStreamWriter(streamName string) StreamWriter {
+	return e.writer
+}
+
+type mapResolver map[string]string
+
+func (r mapResolver) Resolve(paramName string) (string, error) {
+	if v, ok := r[paramName]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("no value for %s", paramName)
+}
+
+func TestSetupParameterPrecedence(t *testing.T) {
+	dir := newTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	err := ioutil.WriteFile(filepath.Join(dir, ".velocity.yml"), []byte(`
+parameters:
+- name: REGISTRY
+- name: GIT_BRANCH
+- name: REGISTRY_PASSWORD
+  secret: true
+`), 0644)
+	assert.Nil(t, err)
+
+	task := &Task{
+		Name:      "build",
+		Workspace: dir,
+		Branch:    "master",
+		Parameters: []ParameterConfig{
+			BasicParameter{Name: "REGISTRY", Value: "registry.example.com"},
+		},
+		Steps: []Step{},
+	}
+	s := NewSetup()
+	s.Init(mapResolver{
+		"REGISTRY":          "project.example.com",
+		"GIT_BRANCH":        "release",
+		"REGISTRY_PASSWORD": "s3cret",
+	}, nil, "", GitConfig{}, SignaturePolicy{})
+
+	w := &bufferWriter{}
+	err = s.Execute(&bufferEmitter{writer: w}, task)
+	assert.Nil(t, err, w.lines)
+
+	assert.Equal(t, "registry.example.com", task.ResolvedParameters["REGISTRY"].Value)
+	assert.Equal(t, "release", task.ResolvedParameters["GIT_BRANCH"].Value)
+	assert.Equal(t, "GIT_COMMIT_SHORT_SHA", task.ResolvedParameters["GIT_COMMIT_SHORT_SHA"].Name)
+
+	assert.Contains(t, w.lines, "Set REGISTRY: project.example.com (from project)")
+	assert.Contains(t, w.lines, "Set REGISTRY: registry.example.com (from task, overrides project)")
+	assert.Contains(t, w.lines, "Set GIT_BRANCH: master (from git)")
+	assert.Contains(t, w.lines, "Set GIT_BRANCH: release (from project, overrides git)")
+	assert.Contains(t, w.lines, "Set REGISTRY_PASSWORD: *** (from project)")
+}
@@ -0,0 +1,321 @@
+package velocity
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// shellEnvironment are the variables of the builder's environment that shell steps and
+// plugins are given, the rest of it, e.g. BUILDER_SECRET, is kept from repository scripts.
+var shellEnvironment = []string{
+	"PATH",
+	"HOME",
+	"USER",
+	"LOGNAME",
+	"SHELL",
+	"LANG",
+	"LC_ALL",
+	"TZ",
+	"TMPDIR",
+}
+
+// getShellEnvironment returns the shellEnvironment variables that are set for the builder.
+func getShellEnvironment() []string {
+	env := []string{}
+	for _, k := range shellEnvironment {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	return env
+}
+
+// shellDrainTimeout is how long output is still read for once a shell command has exited,
+// as processes it started in the background can keep its output open.
+var shellDrainTimeout = 2 * time.Second
+
+// Shell runs a command directly on the host in the task workspace. Builders
+// have to opt in to running these steps as they are not isolated by Docker.
+type Shell struct {
+	BaseStep       `yaml:",inline"`
+	Command        []string          `json:"command" yaml:"command"`
+	Environment    map[string]string `json:"environment" yaml:"environment"`
+	WorkingDir     string            `json:"workingDir" yaml:"workingDir"`
+	IgnoreExitCode bool              `json:"ignoreExitCode" yaml:"ignoreExit"`
+}
+
+func (s *Shell) UnmarshalYamlInterface(y map[interface{}]interface{}) error {
+	switch x := y["description"].(type) {
+	case interface{}:
+		s.Description = x.(string)
+		break
+	}
+
+	s.Command = []string{}
+	switch x := y["command"].(type) {
+	case []interface{}:
+		for _, p := range x {
+			s.Command = append(s.Command, p.(string))
+		}
+		break
+	case interface{}:
+		re := regexp.MustCompile(`(".+")|('.+')|(\S+)`)
+		matches := re.FindAllString(x.(string), -1)
+		for _, m := range matches {
+			s.Command = append(s.Command, strings.TrimFunc(m, func(r rune) bool {
+				return string(r) == `"` || string(r) == `'`
+			}))
+		}
+		break
+	}
+
+	s.Environment = map[string]string{}
+	switch x := y["environment"].(type) {
+	case []interface{}:
+		for _, e := range x {
+			parts := strings.SplitN(e.(string), "=", 2)
+			if len(parts) == 2 {
+				s.Environment[parts[0]] = parts[1]
+			}
+		}
+		break
+	case map[interface{}]interface{}:
+		for k, v := range x {
+			if num, ok := v.(int); ok {
+				v = strconv.Itoa(num)
+			}
+			s.Environment[k.(string)] = v.(string)
+		}
+		break
+	}
+
+	switch x := y["workingDir"].(type) {
+	case interface{}:
+		s.WorkingDir = x.(string)
+		break
+	}
+	switch x := y["ignoreExit"].(type) {
+	case interface{}:
+		s.IgnoreExitCode = x.(bool)
+		break
+	}
+	return nil
+}
+
+func NewShell() *Shell {
+	return &Shell{
+		Command:        []string{},
+		Environment:    map[string]string{},
+		WorkingDir:     "",
+		IgnoreExitCode: false,
+		BaseStep: BaseStep{
+			Type:          "shell",
+			OutputStreams: []string{"shell"},
+		},
+	}
+}
+
+func (s Shell) GetDetails() string {
+	return fmt.Sprintf("command: %s", s.Command)
+}
+
+func (s *Shell) Execute(emitter Emitter, t *Task) error {
+	writer := emitter.GetStreamWriter("shell")
+	writer.SetStatus(StateRunning)
+	writer.Write([]byte(fmt.Sprintf("%s## %s\x1b[0m", infoANSI, s.Description)))
+
+	if len(s.Command) < 1 {
+		writer.SetStatus(StateFailed)
+		writer.Write([]byte(fmt.Sprintf("%s### FAILED: no command given\x1b[0m", errorANSI)))
+		return fmt.Errorf("no command given")
+	}
+
+	dir, err := shellWorkingDir(t.Workspace, s.WorkingDir)
+	if err != nil {
+		writer.SetStatus(StateFailed)
+		writer.Write([]byte(fmt.Sprintf("%s### FAILED: %s\x1b[0m", errorANSI, err)))
+		return err
+	}
+
+	env := getShellEnvironment()
+	for k, v := range s.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	c := exec.Command(s.Command[0], s.Command[1:]...)
+	c.Dir = dir
+	c.Env = env
+
+	exitCode, err := runShellCommand(c, t.ResolvedParameters, writer)
+	if err != nil {
+		writer.SetStatus(StateFailed)
+		writer.Write([]byte(fmt.Sprintf("%s### FAILED: %s\x1b[0m", errorANSI, err)))
+		return err
+	}
+
+	if exitCode != 0 && !s.IgnoreExitCode {
+		writer.SetStatus(StateFailed)
+		writer.Write([]byte(fmt.Sprintf("%s### FAILED (exited: %d)\x1b[0m", errorANSI, exitCode)))
+		return fmt.Errorf("Non-zero exit code: %d", exitCode)
+	}
+
+	writer.SetStatus(StateSuccess)
+	writer.Write([]byte(fmt.Sprintf("%s### SUCCESS (exited: %d)\x1b[0m", successANSI, exitCode)))
+	return nil
+}
+
+// shellWorkingDir returns the directory in the workspace that a shell step runs in,
+// refusing one outside of the workspace.
+func shellWorkingDir(workspace string, workingDir string) (string, error) {
+	dir := filepath.Join(workspace, workingDir)
+	paths := [][2]string{{workspace, dir}}
+	// symlinks in the repository can point outside of it too
+	if rWorkspace, err := filepath.EvalSymlinks(workspace); err == nil {
+		if rDir, err := filepath.EvalSymlinks(dir); err == nil {
+			paths = append(paths, [2]string{rWorkspace, rDir})
+		}
+	}
+	for _, p := range paths {
+		rel, err := filepath.Rel(p[0], p[1])
+		if err != nil || rel == ".." || len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator) {
+			return "", fmt.Errorf("working directory %s is outside of the workspace", workingDir)
+		}
+	}
+
+	return dir, nil
+}
+
+// runShellCommand runs the given command, streaming its combined output
+// (with secrets masked) to the writer, and returns its exit code.
+func runShellCommand(c *exec.Cmd, parameters map[string]Parameter, writer StreamWriter) (int, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return -1, err
+	}
+	defer r.Close()
+	c.Stdout = w
+	c.Stderr = w
+
+	err = c.Start()
+	w.Close()
+	if err != nil {
+		return -1, err
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(done)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if len(line) > 0 {
+				writer.Write([]byte(maskSecrets(strings.TrimSuffix(line, "\n"), parameters)))
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				select {
+				case <-stopped:
+					return
+				default:
+				}
+				GetLogger().Error("could not read shell output", zap.Error(err))
+				writer.Write([]byte(fmt.Sprintf("%s### could not read output: %s\x1b[0m", errorANSI, err)))
+				// keep the pipe drained so that the command doesn't block writing to it
+				io.Copy(ioutil.Discard, r)
+				return
+			}
+		}
+	}()
+
+	err = c.Wait()
+	select {
+	case <-done:
+	case <-time.After(shellDrainTimeout):
+		// a background process still has the output open, which stops the read
+		close(stopped)
+		r.Close()
+		<-done
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), nil
+		}
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	return 0, nil
+}
+
+func (s Shell) Validate(params map[string]Parameter) error {
+	re := regexp.MustCompile("\\$\\{(.+)\\}")
+
+	requiredParams := re.FindAllStringSubmatch(s.WorkingDir, -1)
+	if !isAllInParams(requiredParams, params) {
+		return fmt.Errorf("Parameter %v missing", requiredParams)
+	}
+	for _, c := range s.Command {
+		requiredParams = re.FindAllStringSubmatch(c, -1)
+		if !isAllInParams(requiredParams, params) {
+			return fmt.Errorf("Parameter %v missing", requiredParams)
+		}
+	}
+
+	for key, val := range s.Environment {
+		requiredParams = re.FindAllStringSubmatch(key, -1)
+		if !isAllInParams(requiredParams, params) {
+			return fmt.Errorf("Parameter %v missing", requiredParams)
+		}
+		requiredParams = re.FindAllStringSubmatch(val, -1)
+		if !isAllInParams(requiredParams, params) {
+			return fmt.Errorf("Parameter %v missing", requiredParams)
+		}
+	}
+	return nil
+}
+
+func (s *Shell) SetParams(params map[string]Parameter) error {
+	for paramName, param := range params {
+		s.WorkingDir = strings.Replace(s.WorkingDir, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+
+		cmd := []string{}
+		for _, c := range s.Command {
+			correctedCmd := strings.Replace(c, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			cmd = append(cmd, correctedCmd)
+		}
+		s.Command = cmd
+
+		env := map[string]string{}
+		for key, val := range s.Environment {
+			correctedKey := strings.Replace(key, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			correctedVal := strings.Replace(val, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			env[correctedKey] = correctedVal
+		}
+
+		s.Environment = env
+	}
+	return nil
+}
+
+func (s *Shell) String() string {
+	j, _ := json.Marshal(s)
+	return string(j)
+}
@@ -0,0 +1,127 @@
+package velocity
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type bufferWriter struct {
+	lines  []string
+	status string
+}
+
+func (w *bufferWriter) Write(p []byte) (n int, err error) {
+	w.lines = append(w.lines, string(p))
+	return len(p), nil
+}
+
+func (w *bufferWriter) SetStatus(s string) {
+	w.status = s
+}
+
+func TestShellUnmarshalYamlInterface(t *testing.T) {
+	var y map[interface{}]interface{}
+	err := yaml.Unmarshal([]byte(`
+type: shell
+description: Sign release
+command: ./sign.sh "${VERSION}"
+environment:
+  TOKEN_SLOT: 1
+workingDir: scripts
+ignoreExit: true
+`), &y)
+	assert.Nil(t, err)
+
+	s := NewShell()
+	err = s.UnmarshalYamlInterface(y)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "Sign release", s.Description)
+	assert.Equal(t, []string{"./sign.sh", "${VERSION}"}, s.Command)
+	assert.Equal(t, map[string]string{"TOKEN_SLOT": "1"}, s.Environment)
+	assert.Equal(t, "scripts", s.WorkingDir)
+	assert.True(t, s.IgnoreExitCode)
+}
+
+func TestShellSetParams(t *testing.T) {
+	s := NewShell()
+	s.Command = []string{"./sign.sh", "${VERSION}"}
+	s.Environment = map[string]string{"TAG": "v${VERSION}"}
+
+	params := map[string]Parameter{
+		"VERSION": Parameter{Value: "1.2.3"},
+	}
+	assert.Nil(t, s.Validate(params))
+	assert.NotNil(t, s.Validate(map[string]Parameter{}))
+
+	s.SetParams(params)
+	assert.Equal(t, []string{"./sign.sh", "1.2.3"}, s.Command)
+	assert.Equal(t, map[string]string{"TAG": "v1.2.3"}, s.Environment)
+}
+
+func TestRunShellCommand(t *testing.T) {
+	w := &bufferWriter{}
+	c := exec.Command("sh", "-c", "echo hello s3cret; echo oops >&2; exit 3")
+	exitCode, err := runShellCommand(c, map[string]Parameter{
+		"PASSWORD": Parameter{Value: "s3cret", IsSecret: true},
+	}, w)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, exitCode)
+	assert.Equal(t, []string{"hello ***", "oops"}, w.lines)
+}
+
+func TestRunShellCommandLongLinesAndBackgroundProcesses(t *testing.T) {
+	defer func(d time.Duration) { shellDrainTimeout = d }(shellDrainTimeout)
+	shellDrainTimeout = 100 * time.Millisecond
+
+	w := &bufferWriter{}
+	c := exec.Command("sh", "-c", "head -c 100000 /dev/zero | tr '\\0' a; echo; sleep 30 &")
+	start := time.Now()
+	exitCode, err := runShellCommand(c, map[string]Parameter{}, w)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.Len(t, w.lines, 1)
+	assert.Len(t, w.lines[0], 100000)
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestShellExecuteHidesBuilderEnvironment(t *testing.T) {
+	os.Setenv("BUILDER_SECRET", "s3cret")
+	defer os.Unsetenv("BUILDER_SECRET")
+	dir, err := ioutil.TempDir("", "velocity-shell")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s := NewShell()
+	s.Command = []string{"sh", "-c", "echo \"secret=${BUILDER_SECRET} step=${STEP} path=${PATH:+set}\""}
+	s.Environment = map[string]string{"STEP": "1"}
+	e := &bufferEmitter{writer: &bufferWriter{}}
+	assert.Nil(t, s.Execute(e, &Task{Workspace: dir}))
+	assert.Contains(t, e.writer.lines, "secret= step=1 path=set")
+}
+
+func TestShellWorkingDirStaysInWorkspace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "velocity-shell")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "scripts"), os.ModePerm)
+	os.Symlink("/", filepath.Join(dir, "root"))
+
+	wd, err := shellWorkingDir(dir, "scripts")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "scripts"), wd)
+
+	for _, outside := range []string{"../..", "scripts/../../", "root"} {
+		_, err := shellWorkingDir(dir, outside)
+		assert.NotNil(t, err, outside)
+	}
+}
@@ -0,0 +1,197 @@
+package velocity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Signature policy modes
+const (
+	SignaturePolicyOff     = ""
+	SignaturePolicyWarn    = "warn"
+	SignaturePolicyRequire = "require"
+)
+
+// Signature verification statuses
+const (
+	SignatureVerified  = "verified"
+	SignatureUnsigned  = "unsigned"
+	SignatureUntrusted = "untrusted"
+	SignatureInvalid   = "invalid"
+	// SignatureUnknown is the status of commits that were synchronised before their
+	// signatures were verified
+	SignatureUnknown = ""
+)
+
+// SignaturePolicy describes which commits of a project may be built. TrustedKeys are
+// ASCII armored GPG public keys or SSH public keys in authorized_keys format.
+type SignaturePolicy struct {
+	Mode        string   `json:"mode"`
+	TrustedKeys []string `json:"trustedKeys"`
+}
+
+type SignatureVerification struct {
+	Status      string `json:"status"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (p *SignaturePolicy) Validate() error {
+	switch p.Mode {
+	case SignaturePolicyOff, SignaturePolicyWarn, SignaturePolicyRequire:
+		break
+	default:
+		return fmt.Errorf("unknown signature policy mode: %s", p.Mode)
+	}
+	if p.Mode == SignaturePolicyRequire && len(p.TrustedKeys) < 1 {
+		return fmt.Errorf("at least one trusted key is required")
+	}
+
+	_, err := TrustedKeyFingerprints(p.TrustedKeys)
+	return err
+}
+
+// Check returns an error if the policy refuses a commit with the given verification.
+func (p *SignaturePolicy) Check(v *SignatureVerification) error {
+	if p.Mode != SignaturePolicyRequire || v.Status == SignatureVerified {
+		return nil
+	}
+
+	switch v.Status {
+	case SignatureUnsigned:
+		return fmt.Errorf("commit is not signed and the project requires signed commits")
+	case SignatureInvalid:
+		return fmt.Errorf("commit has an invalid signature")
+	case SignatureUnknown:
+		return fmt.Errorf("commit signature has not been verified, synchronise the project to verify it")
+	default:
+		return fmt.Errorf("commit is not signed by a trusted key (%s)", v.Fingerprint)
+	}
+}
+
+// IsTrusted reports whether the verification is still valid for the policy's trusted keys.
+func (p *SignaturePolicy) IsTrusted(v *SignatureVerification) bool {
+	if v.Status != SignatureVerified {
+		return false
+	}
+	fingerprints, _ := TrustedKeyFingerprints(p.TrustedKeys)
+	for _, f := range fingerprints {
+		if strings.EqualFold(f, v.Fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+func isGPGKey(k string) bool {
+	return strings.HasPrefix(strings.TrimSpace(k), "-----BEGIN PGP PUBLIC KEY BLOCK-----")
+}
+
+// TrustedKeyFingerprints returns the fingerprints, as reported by git, of the given keys
+// including any GPG subkeys.
+func TrustedKeyFingerprints(keys []string) (f []string, _ error) {
+	f = []string{}
+	for _, k := range keys {
+		if isGPGKey(k) {
+			entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(k))
+			if err != nil {
+				return nil, fmt.Errorf("invalid GPG key: %s", err)
+			}
+			for _, e := range entities {
+				f = append(f, fmt.Sprintf("%X", e.PrimaryKey.Fingerprint))
+				for _, s := range e.Subkeys {
+					f = append(f, fmt.Sprintf("%X", s.PublicKey.Fingerprint))
+				}
+			}
+			continue
+		}
+
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH key: %s", err)
+		}
+		f = append(f, ssh.FingerprintSHA256(pub))
+	}
+
+	return f, nil
+}
+
+// VerifySignature checks the signature of a commit against the trusted keys. GPG keys are
+// imported into a temporary keyring and SSH keys written to a temporary allowed signers file.
+func (r *RawRepository) VerifySignature(sha string, trustedKeys []string) (*SignatureVerification, error) {
+	home, err := ioutil.TempDir("", "velocity-gnupg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(home)
+
+	allowedSigners := []string{}
+	for _, k := range trustedKeys {
+		if isGPGKey(k) {
+			c := exec.Command("gpg", "--homedir", home, "--batch", "--import")
+			c.Stdin = strings.NewReader(k)
+			if out, err := c.CombinedOutput(); err != nil {
+				GetLogger().Error("could not import gpg key", zap.String("output", string(out)), zap.Error(err))
+				return nil, fmt.Errorf("could not import GPG key: %s", err)
+			}
+			continue
+		}
+		allowedSigners = append(allowedSigners, fmt.Sprintf("* %s", strings.TrimSpace(k)))
+	}
+	allowedSignersFile := filepath.Join(home, "allowed_signers")
+	err = ioutil.WriteFile(allowedSignersFile, []byte(strings.Join(allowedSigners, "\n")+"\n"), 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	c := exec.Command("git",
+		"-C", r.Directory,
+		"-c", fmt.Sprintf("gpg.ssh.allowedSignersFile=%s", allowedSignersFile),
+		"show", "-s", "--format=%G?%n%GF%n%GP%n%GK", sha,
+	)
+	c.Env = append(os.Environ(), fmt.Sprintf("GNUPGHOME=%s", home))
+	out, err := c.Output()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(out), "\n")
+	for len(lines) < 4 {
+		lines = append(lines, "")
+	}
+
+	v := &SignatureVerification{
+		Status:      SignatureUntrusted,
+		Fingerprint: strings.TrimSpace(lines[1]),
+	}
+	if v.Fingerprint == "" {
+		// the key isn't known so only its ID is available
+		v.Fingerprint = strings.TrimSpace(lines[3])
+	}
+	switch strings.TrimSpace(lines[0]) {
+	case "N":
+		v.Status = SignatureUnsigned
+	case "B":
+		v.Status = SignatureInvalid
+	case "G", "U":
+		fingerprints, err := TrustedKeyFingerprints(trustedKeys)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fingerprints {
+			if strings.EqualFold(f, v.Fingerprint) || strings.EqualFold(f, strings.TrimSpace(lines[2])) {
+				v.Status = SignatureVerified
+				v.Fingerprint = f
+				break
+			}
+		}
+	}
+
+	return v, nil
+}
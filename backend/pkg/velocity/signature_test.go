@@ -0,0 +1,74 @@
+package velocity
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSSHSigningKey(t *testing.T, dir string, name string) (string, string) {
+	keyFile := filepath.Join(dir, name)
+	out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", keyFile).CombinedOutput()
+	assert.Nil(t, err, string(out))
+	pub, err := ioutil.ReadFile(keyFile + ".pub")
+	assert.Nil(t, err)
+
+	return keyFile, string(pub)
+}
+
+func TestVerifySignature(t *testing.T) {
+	dir := newTestRepository(t)
+	defer os.RemoveAll(dir)
+	keyDir, err := ioutil.TempDir("", "velocity-keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(keyDir)
+
+	keyFile, trustedKey := newSSHSigningKey(t, keyDir, "trusted")
+	_, otherKey := newSSHSigningKey(t, keyDir, "other")
+
+	out, err := exec.Command("git", "-C", dir,
+		"-c", "gpg.format=ssh",
+		"-c", "user.signingkey="+keyFile,
+		"commit", "--allow-empty", "-S", "-m", "signed commit",
+	).CombinedOutput()
+	assert.Nil(t, err, string(out))
+
+	repo := &RawRepository{Directory: dir}
+	signed := repo.RevParse("HEAD")
+	unsigned := repo.RevParse("HEAD~1")
+
+	v, err := repo.VerifySignature(signed, []string{trustedKey})
+	assert.Nil(t, err)
+	assert.Equal(t, SignatureVerified, v.Status)
+
+	fingerprints, err := TrustedKeyFingerprints([]string{trustedKey})
+	assert.Nil(t, err)
+	assert.Equal(t, fingerprints[0], v.Fingerprint)
+
+	policy := &SignaturePolicy{Mode: SignaturePolicyRequire, TrustedKeys: []string{trustedKey}}
+	assert.Nil(t, policy.Check(v))
+	assert.True(t, policy.IsTrusted(v))
+	assert.False(t, (&SignaturePolicy{TrustedKeys: []string{otherKey}}).IsTrusted(v))
+
+	v, err = repo.VerifySignature(signed, []string{otherKey})
+	assert.Nil(t, err)
+	assert.Equal(t, SignatureUntrusted, v.Status)
+	assert.NotNil(t, policy.Check(v))
+
+	v, err = repo.VerifySignature(unsigned, []string{trustedKey})
+	assert.Nil(t, err)
+	assert.Equal(t, SignatureUnsigned, v.Status)
+	assert.NotNil(t, policy.Check(v))
+	assert.Nil(t, (&SignaturePolicy{Mode: SignaturePolicyWarn}).Check(v))
+}
+
+func TestSignaturePolicyValidate(t *testing.T) {
+	assert.Nil(t, (&SignaturePolicy{}).Validate())
+	assert.NotNil(t, (&SignaturePolicy{Mode: "sometimes"}).Validate())
+	assert.NotNil(t, (&SignaturePolicy{Mode: SignaturePolicyRequire}).Validate())
+	assert.NotNil(t, (&SignaturePolicy{Mode: SignaturePolicyWarn, TrustedKeys: []string{"not a key"}}).Validate())
+}
@@ -20,6 +20,7 @@ const (
 	successANSI = "\x1b[1m\x1b[49m\x1b[32m"
 	errorANSI   = "\x1b[1m\x1b[49m\x1b[31m"
 	infoANSI    = "\x1b[1m\x1b[49m\x1b[34m"
+	warnANSI    = "\x1b[1m\x1b[49m\x1b[33m"
 )
 
 // Step state constants
@@ -99,6 +100,8 @@ func DetermineStepFromInterface(i map[string]interface{}) (Step, error) {
 		return NewDockerCompose(), nil
 	case "push":
 		return NewDockerPush(), nil
+	case "shell":
+		return NewShell(), nil
 		// case "plugin":
 		// 	var s Plugin
 		// 	s.UnmarshalYamlInterface(y)
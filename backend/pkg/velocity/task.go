@@ -2,8 +2,10 @@ package velocity
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v2"
 )
 
 type Task struct {
@@ -13,8 +15,15 @@ type Task struct {
 	Docker      TaskDocker        `json:"docker" yaml:"docker"`
 	Parameters  []ParameterConfig `json:"parameters" yaml:"parameters"`
 	Steps       []Step            `json:"steps" yaml:"steps"`
+	// Requires are the labels a builder needs to run the task, either "key=value" or "key"
+	Requires []string `json:"requires" yaml:"requires"`
 
 	RunID              string               `json:"-" yaml:"-"`
+	Workspace          string               `json:"-" yaml:"-"`
+	BuildID            string               `json:"-" yaml:"-"`
+	Project            string               `json:"-" yaml:"-"`
+	Branch             string               `json:"-" yaml:"-"`
+	Tag                string               `json:"-" yaml:"-"`
 	ResolvedParameters map[string]Parameter `json:"-" yaml:"-"`
 }
 
@@ -57,6 +66,35 @@ func (t *Task) String() string {
 	return string(j)
 }
 
+// HasStepType returns whether any of the task's steps are of the given type.
+func (t *Task) HasStepType(stepType string) bool {
+	for _, s := range t.Steps {
+		if s.GetType() == stepType {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTask parses a task definition from YAML. Paths referenced by its steps
+// (e.g. compose files) are resolved relative to the given project root.
+func ParseTask(b []byte, projectRoot string) (*Task, error) {
+	var t Task
+	if err := yaml.Unmarshal(b, &t); err != nil {
+		return nil, err
+	}
+
+	for _, s := range t.Steps {
+		if dC, ok := s.(*DockerCompose); ok {
+			if err := dC.parseDockerComposeFile(projectRoot); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &t, nil
+}
+
 func NewTask() Task {
 	return Task{
 		Name:        "",
@@ -82,42 +120,25 @@ func (t *Task) UnmarshalJSON(b []byte) error {
 
 	// Deserialize Parameters
 	if val, _ := objMap["parameters"]; val != nil {
-		var rawParameters []*json.RawMessage
-		err = json.Unmarshal(*val, &rawParameters)
-		if err == nil {
-			t.Parameters = []ParameterConfig{}
-			for _, rawMessage := range rawParameters {
-				var m map[string]interface{}
-				err = json.Unmarshal(*rawMessage, &m)
-				if err != nil {
-					GetLogger().Error("could not unmarshal parameters", zap.Error(err))
-					return err
-				}
-				if _, ok := m["use"]; ok { // derivedParam
-					p := DerivedParameter{}
-					err = json.Unmarshal(*rawMessage, &p)
-					if err != nil {
-						GetLogger().Error("could not unmarshal determined parameter", zap.Error(err))
-						return err
-					}
-					t.Parameters = append(t.Parameters, p)
-				} else if _, ok := m["name"]; ok { // basicParam
-					p := BasicParameter{}
-					err = json.Unmarshal(*rawMessage, &p)
-					if err != nil {
-						GetLogger().Error("could not unmarshal determined parameter", zap.Error(err))
-						return err
-					}
-					t.Parameters = append(t.Parameters, p)
-				}
-
-			}
+		var parameters ParameterConfigs
+		err = json.Unmarshal(*val, &parameters)
+		if err != nil {
+			return err
 		}
+		t.Parameters = parameters
+	}
+
+	if val, _ := objMap["git"]; val != nil {
+		json.Unmarshal(*val, &t.Git)
 	}
 
 	t.Docker = TaskDocker{}
 	json.Unmarshal(*objMap["docker"], &t.Docker)
 
+	if val, _ := objMap["requires"]; val != nil {
+		json.Unmarshal(*val, &t.Requires)
+	}
+
 	// Deserialize Steps by type
 	if val, _ := objMap["steps"]; val != nil {
 		var rawSteps []*json.RawMessage
@@ -204,6 +225,14 @@ func (t *Task) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	t.Parameters = unmarshalConfigParameters(taskMap["parameters"])
 
+	switch x := taskMap["requires"].(type) {
+	case []interface{}:
+		for _, r := range x {
+			t.Requires = append(t.Requires, fmt.Sprintf("%v", r))
+		}
+		break
+	}
+
 	t.Steps = []Step{}
 	switch x := taskMap["steps"].(type) {
 	case []interface{}:
@@ -0,0 +1,44 @@
+package velocity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestTaskUnmarshalRequires(t *testing.T) {
+	var task Task
+	err := yaml.Unmarshal([]byte(`
+description: Build arm64 images
+requires:
+  - arch=arm64
+  - docker
+`), &task)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"arch=arm64", "docker"}, task.Requires)
+
+	b, err := json.Marshal(&task)
+	assert.Nil(t, err)
+	var fromJSON Task
+	assert.Nil(t, json.Unmarshal(b, &fromJSON))
+	assert.Equal(t, task.Requires, fromJSON.Requires)
+}
+
+func TestTaskUnmarshalGit(t *testing.T) {
+	var task Task
+	err := yaml.Unmarshal([]byte(`
+description: Build with submodules
+git:
+  submodule: true
+`), &task)
+	assert.Nil(t, err)
+	assert.True(t, task.Git.Submodule)
+
+	b, err := json.Marshal(&task)
+	assert.Nil(t, err)
+	var fromJSON Task
+	assert.Nil(t, json.Unmarshal(b, &fromJSON))
+	assert.True(t, fromJSON.Git.Submodule)
+}
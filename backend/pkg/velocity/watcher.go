@@ -0,0 +1,131 @@
+package velocity
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/pkg/fileutils"
+	"go.uber.org/zap"
+)
+
+// watchIgnoreDefaults are never watched.
+var watchIgnoreDefaults = []string{".git", ".velocityci"}
+
+// notifier reports changed paths to its Watcher until it is closed.
+type notifier interface {
+	Close() error
+}
+
+// Watcher reports changes to the files of a project, ignoring those matched by the
+// project's .dockerignore and the given ignore patterns. Changes are debounced and sent
+// as a sorted list of paths relative to the project root.
+type Watcher struct {
+	Changes chan []string
+
+	root     string
+	excludes []string
+	debounce time.Duration
+	events   chan string
+	done     chan struct{}
+	notifier notifier
+}
+
+func NewWatcher(projectRoot string, ignore []string, debounce time.Duration) (*Watcher, error) {
+	root, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := readDockerignore(root)
+	if err != nil {
+		return nil, err
+	}
+	excludes = append(excludes, ignore...)
+	excludes = append(excludes, watchIgnoreDefaults...)
+
+	w := &Watcher{
+		Changes:  make(chan []string),
+		root:     root,
+		excludes: excludes,
+		debounce: debounce,
+		events:   make(chan string, 100),
+		done:     make(chan struct{}),
+	}
+	w.notifier, err = newNotifier(w)
+	if err != nil {
+		return nil, err
+	}
+	go w.debounceChanges()
+
+	return w, nil
+}
+
+// isIgnored returns whether an absolute path is outside of the project or ignored.
+func (w *Watcher) isIgnored(path string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == ".." || len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator) {
+		return true
+	}
+	if rel == "." {
+		return false
+	}
+	ignored, err := fileutils.Matches(rel, w.excludes)
+	if err != nil {
+		GetLogger().Error("invalid watch ignore pattern", zap.Error(err))
+		return false
+	}
+	return ignored
+}
+
+// changed is called by the notifier with the absolute path of each changed file.
+func (w *Watcher) changed(path string) {
+	if w.isIgnored(path) {
+		return
+	}
+	rel, _ := filepath.Rel(w.root, path)
+	select {
+	case w.events <- rel:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) debounceChanges() {
+	pending := map[string]bool{}
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	ready := false
+	for {
+		var changes chan []string
+		if ready {
+			changes = w.Changes
+		}
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case path := <-w.events:
+			pending[path] = true
+			ready = false
+			timer.Reset(w.debounce)
+		case <-timer.C:
+			ready = len(pending) > 0
+		case changes <- sortedPaths(pending):
+			pending = map[string]bool{}
+			ready = false
+		}
+	}
+}
+
+func sortedPaths(paths map[string]bool) []string {
+	sorted := []string{}
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.notifier.Close()
+}
@@ -0,0 +1,146 @@
+package velocity
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// inotifyPollTimeout is how often (ms) the inotify loop checks whether it has been closed.
+const inotifyPollTimeout = 250
+
+type inotify struct {
+	fd      int
+	watcher *Watcher
+	lock    sync.Mutex
+	watches map[int]string
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newNotifier(w *Watcher) (notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	n := &inotify{
+		fd:      fd,
+		watcher: w,
+		watches: map[int]string{},
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if err := n.addTree(w.root, false); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	go n.read()
+
+	return n, nil
+}
+
+// addTree watches a directory and the directories below it that aren't ignored. For a new
+// directory, report is set so that files created before it was watched aren't missed.
+func (n *inotify) addTree(dir string, report bool) error {
+	return filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			// e.g. removed while walking
+			return nil
+		}
+		if !f.IsDir() {
+			if report {
+				n.watcher.changed(path)
+			}
+			return nil
+		}
+		if path != dir && n.watcher.isIgnored(path) {
+			return filepath.SkipDir
+		}
+		wd, err := unix.InotifyAddWatch(n.fd, path, inotifyMask)
+		if err != nil {
+			return err
+		}
+		n.lock.Lock()
+		n.watches[wd] = path
+		n.lock.Unlock()
+		return nil
+	})
+}
+
+func (n *inotify) read() {
+	defer close(n.stopped)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	fds := []unix.PollFd{{Fd: int32(n.fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-n.done:
+			return
+		default:
+		}
+
+		ready, err := unix.Poll(fds, inotifyPollTimeout)
+		if err == unix.EINTR || ready == 0 {
+			continue
+		}
+		if err != nil {
+			GetLogger().Error("could not poll inotify", zap.Error(err))
+			return
+		}
+
+		l, err := unix.Read(n.fd, buf)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			GetLogger().Error("could not read inotify events", zap.Error(err))
+			return
+		}
+		n.handleEvents(buf[:l])
+	}
+}
+
+func (n *inotify) handleEvents(buf []byte) {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(ev.Len)]
+		offset += unix.SizeofInotifyEvent + int(ev.Len)
+
+		n.lock.Lock()
+		dir, ok := n.watches[int(ev.Wd)]
+		if ev.Mask&unix.IN_IGNORED != 0 {
+			delete(n.watches, int(ev.Wd))
+		}
+		n.lock.Unlock()
+		if !ok {
+			continue
+		}
+
+		name := string(nameBytes)
+		for len(name) > 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+
+		if ev.Mask&unix.IN_ISDIR != 0 && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 && !n.watcher.isIgnored(path) {
+			if err := n.addTree(path, true); err != nil {
+				GetLogger().Error("could not watch directory", zap.String("path", path), zap.Error(err))
+			}
+		}
+		n.watcher.changed(path)
+	}
+}
+
+func (n *inotify) Close() error {
+	close(n.done)
+	<-n.stopped
+	return unix.Close(n.fd)
+}
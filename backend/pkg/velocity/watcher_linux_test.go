@@ -0,0 +1,53 @@
+package velocity
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherSendsChangedFilesNotIgnored(t *testing.T) {
+	root, err := ioutil.TempDir("", "velocity-watcher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	writeProjectFiles(t, root, map[string]string{
+		".dockerignore":   "node_modules\n*.log\n",
+		"src/main.go":     "package main\n",
+		"build/output":    "old\n",
+		"node_modules/.x": "\n",
+	})
+
+	w, err := NewWatcher(root, []string{"build"}, 50*time.Millisecond)
+	assert.Nil(t, err)
+	defer w.Close()
+
+	writeProjectFiles(t, root, map[string]string{
+		"src/main.go":       "package main\n\nfunc main() {}\n",
+		"src/pkg/new.go":    "package pkg\n",
+		"build/output":      "new\n",
+		"debug.log":         "ignored\n",
+		"node_modules/a.js": "ignored\n",
+		".git/HEAD":         "ignored\n",
+	})
+
+	// a new directory is watched once it has been created, so its files may arrive in a
+	// later batch of changes
+	changed := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for !changed["src/main.go"] || !changed["src/pkg/new.go"] {
+		select {
+		case changes := <-w.Changes:
+			for _, c := range changes {
+				changed[c] = true
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for changes, got %v", sortedPaths(changed))
+		}
+	}
+
+	assert.Equal(t, []string{"src/main.go", "src/pkg", "src/pkg/new.go"}, sortedPaths(changed))
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package velocity
+
+import "fmt"
+
+func newNotifier(w *Watcher) (notifier, error) {
+	return nil, fmt.Errorf("watching files is only supported on Linux")
+}
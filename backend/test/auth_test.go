@@ -81,7 +81,7 @@ func iAmAuthenticated() error {
 }
 
 func theFollowingUsersExist(userTable *gherkin.DataTable) error {
-	uM := user.NewManager(app.DB, valid, trans)
+	uM := user.NewManager(user.NewStormRepository(app.DB), valid, trans)
 
 	for _, r := range userTable.Rows[1:] {
 		_, err := uM.Create(r.Cells[0].Value, r.Cells[1].Value)
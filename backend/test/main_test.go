@@ -84,6 +84,10 @@ func FeatureContext(s *godog.Suite) {
 		valid, trans = domain.NewValidator()
 		app = architect.New()
 		app.DB = domain.NewStormDB("test.db")
+		app.LogsPath = "test-logs"
+		if os.Getenv("ENCRYPTION_KEY") == "" {
+			os.Setenv("ENCRYPTION_KEY", "test")
+		}
 		testServer = httptest.NewUnstartedServer(app.Server.Server.Handler)
 		app.Init()
 		testServer.Config = app.Server.Server
@@ -157,5 +161,6 @@ func FeatureContext(s *godog.Suite) {
 		testServer.Close()
 		app.DB.Close()
 		os.Remove("test.db")
+		os.RemoveAll("test-logs")
 	})
 }